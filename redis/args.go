@@ -0,0 +1,95 @@
+package redis
+
+import (
+	"reflect"
+	"strings"
+)
+
+// Args is a helper for building argument list of variadic commands.
+//
+//	args := redis.Args{}.Add(key).AddFlat(fields)
+//	sender.Send(redis.Req("HSET", args...), cb, 0)
+type Args []interface{}
+
+// Add appends values to argument list.
+func (a Args) Add(values ...interface{}) Args {
+	return append(a, values...)
+}
+
+// AddFlat appends value to argument list, flattening it:
+// - slice and array elements are appended one by one (except []byte, which is single argument),
+// - map is appended as key and value pairs (in unspecified order),
+// - struct (or pointer to struct) is appended as field name and value pairs.
+// Any other value is appended as is.
+//
+// Struct field name could be overridden with tag `redis:"name"`.
+// Field tagged with `redis:"-"` is skipped, and field tagged with `redis:"name,omitempty"`
+// is skipped when it has zero value. Embedded structs are flattened as well.
+func (a Args) AddFlat(v interface{}) Args {
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Slice:
+		if rv.IsNil() {
+			return a
+		}
+		if rv.Type().Elem().Kind() == reflect.Uint8 {
+			return append(a, v)
+		}
+		fallthrough
+	case reflect.Array:
+		for i := 0; i < rv.Len(); i++ {
+			a = append(a, rv.Index(i).Interface())
+		}
+		return a
+	case reflect.Map:
+		iter := rv.MapRange()
+		for iter.Next() {
+			a = append(a, iter.Key().Interface(), iter.Value().Interface())
+		}
+		return a
+	case reflect.Ptr:
+		if !rv.IsNil() && rv.Elem().Kind() == reflect.Struct {
+			return a.addStruct(rv.Elem())
+		}
+	case reflect.Struct:
+		return a.addStruct(rv)
+	}
+	return append(a, v)
+}
+
+func (a Args) addStruct(rv reflect.Value) Args {
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		f := rt.Field(i)
+		fv := rv.Field(i)
+		if f.Anonymous && f.Type.Kind() == reflect.Struct && f.Tag.Get("redis") == "" {
+			a = a.addStruct(fv)
+			continue
+		}
+		if f.PkgPath != "" {
+			// unexported field
+			continue
+		}
+		name := f.Name
+		omitEmpty := false
+		if tag := f.Tag.Get("redis"); tag != "" {
+			if tag == "-" {
+				continue
+			}
+			parts := strings.Split(tag, ",")
+			if parts[0] != "" {
+				name = parts[0]
+			}
+			for _, opt := range parts[1:] {
+				if opt == "omitempty" {
+					omitEmpty = true
+				}
+			}
+		}
+		if omitEmpty && fv.IsZero() {
+			continue
+		}
+		a = append(a, name, fv.Interface())
+	}
+	return a
+}
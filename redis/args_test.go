@@ -0,0 +1,39 @@
+package redis_test
+
+import (
+	"testing"
+
+	. "github.com/joomcode/redispipe/redis"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestArgs(t *testing.T) {
+	args := Args{}.Add("key", 1).AddFlat([]string{"a", "b"}).AddFlat([]byte("bytes")).AddFlat(2)
+	assert.Equal(t, Args{"key", 1, "a", "b", []byte("bytes"), 2}, args)
+
+	args = Args{}.AddFlat([2]int{1, 2}).AddFlat([]int(nil))
+	assert.Equal(t, Args{1, 2}, args)
+
+	args = Args{}.AddFlat(map[string]int{"f": 1})
+	assert.Equal(t, Args{"f", 1}, args)
+
+	type Base struct {
+		ID int `redis:"id"`
+	}
+	type Obj struct {
+		Base
+		Name    string
+		Title   string `redis:"title,omitempty"`
+		Skip    string `redis:"-"`
+		private int
+	}
+	obj := Obj{Base: Base{ID: 5}, Name: "n", Skip: "s"}
+	expect := Args{"id", 5, "Name", "n"}
+	assert.Equal(t, expect, Args{}.AddFlat(obj))
+	assert.Equal(t, expect, Args{}.AddFlat(&obj))
+
+	obj.Title = "t"
+	args = Args{}.Add("HSET", "key").AddFlat(obj)
+	assert.Equal(t, Args{"HSET", "key", "id", 5, "Name", "n", "title", "t"}, args)
+	assert.NoError(t, CheckRequest(Req("HSET", args[1:]...), false))
+}
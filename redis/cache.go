@@ -0,0 +1,169 @@
+package redis
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// Cache is a small in-process cache of request results over a Sender.
+// It is intended for hot read-only requests with tolerable staleness (like GET of configuration keys).
+//
+// Entries are invalidated by time only: Cache doesn't track writes to redis.
+// Cache is bounded: when Size entries are stored, least recently used entry is evicted.
+// Errors are never cached.
+//
+// Cached values are shared between callers, so they should not be modified.
+type Cache struct {
+	s    Sender
+	ttl  time.Duration
+	size int
+
+	m     sync.Mutex
+	lru   list.List
+	items map[string]*list.Element
+}
+
+type cacheEntry struct {
+	key     string
+	res     interface{}
+	expires time.Time
+}
+
+// NewCache returns Cache over sender s, which stores at most size entries for ttl each.
+// Non-positive ttl disables caching: every Get sends request, and nothing is stored.
+func NewCache(s Sender, size int, ttl time.Duration) *Cache {
+	if size <= 0 {
+		size = 1
+	}
+	return &Cache{
+		s:     s,
+		ttl:   ttl,
+		size:  size,
+		items: make(map[string]*list.Element, size),
+	}
+}
+
+// Get returns cached result for request, or sends request synchronously and caches its result.
+// Returns value that could be either result or error.
+func (c *Cache) Get(req Request) interface{} {
+	key, err := cacheKey(req)
+	if err != nil {
+		return err
+	}
+	if res, ok := c.lookup(key); ok {
+		return res
+	}
+	res := Sync{c.s}.Send(req)
+	if AsError(res) == nil {
+		c.store(key, res)
+	}
+	return res
+}
+
+// GetMeta is like Get, but also reports response provenance.
+// Meta.Source is SourceCache if result were taken from cache.
+func (c *Cache) GetMeta(req Request) (interface{}, ResponseMeta) {
+	key, err := cacheKey(req)
+	if err != nil {
+		return err, ResponseMeta{}
+	}
+	if res, ok := c.lookup(key); ok {
+		return res, ResponseMeta{Source: SourceCache}
+	}
+	var res syncRes
+	var meta ResponseMeta
+	res.Add(1)
+	SendWithMeta(c.s, req, FuncFutureMeta(func(r interface{}, _ uint64, m ResponseMeta) {
+		meta = m
+		res.Resolve(r, 0)
+	}), 0)
+	res.Wait()
+	if AsError(res.r) == nil {
+		c.store(key, res.r)
+	}
+	return res.r, meta
+}
+
+// Do is convenient method to construct request and call Get.
+func (c *Cache) Do(cmd string, args ...interface{}) interface{} {
+	return c.Get(Request{cmd, args})
+}
+
+// Invalidate removes cached result for request.
+func (c *Cache) Invalidate(req Request) {
+	key, err := cacheKey(req)
+	if err != nil {
+		return
+	}
+	c.m.Lock()
+	if el, ok := c.items[key]; ok {
+		c.remove(el)
+	}
+	c.m.Unlock()
+}
+
+// Purge removes all cached results.
+func (c *Cache) Purge() {
+	c.m.Lock()
+	c.lru.Init()
+	c.items = make(map[string]*list.Element, c.size)
+	c.m.Unlock()
+}
+
+// Len returns number of stored entries (including expired but not yet evicted).
+func (c *Cache) Len() int {
+	c.m.Lock()
+	defer c.m.Unlock()
+	return c.lru.Len()
+}
+
+func (c *Cache) lookup(key string) (interface{}, bool) {
+	c.m.Lock()
+	defer c.m.Unlock()
+	el, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	entry := el.Value.(*cacheEntry)
+	if time.Now().After(entry.expires) {
+		c.remove(el)
+		return nil, false
+	}
+	c.lru.MoveToFront(el)
+	return entry.res, true
+}
+
+func (c *Cache) store(key string, res interface{}) {
+	if c.ttl <= 0 {
+		return
+	}
+	expires := time.Now().Add(c.ttl)
+	c.m.Lock()
+	defer c.m.Unlock()
+	if el, ok := c.items[key]; ok {
+		entry := el.Value.(*cacheEntry)
+		entry.res = res
+		entry.expires = expires
+		c.lru.MoveToFront(el)
+		return
+	}
+	for c.lru.Len() >= c.size {
+		c.remove(c.lru.Back())
+	}
+	c.items[key] = c.lru.PushFront(&cacheEntry{key: key, res: res, expires: expires})
+}
+
+func (c *Cache) remove(el *list.Element) {
+	c.lru.Remove(el)
+	delete(c.items, el.Value.(*cacheEntry).key)
+}
+
+// cacheKey uses serialized request as a key, so it distinguishes both command and arguments.
+func cacheKey(req Request) (string, error) {
+	buf, err := AppendRequest(nil, req)
+	if err != nil {
+		return "", err
+	}
+	return string(buf), nil
+}
@@ -0,0 +1,122 @@
+package redis_test
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	. "github.com/joomcode/redispipe/redis"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeSender resolves requests synchronously with handler's result and records sent requests.
+type fakeSender struct {
+	m       sync.Mutex
+	reqs    []Request
+	handler func(Request) interface{}
+}
+
+func (s *fakeSender) Send(r Request, cb Future, n uint64) {
+	s.m.Lock()
+	s.reqs = append(s.reqs, r)
+	s.m.Unlock()
+	cb.Resolve(s.handler(r), n)
+}
+
+func (s *fakeSender) SendMany(reqs []Request, cb Future, n uint64) {
+	for i, r := range reqs {
+		s.Send(r, cb, n+uint64(i))
+	}
+}
+
+func (s *fakeSender) SendTransaction(reqs []Request, cb Future, n uint64) {
+	res := make([]interface{}, len(reqs))
+	for i, r := range reqs {
+		s.m.Lock()
+		s.reqs = append(s.reqs, r)
+		s.m.Unlock()
+		res[i] = s.handler(r)
+	}
+	cb.Resolve(res, n)
+}
+
+func (s *fakeSender) Scanner(opts ScanOpts) Scanner { return nil }
+
+func (s *fakeSender) EachShard(cb func(Sender, error) bool) { cb(s, nil) }
+
+func (s *fakeSender) Close() {}
+
+func (s *fakeSender) sent() []Request {
+	s.m.Lock()
+	defer s.m.Unlock()
+	return append([]Request(nil), s.reqs...)
+}
+
+func TestCache(t *testing.T) {
+	calls := 0
+	s := &fakeSender{handler: func(r Request) interface{} {
+		calls++
+		if r.Args[0] == "bad" {
+			return ErrResult.New("ERR bad")
+		}
+		return []byte(r.Args[0].(string))
+	}}
+	c := NewCache(s, 2, 20*time.Millisecond)
+
+	assert.Equal(t, []byte("a"), c.Do("GET", "a"))
+	assert.Equal(t, []byte("a"), c.Do("GET", "a"))
+	assert.Equal(t, 1, calls)
+
+	// different command is different entry
+	c.Do("STRLEN", "a")
+	assert.Equal(t, 2, calls)
+
+	// errors are not cached
+	assert.Error(t, AsError(c.Do("GET", "bad")))
+	assert.Error(t, AsError(c.Do("GET", "bad")))
+	assert.Equal(t, 4, calls)
+
+	// least recently used is evicted
+	c.Do("GET", "a")
+	c.Do("GET", "b")
+	assert.Equal(t, 5, calls)
+	assert.Equal(t, 2, c.Len())
+	c.Do("GET", "a")
+	assert.Equal(t, 5, calls)
+	c.Do("STRLEN", "a")
+	assert.Equal(t, 6, calls)
+
+	// ttl expiration
+	time.Sleep(30 * time.Millisecond)
+	c.Do("GET", "a")
+	assert.Equal(t, 7, calls)
+
+	c.Invalidate(Req("GET", "a"))
+	c.Do("GET", "a")
+	assert.Equal(t, 8, calls)
+
+	c.Purge()
+	assert.Equal(t, 0, c.Len())
+}
+
+func TestCacheDisabled(t *testing.T) {
+	s := &fakeSender{handler: func(r Request) interface{} { return "OK" }}
+	c := NewCache(s, 10, -time.Second)
+	assert.Equal(t, "OK", c.Do("GET", "a"))
+	assert.Equal(t, "OK", c.Do("GET", "a"))
+	assert.Equal(t, 2, len(s.sent()))
+}
+
+func TestCacheGetMeta(t *testing.T) {
+	s := &fakeSender{handler: func(r Request) interface{} { return "OK" }}
+	c := NewCache(s, 10, time.Minute)
+
+	res, meta := c.GetMeta(Req("GET", "a"))
+	assert.Equal(t, "OK", res)
+	assert.Equal(t, SourceUnknown, meta.Source)
+
+	res, meta = c.GetMeta(Req("GET", "a"))
+	assert.Equal(t, "OK", res)
+	assert.Equal(t, SourceCache, meta.Source)
+	assert.Len(t, s.sent(), 1)
+}
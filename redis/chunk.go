@@ -0,0 +1,88 @@
+package redis
+
+// DefaultChunkSize is a number of items sent in one command by chunked helpers if chunk is not positive.
+const DefaultChunkSize = 1000
+
+// ChunkRequests splits args of variadic command cmd into requests of at most chunk items each.
+// Item is step consecutive arguments (step is 2 for key-value pairs of MSET), and prefix is prepended
+// to arguments of every request (e.g. key of SADD).
+// If chunk is not positive, DefaultChunkSize is used.
+func ChunkRequests(cmd string, prefix []interface{}, args []interface{}, step, chunk int) []Request {
+	if chunk <= 0 {
+		chunk = DefaultChunkSize
+	}
+	if step < 1 {
+		step = 1
+	}
+	size := chunk * step
+	reqs := make([]Request, 0, (len(args)+size-1)/size)
+	for len(args) > 0 {
+		n := size
+		if n > len(args) {
+			n = len(args)
+		}
+		reqArgs := make([]interface{}, 0, len(prefix)+n)
+		reqArgs = append(reqArgs, prefix...)
+		reqArgs = append(reqArgs, args[:n]...)
+		reqs = append(reqs, Request{cmd, reqArgs})
+		args = args[n:]
+	}
+	return reqs
+}
+
+// DelChunked removes keys with pipelined DEL commands of at most chunk keys each,
+// and returns total number of removed keys. It avoids giant commands which stall server.
+// If some chunk failed, number of keys removed by other chunks is returned together with first error.
+// Keys should belong to the same slot in cluster; use rediscluster.Cluster.Del for arbitrary keys.
+func DelChunked(s Sender, chunk int, keys ...string) (int64, error) {
+	args := make([]interface{}, len(keys))
+	for i, key := range keys {
+		args[i] = key
+	}
+	return sumChunks(s, ChunkRequests("DEL", nil, args, 1, chunk))
+}
+
+// SAddChunked adds members to set at key with pipelined SADD commands of at most chunk members each,
+// and returns total number of added members.
+// If some chunk failed, number of members added by other chunks is returned together with first error.
+func SAddChunked(s Sender, chunk int, key string, members ...interface{}) (int64, error) {
+	return sumChunks(s, ChunkRequests("SADD", []interface{}{key}, members, 1, chunk))
+}
+
+// MSetChunked sets key-value pairs with pipelined MSET commands of at most chunk pairs each.
+// Note that unlike single MSET, pairs are not set atomically: if some chunk failed, other ones
+// could be applied.
+// Keys should belong to the same slot in cluster; use rediscluster.Cluster.MSet for arbitrary keys.
+func MSetChunked(s Sender, chunk int, pairs ...interface{}) error {
+	if len(pairs)%2 != 0 {
+		return ErrArgumentCount.New("wrong number of arguments for MSET")
+	}
+	ress := Sync{s}.SendMany(ChunkRequests("MSET", nil, pairs, 2, chunk))
+	for _, res := range ress {
+		if err := AsError(res); err != nil {
+			return err
+		}
+		if res != "OK" {
+			return unexpectedResponse(res)
+		}
+	}
+	return nil
+}
+
+// sumChunks pipelines reqs and sums their integer replies.
+func sumChunks(s Sender, reqs []Request) (int64, error) {
+	var sum int64
+	var err error
+	ress := Sync{s}.SendMany(reqs)
+	for _, res := range ress {
+		n, e := responseInt(res)
+		if e != nil {
+			if err == nil {
+				err = e
+			}
+			continue
+		}
+		sum += n
+	}
+	return sum, err
+}
@@ -0,0 +1,67 @@
+package redis_test
+
+import (
+	"testing"
+
+	. "github.com/joomcode/redispipe/redis"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChunkRequests(t *testing.T) {
+	reqs := ChunkRequests("SADD", []interface{}{"set"}, []interface{}{1, 2, 3, 4, 5}, 1, 2)
+	assert.Equal(t, []Request{
+		Req("SADD", "set", 1, 2),
+		Req("SADD", "set", 3, 4),
+		Req("SADD", "set", 5),
+	}, reqs)
+
+	reqs = ChunkRequests("MSET", nil, []interface{}{"a", 1, "b", 2, "c", 3}, 2, 2)
+	assert.Equal(t, []Request{
+		Req("MSET", "a", 1, "b", 2),
+		Req("MSET", "c", 3),
+	}, reqs)
+
+	reqs = ChunkRequests("DEL", nil, make([]interface{}, DefaultChunkSize+1), 1, 0)
+	assert.Len(t, reqs, 2)
+	assert.Empty(t, ChunkRequests("DEL", nil, nil, 1, 10))
+}
+
+func TestDelChunked(t *testing.T) {
+	s := &fakeSender{handler: func(r Request) interface{} { return int64(len(r.Args)) }}
+	n, err := DelChunked(s, 3, "a", "b", "c", "d", "e", "f", "g")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(7), n)
+	assert.Len(t, s.sent(), 3)
+
+	s = &fakeSender{handler: func(r Request) interface{} {
+		if r.Args[0] == "d" {
+			return ErrResult.New("ERR failed")
+		}
+		return int64(len(r.Args))
+	}}
+	n, err = DelChunked(s, 3, "a", "b", "c", "d", "e", "f", "g")
+	assert.Error(t, err)
+	assert.Equal(t, int64(4), n)
+}
+
+func TestSAddChunked(t *testing.T) {
+	s := &fakeSender{handler: func(r Request) interface{} { return int64(len(r.Args) - 1) }}
+	n, err := SAddChunked(s, 2, "set", "a", "b", "c")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), n)
+	for _, r := range s.sent() {
+		assert.Equal(t, "set", r.Args[0])
+	}
+}
+
+func TestMSetChunked(t *testing.T) {
+	s := &fakeSender{handler: func(r Request) interface{} { return "OK" }}
+	assert.NoError(t, MSetChunked(s, 1, "a", 1, "b", 2))
+	assert.Equal(t, []Request{Req("MSET", "a", 1), Req("MSET", "b", 2)}, s.sent())
+
+	err := MSetChunked(s, 1, "a", 1, "b")
+	assert.True(t, IsOfType(err, ErrArgumentCount))
+
+	s.handler = func(r Request) interface{} { return ErrResult.New("ERR failed") }
+	assert.Error(t, MSetChunked(s, 1, "a", 1))
+}
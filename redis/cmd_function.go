@@ -0,0 +1,39 @@
+package redis
+
+// keysAndArgs builds arguments of EVAL-like commands: number of keys, keys, and then arguments.
+func keysAndArgs(head string, keys []string, args []interface{}) []interface{} {
+	res := make([]interface{}, 0, 2+len(keys)+len(args))
+	res = append(res, head, len(keys))
+	for _, key := range keys {
+		res = append(res, key)
+	}
+	return append(res, args...)
+}
+
+// LoadFunctions loads library of functions (FUNCTION LOAD REPLACE, redis >= 7.0).
+// For cluster, library is loaded on every shard (master), since functions are not propagated between shards.
+func LoadFunctions(s Sender, code string) error {
+	var err error
+	s.EachShard(func(shard Sender, e error) bool {
+		if e != nil {
+			err = e
+			return false
+		}
+		_, err = responseString(Sync{shard}.Do("FUNCTION", "LOAD", "REPLACE", code))
+		return err == nil
+	})
+	return err
+}
+
+// FCall calls function (FCALL, redis >= 7.0).
+func FCall(s Sender, name string, keys []string, args []interface{}) (interface{}, error) {
+	res := Sync{s}.Send(Request{"FCALL", keysAndArgs(name, keys, args)})
+	return res, AsError(res)
+}
+
+// FCallRO calls read-only function (FCALL_RO, redis >= 7.0).
+// It is considered replica safe, so cluster could send it to replica with suitable policy.
+func FCallRO(s Sender, name string, keys []string, args []interface{}) (interface{}, error) {
+	res := Sync{s}.Send(Request{"FCALL_RO", keysAndArgs(name, keys, args)})
+	return res, AsError(res)
+}
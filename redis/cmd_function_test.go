@@ -0,0 +1,36 @@
+package redis_test
+
+import (
+	"testing"
+
+	. "github.com/joomcode/redispipe/redis"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFunctions(t *testing.T) {
+	s := &fakeSender{handler: func(r Request) interface{} {
+		switch r.Cmd {
+		case "FUNCTION":
+			return []byte("mylib")
+		case "FCALL_RO":
+			return r.Args[2]
+		}
+		return ErrResult.New("ERR Function not found")
+	}}
+
+	assert.NoError(t, LoadFunctions(s, "#!lua name=mylib\n..."))
+	assert.Equal(t, Request{"FUNCTION", []interface{}{"LOAD", "REPLACE", "#!lua name=mylib\n..."}}, s.sent()[0])
+
+	res, err := FCallRO(s, "getkey", []string{"key"}, []interface{}{1})
+	assert.NoError(t, err)
+	assert.Equal(t, "key", res)
+	assert.Equal(t, Request{"FCALL_RO", []interface{}{"getkey", 1, "key", 1}}, s.sent()[1])
+
+	_, err = FCall(s, "missing", nil, nil)
+	assert.True(t, IsOfType(err, ErrResult))
+	assert.Equal(t, Request{"FCALL", []interface{}{"missing", 0}}, s.sent()[2])
+	// function without keys could be sent to any cluster node
+	key, ok := s.sent()[2].Key()
+	assert.False(t, ok)
+	assert.Equal(t, "RANDOMKEY", key)
+}
@@ -0,0 +1,176 @@
+package redis
+
+import "time"
+
+// HashField is a field of hash with its value.
+type HashField struct {
+	Field string
+	Value string
+}
+
+// HRandField returns random fields of hash (HRANDFIELD, redis >= 6.2).
+// If count is positive, up to count distinct fields are returned. If count is negative, exactly -count
+// fields are returned, and the same field could be returned several times.
+// Value of returned fields is filled only if withValues is true.
+// Empty result is returned if count is 0 or key doesn't exist.
+func HRandField(s Sender, key string, count int, withValues bool) ([]HashField, error) {
+	args := []interface{}{key, count}
+	if withValues {
+		args = append(args, "WITHVALUES")
+	}
+	return HashFieldsResponse(Sync{s}.Send(Request{"HRANDFIELD", args}), withValues)
+}
+
+// HashFieldsResponse parses list of hash fields, interleaved with values if withValues is true
+// (as returned by HGETALL and HRANDFIELD). With RESP3 HRANDFIELD returns field and value pairs
+// as two-element arrays, and they are accepted as well.
+func HashFieldsResponse(res interface{}, withValues bool) ([]HashField, error) {
+	if !withValues {
+		strs, err := responseStrings(res)
+		if err != nil {
+			return nil, err
+		}
+		fields := make([]HashField, len(strs))
+		for i, f := range strs {
+			fields[i].Field = f
+		}
+		return fields, nil
+	}
+	strs, err := responsePairs(res)
+	if err != nil {
+		return nil, err
+	}
+	fields := make([]HashField, len(strs)/2)
+	for i := range fields {
+		fields[i] = HashField{strs[2*i], strs[2*i+1]}
+	}
+	return fields, nil
+}
+
+// HGetAll returns all fields and values of hash (HGETALL).
+// Empty non-nil map is returned if key doesn't exist.
+func HGetAll(s Sender, key string) (map[string]string, error) {
+	return HashMapResponse(Sync{s}.Do("HGETALL", key))
+}
+
+// HGetAllBytes is HGetAll for binary values. Values are not copied from response.
+func HGetAllBytes(s Sender, key string) (map[string][]byte, error) {
+	return HashBytesMapResponse(Sync{s}.Do("HGETALL", key))
+}
+
+// HashMapResponse parses list of hash fields interleaved with values (as returned by HGETALL) into map.
+func HashMapResponse(res interface{}) (map[string]string, error) {
+	arr, err := responseArray(res)
+	if err != nil {
+		return nil, err
+	}
+	if len(arr)%2 != 0 {
+		return nil, unexpectedResponse(res)
+	}
+	m := make(map[string]string, len(arr)/2)
+	for i := 0; i < len(arr); i += 2 {
+		field, ferr := responseString(arr[i])
+		val, verr := responseString(arr[i+1])
+		if ferr != nil || verr != nil {
+			return nil, unexpectedResponse(res)
+		}
+		m[field] = val
+	}
+	return m, nil
+}
+
+// HashBytesMapResponse is HashMapResponse for binary values.
+func HashBytesMapResponse(res interface{}) (map[string][]byte, error) {
+	arr, err := responseArray(res)
+	if err != nil {
+		return nil, err
+	}
+	if len(arr)%2 != 0 {
+		return nil, unexpectedResponse(res)
+	}
+	m := make(map[string][]byte, len(arr)/2)
+	for i := 0; i < len(arr); i += 2 {
+		field, ferr := responseString(arr[i])
+		val, verr := responseBytes(arr[i+1])
+		if ferr != nil || verr != nil {
+			return nil, unexpectedResponse(res)
+		}
+		m[field] = val
+	}
+	return m, nil
+}
+
+// Status codes of hash field expiration commands (HEXPIRE, HPERSIST).
+const (
+	// HFieldMissing - field (or key) doesn't exist.
+	HFieldMissing int64 = -2
+	// HFieldNoTTL - field has no expiration (HPERSIST).
+	HFieldNoTTL int64 = -1
+	// HFieldNotSet - expiration is not set because condition is not met (HEXPIRE).
+	HFieldNotSet int64 = 0
+	// HFieldSet - expiration is set (HEXPIRE), or removed (HPERSIST).
+	HFieldSet int64 = 1
+	// HFieldDeleted - field is deleted, since ttl is zero (HEXPIRE).
+	HFieldDeleted int64 = 2
+)
+
+// HExpire sets time to live of hash fields (HEXPIRE if ttl is whole seconds, HPEXPIRE otherwise; redis >= 7.4).
+// Status of every field is returned in order of fields: HFieldSet, HFieldNotSet, HFieldDeleted
+// or HFieldMissing.
+func HExpire(s Sender, key string, ttl time.Duration, cond ExpireCondition, fields ...string) ([]int64, error) {
+	cmdArgs := durationArgs(ttl, "HEXPIRE", "HPEXPIRE")
+	args := []interface{}{key, cmdArgs[1]}
+	if cond != "" {
+		args = append(args, string(cond))
+	}
+	return hashFieldsInts(s, cmdArgs[0].(string), args, fields)
+}
+
+// HPersist removes time to live of hash fields (HPERSIST, redis >= 7.4).
+// Status of every field is returned in order of fields: HFieldSet, HFieldNoTTL or HFieldMissing.
+func HPersist(s Sender, key string, fields ...string) ([]int64, error) {
+	return hashFieldsInts(s, "HPERSIST", []interface{}{key}, fields)
+}
+
+// HTTL returns time to live of hash fields in order of fields (HPTTL, redis >= 7.4).
+// Field without expiration has time to live time.Duration(HFieldNoTTL), and missing field
+// time.Duration(HFieldMissing).
+func HTTL(s Sender, key string, fields ...string) ([]time.Duration, error) {
+	ns, err := hashFieldsInts(s, "HPTTL", []interface{}{key}, fields)
+	if err != nil {
+		return nil, err
+	}
+	ttls := make([]time.Duration, len(ns))
+	for i, n := range ns {
+		if n < 0 {
+			ttls[i] = time.Duration(n)
+		} else {
+			ttls[i] = time.Duration(n) * time.Millisecond
+		}
+	}
+	return ttls, nil
+}
+
+// hashFieldsInts sends cmd with args followed by FIELDS numfields field..., and parses per-field
+// integer replies.
+func hashFieldsInts(s Sender, cmd string, args []interface{}, fields []string) ([]int64, error) {
+	args = append(args, "FIELDS", len(fields))
+	for _, f := range fields {
+		args = append(args, f)
+	}
+	res := Sync{s}.Send(Request{cmd, args})
+	arr, err := responseArray(res)
+	if err != nil {
+		return nil, err
+	}
+	if len(arr) != len(fields) {
+		return nil, unexpectedResponse(res)
+	}
+	ns := make([]int64, len(arr))
+	for i, v := range arr {
+		if ns[i], err = responseInt(v); err != nil {
+			return nil, unexpectedResponse(res)
+		}
+	}
+	return ns, nil
+}
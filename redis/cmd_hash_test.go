@@ -0,0 +1,155 @@
+package redis_test
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/joomcode/redispipe/redis"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHRandField(t *testing.T) {
+	s := &fakeSender{handler: func(r Request) interface{} {
+		count := r.Args[1].(int)
+		withValues := len(r.Args) == 3
+		switch {
+		case count == 0:
+			return []interface{}{}
+		case count < 0 && withValues:
+			return []interface{}{[]byte("a"), []byte("1"), []byte("a"), []byte("1")}
+		case withValues:
+			return []interface{}{[]byte("a"), []byte("1"), []byte("b"), []byte("2")}
+		}
+		return []interface{}{[]byte("a"), []byte("b")}
+	}}
+
+	fields, err := HRandField(s, "h", 0, false)
+	assert.NoError(t, err)
+	assert.Empty(t, fields)
+
+	fields, err = HRandField(s, "h", 2, false)
+	assert.NoError(t, err)
+	assert.Equal(t, []HashField{{Field: "a"}, {Field: "b"}}, fields)
+
+	fields, err = HRandField(s, "h", 2, true)
+	assert.NoError(t, err)
+	assert.Equal(t, []HashField{{"a", "1"}, {"b", "2"}}, fields)
+
+	fields, err = HRandField(s, "h", -2, true)
+	assert.NoError(t, err)
+	assert.Equal(t, []HashField{{"a", "1"}, {"a", "1"}}, fields)
+
+	assert.Equal(t, []Request{
+		Req("HRANDFIELD", "h", 0),
+		Req("HRANDFIELD", "h", 2),
+		Req("HRANDFIELD", "h", 2, "WITHVALUES"),
+		Req("HRANDFIELD", "h", -2, "WITHVALUES"),
+	}, s.sent())
+
+	_, err = HashFieldsResponse([]interface{}{[]byte("a")}, true)
+	assert.Error(t, err)
+}
+
+func TestHGetAll(t *testing.T) {
+	s := &fakeSender{handler: func(r Request) interface{} {
+		switch r.Args[0] {
+		case "h":
+			return []interface{}{[]byte("a"), []byte("1"), []byte("b"), []byte{0, 0xff}}
+		case "odd":
+			return []interface{}{[]byte("a"), []byte("1"), []byte("b")}
+		case "str":
+			return ErrResult.New("WRONGTYPE Operation against a key holding the wrong kind of value")
+		}
+		return []interface{}{}
+	}}
+
+	m, err := HGetAll(s, "h")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"a": "1", "b": "\x00\xff"}, m)
+	assert.Equal(t, Req("HGETALL", "h"), s.sent()[0])
+
+	b, err := HGetAllBytes(s, "h")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string][]byte{"a": []byte("1"), "b": {0, 0xff}}, b)
+
+	m, err = HGetAll(s, "missing")
+	assert.NoError(t, err)
+	assert.NotNil(t, m)
+	assert.Empty(t, m)
+	b, err = HGetAllBytes(s, "missing")
+	assert.NoError(t, err)
+	assert.NotNil(t, b)
+
+	_, err = HGetAll(s, "odd")
+	assert.True(t, IsOfType(err, ErrResponseUnexpected))
+	_, err = HGetAllBytes(s, "odd")
+	assert.True(t, IsOfType(err, ErrResponseUnexpected))
+
+	_, err = HGetAll(s, "str")
+	assert.Equal(t, "WRONGTYPE", ErrorPrefix(err))
+}
+
+func TestHashFieldExpiration(t *testing.T) {
+	s := &fakeSender{handler: func(r Request) interface{} {
+		switch r.Cmd {
+		case "HEXPIRE", "HPEXPIRE":
+			return []interface{}{int64(1), int64(0), int64(-2)}
+		case "HPERSIST":
+			return []interface{}{int64(1), int64(-1)}
+		case "HPTTL":
+			return []interface{}{int64(1500), int64(-1), int64(-2)}
+		}
+		return ErrResult.New("ERR unknown command")
+	}}
+
+	st, err := HExpire(s, "h", time.Minute, ExpireNX, "a", "b", "c")
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{HFieldSet, HFieldNotSet, HFieldMissing}, st)
+	_, err = HExpire(s, "h", 1500*time.Millisecond, "", "a", "b", "c")
+	assert.NoError(t, err)
+
+	st, err = HPersist(s, "h", "a", "b")
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{HFieldSet, HFieldNoTTL}, st)
+
+	ttls, err := HTTL(s, "h", "a", "b", "c")
+	assert.NoError(t, err)
+	assert.Equal(t, []time.Duration{1500 * time.Millisecond, time.Duration(HFieldNoTTL), time.Duration(HFieldMissing)}, ttls)
+
+	assert.Equal(t, []Request{
+		Req("HEXPIRE", "h", int64(60), "NX", "FIELDS", 3, "a", "b", "c"),
+		Req("HPEXPIRE", "h", int64(1500), "FIELDS", 3, "a", "b", "c"),
+		Req("HPERSIST", "h", "FIELDS", 2, "a", "b"),
+		Req("HPTTL", "h", "FIELDS", 3, "a", "b", "c"),
+	}, s.sent())
+	for _, r := range s.sent() {
+		assert.NoError(t, CheckArity(r, nil))
+	}
+
+	// number of statuses should match number of fields
+	_, err = HTTL(s, "h", "a")
+	assert.True(t, IsOfType(err, ErrResponseUnexpected))
+
+	assert.True(t, WriteCommand("HEXPIRE"))
+	assert.True(t, ReplicaSafe("HPTTL"))
+}
+
+func TestHashFieldsResponseRESP3(t *testing.T) {
+	// RESP3 returns HRANDFIELD field and value pairs as two-element arrays.
+	res := readLines("*2\r\n", "*2\r\n$1\r\na\r\n$1\r\n1\r\n", "*2\r\n$1\r\nb\r\n$1\r\n2\r\n")
+	fields, err := HashFieldsResponse(res, true)
+	assert.NoError(t, err)
+	assert.Equal(t, []HashField{{"a", "1"}, {"b", "2"}}, fields)
+
+	// and HGETALL returns map, which is read as flat list.
+	res = readLines("%2\r\n", "$1\r\na\r\n$1\r\n1\r\n", "$1\r\nb\r\n$1\r\n2\r\n")
+	fields, err = HashFieldsResponse(res, true)
+	assert.NoError(t, err)
+	assert.Equal(t, []HashField{{"a", "1"}, {"b", "2"}}, fields)
+	m, err := HashMapResponse(res)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"a": "1", "b": "2"}, m)
+
+	_, err = HashFieldsResponse(readLines("*1\r\n", "*3\r\n$1\r\na\r\n$1\r\n1\r\n$1\r\n2\r\n"), true)
+	assert.Error(t, err)
+}
@@ -0,0 +1,256 @@
+package redis
+
+import "time"
+
+// KeyType is a type of value stored at key, as reported by TYPE command.
+type KeyType uint8
+
+const (
+	// KeyTypeNone - key doesn't exist.
+	KeyTypeNone KeyType = iota
+	// KeyTypeString - "string"
+	KeyTypeString
+	// KeyTypeList - "list"
+	KeyTypeList
+	// KeyTypeSet - "set"
+	KeyTypeSet
+	// KeyTypeZSet - "zset"
+	KeyTypeZSet
+	// KeyTypeHash - "hash"
+	KeyTypeHash
+	// KeyTypeStream - "stream"
+	KeyTypeStream
+	// KeyTypeOther - type is not known to this package (for example, type of module).
+	KeyTypeOther
+)
+
+var keyTypeNames = [...]string{
+	KeyTypeNone:   "none",
+	KeyTypeString: "string",
+	KeyTypeList:   "list",
+	KeyTypeSet:    "set",
+	KeyTypeZSet:   "zset",
+	KeyTypeHash:   "hash",
+	KeyTypeStream: "stream",
+	KeyTypeOther:  "other",
+}
+
+// String implements fmt.Stringer
+func (t KeyType) String() string {
+	if int(t) < len(keyTypeNames) {
+		return keyTypeNames[t]
+	}
+	return "other"
+}
+
+// ParseKeyType converts reply of TYPE command to KeyType.
+// It is useful for parsing results of TYPE requests sent in batch.
+func ParseKeyType(name string) KeyType {
+	for t, n := range keyTypeNames[:KeyTypeOther] {
+		if n == name {
+			return KeyType(t)
+		}
+	}
+	return KeyTypeOther
+}
+
+// Type returns type of value stored at key (TYPE).
+// KeyTypeNone is returned if key doesn't exist.
+func Type(s Sender, key string) (KeyType, error) {
+	name, err := responseString(Sync{s}.Do("TYPE", key))
+	if err != nil {
+		return KeyTypeNone, err
+	}
+	return ParseKeyType(name), nil
+}
+
+// Dump returns serialized value of key (DUMP). Returned bool is false if key doesn't exist.
+// Payload is opaque binary data which could be passed to Restore.
+func Dump(s Sender, key string) ([]byte, bool, error) {
+	res := Sync{s}.Do("DUMP", key)
+	if res == nil {
+		return nil, false, nil
+	}
+	payload, err := responseBytes(res)
+	return payload, err == nil, err
+}
+
+// RestoreOpts are options of RESTORE command.
+type RestoreOpts struct {
+	// Replace allows to overwrite existing key.
+	Replace bool
+	// AbsTTL means ttl passed to Restore is an absolute unix time in milliseconds.
+	AbsTTL bool
+	// IdleTime sets object idle time (rounded to seconds), so LRU metadata survives migration.
+	// It is sent if it is positive. Server honours it only if maxmemory-policy is not LFU.
+	IdleTime time.Duration
+	// Freq sets object access frequency counter, so LFU metadata survives migration.
+	// It is sent if it is positive. Server honours it only if maxmemory-policy is LFU.
+	Freq int64
+}
+
+// Args returns arguments to be appended to RESTORE command.
+func (o RestoreOpts) Args() []interface{} {
+	var args []interface{}
+	if o.Replace {
+		args = append(args, "REPLACE")
+	}
+	if o.AbsTTL {
+		args = append(args, "ABSTTL")
+	}
+	if o.IdleTime > 0 {
+		args = append(args, "IDLETIME", int64(o.IdleTime/time.Second))
+	}
+	if o.Freq > 0 {
+		args = append(args, "FREQ", o.Freq)
+	}
+	return args
+}
+
+// Restore creates key from payload returned by Dump (RESTORE).
+// Zero ttl means key doesn't expire, otherwise it is sent in milliseconds.
+// Payload is sent as is (binary safe).
+func Restore(s Sender, key string, ttl time.Duration, payload []byte, opts RestoreOpts) error {
+	args := append([]interface{}{key, int64(ttl / time.Millisecond), payload}, opts.Args()...)
+	return AsError(Sync{s}.Send(Request{"RESTORE", args}))
+}
+
+// RestoreAsking is Restore for importing node during cluster slot migration (RESTORE-ASKING):
+// key is accepted even if slot is not served by node yet. It should be sent to importing node directly
+// (for example, with rediscluster.Cluster.SendWithAsking).
+func RestoreAsking(s Sender, key string, ttl time.Duration, payload []byte, opts RestoreOpts) error {
+	args := append([]interface{}{key, int64(ttl / time.Millisecond), payload}, opts.Args()...)
+	return AsError(Sync{s}.Send(Request{"RESTORE-ASKING", args}))
+}
+
+// MigrateOpts are options of MIGRATE command.
+type MigrateOpts struct {
+	// DB is a destination database.
+	DB int
+	// Timeout is a maximum idle time of transfer (sent in milliseconds).
+	Timeout time.Duration
+	// Copy - do not remove keys from source.
+	Copy bool
+	// Replace - overwrite existing keys on destination.
+	Replace bool
+	// Username and Password authenticate on destination (AUTH2 if Username is set, AUTH otherwise).
+	Username, Password string
+}
+
+// MigrateRequest builds MIGRATE request which transfers keys to host:port.
+// Single key is sent in key argument, several keys are sent with KEYS option (redis >= 3.0.6).
+func MigrateRequest(host string, port int, keys []string, opts MigrateOpts) Request {
+	key := ""
+	if len(keys) == 1 {
+		key = keys[0]
+	}
+	args := []interface{}{host, port, key, opts.DB, int64(opts.Timeout / time.Millisecond)}
+	if opts.Copy {
+		args = append(args, "COPY")
+	}
+	if opts.Replace {
+		args = append(args, "REPLACE")
+	}
+	if opts.Username != "" {
+		args = append(args, "AUTH2", opts.Username, opts.Password)
+	} else if opts.Password != "" {
+		args = append(args, "AUTH", opts.Password)
+	}
+	if len(keys) > 1 {
+		args = append(args, "KEYS")
+		for _, k := range keys {
+			args = append(args, k)
+		}
+	}
+	return Request{"MIGRATE", args}
+}
+
+// Migrate transfers keys to host:port atomically (MIGRATE).
+// Returned bool is false if none of keys exists (NOKEY reply).
+// Keys should belong to the same slot in cluster.
+func Migrate(s Sender, host string, port int, keys []string, opts MigrateOpts) (bool, error) {
+	res, err := responseString(Sync{s}.Send(MigrateRequest(host, port, keys, opts)))
+	if err != nil {
+		return false, err
+	}
+	return res != "NOKEY", nil
+}
+
+// ExpireCondition is a condition of EXPIRE family commands (redis >= 7.0).
+// Empty condition sets expiration unconditionally.
+type ExpireCondition string
+
+const (
+	// ExpireNX - set expiration only if key has no expiration.
+	ExpireNX ExpireCondition = "NX"
+	// ExpireXX - set expiration only if key already has expiration.
+	ExpireXX ExpireCondition = "XX"
+	// ExpireGT - set expiration only if it is later than current one.
+	// Key without expiration is considered to have infinite ttl, so it is never set.
+	ExpireGT ExpireCondition = "GT"
+	// ExpireLT - set expiration only if it is earlier than current one.
+	// Key without expiration is considered to have infinite ttl, so it is always set.
+	ExpireLT ExpireCondition = "LT"
+)
+
+// Del removes keys (DEL) and returns number of removed keys.
+func Del(s Sender, keys ...string) (int64, error) {
+	return countKeys(s, "DEL", keys)
+}
+
+// Unlink removes keys (UNLINK) and returns number of removed keys. Unlike DEL, memory is reclaimed
+// in background, so large values don't block server.
+func Unlink(s Sender, keys ...string) (int64, error) {
+	return countKeys(s, "UNLINK", keys)
+}
+
+// UnlinkOrDel is Unlink which falls back to Del if server doesn't know UNLINK (redis < 4.0).
+func UnlinkOrDel(s Sender, keys ...string) (int64, error) {
+	n, err := Unlink(s, keys...)
+	if IsUnknownCommand(err) {
+		return Del(s, keys...)
+	}
+	return n, err
+}
+
+// Touch updates last access time of keys (TOUCH) and returns number of existing keys.
+func Touch(s Sender, keys ...string) (int64, error) {
+	return countKeys(s, "TOUCH", keys)
+}
+
+func countKeys(s Sender, cmd string, keys []string) (int64, error) {
+	args := make([]interface{}, len(keys))
+	for i, key := range keys {
+		args[i] = key
+	}
+	return responseInt(Sync{s}.Send(Request{cmd, args}))
+}
+
+// Expire sets time to live of key (EXPIRE if ttl is whole seconds, PEXPIRE otherwise).
+// Returned bool is false if key doesn't exist or condition is not met.
+func Expire(s Sender, key string, ttl time.Duration, cond ExpireCondition) (bool, error) {
+	return expire(s, key, durationArgs(ttl, "EXPIRE", "PEXPIRE"), cond)
+}
+
+// ExpireAt sets absolute expiration time of key (EXPIREAT if at is whole seconds, PEXPIREAT otherwise).
+// Returned bool is false if key doesn't exist or condition is not met.
+func ExpireAt(s Sender, key string, at time.Time, cond ExpireCondition) (bool, error) {
+	return expire(s, key, durationArgs(time.Duration(at.UnixNano()), "EXPIREAT", "PEXPIREAT"), cond)
+}
+
+// Persist removes time to live of key (PERSIST).
+// Returned bool is false if key has no time to live or doesn't exist.
+func Persist(s Sender, key string) (bool, error) {
+	n, err := responseInt(Sync{s}.Send(Request{"PERSIST", []interface{}{key}}))
+	return n == 1, err
+}
+
+// expire sends command built by durationArgs: cmdArgs[0] is command name and cmdArgs[1] is its time argument.
+func expire(s Sender, key string, cmdArgs []interface{}, cond ExpireCondition) (bool, error) {
+	args := []interface{}{key, cmdArgs[1]}
+	if cond != "" {
+		args = append(args, string(cond))
+	}
+	n, err := responseInt(Sync{s}.Send(Request{cmdArgs[0].(string), args}))
+	return n == 1, err
+}
@@ -0,0 +1,260 @@
+package redis_test
+
+import (
+	"bufio"
+	"bytes"
+	"strconv"
+	"testing"
+	"time"
+
+	. "github.com/joomcode/redispipe/redis"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestType(t *testing.T) {
+	types := map[string]string{"s": "string", "l": "list", "z": "zset", "bf": "MBbloom--"}
+	s := &fakeSender{handler: func(r Request) interface{} {
+		if name, ok := types[r.Args[0].(string)]; ok {
+			return name
+		}
+		return "none"
+	}}
+
+	for key, expect := range map[string]KeyType{
+		"s":       KeyTypeString,
+		"l":       KeyTypeList,
+		"z":       KeyTypeZSet,
+		"bf":      KeyTypeOther,
+		"missing": KeyTypeNone,
+	} {
+		kt, err := Type(s, key)
+		assert.NoError(t, err)
+		assert.Equal(t, expect, kt, key)
+	}
+	assert.Equal(t, "TYPE", s.sent()[0].Cmd)
+
+	for _, name := range []string{"none", "string", "list", "set", "zset", "hash", "stream"} {
+		assert.Equal(t, name, ParseKeyType(name).String())
+	}
+
+	s.handler = func(Request) interface{} { return ErrResult.New("ERR wrong") }
+	_, err := Type(s, "s")
+	assert.Error(t, err)
+}
+
+func TestDumpRestoreBinarySafe(t *testing.T) {
+	// handler passes requests and responses through wire format, like real connection does.
+	store := map[string][]byte{}
+	s := &fakeSender{handler: func(r Request) interface{} {
+		buf, err := AppendRequest(nil, r)
+		if err != nil {
+			return err
+		}
+		args := ReadResponse(bufio.NewReader(bytes.NewReader(buf))).([]interface{})
+		key := string(args[1].([]byte))
+		switch r.Cmd {
+		case "DUMP":
+			v, ok := store[key]
+			if !ok {
+				return ReadResponse(bufio.NewReader(bytes.NewReader([]byte("$-1\r\n"))))
+			}
+			resp := "$" + strconv.Itoa(len(v)) + "\r\n" + string(v) + "\r\n"
+			return ReadResponse(bufio.NewReader(bytes.NewReader([]byte(resp))))
+		case "RESTORE", "RESTORE-ASKING":
+			store[key] = args[3].([]byte)
+			return "OK"
+		}
+		return nil
+	}}
+
+	payload := []byte{0, 1, 0xff, 0xfe, '\r', '\n', 0, 0x80, 'x', 0}
+	assert.NoError(t, Restore(s, "bin", time.Minute, payload, RestoreOpts{Replace: true}))
+	assert.Equal(t, []interface{}{"bin", int64(60000), payload, "REPLACE"}, s.sent()[0].Args)
+
+	dumped, ok, err := Dump(s, "bin")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, payload, dumped)
+
+	_, ok, err = Dump(s, "missing")
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	assert.NoError(t, RestoreAsking(s, "imported", 0, payload, RestoreOpts{}))
+	assert.Equal(t, "RESTORE-ASKING", s.sent()[3].Cmd)
+	assert.Equal(t, payload, store["imported"])
+
+	assert.Equal(t, []interface{}{"REPLACE", "ABSTTL", "IDLETIME", int64(90), "FREQ", int64(5)},
+		RestoreOpts{Replace: true, AbsTTL: true, IdleTime: 90 * time.Second, Freq: 5}.Args())
+}
+
+func TestExpireConditions(t *testing.T) {
+	// ttl in milliseconds of existing keys, 0 means no expiration.
+	ttls := map[string]int64{"persistent": 0, "volatile": 100000}
+	s := &fakeSender{handler: func(r Request) interface{} {
+		cur, ok := ttls[r.Args[0].(string)]
+		if !ok {
+			return int64(0)
+		}
+		ttl := r.Args[1].(int64)
+		if r.Cmd == "EXPIRE" {
+			ttl *= 1000
+		}
+		var cond string
+		if len(r.Args) > 2 {
+			cond = r.Args[2].(string)
+		}
+		set := cond == "" ||
+			cond == "NX" && cur == 0 ||
+			cond == "XX" && cur != 0 ||
+			cond == "GT" && cur != 0 && ttl > cur ||
+			cond == "LT" && (cur == 0 || ttl < cur)
+		if set {
+			return int64(1)
+		}
+		return int64(0)
+	}}
+
+	for _, c := range []struct {
+		key    string
+		ttl    time.Duration
+		cond   ExpireCondition
+		expect bool
+	}{
+		{"persistent", time.Minute, "", true},
+		{"volatile", time.Minute, "", true},
+		{"missing", time.Minute, "", false},
+		{"persistent", time.Minute, ExpireNX, true},
+		{"volatile", time.Minute, ExpireNX, false},
+		{"persistent", time.Minute, ExpireXX, false},
+		{"volatile", time.Minute, ExpireXX, true},
+		{"persistent", time.Hour, ExpireGT, false},
+		{"volatile", time.Hour, ExpireGT, true},
+		{"volatile", time.Minute, ExpireGT, false},
+		{"persistent", time.Minute, ExpireLT, true},
+		{"volatile", time.Minute, ExpireLT, true},
+		{"volatile", time.Hour, ExpireLT, false},
+		{"missing", time.Minute, ExpireLT, false},
+	} {
+		ok, err := Expire(s, c.key, c.ttl, c.cond)
+		assert.NoError(t, err)
+		assert.Equal(t, c.expect, ok, "%s %v %s", c.key, c.ttl, c.cond)
+	}
+
+	s.reqs = nil
+	Expire(s, "volatile", 1500*time.Millisecond, ExpireGT)
+	at := time.Unix(1700000000, 0)
+	ExpireAt(s, "volatile", at, "")
+	ExpireAt(s, "volatile", at.Add(time.Millisecond), ExpireNX)
+	assert.Equal(t, []Request{
+		Req("PEXPIRE", "volatile", int64(1500), "GT"),
+		Req("EXPIREAT", "volatile", int64(1700000000)),
+		Req("PEXPIREAT", "volatile", int64(1700000000001), "NX"),
+	}, s.sent())
+
+	s.handler = func(r Request) interface{} {
+		return ErrResult.New("ERR NX and XX, GT or LT options at the same time are not compatible")
+	}
+	ok, err := Expire(s, "volatile", time.Minute, ExpireNX)
+	assert.False(t, ok)
+	assert.Error(t, err)
+}
+
+func TestPersist(t *testing.T) {
+	// ttl in seconds of existing keys, -1 means no expiration.
+	ttls := map[string]int64{"persistent": -1}
+	s := &fakeSender{handler: func(r Request) interface{} {
+		key := r.Args[0].(string)
+		cur, ok := ttls[key]
+		switch {
+		case r.Cmd == "TTL" && !ok:
+			return int64(-2)
+		case r.Cmd == "TTL":
+			return cur
+		case r.Cmd == "EXPIRE" && ok:
+			ttls[key] = r.Args[1].(int64)
+			return int64(1)
+		case r.Cmd == "PERSIST" && ok && cur != -1:
+			ttls[key] = -1
+			return int64(1)
+		}
+		return int64(0)
+	}}
+
+	ok, err := Expire(s, "persistent", time.Minute, "")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	ok, err = Persist(s, "persistent")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, int64(-1), Sync{s}.Do("TTL", "persistent"))
+
+	ok, err = Persist(s, "persistent")
+	assert.NoError(t, err)
+	assert.False(t, ok)
+	ok, err = Persist(s, "missing")
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	s.handler = func(r Request) interface{} {
+		return ErrResult.New("WRONGTYPE Operation against a key holding the wrong kind of value")
+	}
+	ok, err = Persist(s, "persistent")
+	assert.False(t, ok)
+	assert.Error(t, err)
+}
+
+func TestMigrate(t *testing.T) {
+	assert.Equal(t, []interface{}{"10.0.0.2", 7001, "k", 0, int64(5000)},
+		MigrateRequest("10.0.0.2", 7001, []string{"k"}, MigrateOpts{Timeout: 5 * time.Second}).Args)
+	assert.Equal(t, []interface{}{"10.0.0.2", 7001, "", 1, int64(100), "COPY", "REPLACE",
+		"AUTH2", "user", "pass", "KEYS", "a", "b"},
+		MigrateRequest("10.0.0.2", 7001, []string{"a", "b"}, MigrateOpts{
+			DB: 1, Timeout: 100 * time.Millisecond, Copy: true, Replace: true, Username: "user", Password: "pass",
+		}).Args)
+	assert.Equal(t, []interface{}{"h", 1, "k", 0, int64(0), "AUTH", "pass"},
+		MigrateRequest("h", 1, []string{"k"}, MigrateOpts{Password: "pass"}).Args)
+
+	s := &fakeSender{handler: func(r Request) interface{} {
+		if r.Args[2] == "missing" {
+			return "NOKEY"
+		}
+		return "OK"
+	}}
+	ok, err := Migrate(s, "h", 1, []string{"k"}, MigrateOpts{})
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	ok, err = Migrate(s, "h", 1, []string{"missing"}, MigrateOpts{})
+	assert.NoError(t, err)
+	assert.False(t, ok)
+}
+
+func TestDelUnlinkTouch(t *testing.T) {
+	s := &fakeSender{handler: func(r Request) interface{} { return int64(len(r.Args)) }}
+	n, err := Del(s, "a", "b")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), n)
+	n, err = Touch(s, "a")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), n)
+	n, err = UnlinkOrDel(s, "a", "b", "c")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), n)
+	assert.Equal(t, []Request{Req("DEL", "a", "b"), Req("TOUCH", "a"), Req("UNLINK", "a", "b", "c")}, s.sent())
+
+	// old server doesn't know UNLINK
+	s = &fakeSender{handler: func(r Request) interface{} {
+		if r.Cmd == "UNLINK" {
+			return ErrResult.New("ERR unknown command 'unlink'")
+		}
+		return int64(1)
+	}}
+	n, err = UnlinkOrDel(s, "a")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), n)
+	assert.Equal(t, []Request{Req("UNLINK", "a"), Req("DEL", "a")}, s.sent())
+
+	assert.True(t, IsUnknownCommand(ErrResult.New("ERR unknown command 'unlink', with args beginning with: ")))
+	assert.False(t, IsUnknownCommand(ErrResult.New("ERR wrong number of arguments for 'unlink' command")))
+	assert.False(t, IsUnknownCommand(ErrIO.New("ERR unknown command")))
+}
@@ -0,0 +1,26 @@
+package redis
+
+import (
+	"strconv"
+	"time"
+)
+
+// timeoutArg formats blocking timeout as seconds with fraction. Zero means "block forever".
+func timeoutArg(timeout time.Duration) string {
+	return strconv.FormatFloat(timeout.Seconds(), 'f', -1, 64)
+}
+
+// BLMove atomically pops element from srcDir ("LEFT" or "RIGHT") of list src and pushes it
+// to dstDir of list dst, waiting up to timeout for element to appear (BLMOVE, redis >= 6.2).
+// Zero timeout waits forever. Returned bool is false if timeout expired.
+//
+// Blocking commands are allowed only with redisconn.Opts.ScriptMode. Connection extends read
+// deadline for blocking requests by their timeout, so ReadTimeout could be smaller than timeout.
+func BLMove(s Sender, src, dst, srcDir, dstDir string, timeout time.Duration) (string, bool, error) {
+	return responseOptString(Sync{s}.Do("BLMOVE", src, dst, srcDir, dstDir, timeoutArg(timeout)))
+}
+
+// BRPopLPush is a legacy form of BLMove(s, src, dst, "RIGHT", "LEFT", timeout) (BRPOPLPUSH).
+func BRPopLPush(s Sender, src, dst string, timeout time.Duration) (string, bool, error) {
+	return responseOptString(Sync{s}.Do("BRPOPLPUSH", src, dst, timeoutArg(timeout)))
+}
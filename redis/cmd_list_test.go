@@ -0,0 +1,57 @@
+package redis_test
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/joomcode/redispipe/redis"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBLMove(t *testing.T) {
+	s := &fakeSender{handler: func(r Request) interface{} {
+		if r.Args[0] == "empty" {
+			return nil
+		}
+		return []byte("job")
+	}}
+
+	job, ok, err := BLMove(s, "queue", "processing", "RIGHT", "LEFT", 1500*time.Millisecond)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "job", job)
+	assert.Equal(t, Request{"BLMOVE", []interface{}{"queue", "processing", "RIGHT", "LEFT", "1.5"}}, s.sent()[0])
+
+	_, ok, err = BRPopLPush(s, "empty", "processing", 0)
+	assert.NoError(t, err)
+	assert.False(t, ok)
+	assert.Equal(t, Request{"BRPOPLPUSH", []interface{}{"empty", "processing", "0"}}, s.sent()[1])
+}
+
+func TestBlockingTimeout(t *testing.T) {
+	d, ok := BlockingTimeout(Req("BLMOVE", "a", "b", "LEFT", "RIGHT", "1.5"))
+	assert.True(t, ok)
+	assert.Equal(t, 1500*time.Millisecond, d)
+
+	d, ok = BlockingTimeout(Req("blpop", "a", "b", 2))
+	assert.True(t, ok)
+	assert.Equal(t, 2*time.Second, d)
+
+	d, ok = BlockingTimeout(Req("BZMPOP", "0", 1, "z", "MIN"))
+	assert.True(t, ok)
+	assert.Equal(t, time.Duration(0), d)
+
+	d, ok = BlockingTimeout(Req("XREAD", "COUNT", 1, "BLOCK", 100, "STREAMS", "s", "$"))
+	assert.True(t, ok)
+	assert.Equal(t, 100*time.Millisecond, d)
+
+	d, ok = BlockingTimeout(Req("WAITAOF", 1, 1, int64(250)))
+	assert.True(t, ok)
+	assert.Equal(t, 250*time.Millisecond, d)
+	assert.NoError(t, ForbiddenCommand("WAITAOF", false))
+
+	_, ok = BlockingTimeout(Req("XREAD", "STREAMS", "s", "0"))
+	assert.False(t, ok)
+	_, ok = BlockingTimeout(Req("GET", "a"))
+	assert.False(t, ok)
+}
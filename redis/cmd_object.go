@@ -0,0 +1,135 @@
+package redis
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ObjectInfo is a result of OBJECT subcommands for single key.
+type ObjectInfo struct {
+	// Encoding is internal representation of value, like "int", "embstr", "listpack".
+	Encoding string
+	// Refcount is a number of references to value. Shared small integers have huge refcount.
+	Refcount int64
+	// IdleTime is a time since last access. It is -1 if it is not available
+	// (server uses LFU maxmemory-policy).
+	IdleTime time.Duration
+	// Freq is a logarithmic access frequency counter. It is -1 if it is not available
+	// (server doesn't use LFU maxmemory-policy).
+	Freq int64
+}
+
+// ObjectRefcount returns number of references to value of key (OBJECT REFCOUNT).
+// ErrKeyNotFound is returned if key doesn't exist.
+func ObjectRefcount(s Sender, key string) (int64, error) {
+	res := Sync{s}.Do("OBJECT", "REFCOUNT", key)
+	if res == nil {
+		return 0, ErrKeyNotFound.NewWithNoMessage().WithProperty(EKKey, key)
+	}
+	return responseInt(res)
+}
+
+// ObjectInspect returns OBJECT ENCODING, REFCOUNT, IDLETIME and FREQ of key.
+// All subcommands are sent in a single batch, so it costs one round trip.
+// ErrKeyNotFound is returned if key doesn't exist.
+func ObjectInspect(s Sender, key string) (ObjectInfo, error) {
+	info := ObjectInfo{IdleTime: -1, Freq: -1}
+	res := Sync{s}.SendMany([]Request{
+		Req("OBJECT", "ENCODING", key),
+		Req("OBJECT", "REFCOUNT", key),
+		Req("OBJECT", "IDLETIME", key),
+		Req("OBJECT", "FREQ", key),
+	})
+	if res[0] == nil {
+		return info, ErrKeyNotFound.NewWithNoMessage().WithProperty(EKKey, key)
+	}
+	var err error
+	if info.Encoding, err = responseString(res[0]); err != nil {
+		return info, err
+	}
+	if info.Refcount, err = responseInt(res[1]); err != nil {
+		return info, err
+	}
+	// IDLETIME and FREQ fail depending on maxmemory-policy, so their errors are not reported.
+	if idle, err := responseInt(res[2]); err == nil {
+		info.IdleTime = time.Duration(idle) * time.Second
+	}
+	if freq, err := responseInt(res[3]); err == nil {
+		info.Freq = freq
+	}
+	return info, nil
+}
+
+// DebugObjectInfo is a parsed reply of DEBUG OBJECT.
+type DebugObjectInfo struct {
+	// Encoding is internal representation of value.
+	Encoding string
+	// Refcount is a number of references to value.
+	Refcount int64
+	// SerializedLength is a length of value in RDB format (it is computed on the fly, and is slow for large values).
+	SerializedLength int64
+	// LRU is LRU clock of last access.
+	LRU int64
+	// LRUIdle is a time since last access.
+	LRUIdle time.Duration
+	// Fields are all "name:value" pairs of reply, including ones not parsed above (ql_nodes, etc).
+	Fields map[string]string
+}
+
+// ParseDebugObject parses reply of DEBUG OBJECT ("Value at:0x... refcount:1 encoding:listpack ...").
+// Unknown and malformed fields are left in Fields only.
+func ParseDebugObject(reply string) DebugObjectInfo {
+	info := DebugObjectInfo{Fields: make(map[string]string)}
+	for _, field := range strings.Fields(reply) {
+		i := strings.IndexByte(field, ':')
+		if i <= 0 {
+			continue
+		}
+		name, val := field[:i], field[i+1:]
+		info.Fields[name] = val
+		n, err := strconv.ParseInt(val, 10, 64)
+		switch name {
+		case "encoding":
+			info.Encoding = val
+		case "refcount":
+			if err == nil {
+				info.Refcount = n
+			}
+		case "serializedlength":
+			if err == nil {
+				info.SerializedLength = n
+			}
+		case "lru":
+			if err == nil {
+				info.LRU = n
+			}
+		case "lru_seconds_idle":
+			if err == nil {
+				info.LRUIdle = time.Duration(n) * time.Second
+			}
+		}
+	}
+	return info
+}
+
+// DebugObject returns parsed DEBUG OBJECT reply for key.
+// ErrKeyNotFound is returned if key doesn't exist, and ErrDebugDisabled is returned if DEBUG command
+// is not allowed by server (enable-debug-command, redis >= 7.0).
+func DebugObject(s Sender, key string) (DebugObjectInfo, error) {
+	res := Sync{s}.Do("DEBUG OBJECT", key)
+	if err := AsErrorx(res); err != nil && err.IsOfType(ErrResult) {
+		msg := err.Message()
+		switch {
+		case strings.HasPrefix(msg, "ERR no such key"):
+			return DebugObjectInfo{}, ErrKeyNotFound.NewWithNoMessage().WithProperty(EKKey, key)
+		case strings.HasPrefix(msg, "ERR DEBUG command not allowed"):
+			return DebugObjectInfo{}, ErrDebugDisabled.WrapWithNoMessage(err)
+		}
+	}
+	reply, err := responseString(res)
+	if err != nil {
+		return DebugObjectInfo{}, err
+	}
+	return ParseDebugObject(reply), nil
+}
@@ -0,0 +1,74 @@
+package redis_test
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/joomcode/redispipe/redis"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestObjectInspect(t *testing.T) {
+	s := &fakeSender{handler: func(r Request) interface{} {
+		if r.Args[1] == "missing" {
+			return nil
+		}
+		switch r.Args[0] {
+		case "ENCODING":
+			return []byte("int")
+		case "REFCOUNT":
+			return int64(2147483647)
+		case "IDLETIME":
+			return int64(10)
+		}
+		return ErrResult.New("ERR An LFU maxmemory policy is not selected, access frequency not tracked.")
+	}}
+
+	info, err := ObjectInspect(s, "key")
+	assert.NoError(t, err)
+	assert.Equal(t, ObjectInfo{Encoding: "int", Refcount: 2147483647, IdleTime: 10 * time.Second, Freq: -1}, info)
+	assert.Len(t, s.sent(), 4)
+
+	_, err = ObjectInspect(s, "missing")
+	assert.True(t, IsOfType(err, ErrKeyNotFound))
+
+	n, err := ObjectRefcount(s, "key")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2147483647), n)
+
+	_, err = ObjectRefcount(s, "missing")
+	assert.True(t, IsOfType(err, ErrKeyNotFound))
+}
+
+func TestDebugObject(t *testing.T) {
+	s := &fakeSender{handler: func(r Request) interface{} {
+		switch r.Args[0] {
+		case "missing":
+			return ErrResult.New("ERR no such key")
+		case "disabled":
+			return ErrResult.New("ERR DEBUG command not allowed. If the enable-debug-command option is set to \"no\", " +
+				"you can't use this command.")
+		}
+		return "Value at:0x7f5c2e43a0c0 refcount:1 encoding:listpack serializedlength:42 lru:5832109 " +
+			"lru_seconds_idle:7 ql_nodes:1"
+	}}
+
+	info, err := DebugObject(s, "key")
+	assert.NoError(t, err)
+	assert.Equal(t, "listpack", info.Encoding)
+	assert.Equal(t, int64(1), info.Refcount)
+	assert.Equal(t, int64(42), info.SerializedLength)
+	assert.Equal(t, int64(5832109), info.LRU)
+	assert.Equal(t, 7*time.Second, info.LRUIdle)
+	assert.Equal(t, "1", info.Fields["ql_nodes"])
+	assert.Equal(t, "0x7f5c2e43a0c0", info.Fields["at"])
+	key, _ := s.sent()[0].Key()
+	assert.Equal(t, "key", key)
+
+	_, err = DebugObject(s, "missing")
+	assert.True(t, IsOfType(err, ErrKeyNotFound))
+
+	_, err = DebugObject(s, "disabled")
+	assert.True(t, IsOfType(err, ErrDebugDisabled))
+	assert.True(t, IsOfType(err, ErrResult))
+}
@@ -0,0 +1,59 @@
+package redis
+
+// SPublish publishes message to shard channel (SPUBLISH, redis >= 7.0).
+// Cluster routes it by channel's slot, as if channel were a key.
+// It returns number of subscribers received the message.
+func SPublish(s Sender, channel string, message interface{}) (int64, error) {
+	return responseInt(Sync{s}.Do("SPUBLISH", channel, message))
+}
+
+// PubSubChannels returns active channels (having at least one subscriber) matching pattern
+// (PUBSUB CHANNELS). Empty pattern matches all channels.
+// In cluster it returns channels of single node only.
+func PubSubChannels(s Sender, pattern string) ([]string, error) {
+	args := []interface{}{"CHANNELS"}
+	if pattern != "" {
+		args = append(args, pattern)
+	}
+	return responseStrings(Sync{s}.Send(Request{"PUBSUB", args}))
+}
+
+// PubSubNumSub returns number of subscribers of channels (PUBSUB NUMSUB).
+// Every requested channel is present in result map, with zero count if it has no subscribers.
+func PubSubNumSub(s Sender, channels ...string) (map[string]int64, error) {
+	args := make([]interface{}, 0, len(channels)+1)
+	args = append(args, "NUMSUB")
+	for _, ch := range channels {
+		args = append(args, ch)
+	}
+	return PubSubNumSubResponse(Sync{s}.Send(Request{"PUBSUB", args}))
+}
+
+// PubSubNumSubResponse parses response of PUBSUB NUMSUB: flat array of channel and count pairs.
+func PubSubNumSubResponse(res interface{}) (map[string]int64, error) {
+	arr, err := responseArray(res)
+	if err != nil {
+		return nil, err
+	}
+	if len(arr)%2 != 0 {
+		return nil, unexpectedResponse(res)
+	}
+	counts := make(map[string]int64, len(arr)/2)
+	for i := 0; i < len(arr); i += 2 {
+		ch, err := responseString(arr[i])
+		if err != nil {
+			return nil, unexpectedResponse(res)
+		}
+		n, err := responseInt(arr[i+1])
+		if err != nil {
+			return nil, unexpectedResponse(res)
+		}
+		counts[ch] = n
+	}
+	return counts, nil
+}
+
+// PubSubNumPat returns number of pattern subscriptions (PUBSUB NUMPAT).
+func PubSubNumPat(s Sender) (int64, error) {
+	return responseInt(Sync{s}.Do("PUBSUB", "NUMPAT"))
+}
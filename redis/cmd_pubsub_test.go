@@ -0,0 +1,62 @@
+package redis_test
+
+import (
+	"testing"
+
+	. "github.com/joomcode/redispipe/redis"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPubSubIntrospection(t *testing.T) {
+	s := &fakeSender{handler: func(r Request) interface{} {
+		switch r.Args[0] {
+		case "CHANNELS":
+			return []interface{}{[]byte("news"), []byte("news.sport")}
+		case "NUMSUB":
+			res := []interface{}{}
+			for i, ch := range r.Args[1:] {
+				res = append(res, []byte(ch.(string)), int64(i))
+			}
+			return res
+		}
+		return int64(3)
+	}}
+
+	chans, err := PubSubChannels(s, "")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"news", "news.sport"}, chans)
+	_, err = PubSubChannels(s, "news*")
+	assert.NoError(t, err)
+
+	counts, err := PubSubNumSub(s, "a", "b")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]int64{"a": 0, "b": 1}, counts)
+
+	n, err := PubSubNumPat(s)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), n)
+
+	assert.Equal(t, []Request{
+		Req("PUBSUB", "CHANNELS"),
+		Req("PUBSUB", "CHANNELS", "news*"),
+		Req("PUBSUB", "NUMSUB", "a", "b"),
+		Req("PUBSUB", "NUMPAT"),
+	}, s.sent())
+
+	for _, bad := range []interface{}{
+		int64(1),
+		[]interface{}{[]byte("a")},
+		[]interface{}{[]byte("a"), []byte("1")},
+		[]interface{}{int64(1), int64(1)},
+	} {
+		_, err = PubSubNumSubResponse(bad)
+		assert.True(t, IsOfType(err, ErrResponseUnexpected), "%v", bad)
+	}
+}
+
+func TestPubSubNumSubResponseRESP3(t *testing.T) {
+	// RESP3 returns PUBSUB NUMSUB answer as map, which is read as flat list.
+	counts, err := PubSubNumSubResponse(readLines("%2\r\n", "$1\r\na\r\n:1\r\n", "$1\r\nb\r\n:0\r\n"))
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]int64{"a": 1, "b": 0}, counts)
+}
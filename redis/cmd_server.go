@@ -0,0 +1,527 @@
+package redis
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// anyShard returns first shard of sender (Connection itself for single connection client).
+// It is used to send keyless introspection commands through cluster client as well.
+func anyShard(s Sender) (Sender, error) {
+	var shard Sender
+	var err error
+	s.EachShard(func(sh Sender, e error) bool {
+		shard, err = sh, e
+		return false
+	})
+	if shard == nil && err == nil {
+		err = ErrNoShards.New("sender has no shards")
+	}
+	return shard, err
+}
+
+// CommandInfo is a command description returned by COMMAND and COMMAND INFO.
+type CommandInfo struct {
+	// Name is a lower-cased command name.
+	Name string
+	// Arity is a number of arguments including command name.
+	// Negative arity means command accepts at least -Arity arguments.
+	Arity int
+	// Flags are command flags, like "write", "readonly", "movablekeys".
+	Flags []string
+	// FirstKey is a position of first key argument (command name is at position 0).
+	// Zero means command has no keys.
+	FirstKey int
+	// LastKey is a position of last key argument. Negative value counts from the end
+	// (ie -1 means last argument).
+	LastKey int
+	// Step is a step between key positions.
+	Step int
+}
+
+// HasFlag returns true if command has flag.
+func (ci CommandInfo) HasFlag(flag string) bool {
+	for _, f := range ci.Flags {
+		if strings.EqualFold(f, flag) {
+			return true
+		}
+	}
+	return false
+}
+
+// CommandCount returns number of commands supported by server (COMMAND COUNT).
+// For cluster it is asked from first shard.
+func CommandCount(s Sender) (int, error) {
+	shard, err := anyShard(s)
+	if err != nil {
+		return 0, err
+	}
+	n, err := responseInt(Sync{shard}.Do("COMMAND COUNT"))
+	return int(n), err
+}
+
+// Commands returns descriptions of named commands (COMMAND INFO), or of all commands
+// if no names given (COMMAND). Unknown commands are returned as CommandInfo with empty Name.
+// For cluster it is asked from first shard.
+func Commands(s Sender, names ...string) ([]CommandInfo, error) {
+	shard, err := anyShard(s)
+	if err != nil {
+		return nil, err
+	}
+	req := Request{Cmd: "COMMAND"}
+	if len(names) > 0 {
+		req.Cmd = "COMMAND INFO"
+		for _, name := range names {
+			req.Args = append(req.Args, name)
+		}
+	}
+	return CommandInfoResponse(Sync{shard}.Send(req))
+}
+
+// CommandGetKeys asks server for keys of request (COMMAND GETKEYS). It handles any command known to server,
+// including commands of modules, which are not in static table of CommandKeys.
+// Request without keys is answered with empty slice (server answers it with error).
+// For cluster it is asked from first shard.
+func CommandGetKeys(s Sender, req Request) ([]string, error) {
+	shard, err := anyShard(s)
+	if err != nil {
+		return nil, err
+	}
+	args := make([]interface{}, 0, len(req.Args)+2)
+	for _, word := range strings.Fields(req.Cmd) {
+		args = append(args, word)
+	}
+	args = append(args, req.Args...)
+	res := Sync{shard}.Send(Request{"COMMAND GETKEYS", args})
+	if err := AsErrorx(res); err != nil && strings.Contains(err.Message(), "no key arguments") {
+		return []string{}, nil
+	}
+	return responseStrings(res)
+}
+
+// GetKeysCache extracts keys of requests with COMMAND GETKEYS, and caches key positions per command name
+// and number of arguments, so server is asked once per command shape. It is meant for commands
+// unknown to CommandKeys (for example, commands of modules) whose key positions depend only on number
+// of arguments. It is safe for concurrent use.
+type GetKeysCache struct {
+	// S is a sender COMMAND GETKEYS is sent with.
+	S Sender
+
+	m   sync.Mutex
+	pos map[string][]int
+}
+
+// NewGetKeysCache returns GetKeysCache over sender s.
+func NewGetKeysCache(s Sender) *GetKeysCache {
+	return &GetKeysCache{S: s}
+}
+
+// Keys returns keys of request.
+func (c *GetKeysCache) Keys(req Request) ([]string, error) {
+	shape := strings.ToUpper(req.Cmd) + "/" + strconv.Itoa(len(req.Args))
+	c.m.Lock()
+	pos, ok := c.pos[shape]
+	c.m.Unlock()
+	if ok {
+		keys := make([]string, len(pos))
+		for i, n := range pos {
+			if keys[i], ok = ArgToString(req.Args[n]); !ok {
+				return nil, ErrArgumentType.New("key should be string").
+					WithProperty(EKRequest, req).WithProperty(EKArgPos, n).WithProperty(EKVal, req.Args[n])
+			}
+		}
+		return keys, nil
+	}
+
+	keys, err := CommandGetKeys(c.S, req)
+	if err != nil {
+		return nil, err
+	}
+	// find positions of returned keys in arguments (keys are returned in order of arguments).
+	pos = make([]int, 0, len(keys))
+	n := 0
+	for _, key := range keys {
+		for ; n < len(req.Args); n++ {
+			if arg, _ := ArgToString(req.Args[n]); arg == key {
+				break
+			}
+		}
+		if n == len(req.Args) {
+			// key is not an argument as is, so positions could not be cached.
+			return keys, nil
+		}
+		pos = append(pos, n)
+		n++
+	}
+	c.m.Lock()
+	if c.pos == nil {
+		c.pos = make(map[string][]int)
+	}
+	c.pos[shape] = pos
+	c.m.Unlock()
+	return keys, nil
+}
+
+// CommandInfoResponse parses response of COMMAND and COMMAND INFO.
+func CommandInfoResponse(res interface{}) ([]CommandInfo, error) {
+	arr, err := responseArray(res)
+	if err != nil {
+		return nil, err
+	}
+	infos := make([]CommandInfo, len(arr))
+	for i, v := range arr {
+		if v == nil {
+			continue
+		}
+		if infos[i], err = parseCommandInfo(v); err != nil {
+			return nil, err
+		}
+	}
+	return infos, nil
+}
+
+func parseCommandInfo(res interface{}) (CommandInfo, error) {
+	var info CommandInfo
+	var ints [4]int64
+	// redis 6 and 7 add more fields (acl categories, tips, key specs, subcommands); they are ignored.
+	arr, err := responseArray(res)
+	if err != nil || len(arr) < 6 {
+		return info, unexpectedResponse(res)
+	}
+	if info.Name, err = responseString(arr[0]); err != nil {
+		return info, unexpectedResponse(res)
+	}
+	if info.Flags, err = responseStrings(arr[2]); err != nil {
+		return info, unexpectedResponse(res)
+	}
+	for i, pos := range []int{1, 3, 4, 5} {
+		if ints[i], err = responseInt(arr[pos]); err != nil {
+			return info, unexpectedResponse(res)
+		}
+	}
+	info.Arity = int(ints[0])
+	info.FirstKey = int(ints[1])
+	info.LastKey = int(ints[2])
+	info.Step = int(ints[3])
+	return info, nil
+}
+
+// ClientInfo is a description of client connection, as returned by CLIENT INFO and CLIENT LIST.
+type ClientInfo struct {
+	// ID is a unique client id (CLIENT ID).
+	ID int64
+	// Addr and LAddr are client's address and local address of server socket.
+	Addr  string
+	LAddr string
+	// Name is a name set with CLIENT SETNAME.
+	Name string
+	// User is an ACL user name.
+	User string
+	// DB is a selected database.
+	DB int
+	// Resp is a protocol version (2 or 3), it is 0 if server doesn't report it (redis < 7.0).
+	Resp int
+	// Flags are client flags, like "N" or "S" (see CLIENT LIST).
+	Flags string
+	// Age and Idle are total duration of connection and idle time.
+	Age  time.Duration
+	Idle time.Duration
+	// Sub and PSub are numbers of channel and pattern subscriptions.
+	Sub  int
+	PSub int
+	// Multi is a number of commands in MULTI/EXEC context, it is -1 outside of transaction.
+	Multi int
+	// Cmd is a last command executed.
+	Cmd string
+	// Fields are all "name=value" pairs, including ones not parsed above.
+	Fields map[string]string
+}
+
+// ParseClientInfo parses single line of CLIENT LIST or CLIENT INFO.
+// Malformed integer fields are left in Fields only.
+func ParseClientInfo(line string) ClientInfo {
+	info := ClientInfo{Multi: -1, Fields: make(map[string]string)}
+	for _, field := range strings.Fields(line) {
+		i := strings.IndexByte(field, '=')
+		if i <= 0 {
+			continue
+		}
+		name, val := field[:i], field[i+1:]
+		info.Fields[name] = val
+		n, err := strconv.ParseInt(val, 10, 64)
+		if err != nil {
+			n = 0
+		}
+		switch name {
+		case "id":
+			info.ID = n
+		case "addr":
+			info.Addr = val
+		case "laddr":
+			info.LAddr = val
+		case "name":
+			info.Name = val
+		case "user":
+			info.User = val
+		case "db":
+			info.DB = int(n)
+		case "resp":
+			info.Resp = int(n)
+		case "flags":
+			info.Flags = val
+		case "age":
+			info.Age = time.Duration(n) * time.Second
+		case "idle":
+			info.Idle = time.Duration(n) * time.Second
+		case "sub":
+			info.Sub = int(n)
+		case "psub":
+			info.PSub = int(n)
+		case "multi":
+			if err == nil {
+				info.Multi = int(n)
+			}
+		case "cmd":
+			info.Cmd = val
+		}
+	}
+	return info
+}
+
+// ClientInfoResponse parses response of CLIENT INFO.
+func ClientInfoResponse(res interface{}) (ClientInfo, error) {
+	line, err := responseString(res)
+	if err != nil {
+		return ClientInfo{}, err
+	}
+	return ParseClientInfo(line), nil
+}
+
+// RoleInfo is a parsed response of ROLE command.
+type RoleInfo struct {
+	// Role is one of "master", "slave" or "sentinel".
+	Role string
+	// Offset is replication offset (for master and replica).
+	Offset int64
+	// Replicas are connected replicas (for master).
+	Replicas []ReplicaInfo
+	// MasterHost and MasterPort are address of master (for replica).
+	MasterHost string
+	MasterPort int
+	// State is a replication state, like "connected" (for replica).
+	State string
+	// MasterNames are names of monitored masters (for sentinel).
+	MasterNames []string
+}
+
+// ReplicaInfo describes replica connected to master.
+type ReplicaInfo struct {
+	Host   string
+	Port   int
+	Offset int64
+}
+
+// IsMaster returns true if server is master.
+func (r RoleInfo) IsMaster() bool {
+	return r.Role == "master"
+}
+
+// MemoryStatsResponse parses response of MEMORY STATS: flat list of metric names and values is decoded
+// into map. Integer values are int64, float values (like "peak.percentage") and strings are string,
+// and nested lists of pairs (like per-db "db.0" entries) are decoded into nested maps.
+func MemoryStatsResponse(res interface{}) (map[string]interface{}, error) {
+	arr, err := responseArray(res)
+	if err != nil {
+		return nil, err
+	}
+	stats, ok := decodeMemoryStats(arr)
+	if !ok {
+		return nil, unexpectedResponse(res)
+	}
+	return stats, nil
+}
+
+func decodeMemoryStats(arr []interface{}) (map[string]interface{}, bool) {
+	if len(arr)%2 != 0 {
+		return nil, false
+	}
+	stats := make(map[string]interface{}, len(arr)/2)
+	for i := 0; i < len(arr); i += 2 {
+		name, err := responseString(arr[i])
+		if err != nil {
+			return nil, false
+		}
+		switch v := arr[i+1].(type) {
+		case []byte:
+			stats[name] = string(v)
+		case []interface{}:
+			nested, ok := decodeMemoryStats(v)
+			if !ok {
+				return nil, false
+			}
+			stats[name] = nested
+		case error:
+			return nil, false
+		default:
+			stats[name] = v
+		}
+	}
+	return stats, true
+}
+
+// RoleResponse parses response of ROLE command.
+func RoleResponse(res interface{}) (RoleInfo, error) {
+	var info RoleInfo
+	arr, err := responseArray(res)
+	if err != nil {
+		return info, err
+	}
+	if len(arr) == 0 {
+		return info, unexpectedResponse(res)
+	}
+	if info.Role, err = responseString(arr[0]); err != nil {
+		return info, unexpectedResponse(res)
+	}
+	switch info.Role {
+	case "master":
+		if len(arr) < 3 {
+			return info, unexpectedResponse(res)
+		}
+		if info.Offset, err = responseInt(arr[1]); err != nil {
+			return info, unexpectedResponse(res)
+		}
+		replicas, err := responseArray(arr[2])
+		if err != nil {
+			return info, unexpectedResponse(res)
+		}
+		for _, r := range replicas {
+			fields, err := responseStrings(r)
+			if err != nil || len(fields) < 3 {
+				return info, unexpectedResponse(res)
+			}
+			port, err1 := strconv.Atoi(fields[1])
+			offset, err2 := strconv.ParseInt(fields[2], 10, 64)
+			if err1 != nil || err2 != nil {
+				return info, unexpectedResponse(res)
+			}
+			info.Replicas = append(info.Replicas, ReplicaInfo{Host: fields[0], Port: port, Offset: offset})
+		}
+	case "slave":
+		if len(arr) < 5 {
+			return info, unexpectedResponse(res)
+		}
+		var port int64
+		if info.MasterHost, err = responseString(arr[1]); err != nil {
+			return info, unexpectedResponse(res)
+		}
+		if port, err = responseInt(arr[2]); err != nil {
+			return info, unexpectedResponse(res)
+		}
+		info.MasterPort = int(port)
+		if info.State, err = responseString(arr[3]); err != nil {
+			return info, unexpectedResponse(res)
+		}
+		if info.Offset, err = responseInt(arr[4]); err != nil {
+			return info, unexpectedResponse(res)
+		}
+	case "sentinel":
+		if len(arr) < 2 {
+			return info, unexpectedResponse(res)
+		}
+		if info.MasterNames, err = responseStrings(arr[1]); err != nil {
+			return info, unexpectedResponse(res)
+		}
+	default:
+		return info, unexpectedResponse(res)
+	}
+	return info, nil
+}
+
+// ConfigGet returns configuration parameters matching glob pattern (CONFIG GET).
+// For cluster it is asked from first shard.
+func ConfigGet(s Sender, pattern string) (map[string]string, error) {
+	shard, err := anyShard(s)
+	if err != nil {
+		return nil, err
+	}
+	return ConfigGetResponse(Sync{shard}.Do("CONFIG GET", pattern))
+}
+
+// ConfigGetOrdered is ConfigGet which preserves order of parameters in reply.
+func ConfigGetOrdered(s Sender, pattern string) (OrderedMap, error) {
+	shard, err := anyShard(s)
+	if err != nil {
+		return nil, err
+	}
+	return OrderedMapResponse(Sync{shard}.Do("CONFIG GET", pattern))
+}
+
+// ConfigGetResponse parses response of CONFIG GET: flat array of parameter and value pairs.
+func ConfigGetResponse(res interface{}) (map[string]string, error) {
+	params, err := OrderedMapResponse(res)
+	if err != nil {
+		return nil, err
+	}
+	return params.Map(), nil
+}
+
+// ConfigSet sets configuration parameters (CONFIG SET).
+// All pairs are sent in single command (redis >= 7.0), so they are applied atomically.
+// If server doesn't accept several pairs, they are sent as separate commands in a pipeline,
+// and first error is returned.
+// For cluster it is sent to first shard.
+func ConfigSet(s Sender, pairs map[string]string) error {
+	shard, err := anyShard(s)
+	if err != nil {
+		return err
+	}
+	names := make([]string, 0, len(pairs))
+	for name := range pairs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	args := make([]interface{}, 0, len(pairs)*2)
+	for _, name := range names {
+		args = append(args, name, pairs[name])
+	}
+	err = AsError(Sync{shard}.Send(Request{"CONFIG SET", args}))
+	if len(pairs) < 2 || !IsOfType(err, ErrResult) {
+		return err
+	}
+	// old server knows single pair only.
+	reqs := make([]Request, len(names))
+	for i, name := range names {
+		reqs[i] = Req("CONFIG SET", name, pairs[name])
+	}
+	for _, res := range (Sync{shard}).SendMany(reqs) {
+		if err := AsError(res); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WaitAOF waits until preceding writes of this connection are fsynced to AOF of numlocal (0 or 1) local
+// servers and numreplicas replicas (WAITAOF, redis >= 7.2). Zero timeout waits forever.
+// Number of acknowledgements is returned: it could be less than requested if timeout expired,
+// which is not an error.
+// It should be sent through same connection as writes, so it is not useful for cluster client.
+func WaitAOF(s Sender, numlocal, numreplicas int, timeout time.Duration) (local, replicas int, err error) {
+	res := Sync{s}.Send(Request{"WAITAOF", []interface{}{numlocal, numreplicas, int64(timeout / time.Millisecond)}})
+	arr, err := responseArray(res)
+	if err != nil {
+		return 0, 0, err
+	}
+	if len(arr) != 2 {
+		return 0, 0, unexpectedResponse(res)
+	}
+	l, lok := arr[0].(int64)
+	r, rok := arr[1].(int64)
+	if !lok || !rok {
+		return 0, 0, unexpectedResponse(res)
+	}
+	return int(l), int(r), nil
+}
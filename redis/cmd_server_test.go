@@ -0,0 +1,263 @@
+package redis_test
+
+import (
+	"bufio"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/joomcode/redispipe/redis"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCommandInfoResponse(t *testing.T) {
+	res := []interface{}{
+		[]interface{}{[]byte("get"), int64(2), []interface{}{"readonly", "fast"}, int64(1), int64(1), int64(1),
+			[]interface{}{"@read", "@string", "@fast"}},
+		nil,
+		[]interface{}{[]byte("mset"), int64(-3), []interface{}{"write", "denyoom"}, int64(1), int64(-1), int64(2)},
+	}
+	infos, err := CommandInfoResponse(res)
+	assert.NoError(t, err)
+	assert.Equal(t, []CommandInfo{
+		{Name: "get", Arity: 2, Flags: []string{"readonly", "fast"}, FirstKey: 1, LastKey: 1, Step: 1},
+		{},
+		{Name: "mset", Arity: -3, Flags: []string{"write", "denyoom"}, FirstKey: 1, LastKey: -1, Step: 2},
+	}, infos)
+	assert.True(t, infos[0].HasFlag("READONLY"))
+	assert.False(t, infos[2].HasFlag("readonly"))
+
+	_, err = CommandInfoResponse([]interface{}{[]interface{}{[]byte("get"), int64(2)}})
+	assert.Error(t, err)
+
+	_, err = CommandInfoResponse(ErrResult.New("ERR unknown"))
+	assert.Error(t, err)
+}
+
+func TestCommandCount(t *testing.T) {
+	s := &fakeSender{handler: func(r Request) interface{} { return int64(200) }}
+	n, err := CommandCount(s)
+	assert.NoError(t, err)
+	assert.Equal(t, 200, n)
+	assert.Equal(t, []Request{Req("COMMAND COUNT")}, s.sent())
+}
+
+// noShardSender is a sender without shards, like cluster which has no known nodes.
+type noShardSender struct{ *fakeSender }
+
+func (noShardSender) EachShard(func(Sender, error) bool) {}
+
+func TestCommandCountNoShards(t *testing.T) {
+	_, err := CommandCount(noShardSender{&fakeSender{}})
+	assert.True(t, IsOfType(err, ErrNoShards), "%v", err)
+	assert.True(t, HasTrait(err, ErrTraitConnectivity))
+	assert.True(t, HasTrait(err, ErrTraitNotSent))
+}
+
+func TestWaitAOF(t *testing.T) {
+	s := &fakeSender{handler: func(r Request) interface{} { return []interface{}{int64(1), int64(0)} }}
+	local, replicas, err := WaitAOF(s, 1, 2, 1500*time.Millisecond)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, local)
+	assert.Equal(t, 0, replicas)
+	assert.Equal(t, []Request{Req("WAITAOF", 1, 2, int64(1500))}, s.sent())
+
+	s.handler = func(r Request) interface{} {
+		return ErrResult.New("ERR WAITAOF cannot be used when numlocal is set but appendonly is disabled.")
+	}
+	_, _, err = WaitAOF(s, 1, 0, 0)
+	assert.Error(t, err)
+
+	s.handler = func(r Request) interface{} { return []interface{}{int64(1)} }
+	_, _, err = WaitAOF(s, 1, 0, 0)
+	assert.True(t, IsOfType(err, ErrResponseUnexpected))
+}
+
+func TestMemoryStatsResponse(t *testing.T) {
+	stats, err := MemoryStatsResponse([]interface{}{
+		[]byte("peak.allocated"), int64(1024),
+		[]byte("total.allocated"), int64(900),
+		[]byte("db.0"), []interface{}{
+			[]byte("overhead.hashtable.main"), int64(72),
+			[]byte("overhead.hashtable.expires"), int64(0),
+		},
+		[]byte("peak.percentage"), []byte("87.89"),
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"peak.allocated":  int64(1024),
+		"total.allocated": int64(900),
+		"db.0": map[string]interface{}{
+			"overhead.hashtable.main":    int64(72),
+			"overhead.hashtable.expires": int64(0),
+		},
+		"peak.percentage": "87.89",
+	}, stats)
+
+	_, err = MemoryStatsResponse([]interface{}{[]byte("db.0"), []interface{}{[]byte("odd")}})
+	assert.True(t, IsOfType(err, ErrResponseUnexpected))
+	_, err = MemoryStatsResponse(ErrResult.New("ERR unknown command"))
+	assert.True(t, IsOfType(err, ErrResult))
+}
+
+func TestRoleResponse(t *testing.T) {
+	info, err := RoleResponse([]interface{}{[]byte("master"), int64(3129659), []interface{}{
+		[]interface{}{[]byte("127.0.0.1"), []byte("9001"), []byte("3129242")},
+	}})
+	assert.NoError(t, err)
+	assert.True(t, info.IsMaster())
+	assert.Equal(t, int64(3129659), info.Offset)
+	assert.Equal(t, []ReplicaInfo{{Host: "127.0.0.1", Port: 9001, Offset: 3129242}}, info.Replicas)
+
+	info, err = RoleResponse([]interface{}{[]byte("slave"), []byte("127.0.0.1"), int64(9000),
+		[]byte("connected"), int64(3167038)})
+	assert.NoError(t, err)
+	assert.False(t, info.IsMaster())
+	assert.Equal(t, RoleInfo{Role: "slave", MasterHost: "127.0.0.1", MasterPort: 9000,
+		State: "connected", Offset: 3167038}, info)
+
+	info, err = RoleResponse([]interface{}{[]byte("sentinel"), []interface{}{[]byte("mymaster")}})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"mymaster"}, info.MasterNames)
+
+	_, err = RoleResponse([]interface{}{[]byte("master")})
+	assert.Error(t, err)
+}
+
+func TestConfigGet(t *testing.T) {
+	s := &fakeSender{handler: func(r Request) interface{} {
+		return []interface{}{[]byte("maxmemory"), []byte("0"), []byte("maxmemory-policy"), []byte("noeviction")}
+	}}
+	params, err := ConfigGet(s, "maxmemory*")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"maxmemory": "0", "maxmemory-policy": "noeviction"}, params)
+	assert.Equal(t, []Request{Req("CONFIG GET", "maxmemory*")}, s.sent())
+
+	params, err = ConfigGetResponse([]interface{}{})
+	assert.NoError(t, err)
+	assert.Empty(t, params)
+	_, err = ConfigGetResponse([]interface{}{[]byte("maxmemory")})
+	assert.True(t, IsOfType(err, ErrResponseUnexpected))
+
+	// RESP3 returns CONFIG GET answer as map, which is read as flat list.
+	params, err = ConfigGetResponse(readLines("%1\r\n", "$9\r\nmaxmemory\r\n$1\r\n0\r\n"))
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"maxmemory": "0"}, params)
+
+	ordered, err := ConfigGetOrdered(s, "maxmemory*")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"maxmemory", "maxmemory-policy"}, ordered.Keys())
+	val, ok := ordered.Get("maxmemory-policy")
+	assert.True(t, ok)
+	assert.Equal(t, "noeviction", val)
+	_, ok = ordered.Get("maxclients")
+	assert.False(t, ok)
+
+	// RESP3 map is read as flat array as well, and its order is kept.
+	res := ReadResponse(bufio.NewReader(strings.NewReader("%2\r\n+z\r\n+1\r\n+a\r\n+2\r\n")))
+	ordered, err = OrderedMapResponse(res)
+	assert.NoError(t, err)
+	assert.Equal(t, OrderedMap{{"z", "1"}, {"a", "2"}}, ordered)
+	assert.Equal(t, map[string]string{"z": "1", "a": "2"}, ordered.Map())
+}
+
+func TestConfigSet(t *testing.T) {
+	multi := true
+	s := &fakeSender{handler: func(r Request) interface{} {
+		if len(r.Args) > 2 && !multi {
+			return ErrResult.New("ERR Unknown subcommand or wrong number of arguments for 'set'")
+		}
+		if r.Args[0] == "bad" {
+			return ErrResult.New("ERR Unknown option or number of arguments for CONFIG SET - 'bad'")
+		}
+		return "OK"
+	}}
+
+	assert.NoError(t, ConfigSet(s, map[string]string{"maxmemory": "1gb"}))
+	assert.NoError(t, ConfigSet(s, map[string]string{"maxmemory": "1gb", "maxmemory-policy": "allkeys-lru"}))
+	assert.Equal(t, []Request{
+		Req("CONFIG SET", "maxmemory", "1gb"),
+		Req("CONFIG SET", "maxmemory", "1gb", "maxmemory-policy", "allkeys-lru"),
+	}, s.sent())
+
+	// old server: pairs are sent one by one
+	multi = false
+	s.reqs = nil
+	assert.NoError(t, ConfigSet(s, map[string]string{"maxmemory": "1gb", "maxmemory-policy": "allkeys-lru"}))
+	assert.Equal(t, []Request{
+		Req("CONFIG SET", "maxmemory", "1gb", "maxmemory-policy", "allkeys-lru"),
+		Req("CONFIG SET", "maxmemory", "1gb"),
+		Req("CONFIG SET", "maxmemory-policy", "allkeys-lru"),
+	}, s.sent())
+
+	err := ConfigSet(s, map[string]string{"bad": "1", "maxmemory": "1gb"})
+	assert.True(t, IsOfType(err, ErrResult))
+	err = ConfigSet(s, map[string]string{"bad": "1"})
+	assert.True(t, IsOfType(err, ErrResult))
+}
+
+func TestCommandGetKeys(t *testing.T) {
+	s := &fakeSender{handler: func(r Request) interface{} {
+		// module command: MYMOD.COPY flags src dst
+		if r.Args[0] == "MYMOD.COPY" {
+			if len(r.Args) != 4 {
+				return ErrResult.New("ERR Invalid arguments specified for command")
+			}
+			return []interface{}{r.Args[2], r.Args[3]}
+		}
+		return ErrResult.New("ERR The command has no key arguments")
+	}}
+
+	keys, err := CommandGetKeys(s, Req("MYMOD.COPY", 0, "src", "dst"))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"src", "dst"}, keys)
+	assert.Equal(t, Request{"COMMAND GETKEYS", []interface{}{"MYMOD.COPY", 0, "src", "dst"}}, s.sent()[0])
+
+	keys, err = CommandGetKeys(s, Req("CLIENT LIST"))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{}, keys)
+	assert.Equal(t, []interface{}{"CLIENT", "LIST"}, s.sent()[1].Args)
+
+	c := NewGetKeysCache(s)
+	keys, err = c.Keys(Req("MYMOD.COPY", 0, "a", "b"))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a", "b"}, keys)
+	keys, err = c.Keys(Req("mymod.copy", 1, "c", "d"))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"c", "d"}, keys)
+	// second request of same shape is served from cache
+	assert.Len(t, s.sent(), 3)
+
+	_, err = c.Keys(Req("MYMOD.COPY", 0, "a"))
+	assert.True(t, IsOfType(err, ErrResult))
+	assert.Len(t, s.sent(), 4)
+}
+
+func TestClientInfoResponse(t *testing.T) {
+	line := "id=42 addr=127.0.0.1:51234 laddr=127.0.0.1:6379 fd=8 name=worker-1 age=12 idle=0 flags=N db=3 " +
+		"sub=0 psub=0 ssub=0 multi=-1 qbuf=26 qbuf-free=20448 argv-mem=10 obl=0 oll=0 omem=0 tot-mem=61466 " +
+		"events=r cmd=client|info user=default redir=-1 resp=3\n"
+	info, err := ClientInfoResponse([]byte(line))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(42), info.ID)
+	assert.Equal(t, "127.0.0.1:51234", info.Addr)
+	assert.Equal(t, "127.0.0.1:6379", info.LAddr)
+	assert.Equal(t, "worker-1", info.Name)
+	assert.Equal(t, "default", info.User)
+	assert.Equal(t, 3, info.DB)
+	assert.Equal(t, 3, info.Resp)
+	assert.Equal(t, "N", info.Flags)
+	assert.Equal(t, 12*time.Second, info.Age)
+	assert.Equal(t, -1, info.Multi)
+	assert.Equal(t, "client|info", info.Cmd)
+	assert.Equal(t, "20448", info.Fields["qbuf-free"])
+
+	// redis 6.2 doesn't report resp, and name is empty
+	info = ParseClientInfo("id=7 addr=10.0.0.1:1000 name= db=0 multi=2 cmd=exec")
+	assert.Equal(t, 0, info.Resp)
+	assert.Equal(t, "", info.Name)
+	assert.Equal(t, 2, info.Multi)
+
+	_, err = ClientInfoResponse(ErrResult.New("ERR unknown subcommand 'INFO'"))
+	assert.Error(t, err)
+}
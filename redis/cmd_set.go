@@ -0,0 +1,24 @@
+package redis
+
+// SInterCard returns cardinality of intersection of sets at keys (SINTERCARD, redis >= 7.0).
+// If limit is positive, counting stops when limit is reached.
+// Keys should belong to the same slot in cluster.
+func SInterCard(s Sender, limit int64, keys ...string) (int64, error) {
+	args := make([]interface{}, 0, len(keys)+3)
+	args = append(args, len(keys))
+	for _, key := range keys {
+		args = append(args, key)
+	}
+	if limit > 0 {
+		args = append(args, "LIMIT", limit)
+	}
+	return responseInt(Sync{s}.Send(Request{"SINTERCARD", args}))
+}
+
+// SRandMember returns random members of set (SRANDMEMBER).
+// If count is positive, up to count distinct members are returned. If count is negative, exactly -count
+// members are returned, and the same member could be returned several times.
+// Empty result is returned if count is 0 or key doesn't exist.
+func SRandMember(s Sender, key string, count int) ([]string, error) {
+	return responseStrings(Sync{s}.Do("SRANDMEMBER", key, count))
+}
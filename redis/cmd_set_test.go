@@ -0,0 +1,51 @@
+package redis_test
+
+import (
+	"testing"
+
+	. "github.com/joomcode/redispipe/redis"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSInterCard(t *testing.T) {
+	s := &fakeSender{handler: func(r Request) interface{} { return int64(2) }}
+
+	n, err := SInterCard(s, 0, "a", "b")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), n)
+	_, err = SInterCard(s, 10, "a")
+	assert.NoError(t, err)
+
+	assert.Equal(t, []Request{
+		Req("SINTERCARD", 2, "a", "b"),
+		Req("SINTERCARD", 1, "a", "LIMIT", int64(10)),
+	}, s.sent())
+	key, _ := s.sent()[0].Key()
+	assert.Equal(t, "a", key)
+}
+
+func TestSRandMember(t *testing.T) {
+	s := &fakeSender{handler: func(r Request) interface{} {
+		switch count := r.Args[1].(int); {
+		case count == 0:
+			return []interface{}{}
+		case count < 0:
+			return []interface{}{[]byte("a"), []byte("a"), []byte("a")}
+		}
+		return []interface{}{[]byte("a"), []byte("b")}
+	}}
+
+	members, err := SRandMember(s, "s", 0)
+	assert.NoError(t, err)
+	assert.Empty(t, members)
+
+	members, err = SRandMember(s, "s", 5)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a", "b"}, members)
+
+	members, err = SRandMember(s, "s", -3)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a", "a", "a"}, members)
+
+	assert.Equal(t, Req("SRANDMEMBER", "s", -3), s.sent()[2])
+}
@@ -0,0 +1,68 @@
+package redis
+
+// SortOpts are options for SORT command.
+type SortOpts struct {
+	// By is a pattern for external sort keys, like "weight_*" or "object_*->weight" (hash field).
+	// "nosort" skips sorting.
+	By string
+	// Get are patterns of values to return instead of elements. "#" returns element itself.
+	Get []string
+	// Limit limits number of returned elements.
+	Limit *Limit
+	// Desc sorts in descending order.
+	Desc bool
+	// Alpha sorts lexicographically instead of numerically.
+	Alpha bool
+}
+
+// Args returns arguments to be appended after key.
+func (o SortOpts) Args() []interface{} {
+	var args []interface{}
+	if o.By != "" {
+		args = append(args, "BY", o.By)
+	}
+	args = append(args, o.Limit.Args()...)
+	for _, get := range o.Get {
+		args = append(args, "GET", get)
+	}
+	if o.Desc {
+		args = append(args, "DESC")
+	}
+	if o.Alpha {
+		args = append(args, "ALPHA")
+	}
+	return args
+}
+
+// Sort returns sorted elements of list, set or sorted set (SORT_RO, redis >= 7.0).
+// SORT_RO is readonly, so it could be sent to replica.
+// Missing values referenced by Get patterns are returned as empty strings.
+func Sort(s Sender, key string, opts SortOpts) ([]string, error) {
+	args := append([]interface{}{key}, opts.Args()...)
+	return sortResponse(Sync{s}.Send(Request{"SORT_RO", args}))
+}
+
+// SortStore sorts elements and stores result into dest (SORT ... STORE).
+// It returns number of stored elements.
+func SortStore(s Sender, key string, dest string, opts SortOpts) (int64, error) {
+	args := append([]interface{}{key}, opts.Args()...)
+	args = append(args, "STORE", dest)
+	return responseInt(Sync{s}.Send(Request{"SORT", args}))
+}
+
+func sortResponse(res interface{}) ([]string, error) {
+	arr, err := responseArray(res)
+	if err != nil {
+		return nil, err
+	}
+	strs := make([]string, len(arr))
+	for i, v := range arr {
+		if v == nil {
+			continue
+		}
+		if strs[i], err = responseString(v); err != nil {
+			return nil, unexpectedResponse(res)
+		}
+	}
+	return strs, nil
+}
@@ -0,0 +1,35 @@
+package redis_test
+
+import (
+	"testing"
+
+	. "github.com/joomcode/redispipe/redis"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSortOptsArgs(t *testing.T) {
+	assert.Nil(t, SortOpts{}.Args())
+	assert.Equal(t, []interface{}{"BY", "weight_*", "LIMIT", int64(0), int64(5), "DESC", "ALPHA"},
+		SortOpts{By: "weight_*", Limit: &Limit{Count: 5}, Desc: true, Alpha: true}.Args())
+	assert.Equal(t, []interface{}{"BY", "obj_*->weight", "GET", "#", "GET", "obj_*->name"},
+		SortOpts{By: "obj_*->weight", Get: []string{"#", "obj_*->name"}}.Args())
+}
+
+func TestSort(t *testing.T) {
+	s := &fakeSender{handler: func(r Request) interface{} {
+		if r.Cmd == "SORT" {
+			return int64(2)
+		}
+		return []interface{}{[]byte("1"), []byte("one"), []byte("2"), nil}
+	}}
+
+	res, err := Sort(s, "list", SortOpts{Get: []string{"#", "obj_*->name"}})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"1", "one", "2", ""}, res)
+	assert.Equal(t, Request{"SORT_RO", []interface{}{"list", "GET", "#", "GET", "obj_*->name"}}, s.sent()[0])
+
+	n, err := SortStore(s, "list", "dest", SortOpts{Desc: true})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), n)
+	assert.Equal(t, Request{"SORT", []interface{}{"list", "DESC", "STORE", "dest"}}, s.sent()[1])
+}
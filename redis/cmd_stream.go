@@ -0,0 +1,325 @@
+package redis
+
+import (
+	"strings"
+	"time"
+)
+
+// StreamEntry is an entry of stream.
+type StreamEntry struct {
+	ID string
+	// Fields are field-value pairs of entry. It is nil for entry which were deleted
+	// while it were pending in consumer group.
+	Fields map[string]string
+	// Pairs are the same field-value pairs in order they were added to entry.
+	Pairs OrderedMap
+}
+
+// StreamEntriesResponse parses list of stream entries, as returned by XRANGE, XCLAIM,
+// and inside of XREAD, XREADGROUP and XAUTOCLAIM responses.
+func StreamEntriesResponse(res interface{}) ([]StreamEntry, error) {
+	arr, err := responseArray(res)
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]StreamEntry, len(arr))
+	for i, v := range arr {
+		var ok bool
+		if entries[i], ok = decodeStreamEntry(v); !ok {
+			return nil, unexpectedResponse(res)
+		}
+	}
+	return entries, nil
+}
+
+// decodeStreamEntry decodes single [id, [field, value, ...]] entry.
+func decodeStreamEntry(v interface{}) (StreamEntry, bool) {
+	var entry StreamEntry
+	pair, err := responseArray(v)
+	if err != nil || len(pair) != 2 {
+		return entry, false
+	}
+	if entry.ID, err = responseString(pair[0]); err != nil {
+		return entry, false
+	}
+	if pair[1] == nil {
+		return entry, true
+	}
+	fields, err := OrderedMapResponse(pair[1])
+	if err != nil {
+		return entry, false
+	}
+	entry.Pairs = fields
+	entry.Fields = fields.Map()
+	return entry, true
+}
+
+// StreamInfo is a parsed reply of XINFO STREAM (without FULL modifier, which has different shape).
+type StreamInfo struct {
+	// Length is a number of entries in stream.
+	Length int64
+	// RadixTreeKeys and RadixTreeNodes describe internal representation of stream.
+	RadixTreeKeys  int64
+	RadixTreeNodes int64
+	// Groups is a number of consumer groups.
+	Groups          int64
+	LastGeneratedID string
+	// MaxDeletedEntryID, EntriesAdded and RecordedFirstEntryID are reported by redis >= 7.0.
+	MaxDeletedEntryID    string
+	EntriesAdded         int64
+	RecordedFirstEntryID string
+	// FirstEntry and LastEntry are nil if stream is empty.
+	FirstEntry *StreamEntry
+	LastEntry  *StreamEntry
+}
+
+// GroupInfo is a description of consumer group, as returned by XINFO GROUPS.
+type GroupInfo struct {
+	Name      string
+	Consumers int64
+	// Pending is a number of entries delivered but not acknowledged yet.
+	Pending         int64
+	LastDeliveredID string
+	// EntriesRead and Lag are reported by redis >= 7.0. They are -1 if not reported or unknown.
+	EntriesRead int64
+	Lag         int64
+}
+
+// XInfoStream returns description of stream at key (XINFO STREAM).
+// ErrKeyNotFound is returned if key doesn't exist.
+func XInfoStream(s Sender, key string) (StreamInfo, error) {
+	return StreamInfoResponse(xinfo(s, "STREAM", key))
+}
+
+// XInfoGroups returns consumer groups of stream at key (XINFO GROUPS).
+// ErrKeyNotFound is returned if key doesn't exist.
+func XInfoGroups(s Sender, key string) ([]GroupInfo, error) {
+	return GroupsInfoResponse(xinfo(s, "GROUPS", key))
+}
+
+func xinfo(s Sender, sub, key string) interface{} {
+	res := Sync{s}.Do("XINFO", sub, key)
+	if err := AsErrorx(res); err != nil && err.IsOfType(ErrResult) &&
+		strings.HasPrefix(err.Message(), "ERR no such key") {
+		return ErrKeyNotFound.NewWithNoMessage().WithProperty(EKKey, key)
+	}
+	return res
+}
+
+// StreamInfoResponse parses reply of XINFO STREAM.
+// Unknown fields are skipped, so replies of newer servers are parsed as well.
+func StreamInfoResponse(res interface{}) (StreamInfo, error) {
+	var info StreamInfo
+	arr, err := responseArray(res)
+	if err != nil {
+		return info, err
+	}
+	if len(arr)%2 != 0 {
+		return info, unexpectedResponse(res)
+	}
+	for i := 0; i < len(arr); i += 2 {
+		name, err := responseString(arr[i])
+		if err != nil {
+			return info, unexpectedResponse(res)
+		}
+		v := arr[i+1]
+		switch name {
+		case "length":
+			info.Length, err = responseInt(v)
+		case "radix-tree-keys":
+			info.RadixTreeKeys, err = responseInt(v)
+		case "radix-tree-nodes":
+			info.RadixTreeNodes, err = responseInt(v)
+		case "groups":
+			info.Groups, err = responseInt(v)
+		case "entries-added":
+			info.EntriesAdded, err = responseInt(v)
+		case "last-generated-id":
+			info.LastGeneratedID, err = responseString(v)
+		case "max-deleted-entry-id":
+			info.MaxDeletedEntryID, err = responseString(v)
+		case "recorded-first-entry-id":
+			info.RecordedFirstEntryID, err = responseString(v)
+		case "first-entry", "last-entry":
+			if v == nil {
+				continue
+			}
+			entry, ok := decodeStreamEntry(v)
+			if !ok {
+				return info, unexpectedResponse(res)
+			}
+			if name == "first-entry" {
+				info.FirstEntry = &entry
+			} else {
+				info.LastEntry = &entry
+			}
+		}
+		if err != nil {
+			return info, unexpectedResponse(res)
+		}
+	}
+	return info, nil
+}
+
+// GroupsInfoResponse parses reply of XINFO GROUPS.
+// Unknown fields are skipped, so replies of newer servers are parsed as well.
+func GroupsInfoResponse(res interface{}) ([]GroupInfo, error) {
+	arr, err := responseArray(res)
+	if err != nil {
+		return nil, err
+	}
+	groups := make([]GroupInfo, len(arr))
+	for j, g := range arr {
+		fields, err := responseArray(g)
+		if err != nil || len(fields)%2 != 0 {
+			return nil, unexpectedResponse(res)
+		}
+		info := GroupInfo{EntriesRead: -1, Lag: -1}
+		for i := 0; i < len(fields); i += 2 {
+			name, err := responseString(fields[i])
+			if err != nil {
+				return nil, unexpectedResponse(res)
+			}
+			v := fields[i+1]
+			switch name {
+			case "name":
+				info.Name, err = responseString(v)
+			case "consumers":
+				info.Consumers, err = responseInt(v)
+			case "pending":
+				info.Pending, err = responseInt(v)
+			case "last-delivered-id":
+				info.LastDeliveredID, err = responseString(v)
+			case "entries-read":
+				if v != nil {
+					info.EntriesRead, err = responseInt(v)
+				}
+			case "lag":
+				if v != nil {
+					info.Lag, err = responseInt(v)
+				}
+			}
+			if err != nil {
+				return nil, unexpectedResponse(res)
+			}
+		}
+		groups[j] = info
+	}
+	return groups, nil
+}
+
+// StreamGroup is a set of helpers for consumer group of single stream.
+type StreamGroup struct {
+	S      Sender
+	Stream string
+	Group  string
+}
+
+// CreateGroup creates consumer group (XGROUP CREATE) which will deliver entries after id
+// ("$" means only new entries, "0" means all entries). If mkStream is true, stream is created if it
+// doesn't exist. Redis returns BUSYGROUP error if group already exists.
+func (g StreamGroup) CreateGroup(id string, mkStream bool) error {
+	args := []interface{}{"CREATE", g.Stream, g.Group, id}
+	if mkStream {
+		args = append(args, "MKSTREAM")
+	}
+	return AsError(Sync{g.S}.Send(Request{"XGROUP", args}))
+}
+
+// ReadGroupOpts are options of ReadGroup.
+type ReadGroupOpts struct {
+	// ID - "" or ">" reads new entries, other id reads consumer's pending entries after it.
+	ID string
+	// Count limits number of returned entries (if positive).
+	Count int64
+	// Block - wait for new entries no longer than Block (if positive).
+	// Connection extends its read timeout by this time.
+	Block time.Duration
+	// NoAck - entries are not added to pending list (and so need not to be acknowledged).
+	NoAck bool
+}
+
+// ReadGroup reads entries for consumer (XREADGROUP). Empty result is returned if Block time
+// elapsed without new entries.
+//
+// Note: XREADGROUP is blocking command, so it is allowed only with connection in ScriptMode.
+func (g StreamGroup) ReadGroup(consumer string, opts ReadGroupOpts) ([]StreamEntry, error) {
+	args := []interface{}{"GROUP", g.Group, consumer}
+	if opts.Count > 0 {
+		args = append(args, "COUNT", opts.Count)
+	}
+	if opts.Block > 0 {
+		ms := int64((opts.Block + time.Millisecond - 1) / time.Millisecond)
+		args = append(args, "BLOCK", ms)
+	}
+	if opts.NoAck {
+		args = append(args, "NOACK")
+	}
+	if opts.ID == "" {
+		opts.ID = ">"
+	}
+	args = append(args, "STREAMS", g.Stream, opts.ID)
+	res := Sync{g.S}.Send(Request{"XREADGROUP", args})
+	if res == nil {
+		return nil, nil
+	}
+	streams, err := responseArray(res)
+	if err != nil {
+		return nil, err
+	}
+	stream := streams
+	if len(streams) == 1 {
+		// RESP2 returns list of stream name and entries pairs,
+		// and RESP3 returns map which is read as flat list.
+		if stream, err = responseArray(streams[0]); err != nil {
+			return nil, unexpectedResponse(res)
+		}
+	}
+	if len(stream) != 2 {
+		return nil, unexpectedResponse(res)
+	}
+	return StreamEntriesResponse(stream[1])
+}
+
+// Ack acknowledges processed entries (XACK) and returns number of acknowledged entries.
+func (g StreamGroup) Ack(ids ...string) (int64, error) {
+	args := make([]interface{}, 0, len(ids)+2)
+	args = append(args, g.Stream, g.Group)
+	for _, id := range ids {
+		args = append(args, id)
+	}
+	return responseInt(Sync{g.S}.Send(Request{"XACK", args}))
+}
+
+// AutoClaim transfers to consumer pending entries which are idle at least minIdle,
+// scanning pending list from start id (XAUTOCLAIM, redis >= 6.2). count limits number of
+// scanned entries (if positive).
+// It returns cursor for next call ("0-0" if scan is complete), claimed entries, and ids of
+// entries which were deleted from stream and removed from pending list (redis >= 7.0).
+func (g StreamGroup) AutoClaim(consumer string, minIdle time.Duration, start string, count int64) (
+	next string, entries []StreamEntry, deleted []string, err error) {
+	args := []interface{}{g.Stream, g.Group, consumer, int64(minIdle / time.Millisecond), start}
+	if count > 0 {
+		args = append(args, "COUNT", count)
+	}
+	res := Sync{g.S}.Send(Request{"XAUTOCLAIM", args})
+	arr, err := responseArray(res)
+	if err != nil {
+		return "", nil, nil, err
+	}
+	if len(arr) != 2 && len(arr) != 3 {
+		return "", nil, nil, unexpectedResponse(res)
+	}
+	if next, err = responseString(arr[0]); err != nil {
+		return "", nil, nil, unexpectedResponse(res)
+	}
+	if entries, err = StreamEntriesResponse(arr[1]); err != nil {
+		return "", nil, nil, err
+	}
+	if len(arr) == 3 {
+		if deleted, err = responseStrings(arr[2]); err != nil {
+			return "", nil, nil, unexpectedResponse(res)
+		}
+	}
+	return next, entries, deleted, nil
+}
@@ -0,0 +1,159 @@
+package redis_test
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/joomcode/redispipe/redis"
+	"github.com/stretchr/testify/assert"
+)
+
+func entryReply(id string, fields ...string) interface{} {
+	if fields == nil {
+		return []interface{}{[]byte(id), nil}
+	}
+	arr := make([]interface{}, len(fields))
+	for i, f := range fields {
+		arr[i] = []byte(f)
+	}
+	return []interface{}{[]byte(id), arr}
+}
+
+func TestStreamGroupReadRESP3(t *testing.T) {
+	// RESP3 returns XREADGROUP answer as map of stream name to entries.
+	reply := readLines("%1\r\n", "$6\r\nevents\r\n", "*1\r\n",
+		"*2\r\n$3\r\n1-0\r\n*2\r\n$1\r\na\r\n$1\r\n1\r\n")
+	s := &fakeSender{handler: func(r Request) interface{} { return reply }}
+	g := StreamGroup{S: s, Stream: "events", Group: "workers"}
+
+	entries, err := g.ReadGroup("c1", ReadGroupOpts{})
+	assert.NoError(t, err)
+	assert.Equal(t, []StreamEntry{{ID: "1-0", Fields: map[string]string{"a": "1"}, Pairs: OrderedMap{{"a", "1"}}}}, entries)
+}
+
+func TestStreamGroup(t *testing.T) {
+	s := &fakeSender{handler: func(r Request) interface{} {
+		switch r.Cmd {
+		case "XGROUP":
+			return "OK"
+		case "XREADGROUP":
+			if r.Args[len(r.Args)-1] == "0" {
+				return nil
+			}
+			return []interface{}{
+				[]interface{}{[]byte("events"), []interface{}{
+					entryReply("1-0", "a", "1", "b", "2"),
+					entryReply("2-0"),
+				}},
+			}
+		case "XACK":
+			return int64(len(r.Args) - 2)
+		case "XAUTOCLAIM":
+			return []interface{}{
+				[]byte("3-0"),
+				[]interface{}{entryReply("1-0", "a", "1")},
+				[]interface{}{[]byte("2-0")},
+			}
+		}
+		return nil
+	}}
+	g := StreamGroup{S: s, Stream: "events", Group: "workers"}
+
+	assert.NoError(t, g.CreateGroup("$", true))
+	assert.Equal(t, Req("XGROUP", "CREATE", "events", "workers", "$", "MKSTREAM"), s.sent()[0])
+
+	entries, err := g.ReadGroup("c1", ReadGroupOpts{Count: 10, Block: 1500 * time.Microsecond, NoAck: true})
+	assert.NoError(t, err)
+	assert.Equal(t, []StreamEntry{
+		{ID: "1-0", Fields: map[string]string{"a": "1", "b": "2"}, Pairs: OrderedMap{{"a", "1"}, {"b", "2"}}},
+		{ID: "2-0"},
+	}, entries)
+	assert.Equal(t, Req("XREADGROUP", "GROUP", "workers", "c1", "COUNT", int64(10), "BLOCK", int64(2),
+		"NOACK", "STREAMS", "events", ">"), s.sent()[1])
+
+	entries, err = g.ReadGroup("c1", ReadGroupOpts{ID: "0"})
+	assert.NoError(t, err)
+	assert.Empty(t, entries)
+
+	n, err := g.Ack("1-0", "2-0")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), n)
+	assert.Equal(t, Req("XACK", "events", "workers", "1-0", "2-0"), s.sent()[3])
+
+	next, entries, deleted, err := g.AutoClaim("c2", time.Minute, "0-0", 100)
+	assert.NoError(t, err)
+	assert.Equal(t, "3-0", next)
+	assert.Equal(t, []StreamEntry{{ID: "1-0", Fields: map[string]string{"a": "1"}, Pairs: OrderedMap{{"a", "1"}}}}, entries)
+	assert.Equal(t, []string{"2-0"}, deleted)
+	assert.Equal(t, Req("XAUTOCLAIM", "events", "workers", "c2", int64(60000), "0-0", "COUNT", int64(100)),
+		s.sent()[4])
+
+	// redis 6.2 replies without deleted ids
+	s.handler = func(Request) interface{} {
+		return []interface{}{[]byte("0-0"), []interface{}{}}
+	}
+	next, entries, deleted, err = g.AutoClaim("c2", time.Minute, "0-0", 0)
+	assert.NoError(t, err)
+	assert.Equal(t, "0-0", next)
+	assert.Empty(t, entries)
+	assert.Nil(t, deleted)
+
+	_, err = StreamEntriesResponse([]interface{}{entryReply("1-0", "odd")})
+	assert.Error(t, err)
+}
+
+func TestXInfo(t *testing.T) {
+	s := &fakeSender{handler: func(r Request) interface{} {
+		if r.Args[1] == "missing" {
+			return ErrResult.New("ERR no such key")
+		}
+		if r.Args[0] == "GROUPS" {
+			return []interface{}{
+				[]interface{}{
+					[]byte("name"), []byte("workers"), []byte("consumers"), int64(2),
+					[]byte("pending"), int64(1), []byte("last-delivered-id"), []byte("2-0"),
+					[]byte("entries-read"), int64(2), []byte("lag"), nil,
+				},
+				// redis 6 reply
+				[]interface{}{
+					[]byte("name"), []byte("old"), []byte("consumers"), int64(0),
+					[]byte("pending"), int64(0), []byte("last-delivered-id"), []byte("0-0"),
+				},
+			}
+		}
+		return []interface{}{
+			[]byte("length"), int64(2), []byte("radix-tree-keys"), int64(1),
+			[]byte("radix-tree-nodes"), int64(2), []byte("last-generated-id"), []byte("2-0"),
+			[]byte("max-deleted-entry-id"), []byte("0-0"), []byte("entries-added"), int64(2),
+			[]byte("recorded-first-entry-id"), []byte("1-0"), []byte("groups"), int64(1),
+			[]byte("first-entry"), entryReply("1-0", "a", "1"),
+			[]byte("last-entry"), entryReply("2-0", "b", "2"),
+		}
+	}}
+
+	info, err := XInfoStream(s, "events")
+	assert.NoError(t, err)
+	assert.Equal(t, StreamInfo{
+		Length: 2, RadixTreeKeys: 1, RadixTreeNodes: 2, Groups: 1, LastGeneratedID: "2-0",
+		MaxDeletedEntryID: "0-0", EntriesAdded: 2, RecordedFirstEntryID: "1-0",
+		FirstEntry: &StreamEntry{ID: "1-0", Fields: map[string]string{"a": "1"}, Pairs: OrderedMap{{"a", "1"}}},
+		LastEntry:  &StreamEntry{ID: "2-0", Fields: map[string]string{"b": "2"}, Pairs: OrderedMap{{"b", "2"}}},
+	}, info)
+	assert.Equal(t, Req("XINFO", "STREAM", "events"), s.sent()[0])
+
+	groups, err := XInfoGroups(s, "events")
+	assert.NoError(t, err)
+	assert.Equal(t, []GroupInfo{
+		{Name: "workers", Consumers: 2, Pending: 1, LastDeliveredID: "2-0", EntriesRead: 2, Lag: -1},
+		{Name: "old", LastDeliveredID: "0-0", EntriesRead: -1, Lag: -1},
+	}, groups)
+	assert.Equal(t, Req("XINFO", "GROUPS", "events"), s.sent()[1])
+
+	_, err = XInfoStream(s, "missing")
+	assert.True(t, IsOfType(err, ErrKeyNotFound), "%v", err)
+	_, err = XInfoGroups(s, "missing")
+	assert.True(t, IsOfType(err, ErrKeyNotFound), "%v", err)
+
+	_, err = StreamInfoResponse([]interface{}{[]byte("first-entry"), entryReply("1-0", "odd")})
+	assert.Error(t, err)
+}
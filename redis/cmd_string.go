@@ -0,0 +1,314 @@
+package redis
+
+import (
+	"strconv"
+	"time"
+)
+
+// ExpiryOption is an expiration argument for commands like GETEX.
+// Zero value means "don't touch expiration".
+type ExpiryOption struct {
+	// TTL is relative time to live. It is sent as EX if it is whole seconds, and as PX otherwise.
+	// Negative TTL is rejected with ErrArgumentType (it is not "no expiry": use ExpiryPersist for that).
+	TTL time.Duration
+	// At is absolute expiration time. It is sent as EXAT if it is whole seconds, and as PXAT otherwise.
+	At time.Time
+	// Persist removes time to live.
+	Persist bool
+}
+
+// ExpiryIn returns ExpiryOption with relative time to live.
+func ExpiryIn(ttl time.Duration) ExpiryOption {
+	return ExpiryOption{TTL: ttl}
+}
+
+// ExpiryAt returns ExpiryOption with absolute expiration time.
+func ExpiryAt(at time.Time) ExpiryOption {
+	return ExpiryOption{At: at}
+}
+
+// ExpiryPersist is ExpiryOption which removes time to live.
+var ExpiryPersist = ExpiryOption{Persist: true}
+
+// Args returns arguments to be appended to command.
+func (e ExpiryOption) Args() []interface{} {
+	switch {
+	case e.Persist:
+		return []interface{}{"PERSIST"}
+	case e.TTL > 0:
+		return durationArgs(e.TTL, "EX", "PX")
+	case !e.At.IsZero():
+		return durationArgs(time.Duration(e.At.UnixNano()), "EXAT", "PXAT")
+	}
+	return nil
+}
+
+// durationArgs converts duration to seconds argument if it is whole seconds,
+// and to milliseconds argument otherwise (rounded up, so positive duration never becomes zero).
+func durationArgs(d time.Duration, sec, ms string) []interface{} {
+	if d%time.Second == 0 {
+		return []interface{}{sec, int64(d / time.Second)}
+	}
+	return []interface{}{ms, int64((d + time.Millisecond - 1) / time.Millisecond)}
+}
+
+// responseOptString converts bulk string response which could be nil.
+func responseOptString(res interface{}) (string, bool, error) {
+	if res == nil {
+		return "", false, nil
+	}
+	str, err := responseString(res)
+	if err != nil {
+		return "", false, err
+	}
+	return str, true, nil
+}
+
+// GetDel atomically gets value of key and deletes it (GETDEL, redis >= 6.2).
+// Returned bool is false if key didn't exist.
+func GetDel(s Sender, key string) (string, bool, error) {
+	return responseOptString(Sync{s}.Do("GETDEL", key))
+}
+
+// GetEx atomically gets value of key and changes its expiration (GETEX, redis >= 6.2).
+// Returned bool is false if key didn't exist.
+func GetEx(s Sender, key string, expiry ExpiryOption) (string, bool, error) {
+	if expiry.TTL < 0 {
+		return "", false, ErrArgumentType.New("expiry TTL should not be negative").WithProperty(EKVal, expiry.TTL)
+	}
+	args := append([]interface{}{key}, expiry.Args()...)
+	return responseOptString(Sync{s}.Send(Request{"GETEX", args}))
+}
+
+// Append appends value to string at key (APPEND) and returns new length of string.
+func Append(s Sender, key string, value []byte) (int64, error) {
+	return responseInt(Sync{s}.Do("APPEND", key, value))
+}
+
+// GetRange returns substring of string at key between start and end offsets, both inclusive (GETRANGE).
+// Negative offsets count from the end of string (-1 is last byte).
+// Returned slice is response buffer itself, it is not copied.
+func GetRange(s Sender, key string, start, end int64) ([]byte, error) {
+	return responseBytes(Sync{s}.Do("GETRANGE", key, start, end))
+}
+
+// SetRange overwrites part of string at key starting at offset (SETRANGE) and returns new length of string.
+func SetRange(s Sender, key string, offset int64, value []byte) (int64, error) {
+	return responseInt(Sync{s}.Do("SETRANGE", key, offset, value))
+}
+
+// Incr increments integer at key by one (INCR) and returns new value.
+// ErrOverflow is returned if value would overflow int64.
+func Incr(s Sender, key string) (int64, error) {
+	return responseInt(Sync{s}.Do("INCR", key))
+}
+
+// IncrBy increments integer at key by n (INCRBY) and returns new value.
+// ErrOverflow is returned if value would overflow int64.
+func IncrBy(s Sender, key string, n int64) (int64, error) {
+	return responseInt(Sync{s}.Do("INCRBY", key, n))
+}
+
+// Decr decrements integer at key by one (DECR) and returns new value.
+// ErrOverflow is returned if value would overflow int64.
+func Decr(s Sender, key string) (int64, error) {
+	return responseInt(Sync{s}.Do("DECR", key))
+}
+
+// DecrBy decrements integer at key by n (DECRBY) and returns new value.
+// ErrOverflow is returned if value would overflow int64.
+func DecrBy(s Sender, key string, n int64) (int64, error) {
+	return responseInt(Sync{s}.Do("DECRBY", key, n))
+}
+
+// IncrByFloat increments float at key by n (INCRBYFLOAT) and returns new value.
+// ErrOverflow is returned if value would become NaN or Infinity.
+func IncrByFloat(s Sender, key string, n float64) (float64, error) {
+	str, err := responseString(Sync{s}.Do("INCRBYFLOAT", key, n))
+	if err != nil {
+		return 0, err
+	}
+	v, perr := strconv.ParseFloat(str, 64)
+	if perr != nil {
+		return 0, unexpectedResponse(str)
+	}
+	return v, nil
+}
+
+// LCSOpts are options of LCS command.
+type LCSOpts struct {
+	// Len - return only length of longest common subsequence.
+	Len bool
+	// Idx - return positions of matched ranges in both strings.
+	Idx bool
+	// MinMatchLen - with Idx, return only ranges not shorter than MinMatchLen.
+	MinMatchLen int64
+	// WithMatchLen - with Idx, return length of every matched range.
+	WithMatchLen bool
+}
+
+// Args returns arguments for LCS command after keys.
+func (o LCSOpts) Args() []interface{} {
+	var args []interface{}
+	if o.Len {
+		args = append(args, "LEN")
+	}
+	if o.Idx {
+		args = append(args, "IDX")
+	}
+	if o.MinMatchLen > 0 {
+		args = append(args, "MINMATCHLEN", o.MinMatchLen)
+	}
+	if o.WithMatchLen {
+		args = append(args, "WITHMATCHLEN")
+	}
+	return args
+}
+
+// LCSRange is a range of string, both offsets are inclusive.
+type LCSRange struct {
+	Start, End int64
+}
+
+// LCSMatch is a pair of matched ranges in first and second strings.
+type LCSMatch struct {
+	A, B LCSRange
+	// Len is a length of match. It is returned only with LCSOpts.WithMatchLen.
+	Len int64
+}
+
+// LCSResult is a result of LCS command.
+type LCSResult struct {
+	// Match is the longest common subsequence itself (without Len and Idx options).
+	Match string
+	// Len is a length of longest common subsequence (always set).
+	Len int64
+	// Matches are matched ranges (with Idx option), from last to first as redis returns them.
+	Matches []LCSMatch
+}
+
+// LCS finds longest common subsequence of strings at key1 and key2 (LCS, redis >= 7.0).
+// Keys should belong to the same slot in cluster.
+func LCS(s Sender, key1, key2 string, opts LCSOpts) (LCSResult, error) {
+	args := append([]interface{}{key1, key2}, opts.Args()...)
+	return LCSResponse(Sync{s}.Send(Request{"LCS", args}))
+}
+
+// LCSResponse parses response of LCS command of any form: plain string, length (LEN option)
+// and matches (IDX option, with or without WITHMATCHLEN).
+func LCSResponse(res interface{}) (LCSResult, error) {
+	switch v := res.(type) {
+	case error:
+		return LCSResult{}, v
+	case []byte:
+		return LCSResult{Match: string(v), Len: int64(len(v))}, nil
+	case int64:
+		return LCSResult{Len: v}, nil
+	}
+	arr, err := responseArray(res)
+	if err != nil || len(arr)%2 != 0 {
+		return LCSResult{}, unexpectedResponse(res)
+	}
+	var r LCSResult
+	for i := 0; i < len(arr); i += 2 {
+		name, err := responseString(arr[i])
+		if err != nil {
+			return LCSResult{}, unexpectedResponse(res)
+		}
+		switch name {
+		case "len":
+			if r.Len, err = responseInt(arr[i+1]); err != nil {
+				return LCSResult{}, unexpectedResponse(res)
+			}
+		case "matches":
+			if r.Matches, err = lcsMatches(arr[i+1]); err != nil {
+				return LCSResult{}, unexpectedResponse(res)
+			}
+		}
+	}
+	return r, nil
+}
+
+func lcsMatches(res interface{}) ([]LCSMatch, error) {
+	arr, err := responseArray(res)
+	if err != nil {
+		return nil, err
+	}
+	matches := make([]LCSMatch, len(arr))
+	for i, v := range arr {
+		m, err := responseArray(v)
+		if err != nil || (len(m) != 2 && len(m) != 3) {
+			return nil, unexpectedResponse(v)
+		}
+		if matches[i].A, err = lcsRange(m[0]); err != nil {
+			return nil, err
+		}
+		if matches[i].B, err = lcsRange(m[1]); err != nil {
+			return nil, err
+		}
+		if len(m) == 3 {
+			if matches[i].Len, err = responseInt(m[2]); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return matches, nil
+}
+
+func lcsRange(res interface{}) (LCSRange, error) {
+	arr, err := responseArray(res)
+	if err != nil || len(arr) != 2 {
+		return LCSRange{}, unexpectedResponse(res)
+	}
+	start, err := responseInt(arr[0])
+	if err != nil {
+		return LCSRange{}, err
+	}
+	end, err := responseInt(arr[1])
+	if err != nil {
+		return LCSRange{}, err
+	}
+	return LCSRange{start, end}, nil
+}
+
+// BitUnit is a unit of BitRange offsets (redis >= 7.0).
+type BitUnit string
+
+const (
+	// BitUnitByte - offsets are in bytes (default).
+	BitUnitByte BitUnit = "BYTE"
+	// BitUnitBit - offsets are in bits.
+	BitUnitBit BitUnit = "BIT"
+)
+
+// BitRange is a range argument of BITCOUNT and BITPOS, both offsets are inclusive.
+// Negative offsets count from the end of string.
+type BitRange struct {
+	Start, End int64
+	// Unit is sent only if it is not empty (redis >= 7.0).
+	Unit BitUnit
+}
+
+// Args returns range arguments. Nil range means whole string.
+func (r *BitRange) Args() []interface{} {
+	if r == nil {
+		return nil
+	}
+	if r.Unit == "" {
+		return []interface{}{r.Start, r.End}
+	}
+	return []interface{}{r.Start, r.End, string(r.Unit)}
+}
+
+// BitCount counts set bits in string at key (BITCOUNT). If r is nil, whole string is counted.
+func BitCount(s Sender, key string, r *BitRange) (int64, error) {
+	args := append([]interface{}{key}, r.Args()...)
+	return responseInt(Sync{s}.Send(Request{"BITCOUNT", args}))
+}
+
+// BitPos returns position of first bit set to bit (0 or 1) in string at key (BITPOS).
+// If r is nil, whole string is searched. -1 is returned if bit is not found.
+func BitPos(s Sender, key string, bit int, r *BitRange) (int64, error) {
+	args := append([]interface{}{key, bit}, r.Args()...)
+	return responseInt(Sync{s}.Send(Request{"BITPOS", args}))
+}
@@ -0,0 +1,227 @@
+package redis_test
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	. "github.com/joomcode/redispipe/redis"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExpiryOptionArgs(t *testing.T) {
+	assert.Nil(t, ExpiryOption{}.Args())
+	assert.Equal(t, []interface{}{"PERSIST"}, ExpiryPersist.Args())
+	assert.Equal(t, []interface{}{"EX", int64(10)}, ExpiryIn(10*time.Second).Args())
+	assert.Equal(t, []interface{}{"PX", int64(1500)}, ExpiryIn(1500*time.Millisecond).Args())
+	assert.Equal(t, []interface{}{"PX", int64(1)}, ExpiryIn(time.Microsecond).Args())
+	assert.Equal(t, []interface{}{"EXAT", int64(1600000000)}, ExpiryAt(time.Unix(1600000000, 0)).Args())
+	assert.Equal(t, []interface{}{"PXAT", int64(1600000000500)},
+		ExpiryAt(time.Unix(1600000000, 500*int64(time.Millisecond))).Args())
+}
+
+func TestGetDelGetEx(t *testing.T) {
+	s := &fakeSender{handler: func(r Request) interface{} {
+		if r.Args[0] == "missing" {
+			return nil
+		}
+		return []byte("val")
+	}}
+
+	val, ok, err := GetDel(s, "key")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "val", val)
+
+	_, ok, err = GetDel(s, "missing")
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	val, ok, err = GetEx(s, "key", ExpiryIn(time.Minute))
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "val", val)
+
+	// negative ttl is not sent as "don't touch expiration"
+	_, _, err = GetEx(s, "key", ExpiryIn(-time.Second))
+	assert.True(t, IsOfType(err, ErrArgumentType), "%v", err)
+
+	assert.Equal(t, []Request{
+		Req("GETDEL", "key"),
+		Req("GETDEL", "missing"),
+		Req("GETEX", "key", "EX", int64(60)),
+	}, s.sent())
+}
+
+func TestAppendGetRangeSetRange(t *testing.T) {
+	// range semantics are server's business, only requests and reply decoding are checked.
+	s := &fakeSender{handler: func(r Request) interface{} {
+		if r.Cmd == "GETRANGE" {
+			return []byte("ld!")
+		}
+		return int64(12)
+	}}
+
+	n, err := Append(s, "key", []byte("!"))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(12), n)
+
+	b, err := GetRange(s, "key", -3, -1)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("ld!"), b)
+
+	n, err = SetRange(s, "key", 6, []byte("WORLD"))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(12), n)
+
+	assert.Equal(t, []Request{
+		Req("APPEND", "key", []byte("!")),
+		Req("GETRANGE", "key", int64(-3), int64(-1)),
+		Req("SETRANGE", "key", int64(6), []byte("WORLD")),
+	}, s.sent())
+}
+
+func TestIncrDecr(t *testing.T) {
+	s := &fakeSender{handler: func(r Request) interface{} {
+		switch r.Cmd {
+		case "INCRBYFLOAT":
+			return []byte("10.5")
+		case "DECRBY":
+			return ErrOverflow.New("ERR increment or decrement would overflow")
+		}
+		return int64(11)
+	}}
+
+	n, err := Incr(s, "cnt")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(11), n)
+	n, err = IncrBy(s, "cnt", 10)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(11), n)
+	n, err = Decr(s, "cnt")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(11), n)
+	assert.Equal(t, []Request{Req("INCR", "cnt"), Req("INCRBY", "cnt", int64(10)), Req("DECR", "cnt")}, s.sent())
+
+	_, err = DecrBy(s, "cnt", math.MaxInt64)
+	assert.True(t, IsOfType(err, ErrOverflow))
+
+	f, err := IncrByFloat(s, "f", 0.1)
+	assert.NoError(t, err)
+	assert.Equal(t, 10.5, f)
+	assert.Equal(t, Req("INCRBYFLOAT", "f", 0.1), s.sent()[4])
+
+	s.handler = func(Request) interface{} { return []byte("abc") }
+	_, err = IncrByFloat(s, "f", 1)
+	assert.Error(t, err)
+}
+
+func TestLCS(t *testing.T) {
+	arr := func(v ...interface{}) []interface{} { return v }
+	s := &fakeSender{handler: func(r Request) interface{} {
+		if len(r.Args) == 2 {
+			return []byte("mytext")
+		}
+		if r.Args[2] == "LEN" {
+			return int64(6)
+		}
+		matches := arr(
+			arr(arr(int64(4), int64(7)), arr(int64(5), int64(8))),
+			arr(arr(int64(2), int64(3)), arr(int64(0), int64(1))),
+		)
+		if r.Args[len(r.Args)-1] == "WITHMATCHLEN" {
+			matches = arr(arr(arr(int64(4), int64(7)), arr(int64(5), int64(8)), int64(4)))
+		}
+		return arr([]byte("matches"), matches, []byte("len"), int64(6))
+	}}
+
+	res, err := LCS(s, "k1", "k2", LCSOpts{})
+	assert.NoError(t, err)
+	assert.Equal(t, LCSResult{Match: "mytext", Len: 6}, res)
+
+	res, err = LCS(s, "k1", "k2", LCSOpts{Len: true})
+	assert.NoError(t, err)
+	assert.Equal(t, LCSResult{Len: 6}, res)
+
+	res, err = LCS(s, "k1", "k2", LCSOpts{Idx: true})
+	assert.NoError(t, err)
+	assert.Equal(t, LCSResult{Len: 6, Matches: []LCSMatch{
+		{A: LCSRange{4, 7}, B: LCSRange{5, 8}},
+		{A: LCSRange{2, 3}, B: LCSRange{0, 1}},
+	}}, res)
+
+	res, err = LCS(s, "k1", "k2", LCSOpts{Idx: true, MinMatchLen: 4, WithMatchLen: true})
+	assert.NoError(t, err)
+	assert.Equal(t, LCSResult{Len: 6, Matches: []LCSMatch{
+		{A: LCSRange{4, 7}, B: LCSRange{5, 8}, Len: 4},
+	}}, res)
+
+	assert.Equal(t, []Request{
+		Req("LCS", "k1", "k2"),
+		Req("LCS", "k1", "k2", "LEN"),
+		Req("LCS", "k1", "k2", "IDX"),
+		Req("LCS", "k1", "k2", "IDX", "MINMATCHLEN", int64(4), "WITHMATCHLEN"),
+	}, s.sent())
+
+	// malformed replies
+	for _, bad := range []interface{}{
+		"text",
+		arr([]byte("len")),
+		arr([]byte("matches"), arr(arr(int64(1))), []byte("len"), int64(1)),
+		arr([]byte("matches"), arr(arr(arr(int64(1)), arr(int64(1), int64(2)))), []byte("len"), int64(1)),
+		arr([]byte("len"), []byte("x")),
+	} {
+		_, err = LCSResponse(bad)
+		assert.True(t, IsOfType(err, ErrResponseUnexpected), "%v", bad)
+	}
+	_, err = LCSResponse(ErrResult.New("ERR no such key"))
+	assert.True(t, IsOfType(err, ErrResult))
+}
+
+func TestBitCountBitPos(t *testing.T) {
+	s := &fakeSender{handler: func(r Request) interface{} {
+		if r.Cmd == "BITPOS" {
+			return int64(-1)
+		}
+		return int64(len(r.Args))
+	}}
+
+	assert.Nil(t, (*BitRange)(nil).Args())
+	assert.Equal(t, []interface{}{int64(1), int64(-1)}, (&BitRange{Start: 1, End: -1}).Args())
+	assert.Equal(t, []interface{}{int64(0), int64(7), "BIT"}, (&BitRange{End: 7, Unit: BitUnitBit}).Args())
+
+	n, err := BitCount(s, "key", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), n)
+	n, err = BitCount(s, "key", &BitRange{Start: 1, End: 2})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), n)
+	_, err = BitCount(s, "key", &BitRange{Start: 5, End: 10, Unit: BitUnitBit})
+	assert.NoError(t, err)
+	_, err = BitCount(s, "key", &BitRange{Start: 0, End: -1, Unit: BitUnitByte})
+	assert.NoError(t, err)
+
+	n, err = BitPos(s, "key", 1, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(-1), n)
+	_, err = BitPos(s, "key", 0, &BitRange{Start: 2, End: -1})
+	assert.NoError(t, err)
+	_, err = BitPos(s, "key", 1, &BitRange{Start: 7, End: 15, Unit: BitUnitBit})
+	assert.NoError(t, err)
+
+	assert.Equal(t, []Request{
+		Req("BITCOUNT", "key"),
+		Req("BITCOUNT", "key", int64(1), int64(2)),
+		Req("BITCOUNT", "key", int64(5), int64(10), "BIT"),
+		Req("BITCOUNT", "key", int64(0), int64(-1), "BYTE"),
+		Req("BITPOS", "key", 1),
+		Req("BITPOS", "key", 0, int64(2), int64(-1)),
+		Req("BITPOS", "key", 1, int64(7), int64(15), "BIT"),
+	}, s.sent())
+
+	_, err = BitCount(s, "key", nil)
+	assert.NoError(t, err)
+	s.handler = func(r Request) interface{} { return []byte("x") }
+	_, err = BitPos(s, "key", 1, nil)
+	assert.True(t, IsOfType(err, ErrResponseUnexpected))
+}
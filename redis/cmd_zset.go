@@ -0,0 +1,140 @@
+package redis
+
+import (
+	"math"
+	"strconv"
+)
+
+// ScoreRange is a range of scores for ZRANGEBYSCORE and similar commands.
+// Use math.Inf(-1) and math.Inf(1) for unbounded range ends.
+type ScoreRange struct {
+	Min, Max float64
+	// MinExclusive and MaxExclusive exclude bound from range (rendered with "(" prefix).
+	// They are ignored for infinite bounds.
+	MinExclusive, MaxExclusive bool
+}
+
+// AllScores is a ScoreRange from -inf to +inf.
+var AllScores = ScoreRange{Min: math.Inf(-1), Max: math.Inf(1)}
+
+// Args returns min and max arguments.
+func (r ScoreRange) Args() []interface{} {
+	return []interface{}{scoreBound(r.Min, r.MinExclusive), scoreBound(r.Max, r.MaxExclusive)}
+}
+
+func scoreBound(v float64, exclusive bool) string {
+	switch {
+	case math.IsInf(v, -1):
+		return "-inf"
+	case math.IsInf(v, 1):
+		return "+inf"
+	}
+	s := strconv.FormatFloat(v, 'g', -1, 64)
+	if exclusive {
+		s = "(" + s
+	}
+	return s
+}
+
+// LexRange is a range of members for ZRANGEBYLEX and similar commands.
+// Empty Min means "-" (no lower bound), empty Max means "+" (no upper bound),
+// so zero value covers whole set.
+type LexRange struct {
+	Min, Max string
+	// MinExclusive and MaxExclusive exclude bound from range (rendered with "(" prefix instead of "[").
+	MinExclusive, MaxExclusive bool
+}
+
+// Args returns min and max arguments.
+func (r LexRange) Args() []interface{} {
+	return []interface{}{lexBound(r.Min, r.MinExclusive, "-"), lexBound(r.Max, r.MaxExclusive, "+")}
+}
+
+func lexBound(v string, exclusive bool, unbounded string) string {
+	switch {
+	case v == "":
+		return unbounded
+	case exclusive:
+		return "(" + v
+	}
+	return "[" + v
+}
+
+// Limit is a LIMIT offset count argument.
+type Limit struct {
+	Offset, Count int64
+}
+
+// Args returns arguments to be appended to command. Nil limit gives no arguments.
+func (l *Limit) Args() []interface{} {
+	if l == nil {
+		return nil
+	}
+	return []interface{}{"LIMIT", l.Offset, l.Count}
+}
+
+// ZMember is a sorted set member with its score.
+type ZMember struct {
+	Member string
+	Score  float64
+}
+
+// ZRangeByScore returns members of sorted set with scores in range (ZRANGEBYSCORE).
+// Score of returned members is filled only if withScores is true.
+func ZRangeByScore(s Sender, key string, r ScoreRange, withScores bool, limit *Limit) ([]ZMember, error) {
+	args := append([]interface{}{key}, r.Args()...)
+	if withScores {
+		args = append(args, "WITHSCORES")
+	}
+	args = append(args, limit.Args()...)
+	return ZMembersResponse(Sync{s}.Send(Request{"ZRANGEBYSCORE", args}), withScores)
+}
+
+// ZRangeByLex returns members of sorted set in lexicographical range (ZRANGEBYLEX).
+func ZRangeByLex(s Sender, key string, r LexRange, limit *Limit) ([]string, error) {
+	args := append([]interface{}{key}, r.Args()...)
+	args = append(args, limit.Args()...)
+	return responseStrings(Sync{s}.Send(Request{"ZRANGEBYLEX", args}))
+}
+
+// ZRandMember returns random members of sorted set (ZRANDMEMBER, redis >= 6.2).
+// If count is positive, up to count distinct members are returned. If count is negative, exactly -count
+// members are returned, and the same member could be returned several times.
+// Score of returned members is filled only if withScores is true.
+// Empty result is returned if count is 0 or key doesn't exist.
+func ZRandMember(s Sender, key string, count int, withScores bool) ([]ZMember, error) {
+	args := []interface{}{key, count}
+	if withScores {
+		args = append(args, "WITHSCORES")
+	}
+	return ZMembersResponse(Sync{s}.Send(Request{"ZRANDMEMBER", args}), withScores)
+}
+
+// ZMembersResponse parses response of sorted set range commands.
+// If withScores is true, response is expected to be list of member and score pairs: either flat (RESP2)
+// or of two-element arrays (RESP3).
+func ZMembersResponse(res interface{}, withScores bool) ([]ZMember, error) {
+	if !withScores {
+		strs, err := responseStrings(res)
+		if err != nil {
+			return nil, err
+		}
+		members := make([]ZMember, len(strs))
+		for i, m := range strs {
+			members[i].Member = m
+		}
+		return members, nil
+	}
+	strs, err := responsePairs(res)
+	if err != nil {
+		return nil, err
+	}
+	members := make([]ZMember, len(strs)/2)
+	for i := range members {
+		members[i].Member = strs[2*i]
+		if members[i].Score, err = strconv.ParseFloat(strs[2*i+1], 64); err != nil {
+			return nil, unexpectedResponse(res)
+		}
+	}
+	return members, nil
+}
@@ -0,0 +1,114 @@
+package redis_test
+
+import (
+	"math"
+	"testing"
+
+	. "github.com/joomcode/redispipe/redis"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScoreRangeArgs(t *testing.T) {
+	assert.Equal(t, []interface{}{"-inf", "+inf"}, AllScores.Args())
+	assert.Equal(t, []interface{}{"1", "2.5"}, ScoreRange{Min: 1, Max: 2.5}.Args())
+	assert.Equal(t, []interface{}{"(1", "(2.5"},
+		ScoreRange{Min: 1, Max: 2.5, MinExclusive: true, MaxExclusive: true}.Args())
+	assert.Equal(t, []interface{}{"(-5", "+inf"},
+		ScoreRange{Min: -5, Max: math.Inf(1), MinExclusive: true, MaxExclusive: true}.Args())
+	assert.Equal(t, []interface{}{"-inf", "(0"},
+		ScoreRange{Min: math.Inf(-1), Max: 0, MinExclusive: true, MaxExclusive: true}.Args())
+	assert.Equal(t, []interface{}{"1e+21", "1e-07"}, ScoreRange{Min: 1e21, Max: 1e-7}.Args())
+}
+
+func TestLexRangeArgs(t *testing.T) {
+	assert.Equal(t, []interface{}{"-", "+"}, LexRange{}.Args())
+	assert.Equal(t, []interface{}{"[a", "[c"}, LexRange{Min: "a", Max: "c"}.Args())
+	assert.Equal(t, []interface{}{"(a", "(c"},
+		LexRange{Min: "a", Max: "c", MinExclusive: true, MaxExclusive: true}.Args())
+	assert.Equal(t, []interface{}{"-", "(c"}, LexRange{Max: "c", MaxExclusive: true}.Args())
+	assert.Equal(t, []interface{}{"[a", "+"}, LexRange{Min: "a"}.Args())
+}
+
+func TestZRangeByScore(t *testing.T) {
+	s := &fakeSender{handler: func(r Request) interface{} {
+		if len(r.Args) > 3 && r.Args[3] == "WITHSCORES" {
+			return []interface{}{[]byte("a"), []byte("1"), []byte("b"), []byte("inf")}
+		}
+		return []interface{}{[]byte("a"), []byte("b")}
+	}}
+
+	members, err := ZRangeByScore(s, "z", ScoreRange{Min: 1, Max: math.Inf(1)}, true, &Limit{Offset: 0, Count: 10})
+	assert.NoError(t, err)
+	assert.Equal(t, []ZMember{{"a", 1}, {"b", math.Inf(1)}}, members)
+	assert.Equal(t, []interface{}{"z", "1", "+inf", "WITHSCORES", "LIMIT", int64(0), int64(10)}, s.sent()[0].Args)
+
+	members, err = ZRangeByScore(s, "z", AllScores, false, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []ZMember{{Member: "a"}, {Member: "b"}}, members)
+	assert.Equal(t, []interface{}{"z", "-inf", "+inf"}, s.sent()[1].Args)
+
+	strs, err := ZRangeByLex(s, "z", LexRange{Min: "a", MaxExclusive: true, Max: "c"}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a", "b"}, strs)
+	assert.Equal(t, Request{"ZRANGEBYLEX", []interface{}{"z", "[a", "(c"}}, s.sent()[2])
+
+	_, err = ZMembersResponse([]interface{}{[]byte("a")}, true)
+	assert.Error(t, err)
+}
+
+func TestZRandMember(t *testing.T) {
+	s := &fakeSender{handler: func(r Request) interface{} {
+		count := r.Args[1].(int)
+		withScores := len(r.Args) == 3
+		switch {
+		case count == 0:
+			return []interface{}{}
+		case count < 0 && withScores:
+			return []interface{}{[]byte("a"), []byte("1"), []byte("a"), []byte("1")}
+		case withScores:
+			return []interface{}{[]byte("a"), []byte("1"), []byte("b"), []byte("2.5")}
+		}
+		return []interface{}{[]byte("a"), []byte("b")}
+	}}
+
+	members, err := ZRandMember(s, "z", 0, true)
+	assert.NoError(t, err)
+	assert.Empty(t, members)
+
+	members, err = ZRandMember(s, "z", 2, false)
+	assert.NoError(t, err)
+	assert.Equal(t, []ZMember{{Member: "a"}, {Member: "b"}}, members)
+
+	members, err = ZRandMember(s, "z", 2, true)
+	assert.NoError(t, err)
+	assert.Equal(t, []ZMember{{"a", 1}, {"b", 2.5}}, members)
+
+	members, err = ZRandMember(s, "z", -2, true)
+	assert.NoError(t, err)
+	assert.Equal(t, []ZMember{{"a", 1}, {"a", 1}}, members)
+
+	assert.Equal(t, []Request{
+		Req("ZRANDMEMBER", "z", 0, "WITHSCORES"),
+		Req("ZRANDMEMBER", "z", 2),
+		Req("ZRANDMEMBER", "z", 2, "WITHSCORES"),
+		Req("ZRANDMEMBER", "z", -2, "WITHSCORES"),
+	}, s.sent())
+}
+
+func TestZMembersResponseRESP3(t *testing.T) {
+	// RESP3 returns member and score pairs as two-element arrays, and score as double.
+	res := readLines("*2\r\n", "*2\r\n$1\r\na\r\n,1\r\n", "*2\r\n$1\r\nb\r\n,inf\r\n")
+	members, err := ZMembersResponse(res, true)
+	assert.NoError(t, err)
+	assert.Equal(t, []ZMember{{"a", 1}, {"b", math.Inf(1)}}, members)
+
+	// single pair of ZPOPMIN without count is flat.
+	members, err = ZMembersResponse(readLines("*2\r\n$1\r\na\r\n,1.5\r\n"), true)
+	assert.NoError(t, err)
+	assert.Equal(t, []ZMember{{"a", 1.5}}, members)
+
+	_, err = ZMembersResponse(readLines("*1\r\n", "*1\r\n$1\r\na\r\n"), true)
+	assert.Error(t, err)
+	_, err = ZMembersResponse(readLines("*1\r\n$1\r\na\r\n"), true)
+	assert.Error(t, err)
+}
@@ -0,0 +1,54 @@
+package redis
+
+import "encoding/json"
+
+// Codec serializes values stored with SetValue and read with GetValue.
+// Encoded value is stored as bulk string, so it could be arbitrary binary data.
+type Codec interface {
+	Encode(v interface{}) ([]byte, error)
+	Decode(data []byte, v interface{}) error
+}
+
+// JSONCodec is a Codec which uses encoding/json.
+type JSONCodec struct{}
+
+// Encode implements Codec.Encode
+func (JSONCodec) Encode(v interface{}) ([]byte, error) { return json.Marshal(v) }
+
+// Decode implements Codec.Decode
+func (JSONCodec) Decode(data []byte, v interface{}) error { return json.Unmarshal(data, v) }
+
+// SetValue encodes v with codec and stores it at key (SET).
+// Encoding failure is returned as ErrValueEncode.
+func SetValue(s Sender, codec Codec, key string, v interface{}) error {
+	data, err := codec.Encode(v)
+	if err != nil {
+		return ErrValueEncode.Wrap(err, "value for key %q is not encoded", key)
+	}
+	res := Sync{s}.Do("SET", key, data)
+	if err := AsError(res); err != nil {
+		return err
+	}
+	if res != "OK" {
+		return unexpectedResponse(res)
+	}
+	return nil
+}
+
+// GetValue reads value at key (GET) and decodes it into dest with codec.
+// Returned bool is false if key doesn't exist (dest is not modified then).
+// Decoding failure is returned as ErrValueDecode.
+func GetValue(s Sender, codec Codec, key string, dest interface{}) (bool, error) {
+	res := Sync{s}.Do("GET", key)
+	if res == nil {
+		return false, nil
+	}
+	data, err := responseBytes(res)
+	if err != nil {
+		return false, err
+	}
+	if err := codec.Decode(data, dest); err != nil {
+		return true, ErrValueDecode.Wrap(err, "value of key %q is not decoded", key)
+	}
+	return true, nil
+}
@@ -0,0 +1,79 @@
+package redis_test
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/gob"
+	"strconv"
+	"testing"
+
+	. "github.com/joomcode/redispipe/redis"
+	"github.com/stretchr/testify/assert"
+)
+
+type gobCodec struct{}
+
+func (gobCodec) Encode(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	err := gob.NewEncoder(&buf).Encode(v)
+	return buf.Bytes(), err
+}
+
+func (gobCodec) Decode(data []byte, v interface{}) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
+
+type codecValue struct {
+	Name string
+	Data []byte
+	N    int
+}
+
+func TestSetGetValue(t *testing.T) {
+	// handler passes requests and responses through wire format, like real connection does.
+	store := map[string][]byte{}
+	s := &fakeSender{handler: func(r Request) interface{} {
+		buf, err := AppendRequest(nil, r)
+		if err != nil {
+			return err
+		}
+		args := ReadResponse(bufio.NewReader(bytes.NewReader(buf))).([]interface{})
+		key := string(args[1].([]byte))
+		switch r.Cmd {
+		case "SET":
+			store[key] = args[2].([]byte)
+			return "OK"
+		case "GET":
+			v, ok := store[key]
+			if !ok {
+				return ReadResponse(bufio.NewReader(bytes.NewReader([]byte("$-1\r\n"))))
+			}
+			resp := "$" + strconv.Itoa(len(v)) + "\r\n" + string(v) + "\r\n"
+			return ReadResponse(bufio.NewReader(bytes.NewReader([]byte(resp))))
+		}
+		return nil
+	}}
+
+	for _, codec := range []Codec{JSONCodec{}, gobCodec{}} {
+		v := codecValue{Name: "bin", Data: []byte{0, '\r', '\n', 0xff}, N: 42}
+		assert.NoError(t, SetValue(s, codec, "val", v))
+
+		var got codecValue
+		ok, err := GetValue(s, codec, "val", &got)
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		assert.Equal(t, v, got)
+
+		ok, err = GetValue(s, codec, "missing", &got)
+		assert.NoError(t, err)
+		assert.False(t, ok)
+	}
+
+	store["broken"] = []byte("{")
+	ok, err := GetValue(s, JSONCodec{}, "broken", &codecValue{})
+	assert.True(t, ok)
+	assert.True(t, IsOfType(err, ErrValueDecode))
+
+	err = SetValue(s, JSONCodec{}, "func", func() {})
+	assert.True(t, IsOfType(err, ErrValueEncode))
+}
@@ -0,0 +1,55 @@
+package redis
+
+import "strings"
+
+// arity is a static table of command arities in COMMAND INFO format: number of arguments including
+// command name; negative arity is a minimal number of arguments.
+var arity = map[string]int{}
+
+func addArity(n int, cmds string) {
+	for _, cmd := range strings.Split(cmds, " ") {
+		arity[cmd] = n
+	}
+}
+
+func init() {
+	addArity(-1, "PING")
+	addArity(2, "GET GETDEL STRLEN INCR DECR TYPE TTL PTTL PERSIST DUMP HLEN HGETALL HKEYS HVALS "+
+		"LLEN SCARD SMEMBERS ZCARD XLEN ECHO SELECT")
+	addArity(-2, "GETEX MGET BITCOUNT DEL UNLINK EXISTS TOUCH HRANDFIELD LPOP RPOP SPOP SRANDMEMBER "+
+		"SINTER SUNION SDIFF ZPOPMIN ZPOPMAX ZRANDMEMBER PFADD PFCOUNT PFMERGE GEOPOS GEOHASH WATCH")
+	addArity(3, "SETNX GETSET APPEND INCRBY DECRBY INCRBYFLOAT GETBIT RENAME RENAMENX HGET HEXISTS HSTRLEN "+
+		"LINDEX RPOPLPUSH SISMEMBER ZSCORE PUBLISH SPUBLISH")
+	addArity(-3, "SET MSET MSETNX BITPOS EXPIRE PEXPIRE EXPIREAT PEXPIREAT COPY HMGET HDEL "+
+		"LPUSH RPUSH LPUSHX RPUSHX BLPOP BRPOP SADD SREM SMISMEMBER SINTERSTORE SUNIONSTORE SDIFFSTORE "+
+		"SINTERCARD ZREM ZMSCORE ZRANK ZREVRANK XDEL EVAL EVALSHA FCALL LCS")
+	addArity(4, "SETEX PSETEX GETRANGE SETRANGE SETBIT HSETNX HINCRBY HINCRBYFLOAT LSET LRANGE LTRIM LREM "+
+		"BRPOPLPUSH SMOVE ZINCRBY ZCOUNT ZLEXCOUNT")
+	addArity(-4, "BITOP RESTORE RESTORE-ASKING HSET HMSET ZADD ZRANGE ZREVRANGE ZRANGEBYSCORE ZREVRANGEBYSCORE "+
+		"ZUNIONSTORE ZINTERSTORE XRANGE XREVRANGE XTRIM XACK GEODIST")
+	addArity(5, "LINSERT LMOVE")
+	addArity(-5, "XADD GEOADD HPERSIST HTTL HPTTL")
+	addArity(6, "BLMOVE")
+	addArity(-6, "MIGRATE HEXPIRE HPEXPIRE")
+}
+
+// CheckArity checks number of request arguments against command arity.
+// Arity is looked up in custom table first (keys are upper-case command names, values are in COMMAND INFO
+// format: positive is exact number of arguments including command name, negative is minimal number),
+// and then in static table of common commands. Unknown commands are not checked.
+// It catches forgotten arguments before request is sent.
+func CheckArity(req Request, custom map[string]int) error {
+	cmd := strings.ToUpper(req.Cmd)
+	n, ok := custom[cmd]
+	if !ok {
+		if n, ok = arity[cmd]; !ok {
+			return nil
+		}
+	}
+	got := len(req.Args) + 1
+	if n >= 0 && got != n || n < 0 && got < -n {
+		return ErrArgumentCount.New("wrong number of arguments for %s", req.Cmd).
+			WithProperty(EKRequest, req)
+	}
+	return nil
+}
@@ -0,0 +1,35 @@
+package redis_test
+
+import (
+	"testing"
+
+	. "github.com/joomcode/redispipe/redis"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckArity(t *testing.T) {
+	assert.NoError(t, CheckArity(Req("GET", "key"), nil))
+	assert.NoError(t, CheckArity(Req("get", "key"), nil))
+	assert.NoError(t, CheckArity(Req("PING"), nil))
+	assert.NoError(t, CheckArity(Req("SET", "key", "val", "EX", 10), nil))
+	assert.NoError(t, CheckArity(Req("DEL", "a", "b", "c"), nil))
+	// unknown commands are not checked
+	assert.NoError(t, CheckArity(Req("MYMODULE.CMD"), nil))
+
+	for _, req := range []Request{
+		Req("GET"),
+		Req("GET", "a", "b"),
+		Req("SET", "key"),
+		Req("DEL"),
+		Req("HSET", "key", "field"),
+		Req("LMOVE", "src", "dst", "LEFT"),
+	} {
+		err := CheckArity(req, nil)
+		assert.True(t, IsOfType(err, ErrArgumentCount), "%v", req)
+	}
+
+	custom := map[string]int{"MYMODULE.CMD": -2, "GET": -2}
+	assert.True(t, IsOfType(CheckArity(Req("MYMODULE.CMD"), custom), ErrArgumentCount))
+	assert.NoError(t, CheckArity(Req("MYMODULE.CMD", "key"), custom))
+	assert.NoError(t, CheckArity(Req("GET", "a", "b"), custom))
+}
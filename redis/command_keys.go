@@ -0,0 +1,233 @@
+package redis
+
+import (
+	"strconv"
+	"strings"
+)
+
+// keyRange describes positions of keys in request arguments (command name is not counted).
+// Negative last counts from the end (-1 is last argument).
+type keyRange struct {
+	first, last, step int
+}
+
+var keyRanges = map[string]keyRange{}
+
+func addKeyRange(r keyRange, cmds string) {
+	for _, cmd := range strings.Split(cmds, " ") {
+		keyRanges[cmd] = r
+	}
+}
+
+func init() {
+	addKeyRange(keyRange{0, -1, 1}, "DEL UNLINK EXISTS TOUCH MGET WATCH SINTER SUNION SDIFF "+
+		"SINTERSTORE SUNIONSTORE SDIFFSTORE PFCOUNT PFMERGE")
+	addKeyRange(keyRange{0, -1, 2}, "MSET MSETNX")
+	addKeyRange(keyRange{0, 1, 1}, "RENAME RENAMENX RPOPLPUSH BRPOPLPUSH SMOVE LMOVE BLMOVE COPY "+
+		"GEOSEARCHSTORE ZRANGESTORE LCS")
+	addKeyRange(keyRange{0, -2, 1}, "BLPOP BRPOP BZPOPMIN BZPOPMAX")
+	addKeyRange(keyRange{1, -1, 1}, "BITOP")
+
+	names := strings.Split("EVAL EVALSHA EVAL_RO EVALSHA_RO FCALL FCALL_RO ZUNION ZINTER ZDIFF SINTERCARD "+
+		"ZINTERCARD LMPOP ZMPOP BLMPOP BZMPOP ZUNIONSTORE ZINTERSTORE ZDIFFSTORE XREAD XREADGROUP MIGRATE "+
+		"SORT SORT_RO GEORADIUS GEORADIUSBYMEMBER", " ")
+	for cmd := range keyRanges {
+		names = append(names, cmd)
+	}
+	multiKey = makeSet(names)
+}
+
+// multiKey are commands which could have more than one key, or whose first key is not the first argument.
+var multiKey []uint64
+
+// irregularKeys are commands whose first key is not the first argument.
+var irregularKeys = makeSet(strings.Split("EVAL EVALSHA EVAL_RO EVALSHA_RO FCALL FCALL_RO BITOP "+
+	"ZUNION ZINTER ZDIFF SINTERCARD ZINTERCARD LMPOP ZMPOP BLMPOP BZMPOP XREAD XREADGROUP MIGRATE "+
+	"OBJECT MEMORY XINFO XGROUP", " "))
+
+// keyless commands has no keys at all.
+var keyless = makeSet(strings.Split("PING ECHO INFO TIME DBSIZE FLUSHALL FLUSHDB CONFIG CLIENT CLUSTER "+
+	"COMMAND SCRIPT FUNCTION PUBLISH SPUBLISH PUBSUB SELECT AUTH HELLO MULTI EXEC DISCARD UNWATCH RESET "+
+	"RANDOMKEY SCAN KEYS WAIT WAITAOF LASTSAVE SLOWLOG LATENCY ROLE READONLY READWRITE ASKING QUIT SAVE "+
+	"BGSAVE BGREWRITEAOF SHUTDOWN MONITOR SWAPDB DEBUG ACL MODULE REPLICAOF SLAVEOF FAILOVER", " "))
+
+// CommandKeys returns all keys of request.
+// Key positions are taken from static table, which includes commands with irregular key positions
+// (EVAL, FCALL, ZUNIONSTORE, XREAD, MIGRATE, GEORADIUS with STORE, SORT with STORE, OBJECT, MEMORY USAGE etc).
+// Unknown command is assumed to have single key as first argument.
+// Error is returned if key argument could not be converted to string, or if number of keys is malformed.
+func CommandKeys(req Request) ([]string, error) {
+	idx, err := keyIndexes(req)
+	if err != nil {
+		return nil, err
+	}
+	if len(idx) == 0 {
+		return nil, nil
+	}
+	keys := make([]string, len(idx))
+	for i, n := range idx {
+		var ok bool
+		if keys[i], ok = ArgToString(req.Args[n]); !ok {
+			return nil, ErrArgumentType.New("key should be string").
+				WithProperty(EKRequest, req).WithProperty(EKArgPos, n).WithProperty(EKVal, req.Args[n])
+		}
+	}
+	return keys, nil
+}
+
+// MultiKeyCommand reports whether cmd could have more than one key, or keys at irregular positions,
+// so CommandKeys should be used to find them. Other commands have at most one key, which is returned
+// by Request.Key. It doesn't allocate.
+func MultiKeyCommand(cmd string) bool {
+	if space := strings.IndexByte(cmd, ' '); space >= 0 {
+		cmd = cmd[:space]
+	}
+	return checkSet(cmd, multiKey)
+}
+
+// MapKeys returns copy of request with every key replaced by f(key).
+// Key positions are found as in CommandKeys. Request is returned unchanged if its keys could not be found,
+// so malformed request is reported when it is checked before sending.
+// It is useful for Opts.RequestRewriter of redisconn, for example, to prefix keys with tenant id.
+func MapKeys(req Request, f func(key string) string) Request {
+	idx, err := keyIndexes(req)
+	if err != nil || len(idx) == 0 {
+		return req
+	}
+	args := make([]interface{}, len(req.Args))
+	copy(args, req.Args)
+	for _, n := range idx {
+		if key, ok := ArgToString(args[n]); ok {
+			args[n] = f(key)
+		}
+	}
+	return Request{req.Cmd, args}
+}
+
+// splitCommand returns upper-cased command name, subcommand (if any) and
+// offset of first argument after subcommand.
+func splitCommand(req Request) (string, string, int) {
+	cmd := strings.ToUpper(req.Cmd)
+	if space := strings.IndexByte(cmd, ' '); space >= 0 {
+		return cmd[:space], cmd[space+1:], 0
+	}
+	switch cmd {
+	case "OBJECT", "MEMORY", "XINFO", "XGROUP":
+		if len(req.Args) > 0 {
+			sub, _ := ArgToString(req.Args[0])
+			return cmd, strings.ToUpper(sub), 1
+		}
+	}
+	return cmd, "", 0
+}
+
+func keyIndexes(req Request) ([]int, error) {
+	cmd, sub, off := splitCommand(req)
+	args := req.Args[off:]
+	shift := func(idx []int) []int {
+		for i := range idx {
+			idx[i] += off
+		}
+		return idx
+	}
+
+	switch cmd {
+	case "OBJECT", "MEMORY", "XINFO", "XGROUP":
+		switch sub {
+		case "HELP", "STATS", "DOCTOR", "MALLOC-STATS", "PURGE":
+			return nil, nil
+		}
+		if len(args) == 0 {
+			return nil, nil
+		}
+		return shift([]int{0}), nil
+	case "EVAL", "EVALSHA", "EVAL_RO", "EVALSHA_RO", "FCALL", "FCALL_RO":
+		return numkeysIndexes(req, args, 1, shift)
+	case "ZUNION", "ZINTER", "ZDIFF", "SINTERCARD", "ZINTERCARD", "LMPOP", "ZMPOP":
+		return numkeysIndexes(req, args, 0, shift)
+	case "BLMPOP", "BZMPOP":
+		return numkeysIndexes(req, args, 1, shift)
+	case "ZUNIONSTORE", "ZINTERSTORE", "ZDIFFSTORE":
+		if len(args) == 0 {
+			return nil, nil
+		}
+		idx, err := numkeysIndexes(req, args, 1, shift)
+		return append([]int{off}, idx...), err
+	case "XREAD", "XREADGROUP":
+		for i, arg := range args {
+			if s, _ := ArgToString(arg); strings.EqualFold(s, "STREAMS") {
+				n := (len(args) - i - 1) / 2
+				idx := make([]int, n)
+				for j := range idx {
+					idx[j] = i + 1 + j
+				}
+				return shift(idx), nil
+			}
+		}
+		return nil, nil
+	case "MIGRATE":
+		if len(args) > 2 {
+			if s, _ := ArgToString(args[2]); s != "" {
+				return shift([]int{2}), nil
+			}
+		}
+		for i, arg := range args {
+			if s, _ := ArgToString(arg); strings.EqualFold(s, "KEYS") {
+				idx := make([]int, 0, len(args)-i-1)
+				for j := i + 1; j < len(args); j++ {
+					idx = append(idx, j)
+				}
+				return shift(idx), nil
+			}
+		}
+		return nil, nil
+	case "SORT", "SORT_RO", "GEORADIUS", "GEORADIUSBYMEMBER":
+		if len(args) == 0 {
+			return nil, nil
+		}
+		idx := []int{0}
+		for i := 1; i < len(args)-1; i++ {
+			s, _ := ArgToString(args[i])
+			if strings.EqualFold(s, "STORE") || strings.EqualFold(s, "STOREDIST") {
+				idx = append(idx, i+1)
+				i++
+			}
+		}
+		return shift(idx), nil
+	}
+
+	if checkSet(cmd, keyless) {
+		return nil, nil
+	}
+	r, ok := keyRanges[cmd]
+	if !ok {
+		r = keyRange{0, 0, 1}
+	}
+	last := r.last
+	if last < 0 {
+		last += len(args)
+	}
+	var idx []int
+	for i := r.first; i <= last && i < len(args); i += r.step {
+		idx = append(idx, i)
+	}
+	return shift(idx), nil
+}
+
+// numkeysIndexes returns indexes of keys for commands with explicit number of keys argument at position pos.
+func numkeysIndexes(req Request, args []interface{}, pos int, shift func([]int) []int) ([]int, error) {
+	if len(args) <= pos {
+		return nil, nil
+	}
+	s, _ := ArgToString(args[pos])
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 0 || pos+1+n > len(args) {
+		return nil, ErrArgumentType.New("wrong number of keys").
+			WithProperty(EKRequest, req).WithProperty(EKArgPos, pos).WithProperty(EKVal, args[pos])
+	}
+	idx := make([]int, n)
+	for i := range idx {
+		idx[i] = pos + 1 + i
+	}
+	return shift(idx), nil
+}
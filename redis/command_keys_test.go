@@ -0,0 +1,93 @@
+package redis_test
+
+import (
+	"testing"
+
+	. "github.com/joomcode/redispipe/redis"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCommandKeys(t *testing.T) {
+	check := func(req Request, expect ...string) {
+		keys, err := CommandKeys(req)
+		assert.NoError(t, err, req.String())
+		if len(expect) == 0 {
+			assert.Empty(t, keys, req.String())
+		} else {
+			assert.Equal(t, expect, keys, req.String())
+		}
+	}
+
+	check(Req("GET", "a"), "a")
+	check(Req("set", "a", 1), "a")
+	check(Req("PING"))
+	check(Req("CLIENT SETNAME", "name"))
+	check(Req("MGET", "a", "b", "c"), "a", "b", "c")
+	check(Req("MSET", "a", 1, "b", 2), "a", "b")
+	check(Req("BLPOP", "a", "b", 0), "a", "b")
+	check(Req("BITOP", "AND", "dst", "a", "b"), "dst", "a", "b")
+	check(Req("RENAME", "a", "b"), "a", "b")
+	check(Req("EVAL", "return 1", 2, "a", "b", "argv"), "a", "b")
+	check(Req("EVALSHA", "abcd", 0, "argv"))
+	check(Req("FCALL", "fn", 1, "a", "argv"), "a")
+	check(Req("ZUNIONSTORE", "dst", 2, "a", "b", "WEIGHTS", 1, 2), "dst", "a", "b")
+	check(Req("ZINTER", 2, "a", "b"), "a", "b")
+	check(Req("BZMPOP", 1, 2, "a", "b", "MIN"), "a", "b")
+	check(Req("XREAD", "COUNT", 2, "STREAMS", "s1", "s2", "0", "0"), "s1", "s2")
+	check(Req("XREADGROUP", "GROUP", "g", "c", "STREAMS", "s1", ">"), "s1")
+	check(Req("MIGRATE", "host", 6379, "a", 0, 1000), "a")
+	check(Req("MIGRATE", "host", 6379, "", 0, 1000, "KEYS", "a", "b"), "a", "b")
+	check(Req("GEORADIUS", "geo", 1, 2, 3, "km", "STORE", "dst"), "geo", "dst")
+	check(Req("SORT", "list", "LIMIT", 0, 10, "STORE", "dst"), "list", "dst")
+	check(Req("OBJECT", "ENCODING", "a"), "a")
+	check(Req("OBJECT ENCODING", "a"), "a")
+	check(Req("MEMORY", "USAGE", "a", "SAMPLES", 5), "a")
+	check(Req("MEMORY STATS"))
+	check(Req("XINFO", "STREAM", "s"), "s")
+	check(Req("XGROUP CREATE", "s", "g", "$"), "s")
+
+	_, err := CommandKeys(Req("EVAL", "return 1", 3, "a"))
+	assert.Error(t, err)
+	_, err = CommandKeys(Req("GET", []int{1}))
+	assert.Error(t, err)
+}
+
+func TestMultiKeyCommand(t *testing.T) {
+	for _, cmd := range []string{"MSET", "mget", "EVAL", "ZUNIONSTORE", "SORT", "XREAD", "BITOP", "RENAME"} {
+		assert.True(t, MultiKeyCommand(cmd), cmd)
+	}
+	for _, cmd := range []string{"GET", "set", "HSET", "PING", "OBJECT ENCODING", "XINFO"} {
+		assert.False(t, MultiKeyCommand(cmd), cmd)
+	}
+}
+
+func TestRequestKeyIrregular(t *testing.T) {
+	k, ok := Req("MEMORY", "USAGE", "a").Key()
+	assert.Equal(t, "a", k)
+	assert.True(t, ok)
+
+	k, ok = Req("XREAD", "COUNT", 1, "STREAMS", "s", "0").Key()
+	assert.Equal(t, "s", k)
+	assert.True(t, ok)
+
+	k, ok = Req("EVAL", "return 1", 0, "argv").Key()
+	assert.False(t, ok)
+	assert.Equal(t, "RANDOMKEY", k)
+
+	_, ok = Req("EVAL", "return 1", 3, "a").Key()
+	assert.False(t, ok)
+}
+
+func TestMapKeys(t *testing.T) {
+	prefix := func(key string) string { return "t1:" + key }
+	req := Req("MSET", "a", 1, []byte("b"), 2)
+	assert.Equal(t, Req("MSET", "t1:a", 1, "t1:b", 2), MapKeys(req, prefix))
+	// original request is not modified
+	assert.Equal(t, Req("MSET", "a", 1, []byte("b"), 2), req)
+
+	assert.Equal(t, Req("EVAL", "return 1", 1, "t1:a", "argv"), MapKeys(Req("EVAL", "return 1", 1, "a", "argv"), prefix))
+	assert.Equal(t, Req("XREAD", "STREAMS", "t1:s", "0"), MapKeys(Req("XREAD", "STREAMS", "s", "0"), prefix))
+	assert.Equal(t, Req("PING"), MapKeys(Req("PING"), prefix))
+	// malformed request is returned as is
+	assert.Equal(t, Req("EVAL", "return 1", 3, "a"), MapKeys(Req("EVAL", "return 1", 3, "a"), prefix))
+}
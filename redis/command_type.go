@@ -1,6 +1,10 @@
 package redis
 
-import "strings"
+import (
+	"strconv"
+	"strings"
+	"time"
+)
 
 // hackish case insensitive hash function
 func fnv1a64NoCase(s string) uint64 {
@@ -47,12 +51,14 @@ var replicaSafe = makeSet(strings.Split(
 	"PING ECHO DUMP MEMORY EXISTS GET GETRANGE RANDOMKEY KEYS TYPE TTL PTTL "+
 		"BITCOUNT BITPOS GETBIT "+
 		"GEOHASH GEOPOS GEODIST GEORADIUS_RO GEORADIUSBYMEMBER_RO "+
-		"HEXISTS HGET HGETALL HKEYS HLEN HMGET HSTRLEN HVALS "+
+		"HEXISTS HGET HGETALL HKEYS HLEN HMGET HSTRLEN HVALS HRANDFIELD HTTL HPTTL "+
 		"LINDEX LLEN LRANGE "+
 		"PFCOUNT "+
 		"SCARD SDIFF SINTER SISMEMBER SMEMBERS SRANDMEMBER STRLEN SUNION "+
+		"SINTERCARD LCS "+
 		"ZCARD ZCOUNT ZLEXCOUNT ZRANGE ZRANGEBYLEX ZREVRANGEBYLEX "+
-		"ZRANGEBYSCORE ZRANK ZREVRANGE ZREVRANGEBYSCORE ZREVRANK ZSCORE "+
+		"ZRANGEBYSCORE ZRANK ZREVRANGE ZREVRANGEBYSCORE ZREVRANK ZSCORE ZRANDMEMBER "+
+		"SORT_RO FCALL_RO "+
 		"XPENDING XREVRANGE XREAD XLEN ", " "))
 
 // ReplicaSafe returns true if command is readonly and "safe to run on replica".
@@ -62,7 +68,26 @@ func ReplicaSafe(name string) bool {
 	return checkSet(name, replicaSafe)
 }
 
-var blocking = makeSet(strings.Split("BLPOP BRPOP BLPOPPUSH BZPOPMIN BZPOPMAX XREAD XREADGROUP SAVE WATCH", " "))
+var writeCommands = makeSet(strings.Split(
+	"SET SETNX SETEX PSETEX MSET MSETNX APPEND SETRANGE GETSET GETDEL GETEX "+
+		"INCR INCRBY INCRBYFLOAT DECR DECRBY SETBIT BITOP BITFIELD "+
+		"DEL UNLINK EXPIRE PEXPIRE EXPIREAT PEXPIREAT PERSIST RENAME RENAMENX RESTORE RESTORE-ASKING COPY MOVE MIGRATE "+
+		"HSET HSETNX HMSET HDEL HINCRBY HINCRBYFLOAT HEXPIRE HPEXPIRE HPERSIST "+
+		"LPUSH RPUSH LPUSHX RPUSHX LPOP RPOP LSET LREM LINSERT LTRIM RPOPLPUSH LMOVE "+
+		"SADD SREM SPOP SMOVE SINTERSTORE SUNIONSTORE SDIFFSTORE "+
+		"ZADD ZINCRBY ZREM ZREMRANGEBYSCORE ZREMRANGEBYRANK ZREMRANGEBYLEX ZPOPMIN ZPOPMAX "+
+		"ZUNIONSTORE ZINTERSTORE ZDIFFSTORE ZRANGESTORE "+
+		"PFADD PFMERGE GEOADD XADD XDEL XTRIM XACK XCLAIM XAUTOCLAIM XSETID "+
+		"FLUSHDB FLUSHALL SWAPDB", " "))
+
+// WriteCommand returns true if command is known to modify data.
+// Commands which may or may not write (like EVAL) are not included.
+func WriteCommand(name string) bool {
+	return checkSet(name, writeCommands)
+}
+
+var blocking = makeSet(strings.Split("BLPOP BRPOP BLPOPPUSH BRPOPLPUSH BLMOVE BLMPOP BZPOPMIN BZPOPMAX BZMPOP "+
+	"XREAD XREADGROUP SAVE WATCH", " "))
 
 // Blocking returns true if command is known to be blocking.
 // Blocking commands could stall whole pipeline and therefore affect other commands sent
@@ -79,18 +104,20 @@ func Blocking(name string) bool {
 
 var subscribeHash = fnv1a64NoCase("SUBSCRIBE")
 var psubscribeHash = fnv1a64NoCase("PSUBSCRIBE")
+var ssubscribeHash = fnv1a64NoCase("SSUBSCRIBE")
+var monitorHash = fnv1a64NoCase("MONITOR")
 
 // Dangerous returns true if command is not safe to use with the connector.
-// Currently it includes `SUBSCRIBE`, `PSUBSCRIBE` commands, because they changes connection protocol mode.
+// Currently it includes `SUBSCRIBE`, `PSUBSCRIBE`, `SSUBSCRIBE` and `MONITOR` commands,
+// because they changes connection protocol mode.
 func Dangerous(name string) bool {
 	h := fnv1a64NoCase(name)
-	return h == subscribeHash || h == psubscribeHash
+	return h == subscribeHash || h == psubscribeHash || h == ssubscribeHash || h == monitorHash
 }
 
 // ForbiddenCommand returns true if command is not allowed to run.
 func ForbiddenCommand(name string, singleThreaded bool) error {
-	h := fnv1a64NoCase(name)
-	if h == subscribeHash || h == psubscribeHash {
+	if Dangerous(name) {
 		return ErrCommandForbidden.New("command %s could not be used with this connector", name)
 	}
 	if !singleThreaded && checkSet(name, blocking) {
@@ -98,3 +125,48 @@ func ForbiddenCommand(name string, singleThreaded bool) error {
 	}
 	return nil
 }
+
+// BlockingTimeout returns timeout of blocking request: last argument of BLPOP, BRPOP, BRPOPLPUSH,
+// BLMOVE, BZPOPMIN and BZPOPMAX, first argument of BLMPOP and BZMPOP, BLOCK option of XREAD
+// and XREADGROUP, and last argument of WAIT and WAITAOF (which are not forbidden by ForbiddenCommand,
+// since they block only while replicas acknowledge writes). Zero timeout means request blocks forever (it is also returned if timeout is malformed).
+// ok is false if request doesn't block.
+func BlockingTimeout(req Request) (timeout time.Duration, ok bool) {
+	var arg interface{}
+	var unit float64
+	switch strings.ToUpper(req.Cmd) {
+	case "BLPOP", "BRPOP", "BRPOPLPUSH", "BLMOVE", "BZPOPMIN", "BZPOPMAX":
+		if len(req.Args) == 0 {
+			return 0, false
+		}
+		arg, unit = req.Args[len(req.Args)-1], float64(time.Second)
+	case "BLMPOP", "BZMPOP":
+		if len(req.Args) == 0 {
+			return 0, false
+		}
+		arg, unit = req.Args[0], float64(time.Second)
+	case "WAIT", "WAITAOF":
+		if len(req.Args) == 0 {
+			return 0, false
+		}
+		arg, unit = req.Args[len(req.Args)-1], float64(time.Millisecond)
+	case "XREAD", "XREADGROUP":
+		for i := 0; i < len(req.Args)-1; i++ {
+			if s, _ := ArgToString(req.Args[i]); strings.EqualFold(s, "BLOCK") {
+				arg, unit = req.Args[i+1], float64(time.Millisecond)
+				break
+			}
+		}
+		if arg == nil {
+			return 0, false
+		}
+	default:
+		return 0, false
+	}
+	s, _ := ArgToString(arg)
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil || f <= 0 {
+		return 0, true
+	}
+	return time.Duration(f * unit), true
+}
@@ -3,6 +3,7 @@ package redis_test
 import (
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/joomcode/redispipe/redis"
 
@@ -13,10 +14,16 @@ func TestCommandType(t *testing.T) {
 	assert.True(t, redis.ReplicaSafe("GET"))
 	assert.True(t, redis.ReplicaSafe("Get"))
 	assert.True(t, redis.ReplicaSafe("get"))
+	assert.True(t, redis.ReplicaSafe("SORT_RO"))
 	assert.False(t, redis.ReplicaSafe("SET"))
 	assert.False(t, redis.ReplicaSafe("Set"))
 	assert.False(t, redis.ReplicaSafe("set"))
 
+	assert.True(t, redis.WriteCommand("SET"))
+	assert.True(t, redis.WriteCommand("hset"))
+	assert.False(t, redis.WriteCommand("GET"))
+	assert.False(t, redis.WriteCommand("EVAL"))
+
 	assert.True(t, redis.Blocking("BLPOP"))
 	assert.True(t, redis.Blocking("Blpop"))
 	assert.True(t, redis.Blocking("blpop"))
@@ -27,11 +34,42 @@ func TestCommandType(t *testing.T) {
 	assert.True(t, redis.Dangerous("SUBSCRIBE"))
 	assert.True(t, redis.Dangerous("Subscribe"))
 	assert.True(t, redis.Dangerous("subscribe"))
+	assert.True(t, redis.Dangerous("MONITOR"))
+	assert.True(t, redis.Dangerous("SSUBSCRIBE"))
 	assert.False(t, redis.Dangerous("PUBLISH"))
 	assert.False(t, redis.Dangerous("Publish"))
 	assert.False(t, redis.Dangerous("publish"))
 }
 
+func TestCommandCaseInsensitive(t *testing.T) {
+	for _, cmd := range []string{"BLPOP", "Blpop", "blpop"} {
+		timeout, ok := redis.BlockingTimeout(redis.Req(cmd, "l", 1))
+		assert.True(t, ok)
+		assert.Equal(t, time.Second, timeout)
+		assert.Error(t, redis.ForbiddenCommand(cmd, false))
+	}
+	for _, cmd := range []string{"RANDOMKEY", "RandomKey", "randomkey"} {
+		key, ok := redis.Req(cmd).Key()
+		assert.Equal(t, "RANDOMKEY", key)
+		assert.False(t, ok)
+	}
+	for _, cmd := range []string{"EVAL", "Eval", "eval"} {
+		key, ok := redis.Req(cmd, "return 1", 1, "k").Key()
+		assert.Equal(t, "k", key)
+		assert.True(t, ok)
+	}
+	assert.Nil(t, redis.CheckArity(redis.Req("get", "k"), nil))
+	assert.Error(t, redis.CheckArity(redis.Req("Get"), nil))
+
+	// casing of command is preserved on the wire
+	buf, err := redis.AppendRequest(nil, redis.Req("Get", "k"))
+	assert.Nil(t, err)
+	assert.Equal(t, "*2\r\n$3\r\nGet\r\n$1\r\nk\r\n", string(buf))
+
+	assert.Equal(t, []interface{}{[]byte("0"), "TYPE", "hash"},
+		redis.ScanOpts{Cmd: "scan", Type: "hash"}.Request(nil).Args)
+}
+
 var sum int
 
 func BenchmarkCommandType(b *testing.B) {
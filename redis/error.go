@@ -1,6 +1,9 @@
 package redis
 
 import (
+	"errors"
+	"strings"
+
 	"github.com/joomcode/errorx"
 )
 
@@ -24,6 +27,9 @@ var (
 	// ErrTraitConnectivity marks all networking and io errors
 	ErrTraitConnectivity = errorx.RegisterTrait("network")
 
+	// ErrNoShards - sender has no shard to send keyless command to (see CommandCount, ConfigGet, etc).
+	ErrNoShards = Errors.NewType("no_shards", ErrTraitNotSent, ErrTraitConnectivity)
+
 	// ErrIO - io error: read/write error, or timeout, or connection closed while reading/writting
 	// It is not known if request were processed or not
 	ErrIO = Errors.NewType("io error", ErrTraitConnectivity)
@@ -32,14 +38,38 @@ var (
 	ErrRequest = Errors.NewSubNamespace("request")
 	// ErrArgumentType - argument is not serializable
 	ErrArgumentType = ErrRequest.NewType("argument_type")
+	// ErrArgumentCount - number of arguments doesn't match command arity (see CheckArity)
+	ErrArgumentCount = ErrRequest.NewType("argument_count")
 	// ErrBatchFormat - some other command in batch is malformed
 	ErrBatchFormat = ErrRequest.NewType("batch_format")
 	// ErrNoSlotKey - no key to determine cluster slot
 	ErrNoSlotKey = ErrRequest.NewType("no_slot_key")
+	// ErrCrossSlot - keys of request (or of batch) belong to different cluster slots
+	ErrCrossSlot = ErrRequest.NewType("cross_slot")
 	// ErrRequestCancelled - request already cancelled
 	ErrRequestCancelled = ErrRequest.NewType("request_cancelled")
 	// ErrCommandForbidden - command is blocking or dangerous
 	ErrCommandForbidden = ErrRequest.NewType("command_forbidden")
+	// ErrValueEncode - Codec failed to encode value (see SetValue). Cause is codec's error.
+	ErrValueEncode = ErrRequest.NewType("value_encode")
+	// ErrScanTypeUnsupported - server rejected SCAN with TYPE option (ScanOpts.Type requires redis >= 6.0).
+	// Cause is server's error.
+	ErrScanTypeUnsupported = ErrRequest.NewType("scan_type_unsupported")
+
+	// ErrKeyNotFound - key doesn't exist (returned by helpers which need existing key, like ObjectInspect).
+	ErrKeyNotFound = Errors.NewType("key_not_found")
+
+	// ErrDebugDisabled - DEBUG command is not allowed by server (enable-debug-command option).
+	// Cause is server's error.
+	ErrDebugDisabled = Errors.NewType("debug_disabled")
+
+	// ErrNoQuorum - less than requested number of senders answered QuorumRead successfully.
+	// Cause is error of first failed sender.
+	ErrNoQuorum = Errors.NewType("no_quorum")
+
+	// ErrSinkWrite - writer passed to ReadResponseTo (StreamingFuture.Sink) failed.
+	// Response were consumed, so connection is not affected.
+	ErrSinkWrite = Errors.NewType("sink_write")
 
 	// ErrResponse - response malformed. Redis returns unexpected response.
 	ErrResponse = Errors.NewSubNamespace("response")
@@ -47,8 +77,13 @@ var (
 	ErrResponseFormat = ErrResponse.NewType("format")
 	// ErrResponseUnexpected - response is valid redis response, but its structure/type unexpected
 	ErrResponseUnexpected = ErrResponse.NewType("unexpected")
+	// ErrValueDecode - Codec failed to decode value (see GetValue). Cause is codec's error.
+	ErrValueDecode = ErrResponse.NewType("value_decode")
 	// ErrHeaderlineTooLarge - header line too large
 	ErrHeaderlineTooLarge = ErrResponse.NewType("headerline_too_large")
+	// ErrResponseTooLarge - response exceeds limit requested with LimitedFuture (or ReadResponseLimit).
+	// Response is not fully read, so connection is reset.
+	ErrResponseTooLarge = ErrResponse.NewType("too_large")
 	// ErrHeaderlineEmpty - header line is empty
 	ErrHeaderlineEmpty = ErrResponse.NewType("headerline_empty")
 	// ErrIntegerParsing - integer malformed
@@ -59,6 +94,9 @@ var (
 	ErrUnknownHeaderType = ErrResponse.NewType("unknown_headerline_type")
 	// ErrPing - ping receives wrong response
 	ErrPing = ErrResponse.NewType("ping")
+	// ErrDesync - responses are not matched to requests anymore (see redisconn.Opts.CheckDesync).
+	// Connection is closed, and it is not known if requests were processed or not.
+	ErrDesync = ErrResponse.NewType("desync", ErrTraitConnectivity)
 
 	// ErrTraitClusterMove signals that error happens due to cluster rebalancing.
 	ErrTraitClusterMove = errorx.RegisterTrait("cluster_move")
@@ -77,6 +115,8 @@ var (
 	ErrExecAbort = ErrResult.NewSubtype("exec_abort")
 	// ErrTryAgain - EXEC returns TryAgain
 	ErrTryAgain = ErrResult.NewSubtype("exec_try_again")
+	// ErrOverflow - increment or decrement would overflow integer (or produce NaN or Infinity for float).
+	ErrOverflow = ErrResult.NewSubtype("overflow")
 )
 
 var (
@@ -92,15 +132,191 @@ var (
 	EKArgPos = errorx.RegisterPrintableProperty("argpos")
 	// EKRequest - request that triggered error.
 	EKRequest = errorx.RegisterPrintableProperty("request")
+	// EKRequestPos - position of malformed request in batch (set by EncodePipeline).
+	EKRequestPos = errorx.RegisterPrintableProperty("requestpos")
 	// EKRequests - batch requests that triggered error.
 	EKRequests = errorx.RegisterPrintableProperty("requests")
 	// EKResponse - unexpected response
 	EKResponse = errorx.RegisterProperty("response")
 	// EKAddress - address of redis that has a problems
 	EKAddress = errorx.RegisterPrintableProperty("address")
+	// EKKey - key which were not found
+	EKKey = errorx.RegisterPrintableProperty("key")
+	// EKLimit - response size limit which were exceeded.
+	EKLimit = errorx.RegisterPrintableProperty("limit")
 )
 
 var (
 	// CollectTrace - should Sync and SyncCtx wrappers collect stack traces on a call side.
 	CollectTrace = false
 )
+
+// AsErrorxChain finds first *errorx.Error in err's chain (as followed by errors.Unwrap).
+// It allows to inspect redispipe errors wrapped by middleware with fmt.Errorf("...: %w", err).
+func AsErrorxChain(err error) *errorx.Error {
+	var xerr *errorx.Error
+	if errors.As(err, &xerr) {
+		return xerr
+	}
+	return nil
+}
+
+// IsOfType checks if any *errorx.Error in err's chain is of type t (or of its subtype).
+// Unlike errorx.IsOfType, it looks through standard wrappers and causes of opaque errorx wraps as well.
+func IsOfType(err error, t *errorx.Type) bool {
+	for ; err != nil; err = unwrapError(err) {
+		if xerr, ok := err.(*errorx.Error); ok && xerr.IsOfType(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// HasTrait checks if any *errorx.Error in err's chain has trait.
+// Unlike errorx.HasTrait, it looks through standard wrappers and causes of opaque errorx wraps as well.
+func HasTrait(err error, trait errorx.Trait) bool {
+	for ; err != nil; err = unwrapError(err) {
+		if xerr, ok := err.(*errorx.Error); ok && xerr.HasTrait(trait) {
+			return true
+		}
+	}
+	return false
+}
+
+// Is reports whether any error in err's chain matches target, as errors.Is does.
+// *errorx.Error doesn't implement Unwrap, so errors.Is stops at it; Is follows its Cause instead.
+// redispipe error kinds are error types, not error values, so they are matched with Kind sentinel:
+// Is(err, KindOf(redisconn.ErrNotConnected)). Use Std to match them with standard errors.Is.
+func Is(err, target error) bool {
+	kind, isKind := target.(Kind)
+	for ; err != nil; err = unwrapError(err) {
+		if err == target {
+			return true
+		}
+		if xerr, ok := err.(*errorx.Error); ok && isKind && xerr.IsOfType(kind.Type) {
+			return true
+		}
+		if x, ok := err.(interface{ Is(error) bool }); ok && x.Is(target) {
+			return true
+		}
+	}
+	return false
+}
+
+// Kind is a sentinel error which matches errors of its type (and its subtypes) with Is,
+// and with errors.Is for errors wrapped with Std.
+type Kind struct {
+	Type *errorx.Type
+}
+
+// KindOf returns sentinel error for error type t.
+func KindOf(t *errorx.Type) Kind {
+	return Kind{Type: t}
+}
+
+// Error implements error.
+func (k Kind) Error() string {
+	return k.Type.FullName()
+}
+
+// StdError adapts *errorx.Error to standard errors package: errors.Is matches it against Kind sentinels
+// and errors in its Cause chain, and errors.As finds *errorx.Error in it.
+// errorx.Error (as of errorx v1.0.1) implements neither Unwrap nor Is, so errors returned by redispipe
+// could not be matched with errors.Is directly, and should be wrapped with Std first.
+type StdError struct {
+	Err *errorx.Error
+}
+
+// Std wraps err with StdError if it is *errorx.Error. Other errors are returned as is.
+func Std(err error) error {
+	if xerr, ok := err.(*errorx.Error); ok {
+		return StdError{xerr}
+	}
+	return err
+}
+
+// Error implements error.
+func (e StdError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns cause of error (wrapped with Std as well).
+func (e StdError) Unwrap() error {
+	return Std(e.Err.Cause())
+}
+
+// Is matches error against Kind sentinel.
+func (e StdError) Is(target error) bool {
+	kind, ok := target.(Kind)
+	return ok && e.Err.IsOfType(kind.Type)
+}
+
+// As sets target to wrapped error if target is **errorx.Error.
+func (e StdError) As(target interface{}) bool {
+	if p, ok := target.(**errorx.Error); ok {
+		*p = e.Err
+		return true
+	}
+	return false
+}
+
+// unwrapError returns next error in err's chain: cause of *errorx.Error, or result of errors.Unwrap
+// for other errors.
+func unwrapError(err error) error {
+	if xerr, ok := err.(*errorx.Error); ok {
+		return xerr.Cause()
+	}
+	return errors.Unwrap(err)
+}
+
+// ErrorPrefix returns error code of redis error reply, ie its first word if it is upper-cased
+// ("ERR", "WRONGTYPE", "NOSCRIPT", "CLUSTERDOWN", etc).
+// It returns empty string if err is not redis error reply (ErrResult), or reply has no such prefix.
+func ErrorPrefix(err error) string {
+	for ; err != nil; err = unwrapError(err) {
+		if xerr, ok := err.(*errorx.Error); ok && xerr.IsOfType(ErrResult) {
+			return errorPrefix(xerr.Message())
+		}
+	}
+	return ""
+}
+
+// IsUnknownCommand checks if err is redis error reply to command server doesn't know
+// ("ERR unknown command ..."), ie server is too old for it.
+func IsUnknownCommand(err error) bool {
+	for ; err != nil; err = unwrapError(err) {
+		if xerr, ok := err.(*errorx.Error); ok && xerr.IsOfType(ErrResult) {
+			return strings.HasPrefix(xerr.Message(), "ERR unknown command")
+		}
+	}
+	return false
+}
+
+// ParseRedirect returns target address and slot of MOVED or ASK error reply.
+// ask is true for ASK redirection. ok is false if err is not redirection.
+func ParseRedirect(err error) (addr string, slot int64, ask bool, ok bool) {
+	xerr := AsErrorxChain(err)
+	if xerr == nil || !xerr.HasTrait(ErrTraitClusterMove) {
+		return "", 0, false, false
+	}
+	if v, has := xerr.Property(EKMovedTo); has {
+		addr, _ = v.(string)
+	}
+	if v, has := xerr.Property(EKSlot); has {
+		slot, _ = v.(int64)
+	}
+	return addr, slot, xerr.IsOfType(ErrAsk), addr != ""
+}
+
+// errorPrefix returns first word of error reply if it consists of upper-case letters, digits and '_',
+// and starts with letter.
+func errorPrefix(txt string) string {
+	i := 0
+	for ; i < len(txt) && txt[i] != ' '; i++ {
+		c := txt[i]
+		if !(c >= 'A' && c <= 'Z' || i > 0 && (c >= '0' && c <= '9' || c == '_')) {
+			return ""
+		}
+	}
+	return txt[:i]
+}
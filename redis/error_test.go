@@ -0,0 +1,70 @@
+package redis_test
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/joomcode/errorx"
+	. "github.com/joomcode/redispipe/redis"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestErrorChain(t *testing.T) {
+	base := ErrIO.WrapWithNoMessage(io.EOF)
+	wrapped := fmt.Errorf("middleware: %w", base)
+
+	assert.True(t, IsOfType(wrapped, ErrIO))
+	assert.False(t, IsOfType(wrapped, ErrResult))
+	assert.True(t, HasTrait(wrapped, ErrTraitConnectivity))
+	assert.False(t, HasTrait(wrapped, ErrTraitNotSent))
+	assert.True(t, Is(wrapped, io.EOF))
+	assert.True(t, Is(ErrResult.Wrap(wrapped, "outer"), io.EOF))
+	assert.False(t, Is(wrapped, io.ErrUnexpectedEOF))
+
+	var xerr *errorx.Error
+	assert.True(t, errors.As(wrapped, &xerr))
+	assert.Equal(t, base, xerr)
+	assert.Equal(t, base, AsErrorxChain(wrapped))
+
+	moved := fmt.Errorf("retrier: %w", fmt.Errorf("attempt 2: %w", ErrMoved.New("MOVED 1 127.0.0.1:7000")))
+	assert.True(t, IsOfType(moved, ErrMoved))
+	assert.True(t, IsOfType(moved, ErrResult))
+	assert.True(t, HasTrait(moved, ErrTraitClusterMove))
+
+	assert.Nil(t, AsErrorxChain(io.EOF))
+	assert.False(t, IsOfType(nil, ErrIO))
+}
+
+func TestErrorStd(t *testing.T) {
+	base := ErrIO.WrapWithNoMessage(io.EOF)
+	wrapped := fmt.Errorf("middleware: %w", Std(ErrDesync.Wrap(base, "outer")))
+
+	assert.True(t, errors.Is(wrapped, KindOf(ErrDesync)))
+	assert.True(t, errors.Is(wrapped, KindOf(ErrIO)))
+	assert.True(t, errors.Is(wrapped, io.EOF))
+	assert.False(t, errors.Is(wrapped, KindOf(ErrResult)))
+	assert.True(t, errors.Is(Std(ErrMoved.New("MOVED 1 127.0.0.1:7000")), KindOf(ErrResult)))
+
+	var xerr *errorx.Error
+	assert.True(t, errors.As(wrapped, &xerr))
+	assert.True(t, xerr.IsOfType(ErrDesync))
+
+	// Is matches Kind without Std.
+	assert.True(t, Is(fmt.Errorf("middleware: %w", base), KindOf(ErrIO)))
+	assert.False(t, Is(base, KindOf(ErrResult)))
+	assert.Equal(t, io.EOF, Std(io.EOF))
+	assert.Equal(t, "redispipe.io error", KindOf(ErrIO).Error())
+}
+
+func TestErrorPrefix(t *testing.T) {
+	assert.Equal(t, "WRONGTYPE", ErrorPrefix(ErrResult.New("WRONGTYPE Operation against a key holding the wrong kind of value")))
+	assert.Equal(t, "CLUSTERDOWN", ErrorPrefix(fmt.Errorf("call: %w", ErrResult.New("CLUSTERDOWN The cluster is down"))))
+	assert.Equal(t, "NOSCRIPT", ErrorPrefix(ErrResult.New("NOSCRIPT")))
+	assert.Equal(t, "MOVED", ErrorPrefix(ErrMoved.New("MOVED 1 127.0.0.1:7000")))
+	assert.Equal(t, "", ErrorPrefix(ErrResult.New("no prefix here")))
+	assert.Equal(t, "", ErrorPrefix(ErrResult.New("")))
+	assert.Equal(t, "", ErrorPrefix(ErrIO.New("ERR not a reply")))
+	assert.Equal(t, "", ErrorPrefix(nil))
+}
@@ -0,0 +1,123 @@
+package redis
+
+import (
+	"sync"
+
+	"github.com/joomcode/errorx"
+)
+
+// FollowMoved is a Sender wrapper which follows MOVED redirections of single requests:
+// request answered with MOVED is re-sent to target node, connection to which is established with Dial
+// and cached for following redirections.
+// It is a lightweight replacement of cluster client for tools and scripts which are run against
+// server which could unexpectedly be a cluster node. Cluster topology is not tracked, so every
+// request is sent to S first. ASK redirections and transactions are not followed.
+type FollowMoved struct {
+	// S is a sender requests are sent to first.
+	S Sender
+	// Dial establishes connection to node at address from MOVED reply.
+	Dial func(addr string) (Sender, error)
+	// MaxRedirects - limit of redirections of single request. MOVED error is returned when it is exceeded.
+	// Default is 3.
+	MaxRedirects int
+
+	m     sync.Mutex
+	conns map[string]Sender
+}
+
+// NewFollowMoved returns FollowMoved over sender s.
+func NewFollowMoved(s Sender, dial func(addr string) (Sender, error)) *FollowMoved {
+	return &FollowMoved{S: s, Dial: dial}
+}
+
+// Send implements Sender.Send
+func (f *FollowMoved) Send(req Request, cb Future, n uint64) {
+	f.S.Send(req, &movedFuture{f: f, req: req, cb: cb, n: n}, n)
+}
+
+// SendMany implements Sender.SendMany
+func (f *FollowMoved) SendMany(reqs []Request, cb Future, n uint64) {
+	for i, req := range reqs {
+		f.Send(req, cb, n+uint64(i))
+	}
+}
+
+// SendTransaction implements Sender.SendTransaction. Transaction is sent to S without following redirections.
+func (f *FollowMoved) SendTransaction(reqs []Request, cb Future, n uint64) {
+	f.S.SendTransaction(reqs, cb, n)
+}
+
+// Scanner implements Sender.Scanner (it scans S only).
+func (f *FollowMoved) Scanner(opts ScanOpts) Scanner {
+	return f.S.Scanner(opts)
+}
+
+// EachShard implements Sender.EachShard (it iterates over S only).
+func (f *FollowMoved) EachShard(cb func(Sender, error) bool) {
+	f.S.EachShard(cb)
+}
+
+// Close closes S and all connections established for redirections.
+func (f *FollowMoved) Close() {
+	f.m.Lock()
+	conns := f.conns
+	f.conns = nil
+	f.m.Unlock()
+	for _, c := range conns {
+		c.Close()
+	}
+	f.S.Close()
+}
+
+// conn returns cached connection to addr, or dials it.
+func (f *FollowMoved) conn(addr string) (Sender, error) {
+	f.m.Lock()
+	defer f.m.Unlock()
+	if c, ok := f.conns[addr]; ok {
+		return c, nil
+	}
+	c, err := f.Dial(addr)
+	if err != nil {
+		return nil, err
+	}
+	if f.conns == nil {
+		f.conns = make(map[string]Sender)
+	}
+	f.conns[addr] = c
+	return c, nil
+}
+
+type movedFuture struct {
+	f       *FollowMoved
+	req     Request
+	cb      Future
+	n       uint64
+	redirno int
+}
+
+func (m *movedFuture) Cancelled() error {
+	return m.cb.Cancelled()
+}
+
+func (m *movedFuture) Resolve(res interface{}, _ uint64) {
+	addr, _, ask, ok := ParseRedirect(AsError(res))
+	max := m.f.MaxRedirects
+	if max <= 0 {
+		max = 3
+	}
+	if !ok || ask || m.redirno >= max {
+		m.cb.Resolve(res, m.n)
+		return
+	}
+	c, err := m.f.conn(addr)
+	if err != nil {
+		xerr, isx := err.(*errorx.Error)
+		if !isx {
+			xerr = ErrIO.WrapWithNoMessage(err)
+		}
+		m.cb.Resolve(xerr.WithProperty(EKAddress, addr).WithProperty(EKRequest, m.req), m.n)
+		return
+	}
+	m.redirno++
+	c.Send(m.req, m, m.n)
+}
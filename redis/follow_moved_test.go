@@ -0,0 +1,67 @@
+package redis_test
+
+import (
+	"errors"
+	"testing"
+
+	. "github.com/joomcode/redispipe/redis"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseRedirect(t *testing.T) {
+	addr, slot, ask, ok := ParseRedirect(AsError(readLines("-MOVED 3999 127.0.0.1:6381\r\n")))
+	assert.True(t, ok)
+	assert.False(t, ask)
+	assert.Equal(t, "127.0.0.1:6381", addr)
+	assert.Equal(t, int64(3999), slot)
+
+	addr, _, ask, ok = ParseRedirect(AsError(readLines("-ASK 3999 127.0.0.1:6382\r\n")))
+	assert.True(t, ok)
+	assert.True(t, ask)
+	assert.Equal(t, "127.0.0.1:6382", addr)
+
+	_, _, _, ok = ParseRedirect(AsError(readLines("-ERR unknown command\r\n")))
+	assert.False(t, ok)
+	_, _, _, ok = ParseRedirect(nil)
+	assert.False(t, ok)
+}
+
+func TestFollowMoved(t *testing.T) {
+	moved := func(addr string) interface{} {
+		return readLines("-MOVED 1 " + addr + "\r\n")
+	}
+	first := &fakeSender{handler: func(r Request) interface{} {
+		if r.Args[0] == "loop" {
+			return moved("loop:1")
+		}
+		return moved("second:1")
+	}}
+	second := &fakeSender{handler: func(r Request) interface{} { return []byte("value") }}
+	loop := &fakeSender{handler: func(r Request) interface{} { return moved("loop:1") }}
+	var dials []string
+	f := NewFollowMoved(first, func(addr string) (Sender, error) {
+		dials = append(dials, addr)
+		switch addr {
+		case "second:1":
+			return second, nil
+		case "loop:1":
+			return loop, nil
+		}
+		return nil, errors.New("no route")
+	})
+
+	assert.Equal(t, []byte("value"), Sync{f}.Do("GET", "key"))
+	assert.Equal(t, []byte("value"), Sync{f}.Do("GET", "other"))
+	assert.Equal(t, []string{"second:1"}, dials)
+	assert.Len(t, second.sent(), 2)
+
+	res := Sync{f}.Do("GET", "loop")
+	assert.True(t, IsOfType(AsError(res), ErrMoved))
+	// redirections are limited by MaxRedirects (default 3)
+	assert.Len(t, loop.sent(), 3)
+
+	first.handler = func(r Request) interface{} { return moved("unknown:1") }
+	res = Sync{f}.Do("GET", "key")
+	assert.True(t, IsOfType(AsError(res), ErrIO))
+	assert.Equal(t, []string{"second:1", "loop:1", "unknown:1"}, dials)
+}
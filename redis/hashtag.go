@@ -0,0 +1,25 @@
+package redis
+
+import "strings"
+
+// HashTag returns hash tag "{tag}". Keys containing the same hash tag belong to the same cluster slot.
+func HashTag(tag string) string {
+	return "{" + tag + "}"
+}
+
+// TaggedKey returns key "{tag}rest", which belongs to the same cluster slot as other keys tagged with tag.
+func TaggedKey(tag, rest string) string {
+	return "{" + tag + "}" + rest
+}
+
+// ExtractHashTag returns part of key which is hashed to compute cluster slot:
+// content between first '{' and first '}' after it, if it is not empty.
+// ok is false if key has no such hash tag, and then whole key is hashed.
+func ExtractHashTag(key string) (tag string, ok bool) {
+	if start := strings.IndexByte(key, '{'); start >= 0 {
+		if end := strings.IndexByte(key[start+1:], '}'); end > 0 {
+			return key[start+1 : start+1+end], true
+		}
+	}
+	return "", false
+}
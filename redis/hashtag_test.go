@@ -0,0 +1,29 @@
+package redis_test
+
+import (
+	"testing"
+
+	. "github.com/joomcode/redispipe/redis"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHashTag(t *testing.T) {
+	assert.Equal(t, "{user1}", HashTag("user1"))
+	assert.Equal(t, "{user1}:profile", TaggedKey("user1", ":profile"))
+
+	for key, tag := range map[string]string{
+		"{user1}:profile":  "user1",
+		"foo{bar}baz{qux}": "bar",
+		"foo{{bar}}":       "{bar",
+		"{user1}":          "user1",
+		"a{b}":             "b",
+	} {
+		got, ok := ExtractHashTag(key)
+		assert.True(t, ok, key)
+		assert.Equal(t, tag, got, key)
+	}
+	for _, key := range []string{"", "foo", "foo{}{bar}", "foo{bar", "foo}bar{", "{}"} {
+		_, ok := ExtractHashTag(key)
+		assert.False(t, ok, key)
+	}
+}
@@ -0,0 +1,44 @@
+package redis
+
+import "time"
+
+// releaseLockScript deletes KEYS[1] only if its value is ARGV[1].
+var releaseLockScript = NewScript(`if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('DEL', KEYS[1])
+end
+return 0
+`)
+
+// SetNX sets key to value only if key doesn't exist (SET NX), with time to live ttl if it is positive.
+// Returned bool is true if key were set, and false if it already exists.
+// With unique value (token) it acquires single-instance lock, which is released with ReleaseLock.
+func SetNX(s Sender, key string, value interface{}, ttl time.Duration) (bool, error) {
+	args := []interface{}{key, value, "NX"}
+	if ttl > 0 {
+		args = append(args, durationArgs(ttl, "EX", "PX")...)
+	}
+	res := Sync{s}.Send(Request{"SET", args})
+	switch v := res.(type) {
+	case nil:
+		return false, nil
+	case string:
+		if v == "OK" {
+			return true, nil
+		}
+	case error:
+		return false, v
+	}
+	return false, unexpectedResponse(res)
+}
+
+// ReleaseLock deletes key only if its value is still token, ie lock acquired with SetNX is still held
+// (it could expire and be acquired by someone else). Check and delete are done atomically by Lua script.
+// Returned bool is false if lock were not held.
+func ReleaseLock(s Sender, key string, token interface{}) (bool, error) {
+	res, err := releaseLockScript.Run(s, []string{key}, []interface{}{token})
+	if err != nil {
+		return false, err
+	}
+	n, err := responseInt(res)
+	return n == 1, err
+}
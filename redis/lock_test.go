@@ -0,0 +1,57 @@
+package redis_test
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/joomcode/redispipe/redis"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetNXReleaseLock(t *testing.T) {
+	store := map[string]interface{}{}
+	s := &fakeSender{handler: func(r Request) interface{} {
+		key := r.Args[0]
+		switch r.Cmd {
+		case "SET":
+			if _, ok := store[key.(string)]; ok {
+				return nil
+			}
+			store[key.(string)] = r.Args[1]
+			return "OK"
+		case "EVALSHA":
+			// {sha, numkeys, key, token}
+			if store[r.Args[2].(string)] == r.Args[3] {
+				delete(store, r.Args[2].(string))
+				return int64(1)
+			}
+			return int64(0)
+		}
+		return nil
+	}}
+
+	ok, err := SetNX(s, "lock", "token1", 1500*time.Millisecond)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, []interface{}{"lock", "token1", "NX", "PX", int64(1500)}, s.sent()[0].Args)
+
+	ok, err = SetNX(s, "lock", "token2", 0)
+	assert.NoError(t, err)
+	assert.False(t, ok)
+	assert.Equal(t, []interface{}{"lock", "token2", "NX"}, s.sent()[1].Args)
+
+	ok, err = ReleaseLock(s, "lock", "token2")
+	assert.NoError(t, err)
+	assert.False(t, ok)
+	ok, err = ReleaseLock(s, "lock", "token1")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	ok, err = SetNX(s, "lock", "token2", time.Second)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	s.handler = func(Request) interface{} { return ErrResult.New("ERR failed") }
+	_, err = SetNX(s, "lock", "token3", time.Second)
+	assert.Error(t, err)
+}
@@ -0,0 +1,89 @@
+package redis
+
+// ResponseSource describes kind of node (or cache) response were received from.
+type ResponseSource uint8
+
+const (
+	// SourceUnknown - sender doesn't know (or doesn't report) role of the node.
+	SourceUnknown ResponseSource = iota
+	// SourceMaster - response were received from master.
+	SourceMaster
+	// SourceReplica - response were received from replica.
+	SourceReplica
+	// SourceCache - response were taken from in-process Cache.
+	SourceCache
+)
+
+// String implements fmt.Stringer
+func (s ResponseSource) String() string {
+	switch s {
+	case SourceMaster:
+		return "master"
+	case SourceReplica:
+		return "replica"
+	case SourceCache:
+		return "cache"
+	default:
+		return "unknown"
+	}
+}
+
+// ResponseMeta is a provenance information of response.
+// It is useful for debugging stale reads in replica-read setups.
+type ResponseMeta struct {
+	// Source is a kind of node response came from.
+	Source ResponseSource
+	// Addr is an address of node response came from (empty for cache).
+	Addr string
+}
+
+// FutureMeta is a Future which could accept response provenance as well.
+type FutureMeta interface {
+	Future
+	// ResolveMeta is called instead of Resolve by MetaSender.
+	ResolveMeta(res interface{}, n uint64, meta ResponseMeta)
+}
+
+// MetaSender is implemented by senders which are able to report response provenance.
+type MetaSender interface {
+	// SendWithMeta sends request as Send does, but resolves cb with cb.ResolveMeta.
+	SendWithMeta(r Request, cb FutureMeta, n uint64)
+}
+
+// SendWithMeta sends request with s.SendWithMeta if s implements MetaSender.
+// Otherwise it sends request with s.Send and resolves cb with zero ResponseMeta.
+func SendWithMeta(s Sender, r Request, cb FutureMeta, n uint64) {
+	if ms, ok := s.(MetaSender); ok {
+		ms.SendWithMeta(r, cb, n)
+		return
+	}
+	s.Send(r, WithMeta(cb, ResponseMeta{}), n)
+}
+
+// WithMeta returns Future which passes fixed meta to cb.ResolveMeta.
+func WithMeta(cb FutureMeta, meta ResponseMeta) Future {
+	return metaFuture{cb, meta}
+}
+
+type metaFuture struct {
+	cb   FutureMeta
+	meta ResponseMeta
+}
+
+func (f metaFuture) Cancelled() error { return f.cb.Cancelled() }
+
+func (f metaFuture) Resolve(res interface{}, n uint64) {
+	f.cb.ResolveMeta(res, n, f.meta)
+}
+
+// FuncFutureMeta simple wrapper that makes FutureMeta from function.
+type FuncFutureMeta func(res interface{}, n uint64, meta ResponseMeta)
+
+// Cancelled implements Future.Cancelled (always false)
+func (f FuncFutureMeta) Cancelled() error { return nil }
+
+// Resolve implements Future.Resolve (by calling wrapped function with zero meta).
+func (f FuncFutureMeta) Resolve(res interface{}, n uint64) { f(res, n, ResponseMeta{}) }
+
+// ResolveMeta implements FutureMeta.ResolveMeta (by calling wrapped function).
+func (f FuncFutureMeta) ResolveMeta(res interface{}, n uint64, meta ResponseMeta) { f(res, n, meta) }
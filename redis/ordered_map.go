@@ -0,0 +1,65 @@
+package redis
+
+// OrderedMap is a list of key-value pairs in order of reply. It is parsed from flat key-value array
+// (or RESP3 map, which is returned as flat array by ReadResponse). Unlike Go map it preserves order,
+// which is meaningful for some replies: field order of stream entry, parameters matched by CONFIG GET
+// glob, etc.
+//
+// Helpers which preserve order: ConfigGetOrdered, StreamEntry.Pairs (XRANGE, XREAD, etc),
+// HashFieldsResponse (HGETALL). Other helpers return Go maps.
+type OrderedMap []MapEntry
+
+// MapEntry is a key-value pair of OrderedMap.
+type MapEntry struct {
+	Key   string
+	Value string
+}
+
+// Keys returns keys in order.
+func (m OrderedMap) Keys() []string {
+	keys := make([]string, len(m))
+	for i, e := range m {
+		keys[i] = e.Key
+	}
+	return keys
+}
+
+// Get returns value of first entry with key. Lookup is linear.
+func (m OrderedMap) Get(key string) (string, bool) {
+	for _, e := range m {
+		if e.Key == key {
+			return e.Value, true
+		}
+	}
+	return "", false
+}
+
+// Map converts m to Go map. If key is repeated, its last value is used.
+func (m OrderedMap) Map() map[string]string {
+	res := make(map[string]string, len(m))
+	for _, e := range m {
+		res[e.Key] = e.Value
+	}
+	return res
+}
+
+// OrderedMapResponse parses flat array of keys interleaved with values into OrderedMap.
+func OrderedMapResponse(res interface{}) (OrderedMap, error) {
+	arr, err := responseArray(res)
+	if err != nil {
+		return nil, err
+	}
+	if len(arr)%2 != 0 {
+		return nil, unexpectedResponse(res)
+	}
+	m := make(OrderedMap, len(arr)/2)
+	for i := range m {
+		key, kerr := responseString(arr[2*i])
+		val, verr := responseString(arr[2*i+1])
+		if kerr != nil || verr != nil {
+			return nil, unexpectedResponse(res)
+		}
+		m[i] = MapEntry{key, val}
+	}
+	return m, nil
+}
@@ -0,0 +1,59 @@
+package redis
+
+import "context"
+
+// Pipeline accumulates requests and sends them together on Exec.
+// Note that any Sender already pipelines concurrent requests implicitly; Pipeline is just
+// a convenient way to collect batch of requests and to wait for all of them.
+// Pipeline is not safe for concurrent use.
+type Pipeline struct {
+	S    Sender
+	reqs []Request
+}
+
+// NewPipeline returns empty pipeline over sender.
+func NewPipeline(s Sender) *Pipeline {
+	return &Pipeline{S: s}
+}
+
+// Send queues request and returns its index in Exec results.
+func (p *Pipeline) Send(cmd string, args ...interface{}) int {
+	return p.Queue(Request{cmd, args})
+}
+
+// Queue queues request and returns its index in Exec results.
+func (p *Pipeline) Queue(r Request) int {
+	p.reqs = append(p.reqs, r)
+	return len(p.reqs) - 1
+}
+
+// Len returns number of queued requests.
+func (p *Pipeline) Len() int {
+	return len(p.reqs)
+}
+
+// Exec sends queued requests and waits for all results. Results are in the same order as requests,
+// and each result could be value or error. Returned error is the first error among results.
+// Pipeline is emptied, so it could be reused.
+func (p *Pipeline) Exec() ([]interface{}, error) {
+	reqs := p.reqs
+	p.reqs = nil
+	return pipelineResults(Sync{p.S}.SendMany(reqs))
+}
+
+// ExecCtx is like Exec, but stops waiting when ctx is done. Results of requests not answered yet
+// are ErrRequestCancelled then (note: redis still could execute them).
+func (p *Pipeline) ExecCtx(ctx context.Context) ([]interface{}, error) {
+	reqs := p.reqs
+	p.reqs = nil
+	return pipelineResults(SyncCtx{p.S}.SendMany(ctx, reqs))
+}
+
+func pipelineResults(res []interface{}) ([]interface{}, error) {
+	for _, r := range res {
+		if err := AsError(r); err != nil {
+			return res, err
+		}
+	}
+	return res, nil
+}
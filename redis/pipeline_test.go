@@ -0,0 +1,54 @@
+package redis_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	. "github.com/joomcode/redispipe/redis"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPipeline(t *testing.T) {
+	s := &fakeSender{handler: func(r Request) interface{} {
+		if r.Cmd == "BAD" {
+			return ErrResult.New("ERR bad")
+		}
+		return r.Args[0]
+	}}
+
+	p := NewPipeline(s)
+	assert.Equal(t, 0, p.Send("ECHO", "a"))
+	assert.Equal(t, 1, p.Queue(Req("ECHO", "b")))
+	assert.Equal(t, 2, p.Len())
+
+	res, err := p.Exec()
+	assert.NoError(t, err)
+	assert.Equal(t, []interface{}{"a", "b"}, res)
+	assert.Equal(t, 0, p.Len())
+
+	p.Send("ECHO", "c")
+	p.Send("BAD")
+	res, err = p.Exec()
+	assert.True(t, IsOfType(err, ErrResult))
+	assert.Equal(t, "c", res[0])
+	assert.Equal(t, err, res[1])
+
+	res, err = p.Exec()
+	assert.NoError(t, err)
+	assert.Len(t, res, 0)
+}
+
+func TestPipelineExecCtx(t *testing.T) {
+	s := &lazySender{fakeSender: fakeSender{handler: func(r Request) interface{} {
+		return r.Args[0]
+	}}}
+
+	p := NewPipeline(s)
+	p.Send("ECHO", "a")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	res, err := p.ExecCtx(ctx)
+	assert.True(t, IsOfType(err, ErrRequestCancelled))
+	assert.Len(t, res, 1)
+}
@@ -0,0 +1,74 @@
+package redis
+
+import (
+	"context"
+	"sync"
+)
+
+// Promise is a Future which could be awaited later.
+// It is handy for firing several requests and waiting for their results afterwards.
+type Promise struct {
+	r       interface{}
+	wait    chan struct{}
+	resolve sync.Once
+
+	mu        sync.Mutex
+	cancelled error
+}
+
+// NewPromise returns new unresolved Promise.
+func NewPromise() *Promise {
+	return &Promise{wait: make(chan struct{})}
+}
+
+// DoAsync sends request and returns Promise for its result.
+func DoAsync(s Sender, cmd string, args ...interface{}) *Promise {
+	p := NewPromise()
+	s.Send(Request{cmd, args}, p, 0)
+	return p
+}
+
+// Resolve implements Future.Resolve. Only first resolution is kept.
+func (p *Promise) Resolve(res interface{}, _ uint64) {
+	p.resolve.Do(func() {
+		p.r = res
+		close(p.wait)
+	})
+}
+
+// Cancelled implements Future.Cancelled.
+// Promise is cancelled if context passed to AwaitCtx were done before resolution.
+func (p *Promise) Cancelled() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.cancelled
+}
+
+// Done returns channel that will be closed on resolution.
+func (p *Promise) Done() <-chan struct{} {
+	return p.wait
+}
+
+// Await waits for result.
+func (p *Promise) Await() (interface{}, error) {
+	<-p.wait
+	return p.r, AsError(p.r)
+}
+
+// AwaitCtx waits for result until ctx is done.
+// If ctx is done first, ErrRequestCancelled is returned, and Promise is marked as cancelled,
+// so request will not be sent if it were not sent yet. Cancellation error of first such ctx is kept.
+func (p *Promise) AwaitCtx(ctx context.Context) (interface{}, error) {
+	select {
+	case <-p.wait:
+		return p.r, AsError(p.r)
+	case <-ctx.Done():
+		p.mu.Lock()
+		if p.cancelled == nil {
+			p.cancelled = ctx.Err()
+		}
+		p.mu.Unlock()
+		err := ErrRequestCancelled.WrapWithNoMessage(ctx.Err())
+		return err, err
+	}
+}
@@ -0,0 +1,90 @@
+package redis_test
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/joomcode/redispipe/redis"
+	"github.com/stretchr/testify/assert"
+)
+
+// lazySender keeps requests until flush is called.
+type lazySender struct {
+	fakeSender
+	queue []func()
+}
+
+func (s *lazySender) Send(r Request, cb Future, n uint64) {
+	s.queue = append(s.queue, func() {
+		if err := cb.Cancelled(); err != nil {
+			cb.Resolve(ErrRequestCancelled.WrapWithNoMessage(err), n)
+			return
+		}
+		s.fakeSender.Send(r, cb, n)
+	})
+}
+
+func (s *lazySender) SendMany(reqs []Request, cb Future, n uint64) {
+	for i, r := range reqs {
+		s.Send(r, cb, n+uint64(i))
+	}
+}
+
+func (s *lazySender) flush() {
+	for _, f := range s.queue {
+		f()
+	}
+	s.queue = nil
+}
+
+func TestPromise(t *testing.T) {
+	s := &lazySender{fakeSender: fakeSender{handler: func(r Request) interface{} {
+		if r.Cmd == "BAD" {
+			return ErrResult.New("ERR bad")
+		}
+		return r.Args[0]
+	}}}
+
+	p1 := DoAsync(s, "ECHO", "one")
+	p2 := DoAsync(s, "BAD")
+	p3 := DoAsync(s, "ECHO", "three")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := p3.AwaitCtx(ctx)
+	assert.True(t, IsOfType(err, ErrRequestCancelled))
+	assert.Equal(t, context.Canceled, p3.Cancelled())
+
+	s.flush()
+
+	res, err := p1.Await()
+	assert.NoError(t, err)
+	assert.Equal(t, "one", res)
+
+	_, err = p2.AwaitCtx(context.Background())
+	assert.True(t, IsOfType(err, ErrResult))
+
+	<-p3.Done()
+	assert.Len(t, s.sent(), 2)
+}
+
+func TestPromiseAwaitCtxTwice(t *testing.T) {
+	p := NewPromise()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := p.AwaitCtx(ctx)
+	assert.True(t, IsOfType(err, ErrRequestCancelled))
+
+	ctx, cancel = context.WithTimeout(context.Background(), 0)
+	defer cancel()
+	_, err = p.AwaitCtx(ctx)
+	assert.True(t, IsOfType(err, ErrRequestCancelled))
+	assert.Equal(t, context.Canceled, p.Cancelled())
+
+	p.Resolve("OK", 0)
+	p.Resolve("again", 0)
+	res, err := p.Await()
+	assert.NoError(t, err)
+	assert.Equal(t, "OK", res)
+}
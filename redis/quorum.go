@@ -0,0 +1,127 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+)
+
+// errQuorumReached is returned by Cancelled of QuorumRead future to drop requests which are not sent yet.
+var errQuorumReached = errors.New("quorum is reached")
+
+// QuorumRead sends same read request to every sender of ss (for example, to several replicas of a shard)
+// and waits for k successful responses. Then reconcile picks answer from them (see QuorumMajority and
+// QuorumLatest). Responses are passed to reconcile in order of arrival.
+// If k <= 0 or k > len(ss), all senders should answer. Requests not sent yet when k responses are collected
+// (or ctx is done) are dropped through Future.Cancelled, but requests already sent are not aborted.
+// ErrNoQuorum is returned if k successful responses could not be collected, and ErrRequestCancelled
+// if ctx is done before that.
+//
+// Redis has no real quorum: replicas are asynchronous, and no set of them is guaranteed to have latest
+// write. QuorumRead only reduces probability of stale read, at cost of k requests instead of one.
+func QuorumRead(ctx context.Context, ss []Sender, req Request, k int,
+	reconcile func(results []interface{}) interface{}) (interface{}, error) {
+	if k <= 0 || k > len(ss) {
+		k = len(ss)
+	}
+	if k == 0 {
+		return nil, ErrNoQuorum.New("no senders")
+	}
+	q := &quorum{active: newActive(ctx), k: k, left: len(ss)}
+	for _, s := range ss {
+		s.Send(req, q, 0)
+	}
+	select {
+	case <-ctx.Done():
+		q.finish()
+		return nil, ErrRequestCancelled.WrapWithNoMessage(ctx.Err())
+	case <-q.ch:
+	}
+	if len(q.results) < k {
+		return nil, ErrNoQuorum.Wrap(q.err, "%d of %d senders answered, %d required", len(q.results), len(ss), k)
+	}
+	return reconcile(q.results), nil
+}
+
+// QuorumMajority is a reconcile function for QuorumRead which returns most frequent result
+// (the earliest one of equally frequent).
+func QuorumMajority(results []interface{}) interface{} {
+	best, bestCount := 0, 0
+	for i := range results {
+		count := 0
+		for j := range results {
+			if reflect.DeepEqual(results[i], results[j]) {
+				count++
+			}
+		}
+		if count > bestCount {
+			best, bestCount = i, count
+		}
+	}
+	return results[best]
+}
+
+// QuorumLatest returns reconcile function for QuorumRead which returns result with highest version:
+// less(a, b) reports whether version of a is lower than version of b (for example, it compares timestamps
+// stored with values).
+func QuorumLatest(less func(a, b interface{}) bool) func(results []interface{}) interface{} {
+	return func(results []interface{}) interface{} {
+		best := results[0]
+		for _, res := range results[1:] {
+			if less(best, res) {
+				best = res
+			}
+		}
+		return best
+	}
+}
+
+type quorum struct {
+	active
+	m       sync.Mutex
+	k       int
+	left    int
+	results []interface{}
+	err     error
+	done    bool
+}
+
+// Cancelled implements Future.Cancelled
+func (q *quorum) Cancelled() error {
+	q.m.Lock()
+	done := q.done
+	q.m.Unlock()
+	if done {
+		return errQuorumReached
+	}
+	return q.active.Cancelled()
+}
+
+// Resolve implements Future.Resolve
+func (q *quorum) Resolve(res interface{}, _ uint64) {
+	q.m.Lock()
+	defer q.m.Unlock()
+	q.left--
+	if q.done {
+		return
+	}
+	if err := AsError(res); err != nil {
+		if q.err == nil {
+			q.err = err
+		}
+	} else {
+		q.results = append(q.results, res)
+	}
+	if len(q.results) == q.k || q.left == 0 {
+		q.done = true
+		q.active.done()
+	}
+}
+
+// finish drops responses which are not received yet.
+func (q *quorum) finish() {
+	q.m.Lock()
+	q.done = true
+	q.m.Unlock()
+}
@@ -0,0 +1,60 @@
+package redis_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	. "github.com/joomcode/redispipe/redis"
+)
+
+// stuckSender never answers, but keeps future to check it is cancelled.
+type stuckSender struct {
+	fakeSender
+	cb Future
+}
+
+func (s *stuckSender) Send(r Request, cb Future, n uint64) { s.cb = cb }
+
+func TestQuorumRead(t *testing.T) {
+	answer := func(res interface{}) *fakeSender {
+		return &fakeSender{handler: func(Request) interface{} { return res }}
+	}
+	ctx := context.Background()
+	req := Req("GET", "k")
+	v1, v2 := []byte("1:old"), []byte("2:new")
+
+	res, err := QuorumRead(ctx, []Sender{answer(v1), answer(v2), answer(v2)}, req, 0, QuorumMajority)
+	assert.NoError(t, err)
+	assert.Equal(t, v2, res)
+
+	// version is a prefix of value
+	latest := QuorumLatest(func(a, b interface{}) bool { return a.([]byte)[0] < b.([]byte)[0] })
+	res, err = QuorumRead(ctx, []Sender{answer(v2), answer(v1), answer(v1)}, req, 0, latest)
+	assert.NoError(t, err)
+	assert.Equal(t, v2, res)
+
+	// failed sender is tolerated if quorum is reached
+	failed := answer(ErrIO.New("connection refused"))
+	res, err = QuorumRead(ctx, []Sender{failed, answer(v1), answer(v2)}, req, 2, latest)
+	assert.NoError(t, err)
+	assert.Equal(t, v2, res)
+
+	_, err = QuorumRead(ctx, []Sender{failed, answer(v1), failed}, req, 2, latest)
+	assert.True(t, IsOfType(err, ErrNoQuorum), "%v", err)
+
+	// straggler is dropped when quorum is reached
+	stuck := &stuckSender{}
+	res, err = QuorumRead(ctx, []Sender{stuck, answer(v1), answer(v1)}, req, 2, QuorumMajority)
+	assert.NoError(t, err)
+	assert.Equal(t, v1, res)
+	assert.Error(t, stuck.cb.Cancelled())
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	_, err = QuorumRead(ctx, []Sender{stuck, answer(v1)}, req, 2, QuorumMajority)
+	assert.True(t, IsOfType(err, ErrRequestCancelled), "%v", err)
+	assert.Error(t, stuck.cb.Cancelled())
+}
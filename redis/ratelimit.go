@@ -0,0 +1,77 @@
+package redis
+
+import (
+	"time"
+)
+
+// rateLimitScript is a token bucket stored in hash at KEYS[1]: bucket of ARGV[1] tokens is refilled
+// in ARGV[2] milliseconds. It returns {allowed, remaining tokens, milliseconds until next token}.
+// Server's clock is used, so limit is shared correctly by clients with skewed clocks.
+var rateLimitScript = NewScript(`redis.replicate_commands()
+local limit = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+local state = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(state[1])
+local ts = tonumber(state[2])
+if tokens == nil or ts == nil then
+	tokens = limit
+	ts = now
+end
+local rate = limit / window
+tokens = math.min(limit, tokens + math.max(0, now - ts) * rate)
+local allowed = 0
+local retry = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+else
+	retry = math.ceil((1 - tokens) / rate)
+end
+redis.call('HMSET', KEYS[1], 'tokens', tostring(tokens), 'ts', now)
+redis.call('PEXPIRE', KEYS[1], window)
+return {allowed, math.floor(tokens), retry}
+`)
+
+// RateLimiter is a distributed token bucket rate limiter. Bucket state is kept in redis hash,
+// and it is updated atomically by Lua script (called with EVALSHA, see Script).
+// Every bucket uses single key, so RateLimiter works with cluster.
+type RateLimiter struct {
+	// S is a sender buckets are stored with.
+	S Sender
+	// Prefix is prepended to keys of buckets.
+	Prefix string
+}
+
+// NewRateLimiter returns RateLimiter over sender s.
+func NewRateLimiter(s Sender, prefix string) *RateLimiter {
+	return &RateLimiter{S: s, Prefix: prefix}
+}
+
+// Allow takes token from bucket key, which holds up to limit tokens and is refilled completely in window
+// (ie limit requests per window on average, with bursts up to limit).
+// It returns number of tokens left, and time until next token is available if request is not allowed.
+// Bucket expires after window of inactivity.
+func (l *RateLimiter) Allow(key string, limit int, window time.Duration) (allowed bool, remaining int, retryAfter time.Duration, err error) {
+	ms := int64(window / time.Millisecond)
+	if limit <= 0 || ms <= 0 {
+		return false, 0, 0, ErrArgumentType.New("limit and window should be positive").
+			WithProperty(EKVal, limit)
+	}
+	res, err := rateLimitScript.Run(l.S, []string{l.Prefix + key}, []interface{}{limit, ms})
+	if err != nil {
+		return false, 0, 0, err
+	}
+	arr, err := responseArray(res)
+	if err != nil || len(arr) != 3 {
+		return false, 0, 0, unexpectedResponse(res)
+	}
+	var vals [3]int64
+	for i := range vals {
+		if vals[i], err = responseInt(arr[i]); err != nil {
+			return false, 0, 0, unexpectedResponse(res)
+		}
+	}
+	return vals[0] == 1, int(vals[1]), time.Duration(vals[2]) * time.Millisecond, nil
+}
@@ -9,31 +9,230 @@ import (
 	"github.com/joomcode/errorx"
 )
 
-// ReadResponse reads single RESP answer from bufio.Reader
+// ReadResponse reads single RESP answer from bufio.Reader.
+// RESP3 types are converted to their RESP2 counterparts: map, set and push frame are returned as array,
+// null as nil, boolean as integer, double, big number and verbatim string as bulk string (ie []byte).
+// RESP3 attributes preceding answer (and its elements) are skipped.
 func ReadResponse(b *bufio.Reader) interface{} {
+	line, rerr := readHeaderLine(b)
+	if rerr != nil {
+		return rerr
+	}
+	if line[0] == '|' {
+		if _, rerr = readAttributes(b, line, nil); rerr != nil {
+			return rerr
+		}
+		return ReadResponse(b)
+	}
+	return readResponseLine(b, line, nil)
+}
+
+// ReadResponseLimit reads single RESP answer like ReadResponse, but returns ErrResponseTooLarge
+// as soon as answer (header lines included) is known to exceed limit bytes, before allocating for it.
+// Rest of answer is left unread, so connection could not be used after that.
+func ReadResponseLimit(b *bufio.Reader, limit int64) interface{} {
+	return readResponse(b, &readLimit{limit: limit, left: limit})
+}
+
+// readLimit tracks bytes left for answer read with ReadResponseLimit.
+type readLimit struct {
+	limit int64
+	left  int64
+}
+
+// take accounts n bytes of answer. It is no-op for nil limit.
+func (l *readLimit) take(n int64) *errorx.Error {
+	if l == nil {
+		return nil
+	}
+	l.left -= n
+	if l.left < 0 {
+		return ErrResponseTooLarge.NewWithNoMessage().WithProperty(EKLimit, l.limit)
+	}
+	return nil
+}
+
+func readResponse(b *bufio.Reader, lim *readLimit) interface{} {
+	if lim == nil {
+		return ReadResponse(b)
+	}
+	line, rerr := readHeaderLine(b)
+	if rerr != nil {
+		return rerr
+	}
+	if rerr = lim.take(int64(len(line)) + 2); rerr != nil {
+		return rerr
+	}
+	if line[0] == '|' {
+		if _, rerr = readAttributes(b, line, lim); rerr != nil {
+			return rerr
+		}
+		return readResponse(b, lim)
+	}
+	return readResponseLine(b, line, lim)
+}
+
+// WithAttributes is returned by ReadResponseWithAttributes when answer is preceded by RESP3 attributes.
+type WithAttributes struct {
+	// Attributes are flat list of attribute key-value pairs (like HGETALL answer).
+	Attributes []interface{}
+	// Value is an answer itself.
+	Value interface{}
+}
+
+// ReadResponseWithAttributes reads single RESP answer like ReadResponse, but if answer is preceded
+// by RESP3 attributes ('|' type), it returns WithAttributes. Attributes of nested elements are skipped.
+func ReadResponseWithAttributes(b *bufio.Reader) interface{} {
+	attrs, rerr := ReadAttributes(b)
+	if rerr != nil {
+		return rerr
+	}
+	res := ReadResponse(b)
+	if attrs == nil {
+		return res
+	}
+	if e, ok := res.(*errorx.Error); ok && !e.IsOfType(ErrResult) {
+		return e
+	}
+	return WithAttributes{Attributes: attrs, Value: res}
+}
+
+// ReadAttributes reads RESP3 attributes ('|' type) preceding next answer in bufio.Reader,
+// and returns them as flat list of key-value pairs. Answer itself is left unread.
+// If answer has no attributes, nil is returned.
+func ReadAttributes(b *bufio.Reader) ([]interface{}, *errorx.Error) {
+	var attrs []interface{}
+	for {
+		head, err := b.Peek(1)
+		if err != nil {
+			return nil, ErrIO.WrapWithNoMessage(err)
+		}
+		if head[0] != '|' {
+			return attrs, nil
+		}
+		line, rerr := readHeaderLine(b)
+		if rerr != nil {
+			return nil, rerr
+		}
+		more, rerr := readAttributes(b, line, nil)
+		if rerr != nil {
+			return nil, rerr
+		}
+		attrs = append(attrs, more...)
+	}
+}
+
+// readAttributes reads attributes which header line is line.
+func readAttributes(b *bufio.Reader, line []byte, lim *readLimit) ([]interface{}, *errorx.Error) {
+	v, rerr := parseInt(line[1:])
+	if rerr != nil {
+		return nil, rerr.WithProperty(EKLine, line)
+	}
+	if v < 0 {
+		return nil, ErrResponseFormat.NewWithNoMessage().WithProperty(EKLine, line)
+	}
+	if lim != nil && v > lim.left/6 {
+		// every key and value takes at least 3 bytes ("_\r\n"), so attributes don't fit limit.
+		return nil, lim.take(6 * v)
+	}
+	attrs := make([]interface{}, 2*v)
+	for i := range attrs {
+		attrs[i] = readResponse(b, lim)
+		if e, ok := attrs[i].(*errorx.Error); ok && !e.IsOfType(ErrResult) {
+			return nil, e
+		}
+	}
+	return attrs, nil
+}
+
+// ReadResponseTo reads single RESP answer from bufio.Reader.
+// If answer is bulk string, its content is copied to w instead of being allocated,
+// and StreamedBulk is returned. Other answers are returned as with ReadResponse.
+// If w fails to write, rest of bulk string is discarded and ErrSinkWrite is returned.
+func ReadResponseTo(b *bufio.Reader, w io.Writer) interface{} {
+	line, rerr := readHeaderLine(b)
+	if rerr != nil {
+		return rerr
+	}
+	if line[0] == '|' {
+		if _, rerr = readAttributes(b, line, nil); rerr != nil {
+			return rerr
+		}
+		return ReadResponseTo(b, w)
+	}
+	if line[0] != '$' {
+		return readResponseLine(b, line, nil)
+	}
+	v, rerr := parseInt(line[1:])
+	if rerr != nil {
+		return rerr.WithProperty(EKLine, line)
+	}
+	if v < 0 {
+		return nil
+	}
+	sink := sinkWriter{w: w}
+	if _, err := io.CopyN(&sink, b, v); err != nil {
+		return ErrIO.WrapWithNoMessage(err)
+	}
+	var crlf [2]byte
+	if _, err := io.ReadFull(b, crlf[:]); err != nil {
+		return ErrIO.WrapWithNoMessage(err)
+	}
+	if crlf[0] != '\r' || crlf[1] != '\n' {
+		return ErrNoFinalRN.NewWithNoMessage()
+	}
+	if sink.err != nil {
+		return ErrSinkWrite.WrapWithNoMessage(sink.err)
+	}
+	return StreamedBulk{Len: v}
+}
+
+// sinkWriter remembers first write error and discards the rest of data,
+// so the response is fully consumed from connection.
+type sinkWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (s *sinkWriter) Write(p []byte) (int, error) {
+	if s.err == nil {
+		_, s.err = s.w.Write(p)
+	}
+	return len(p), nil
+}
+
+// StreamedBulk is returned by ReadResponseTo when bulk string were copied to writer.
+type StreamedBulk struct {
+	// Len is a length of bulk string.
+	Len int64
+}
+
+func readHeaderLine(b *bufio.Reader) ([]byte, *errorx.Error) {
 	line, isPrefix, err := b.ReadLine()
 	if err != nil {
-		return ErrIO.WrapWithNoMessage(err)
+		return nil, ErrIO.WrapWithNoMessage(err)
 	}
 
 	if isPrefix {
-		return ErrHeaderlineTooLarge.NewWithNoMessage().WithProperty(EKLine, line)
+		return nil, ErrHeaderlineTooLarge.NewWithNoMessage().WithProperty(EKLine, line)
 	}
 
 	if len(line) == 0 {
-		return ErrHeaderlineEmpty.NewWithNoMessage()
+		return nil, ErrHeaderlineEmpty.NewWithNoMessage()
 	}
+	return line, nil
+}
 
+func readResponseLine(b *bufio.Reader, line []byte, lim *readLimit) interface{} {
+	var err error
 	var v int64
 	switch line[0] {
 	case '+':
 		return string(line[1:])
 	case '-':
-		// detect MOVED and ASK
 		txt := string(line[1:])
-		moved := strings.HasPrefix(txt, "MOVED ")
-		ask := strings.HasPrefix(txt, "ASK ")
-		if moved || ask {
+		switch prefix := errorPrefix(txt); prefix {
+		case "MOVED", "ASK":
 			parts := bytes.Split(line, []byte(" "))
 			if len(parts) < 3 {
 				return ErrResponseFormat.NewWithNoMessage().WithProperty(EKLine, line)
@@ -43,20 +242,20 @@ func ReadResponse(b *bufio.Reader) interface{} {
 				return err.WithProperty(EKLine, line)
 			}
 			kind := ErrAsk
-			if moved {
+			if prefix == "MOVED" {
 				kind = ErrMoved
 			}
 			return kind.New(txt).WithProperty(EKMovedTo, string(parts[2])).WithProperty(EKSlot, slot)
-		}
-		if strings.HasPrefix(txt, "LOADING") {
+		case "LOADING":
 			return ErrLoading.New(txt)
-		}
-		if strings.HasPrefix(txt, "EXECABORT") {
+		case "EXECABORT":
 			return ErrExecAbort.New(txt)
-		}
-		if strings.HasPrefix(txt, "TRYAGAIN") {
+		case "TRYAGAIN":
 			return ErrTryAgain.New(txt)
 		}
+		if strings.HasSuffix(txt, "would overflow") || strings.HasSuffix(txt, "NaN or Infinity") {
+			return ErrOverflow.New(txt)
+		}
 		return ErrResult.New(txt)
 	case ':':
 		v, err := parseInt(line[1:])
@@ -64,7 +263,7 @@ func ReadResponse(b *bufio.Reader) interface{} {
 			return err.WithProperty(EKLine, line)
 		}
 		return v
-	case '$':
+	case '$', '=', '!':
 		var rerr *errorx.Error
 		if v, rerr = parseInt(line[1:]); rerr != nil {
 			return rerr.WithProperty(EKLine, line)
@@ -72,6 +271,9 @@ func ReadResponse(b *bufio.Reader) interface{} {
 		if v < 0 {
 			return nil
 		}
+		if rerr = lim.take(v + 2); rerr != nil {
+			return rerr
+		}
 		buf := make([]byte, v+2, v+2)
 		if _, err = io.ReadFull(b, buf); err != nil {
 			return ErrIO.WrapWithNoMessage(err)
@@ -79,8 +281,33 @@ func ReadResponse(b *bufio.Reader) interface{} {
 		if buf[v] != '\r' || buf[v+1] != '\n' {
 			return ErrNoFinalRN.NewWithNoMessage()
 		}
+		switch line[0] {
+		case '=':
+			// RESP3 verbatim string: skip format ("txt:").
+			if v >= 4 && buf[3] == ':' {
+				return buf[4:v:v]
+			}
+		case '!':
+			// RESP3 blob error
+			return ErrResult.New(string(buf[:v]))
+		}
 		return buf[:v:v]
-	case '*':
+	case '_':
+		// RESP3 null
+		return nil
+	case ',', '(':
+		// RESP3 double and big number are returned as bulk string, the same way RESP2 returns them.
+		return append([]byte(nil), line[1:]...)
+	case '#':
+		// RESP3 boolean is returned as integer, the same way RESP2 returns it.
+		switch string(line[1:]) {
+		case "t":
+			return int64(1)
+		case "f":
+			return int64(0)
+		}
+		return ErrResponseFormat.NewWithNoMessage().WithProperty(EKLine, line)
+	case '*', '%', '~', '>':
 		var rerr *errorx.Error
 		if v, rerr = parseInt(line[1:]); rerr != nil {
 			return rerr.WithProperty(EKLine, line)
@@ -88,9 +315,18 @@ func ReadResponse(b *bufio.Reader) interface{} {
 		if v < 0 {
 			return nil
 		}
+		if line[0] == '%' {
+			// RESP3 map is returned as flat list of key-value pairs, the same way RESP2 returns it.
+			// Set and push frame are returned as array as well.
+			v *= 2
+		}
+		if lim != nil && v > lim.left/3 {
+			// every element takes at least 3 bytes ("_\r\n"), so array doesn't fit limit.
+			return lim.take(3 * v)
+		}
 		result := make([]interface{}, v)
 		for i := int64(0); i < v; i++ {
-			result[i] = ReadResponse(b)
+			result[i] = readResponse(b, lim)
 			if e, ok := result[i].(*errorx.Error); ok && !e.IsOfType(ErrResult) {
 				return e
 			}
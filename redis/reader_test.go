@@ -144,6 +144,16 @@ func TestReadResponse_Correct(t *testing.T) {
 		assert.Equal(t, "LOADING", err.Message())
 	}
 
+	res = readLines("-ERR increment or decrement would overflow\r\n")
+	checkErrType(t, res, ErrOverflow)
+
+	res = readLines("-ERR increment would produce NaN or Infinity\r\n")
+	checkErrType(t, res, ErrOverflow)
+
+	res = readLines("-ERR value is not an integer or out of range\r\n")
+	checkErrType(t, res, ErrResult)
+	assert.False(t, res.(*errorx.Error).IsOfType(ErrOverflow))
+
 	for i := -1000; i <= 1000; i++ {
 		res = readLines(fmt.Sprintf(":%d\r\n", i))
 		assert.Equal(t, int64(i), res)
@@ -187,3 +197,117 @@ func TestReadResponse_Correct(t *testing.T) {
 	res = readLines("*-1\r\n")
 	assert.Nil(t, res)
 }
+
+type failingWriter struct{ n int }
+
+func (f *failingWriter) Write(p []byte) (int, error) {
+	if f.n+len(p) > 3 {
+		return 0, fmt.Errorf("disk full")
+	}
+	f.n += len(p)
+	return len(p), nil
+}
+
+func TestReadResponseTo(t *testing.T) {
+	var buf strings.Builder
+	b := lines2bufio("$5\r\nhello\r\n", "+OK\r\n", "$-1\r\n", "$6\r\nworld!\r\n", ":1\r\n", "$2\r\nab")
+
+	res := ReadResponseTo(b, &buf)
+	assert.Equal(t, StreamedBulk{Len: 5}, res)
+	assert.Equal(t, "hello", buf.String())
+
+	assert.Equal(t, "OK", ReadResponseTo(b, &buf))
+	assert.Nil(t, ReadResponseTo(b, &buf))
+
+	// sink error doesn't break stream
+	res = ReadResponseTo(b, &failingWriter{})
+	checkErrType(t, res, ErrSinkWrite)
+	assert.Equal(t, int64(1), ReadResponseTo(b, &buf))
+
+	res = ReadResponseTo(b, &buf)
+	checkErrType(t, res, ErrIO)
+
+	res = ReadResponseTo(lines2bufio("$2\r\nabcd"), &buf)
+	checkErrType(t, res, ErrNoFinalRN)
+}
+
+func TestReadResponseAttributes(t *testing.T) {
+	attr := "|1\r\n+key-popularity\r\n*2\r\n$1\r\na\r\n:100\r\n"
+	reply := "%2\r\n+first\r\n:1\r\n+second\r\n:2\r\n"
+	expect := []interface{}{"first", int64(1), "second", int64(2)}
+
+	// by default attribute is skipped
+	assert.Equal(t, expect, readLines(attr, reply))
+	assert.Equal(t, []interface{}{int64(1), "OK"}, readLines("*2\r\n", attr, ":1\r\n", "+OK\r\n"))
+
+	res := ReadResponseWithAttributes(lines2bufio(attr, reply))
+	assert.Equal(t, WithAttributes{
+		Attributes: []interface{}{"key-popularity", []interface{}{[]byte("a"), int64(100)}},
+		Value:      expect,
+	}, res)
+	assert.Equal(t, "OK", ReadResponseWithAttributes(lines2bufio("+OK\r\n")))
+
+	var buf strings.Builder
+	assert.Equal(t, StreamedBulk{Len: 5}, ReadResponseTo(lines2bufio(attr, "$5\r\nhello\r\n"), &buf))
+	assert.Equal(t, "hello", buf.String())
+
+	checkErrType(t, readLines("|1\r\n+key\r\n"), ErrIO)
+	checkErrType(t, readLines("|-1\r\n+OK\r\n"), ErrResponseFormat)
+	checkErrType(t, ReadResponseLimit(lines2bufio("|1000\r\n+OK\r\n"), 64), ErrResponseTooLarge)
+
+	b := lines2bufio(attr, attr, "+OK\r\n")
+	attrs, rerr := ReadAttributes(b)
+	assert.Nil(t, rerr)
+	assert.Len(t, attrs, 4)
+	assert.Equal(t, "OK", ReadResponse(b))
+	attrs, rerr = ReadAttributes(lines2bufio("+OK\r\n"))
+	assert.Nil(t, rerr)
+	assert.Nil(t, attrs)
+}
+
+func TestReadResponseLimit(t *testing.T) {
+	reply := "*2\r\n$5\r\nhello\r\n$5\r\nworld\r\n"
+	expect := []interface{}{[]byte("hello"), []byte("world")}
+	assert.Equal(t, expect, ReadResponseLimit(lines2bufio(reply), int64(len(reply))))
+	checkErrType(t, ReadResponseLimit(lines2bufio(reply), int64(len(reply))-1), ErrResponseTooLarge)
+
+	// huge answers are rejected before allocation
+	checkErrType(t, ReadResponseLimit(lines2bufio("$1000000000000\r\n"), 1<<20), ErrResponseTooLarge)
+	checkErrType(t, ReadResponseLimit(lines2bufio("*1000000000000\r\n"), 1<<20), ErrResponseTooLarge)
+	checkErrType(t, ReadResponseLimit(lines2bufio("%400000\r\n"), 1<<20), ErrResponseTooLarge)
+
+	// attributes are counted as well
+	attr := "|1\r\n+key-popularity\r\n:100\r\n"
+	assert.Equal(t, "OK", ReadResponseLimit(lines2bufio(attr, "+OK\r\n"), int64(len(attr)+5)))
+	checkErrType(t, ReadResponseLimit(lines2bufio(attr, "+OK\r\n"), int64(len(attr)+4)), ErrResponseTooLarge)
+
+	// other errors are returned as is
+	checkErrType(t, ReadResponseLimit(lines2bufio("$5\r\nhel"), 100), ErrIO)
+	checkErrType(t, ReadResponseLimit(lines2bufio("-ERR oops\r\n"), 100), ErrResult)
+}
+
+func TestReadResponseResp3Types(t *testing.T) {
+	assert.Nil(t, readLines("_\r\n"))
+	assert.Equal(t, []byte("3.14"), readLines(",3.14\r\n"))
+	assert.Equal(t, []byte("-inf"), readLines(",-inf\r\n"))
+	assert.Equal(t, []byte("3492890328409238509324850943850943825024385"),
+		readLines("(3492890328409238509324850943850943825024385\r\n"))
+	assert.Equal(t, int64(1), readLines("#t\r\n"))
+	assert.Equal(t, int64(0), readLines("#f\r\n"))
+	checkErrType(t, readLines("#x\r\n"), ErrResponseFormat)
+	assert.Equal(t, []byte("Some string"), readLines("=15\r\ntxt:Some string\r\n"))
+	assert.Equal(t, []interface{}{int64(1), []byte("a")}, readLines("~2\r\n:1\r\n$1\r\na\r\n"))
+	assert.Equal(t, []interface{}{[]byte("message"), []byte("ch"), []byte("hi")},
+		readLines(">3\r\n$7\r\nmessage\r\n$2\r\nch\r\n$2\r\nhi\r\n"))
+
+	res := readLines("!21\r\nSYNTAX invalid syntax\r\n")
+	checkErrType(t, res, ErrResult)
+	assert.Equal(t, "SYNTAX", ErrorPrefix(res.(error)))
+}
+
+func TestReadResponseIntegerVsBulk(t *testing.T) {
+	// INCR-like integer reply is int64, while GET of numeric value is bulk string.
+	assert.Equal(t, int64(42), readLines(":42\r\n"))
+	assert.Equal(t, []byte("42"), readLines("$2\r\n42\r\n"))
+	assert.Equal(t, []interface{}{int64(42), []byte("42")}, readLines("*2\r\n:42\r\n$2\r\n42\r\n"))
+}
@@ -1,6 +1,10 @@
 package redis
 
-import "fmt"
+import (
+	"fmt"
+	"io"
+	"strings"
+)
 
 // Req - convenient wrapper to create Request.
 func Req(cmd string, args ...interface{}) Request {
@@ -35,23 +39,30 @@ func (r Request) String() string {
 }
 
 // Key returns first field of request that should be used as a key for redis cluster.
+// Request which could be sent to any node (RANDOMKEY, or EVAL, FCALL, OBJECT HELP etc without keys)
+// returns "RANDOMKEY" and false.
 func (r Request) Key() (string, bool) {
-	if r.Cmd == "RANDOMKEY" {
+	if strings.EqualFold(r.Cmd, "RANDOMKEY") {
 		return "RANDOMKEY", false
 	}
-	var n int
-	switch r.Cmd {
-	case "EVAL", "EVALSHA":
-		n = 2
-	case "BITOP":
-		n = 1
-	default:
-		n = 0
+	name := r.Cmd
+	if space := strings.IndexByte(name, ' '); space >= 0 {
+		name = name[:space]
 	}
-	if len(r.Args) <= n {
+	if checkSet(name, irregularKeys) {
+		idx, err := keyIndexes(r)
+		if err != nil {
+			return "", false
+		}
+		if len(idx) == 0 {
+			return "RANDOMKEY", false
+		}
+		return ArgToString(r.Args[idx[0]])
+	}
+	if len(r.Args) == 0 {
 		return "", false
 	}
-	return ArgToString(r.Args[n])
+	return ArgToString(r.Args[0])
 }
 
 // Future is interface accepted by Sender to signal request completion.
@@ -66,6 +77,92 @@ type Future interface {
 	Cancelled() error
 }
 
+// Discard is a Future which ignores result. Pass it to Send for fire-and-forget requests
+// (metrics, access logs) to state result is not needed: errors are silently dropped.
+// It doesn't allocate.
+var Discard Future = discard{}
+
+type discard struct{}
+
+func (discard) Resolve(interface{}, uint64) {}
+func (discard) Cancelled() error            { return nil }
+
+// StreamingFuture is a Future which wants bulk string response to be copied directly into Sink
+// instead of being allocated. Then Resolve receives StreamedBulk (or nil, or error).
+// It is useful for large values. It is recognized only by redisconn.Connection.Send, and only if
+// it is passed as is (ie not wrapped by cluster or other wrapper).
+type StreamingFuture interface {
+	Future
+	// Sink returns writer bulk string should be copied to.
+	Sink() io.Writer
+}
+
+// TypedFuture is a Future which wants to know RESP type of response: leading type byte
+// ('+', '-', ':', '$', '*', or RESP3 types like ',', '#', '%'). If future implements it, ResolveTyped
+// is called instead of Resolve for responses read from socket. Errors not received from redis
+// (io errors, etc) are passed to Resolve.
+// Integer reply (':') is always int64 and bulk string ('$') is always []byte, so caller could tell
+// value redis computed as integer (INCR) from numeric string (GET) without parsing it.
+// As StreamingFuture, it is recognized only by redisconn.Connection and only if it is not wrapped.
+type TypedFuture interface {
+	Future
+	ResolveTyped(res interface{}, n uint64, respType byte)
+}
+
+// AttributesFuture is a Future which wants RESP3 attributes ('|' type) preceding response.
+// If response is preceded by attributes, ResolveAttributes is called with them (as flat list
+// of key-value pairs, like ReadAttributes returns) right before response is resolved.
+// Attributes of nested elements are skipped.
+// As StreamingFuture, it is recognized only by redisconn.Connection and only if it is not wrapped.
+type AttributesFuture interface {
+	Future
+	ResolveAttributes(attrs []interface{}, n uint64)
+}
+
+// LimitedFuture is a Future which limits size of response: if response exceeds MaxResponseBytes
+// (counting RESP framing), reading is aborted and Resolve receives ErrResponseTooLarge.
+// Since rest of response could not be skipped cleanly, connection is reset: requests sent after
+// this one fail with the same error, and connection reconnects.
+// As StreamingFuture, it is recognized only by redisconn.Connection and only if it is not wrapped.
+// Limit is not applied to StreamingFuture.
+type LimitedFuture interface {
+	Future
+	MaxResponseBytes() int64
+}
+
+// WithMaxResponseBytes returns LimitedFuture which passes response to cb.
+func WithMaxResponseBytes(cb Future, limit int64) LimitedFuture {
+	return limitedFuture{cb, limit}
+}
+
+type limitedFuture struct {
+	Future
+	limit int64
+}
+
+func (f limitedFuture) MaxResponseBytes() int64 { return f.limit }
+
+// RequestFuture is a Future which wants to know request result belongs to, for example, to log command
+// which produced an error. If future implements it, ResolveReq is called instead of Resolve.
+// As StreamingFuture, it is recognized only by redisconn.Connection and only if it is not wrapped
+// (so it is not recognized by SendTransaction, which resolves future with EXEC result only).
+type RequestFuture interface {
+	Future
+	ResolveReq(req Request, res interface{}, n uint64)
+}
+
+// FuncFutureReq simple wrapper that makes RequestFuture from function.
+type FuncFutureReq func(req Request, res interface{}, n uint64)
+
+// Cancelled implements Future.Cancelled (always false)
+func (f FuncFutureReq) Cancelled() error { return nil }
+
+// Resolve implements Future.Resolve (by calling wrapped function with empty request).
+func (f FuncFutureReq) Resolve(res interface{}, n uint64) { f(Request{}, res, n) }
+
+// ResolveReq implements RequestFuture.ResolveReq (by calling wrapped function).
+func (f FuncFutureReq) ResolveReq(req Request, res interface{}, n uint64) { f(req, res, n) }
+
 // FuncFuture simple wrapper that makes Future from function.
 type FuncFuture func(res interface{}, n uint64)
 
@@ -1,8 +1,12 @@
 package redis_test
 
 import (
+	"math"
+	"strconv"
 	"testing"
 
+	"github.com/joomcode/errorx"
+
 	. "github.com/joomcode/redispipe/redis"
 	"github.com/stretchr/testify/assert"
 )
@@ -344,3 +348,115 @@ func TestAppendRequestCmdAndArgcount(t *testing.T) {
 	assert.Equal(t, []byte("*5\r\n$3\r\nCMD\r\n$7\r\nONE TWO\r\n$2\r\nhi\r\n$2\r\nho\r\n$2\r\nhu\r\n"), k)
 	assert.Nil(t, err)
 }
+
+func TestAppendRequestIntegerLengths(t *testing.T) {
+	check := func(arg interface{}, str string) {
+		b, err := AppendRequest(nil, Req("CMD", arg))
+		assert.Nil(t, err)
+		expect := "*2\r\n$3\r\nCMD\r\n$" + strconv.Itoa(len(str)) + "\r\n" + str + "\r\n"
+		assert.Equal(t, expect, string(b), "argument %s", str)
+	}
+
+	p := uint64(1)
+	for k := 0; k < 20; k++ {
+		for _, u := range []uint64{p - 1, p, p + 1} {
+			check(u, strconv.FormatUint(u, 10))
+			if u <= math.MaxInt64 {
+				i := int64(u)
+				check(i, strconv.FormatInt(i, 10))
+				check(-i, strconv.FormatInt(-i, 10))
+			}
+		}
+		p *= 10
+	}
+	check(uint64(math.MaxUint64), strconv.FormatUint(math.MaxUint64, 10))
+	check(int64(math.MaxInt64), strconv.FormatInt(math.MaxInt64, 10))
+	check(int64(math.MinInt64), strconv.FormatInt(math.MinInt64, 10))
+}
+
+func TestRequestSize(t *testing.T) {
+	reqs := []Request{
+		Req("PING"),
+		Req("CLIENT LIST"),
+		Req("SET", "key", "value"),
+		Req("SET", []byte("key"), []byte{}),
+		Req("MSET", "a", nil, "b", true, "c", false),
+		Req("ZADD", "z", 1.5, "a", float32(-0.25), "b", 1e21, "c"),
+		Req("CMD", int8(-128), uint8(255), int16(-32768), uint16(65535)),
+		Req("CMD", int32(math.MinInt32), uint32(math.MaxUint32), int(-1), uint(10)),
+		Req("CMD", int64(math.MinInt64), int64(math.MaxInt64), uint64(math.MaxUint64)),
+		Req("CMD", string(make([]byte, 9)), string(make([]byte, 10)), make([]byte, 100), make([]byte, 12345)),
+	}
+	args := make([]interface{}, 12)
+	reqs = append(reqs, Req("LONG", args...), Req("LONG MANY", append(args, args...)...))
+	p := uint64(1)
+	for k := 0; k < 20; k++ {
+		reqs = append(reqs, Req("CMD", p-1, p, int64(p-1), -int64(p-1)))
+		p *= 10
+	}
+
+	for _, req := range reqs {
+		b, err := AppendRequest(nil, req)
+		assert.Nil(t, err)
+		size, err := RequestSize(req)
+		assert.Nil(t, err)
+		assert.Equal(t, len(b), size, "request %v", req)
+	}
+
+	_, err := RequestSize(Req("CMD", struct{}{}))
+	assert.Error(t, err)
+}
+
+func TestEncodePipeline(t *testing.T) {
+	reqs := []Request{Req("GET", "one"), Req("INCRBY", "cnt", 5), Req("SET", "k", []byte("v"), "EX", 10)}
+	var expect []byte
+	for _, req := range reqs {
+		expect, _ = AppendRequest(expect, req)
+	}
+	buf, err := EncodePipeline(reqs, []byte("prefix"))
+	assert.Nil(t, err)
+	assert.Equal(t, "prefix"+string(expect), string(buf))
+
+	bad := append(reqs[:2:2], Req("SET", "k", struct{}{}), Req("GET", "k"))
+	buf, err = EncodePipeline(bad, []byte("prefix"))
+	assert.Equal(t, "prefix", string(buf))
+	if assert.Error(t, err) {
+		pos, _ := err.(*errorx.Error).Property(EKRequestPos)
+		assert.Equal(t, 2, pos)
+	}
+}
+
+func BenchmarkEncodePipeline(b *testing.B) {
+	reqs := make([]Request, 10000)
+	val := make([]byte, 64)
+	for i := range reqs {
+		if i%2 == 0 {
+			reqs[i] = Req("GET", "key:"+strconv.Itoa(i))
+		} else {
+			reqs[i] = Req("SET", "key:"+strconv.Itoa(i), val, "EX", 3600)
+		}
+	}
+	var buf []byte
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var err error
+		if buf, err = EncodePipeline(reqs, buf[:0]); err != nil {
+			b.Fatal(err)
+		}
+	}
+	b.SetBytes(int64(len(buf)))
+}
+
+func TestDiscard(t *testing.T) {
+	s := &fakeSender{handler: func(Request) interface{} { return ErrIO.New("dropped") }}
+	s.Send(Req("SET", "k", "v"), Discard, 0)
+	assert.Equal(t, []Request{Req("SET", "k", "v")}, s.sent())
+	assert.Nil(t, Discard.Cancelled())
+
+	allocs := testing.AllocsPerRun(100, func() {
+		var f Future = Discard
+		f.Resolve(nil, 1)
+	})
+	assert.Equal(t, 0.0, allocs)
+}
@@ -2,6 +2,7 @@ package redis
 
 import (
 	"strconv"
+	"strings"
 
 	"github.com/joomcode/errorx"
 )
@@ -131,41 +132,110 @@ func appendHead(b []byte, t byte, i int) []byte {
 }
 
 func appendBulkInt(b []byte, i int64) []byte {
-	if i >= -99999999 && i <= 999999999 {
-		b = append(b, '$', '0', '\r', '\n')
-	} else {
-		b = append(b, '$', '0', '0', '\r', '\n')
-	}
-	l := len(b)
+	b = appendHead(b, '$', intLen(i))
 	b = appendInt(b, i)
-	li := byte(len(b) - l)
-	if li < 10 {
-		b[l-3] = li + '0'
-	} else {
-		d := li / 10
-		b[l-4] = d + '0'
-		b[l-3] = li - (d * 10) + '0'
-	}
 	return b
 }
 
 func appendBulkUint(b []byte, i uint64) []byte {
-	if i <= 999999999 {
-		b = append(b, '$', '0', '\r', '\n')
-	} else {
-		b = append(b, '$', '0', '0', '\r', '\n')
-	}
-	l := len(b)
+	b = appendHead(b, '$', uintLen(i))
 	b = appendUint(b, i)
-	li := byte(len(b) - l)
-	if li < 10 {
-		b[l-3] = li + '0'
+	return b
+}
+
+// EncodePipeline appends all requests to dst with AppendRequest.
+// It stops at first malformed request and returns its error with EKRequestPos property set to its index.
+// In case of error returned buffer is truncated to original size of dst, so it still could be reused.
+func EncodePipeline(reqs []Request, dst []byte) ([]byte, error) {
+	oldSize := len(dst)
+	for i, req := range reqs {
+		var err error
+		if dst, err = AppendRequest(dst, req); err != nil {
+			return dst[:oldSize], err.(*errorx.Error).WithProperty(EKRequestPos, i)
+		}
+	}
+	return dst, nil
+}
+
+// uintLen returns number of decimal digits in u.
+func uintLen(u uint64) int {
+	n := 1
+	for u >= 10 {
+		u /= 10
+		n++
+	}
+	return n
+}
+
+// intLen returns length of decimal representation of i including sign.
+func intLen(i int64) int {
+	if i < 0 {
+		return uintLen(uint64(-i)) + 1
+	}
+	return uintLen(uint64(i))
+}
+
+// headLen returns length of "$n\r\n" or "*n\r\n" header.
+func headLen(n int) int {
+	return 1 + uintLen(uint64(n)) + 2
+}
+
+// RequestSize returns exact length of request serialized by AppendRequest.
+// It returns same error AppendRequest would return.
+func RequestSize(req Request) (int, error) {
+	var size int
+	if space := strings.IndexByte(req.Cmd, ' '); space == -1 {
+		size = headLen(len(req.Args)+1) + headLen(len(req.Cmd)) + len(req.Cmd) + 2
 	} else {
-		d := li / 10
-		b[l-4] = d + '0'
-		b[l-3] = li - (d * 10) + '0'
+		size = headLen(len(req.Args)+2) +
+			headLen(space) + space + 2 +
+			headLen(len(req.Cmd)-space-1) + len(req.Cmd) - space - 1 + 2
 	}
-	return b
+	var fbuf [32]byte
+	for i, val := range req.Args {
+		n := 0
+		switch v := val.(type) {
+		case string:
+			n = len(v)
+		case []byte:
+			n = len(v)
+		case int:
+			n = intLen(int64(v))
+		case uint:
+			n = uintLen(uint64(v))
+		case int64:
+			n = intLen(v)
+		case uint64:
+			n = uintLen(v)
+		case int32:
+			n = intLen(int64(v))
+		case uint32:
+			n = uintLen(uint64(v))
+		case int8:
+			n = intLen(int64(v))
+		case uint8:
+			n = uintLen(uint64(v))
+		case int16:
+			n = intLen(int64(v))
+		case uint16:
+			n = uintLen(uint64(v))
+		case bool:
+			n = 1
+		case float32:
+			n = len(strconv.AppendFloat(fbuf[:0], float64(v), 'f', -1, 32))
+		case float64:
+			n = len(strconv.AppendFloat(fbuf[:0], v, 'f', -1, 64))
+		case nil:
+			n = 0
+		default:
+			return 0, ErrArgumentType.NewWithNoMessage().
+				WithProperty(EKVal, val).
+				WithProperty(EKArgPos, i).
+				WithProperty(EKRequest, req)
+		}
+		size += headLen(n) + n + 2
+	}
+	return size, nil
 }
 
 // ArgToString returns string representataion of an argument.
@@ -70,3 +70,102 @@ func TransactionResponse(res interface{}) ([]interface{}, error) {
 	}
 	return nil, res.(error)
 }
+
+func unexpectedResponse(res interface{}) *errorx.Error {
+	return ErrResponseUnexpected.NewWithNoMessage().WithProperty(EKResponse, res)
+}
+
+// responseInt converts integer response.
+func responseInt(res interface{}) (int64, error) {
+	switch v := res.(type) {
+	case int64:
+		return v, nil
+	case error:
+		return 0, v
+	}
+	return 0, unexpectedResponse(res)
+}
+
+// responseString converts bulk or simple string response.
+func responseString(res interface{}) (string, error) {
+	switch v := res.(type) {
+	case []byte:
+		return string(v), nil
+	case string:
+		return v, nil
+	case error:
+		return "", v
+	}
+	return "", unexpectedResponse(res)
+}
+
+// responseBytes converts bulk string response without copying.
+func responseBytes(res interface{}) ([]byte, error) {
+	switch v := res.(type) {
+	case []byte:
+		return v, nil
+	case error:
+		return nil, v
+	}
+	return nil, unexpectedResponse(res)
+}
+
+// responseArray converts array response.
+func responseArray(res interface{}) ([]interface{}, error) {
+	switch v := res.(type) {
+	case []interface{}:
+		return v, nil
+	case error:
+		return nil, v
+	}
+	return nil, unexpectedResponse(res)
+}
+
+// responseStrings converts array of strings response.
+func responseStrings(res interface{}) ([]string, error) {
+	arr, err := responseArray(res)
+	if err != nil {
+		return nil, err
+	}
+	strs := make([]string, len(arr))
+	for i, v := range arr {
+		if strs[i], err = responseString(v); err != nil {
+			return nil, unexpectedResponse(res)
+		}
+	}
+	return strs, nil
+}
+
+// responsePairs converts list of pairs (like member and score of ZRANGE WITHSCORES) to flat list of strings.
+// RESP2 returns pairs interleaved in flat list, and RESP3 returns list of two-element arrays,
+// so both shapes are accepted.
+func responsePairs(res interface{}) ([]string, error) {
+	arr, err := responseArray(res)
+	if err != nil {
+		return nil, err
+	}
+	if len(arr) == 0 {
+		return nil, nil
+	}
+	if _, nested := arr[0].([]interface{}); !nested {
+		if len(arr)%2 != 0 {
+			return nil, unexpectedResponse(res)
+		}
+		return responseStrings(res)
+	}
+	strs := make([]string, 0, 2*len(arr))
+	for _, v := range arr {
+		pair, ok := v.([]interface{})
+		if !ok || len(pair) != 2 {
+			return nil, unexpectedResponse(res)
+		}
+		for _, e := range pair {
+			str, err := responseString(e)
+			if err != nil {
+				return nil, unexpectedResponse(res)
+			}
+			strs = append(strs, str)
+		}
+	}
+	return strs, nil
+}
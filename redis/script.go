@@ -0,0 +1,41 @@
+package redis
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+)
+
+// Script is a Lua script which is called with EVALSHA, so its source is not sent with every call.
+// If script is not in server's cache yet (NOSCRIPT error), it is called with EVAL, which caches it.
+// Script should access only keys passed to it, so it works with cluster (all keys should be in same slot).
+type Script struct {
+	// Src is a source code of script.
+	Src string
+	// Sha1 is a hex-encoded SHA1 digest of Src, as used by EVALSHA.
+	Sha1 string
+}
+
+// NewScript returns Script with computed digest.
+func NewScript(src string) *Script {
+	sum := sha1.Sum([]byte(src))
+	return &Script{Src: src, Sha1: hex.EncodeToString(sum[:])}
+}
+
+// EvalSha returns EVALSHA request for script.
+func (sc *Script) EvalSha(keys []string, args []interface{}) Request {
+	return Request{"EVALSHA", keysAndArgs(sc.Sha1, keys, args)}
+}
+
+// Eval returns EVAL request for script.
+func (sc *Script) Eval(keys []string, args []interface{}) Request {
+	return Request{"EVAL", keysAndArgs(sc.Src, keys, args)}
+}
+
+// Run calls script with EVALSHA, and falls back to EVAL on NOSCRIPT error.
+func (sc *Script) Run(s Sender, keys []string, args []interface{}) (interface{}, error) {
+	res := Sync{s}.Send(sc.EvalSha(keys, args))
+	if ErrorPrefix(AsError(res)) == "NOSCRIPT" {
+		res = Sync{s}.Send(sc.Eval(keys, args))
+	}
+	return res, AsError(res)
+}
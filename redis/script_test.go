@@ -0,0 +1,70 @@
+package redis_test
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/joomcode/redispipe/redis"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScript(t *testing.T) {
+	sc := NewScript("return 1")
+	assert.Equal(t, "e0e1f9fabfc9d4800c877a703b823ac0578ff8db", sc.Sha1)
+
+	loaded := false
+	s := &fakeSender{handler: func(r Request) interface{} {
+		switch r.Cmd {
+		case "EVALSHA":
+			if !loaded {
+				return ErrResult.New("NOSCRIPT No matching script. Please use EVAL.")
+			}
+		case "EVAL":
+			loaded = true
+		}
+		return int64(1)
+	}}
+	res, err := sc.Run(s, []string{"k"}, []interface{}{"a"})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), res)
+	assert.Equal(t, []Request{
+		{"EVALSHA", []interface{}{sc.Sha1, 1, "k", "a"}},
+		{"EVAL", []interface{}{"return 1", 1, "k", "a"}},
+	}, s.sent())
+
+	_, err = sc.Run(s, nil, nil)
+	assert.NoError(t, err)
+	assert.Len(t, s.sent(), 3)
+	key, ok := s.sent()[0].Key()
+	assert.True(t, ok)
+	assert.Equal(t, "k", key)
+}
+
+func TestRateLimiter(t *testing.T) {
+	var reply []interface{}
+	s := &fakeSender{handler: func(r Request) interface{} {
+		return reply
+	}}
+	l := NewRateLimiter(s, "rl:")
+
+	reply = []interface{}{int64(1), int64(4), int64(0)}
+	allowed, remaining, retry, err := l.Allow("user:1", 5, time.Second)
+	assert.NoError(t, err)
+	assert.True(t, allowed)
+	assert.Equal(t, 4, remaining)
+	assert.Equal(t, time.Duration(0), retry)
+	req := s.sent()[0]
+	assert.Equal(t, "EVALSHA", req.Cmd)
+	assert.Equal(t, []interface{}{1, "rl:user:1", 5, int64(1000)}, req.Args[1:])
+
+	reply = []interface{}{int64(0), int64(0), int64(150)}
+	allowed, remaining, retry, err = l.Allow("user:1", 5, time.Second)
+	assert.NoError(t, err)
+	assert.False(t, allowed)
+	assert.Equal(t, 0, remaining)
+	assert.Equal(t, 150*time.Millisecond, retry)
+
+	_, _, _, err = l.Allow("user:1", 0, time.Second)
+	assert.Error(t, err)
+	assert.Len(t, s.sent(), 2)
+}
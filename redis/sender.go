@@ -2,6 +2,7 @@ package redis
 
 import (
 	"errors"
+	"strings"
 )
 
 // Sender is interface of client implementation.
@@ -57,6 +58,10 @@ type ScanOpts struct {
 	Match string
 	// Count - soft-limit of single *SCAN answer
 	Count int
+	// Type - filter keys by type of value on server side (SCAN only, redis >= 6.0),
+	// for example "stream" or KeyTypeStream.String(). Older servers reject it,
+	// and scanner fails with ErrScanTypeUnsupported.
+	Type string
 }
 
 // Request returns corresponding request to be send.
@@ -69,7 +74,8 @@ func (s ScanOpts) Request(it []byte) Request {
 	if s.Cmd == "" {
 		s.Cmd = "SCAN"
 	}
-	if s.Cmd != "SCAN" {
+	scan := strings.EqualFold(s.Cmd, "SCAN")
+	if !scan {
 		args = append(args, s.Key)
 	}
 	args = append(args, it)
@@ -79,6 +85,9 @@ func (s ScanOpts) Request(it []byte) Request {
 	if s.Count > 0 {
 		args = append(args, "COUNT", s.Count)
 	}
+	if s.Type != "" && scan {
+		args = append(args, "TYPE", s.Type)
+	}
 	return Request{s.Cmd, args}
 }
 
@@ -115,6 +124,10 @@ func (s *ScannerBase) Cancelled() error {
 func (s *ScannerBase) Resolve(res interface{}, _ uint64) {
 	var keys []string
 	s.Iter, keys, s.Err = ScanResponse(res)
+	if s.Type != "" && s.Err != nil && ErrorPrefix(s.Err) == "ERR" {
+		// redis < 6.0 answers "ERR syntax error" to unknown option
+		s.Err = ErrScanTypeUnsupported.WrapWithNoMessage(s.Err)
+	}
 	cb := s.cb
 	s.cb = nil
 	if s.Err != nil {
@@ -0,0 +1,36 @@
+package redis_test
+
+import (
+	"testing"
+
+	. "github.com/joomcode/redispipe/redis"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScanType(t *testing.T) {
+	opts := ScanOpts{Match: "audit:*", Count: 100, Type: KeyTypeStream.String()}
+	assert.Equal(t, Request{"SCAN", []interface{}{[]byte("0"), "MATCH", "audit:*", "COUNT", 100, "TYPE", "stream"}},
+		opts.Request(nil))
+	// TYPE is an option of SCAN only
+	assert.Equal(t, Request{"HSCAN", []interface{}{"h", []byte("0")}},
+		ScanOpts{Cmd: "HSCAN", Key: "h", Type: "stream"}.Request(nil))
+
+	var res interface{}
+	done := FuncFuture(func(r interface{}, _ uint64) { res = r })
+
+	s := &fakeSender{handler: func(r Request) interface{} {
+		return []interface{}{[]byte("0"), []interface{}{[]byte("audit:1")}}
+	}}
+	scanner := &ScannerBase{ScanOpts: opts}
+	scanner.DoNext(done, s)
+	assert.Equal(t, []string{"audit:1"}, res)
+	assert.True(t, scanner.IterLast())
+
+	old := &fakeSender{handler: func(r Request) interface{} {
+		return ErrResult.New("ERR syntax error")
+	}}
+	scanner = &ScannerBase{ScanOpts: opts}
+	scanner.DoNext(done, old)
+	assert.True(t, IsOfType(AsError(res), ErrScanTypeUnsupported))
+	assert.Equal(t, "ERR", ErrorPrefix(AsError(res)))
+}
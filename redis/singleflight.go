@@ -0,0 +1,144 @@
+package redis
+
+import "sync"
+
+// SingleFlight is a Sender wrapper which coalesces concurrent identical requests: while request is in flight,
+// same request (same command and arguments) sent through SingleFlight is not sent again, but waits for result
+// of the first one. It reduces load when many goroutines read same hot key at once (cache stampede).
+//
+// It makes sense only for idempotent reads: coalesced request could observe result computed slightly before
+// it were sent. Requests are coalesced if Coalesce returns true, which is ReplicaSafe by default.
+// Every waiter receives its own copy of result, so it could be modified safely.
+// Transactions are never coalesced.
+type SingleFlight struct {
+	// S is a sender requests are sent through.
+	S Sender
+	// Coalesce reports whether request could be coalesced. Default is ReplicaSafe(req.Cmd).
+	Coalesce func(req Request) bool
+
+	m       sync.Mutex
+	flights map[string]*flight
+}
+
+// NewSingleFlight returns SingleFlight over sender s.
+func NewSingleFlight(s Sender) *SingleFlight {
+	return &SingleFlight{S: s}
+}
+
+// flight is a request in flight, which is a Future for all its waiters.
+type flight struct {
+	sf      *SingleFlight
+	key     string
+	waiters []flightWaiter
+}
+
+type flightWaiter struct {
+	cb Future
+	n  uint64
+}
+
+// Send implements Sender.Send
+func (sf *SingleFlight) Send(req Request, cb Future, n uint64) {
+	if cb == nil || !sf.coalesce(req) {
+		sf.S.Send(req, cb, n)
+		return
+	}
+	key, err := cacheKey(req)
+	if err != nil {
+		// malformed request is reported by S.
+		sf.S.Send(req, cb, n)
+		return
+	}
+	sf.m.Lock()
+	if f, ok := sf.flights[key]; ok {
+		f.waiters = append(f.waiters, flightWaiter{cb, n})
+		sf.m.Unlock()
+		return
+	}
+	if sf.flights == nil {
+		sf.flights = make(map[string]*flight)
+	}
+	f := &flight{sf: sf, key: key, waiters: []flightWaiter{{cb, n}}}
+	sf.flights[key] = f
+	sf.m.Unlock()
+	sf.S.Send(req, f, 0)
+}
+
+// SendMany implements Sender.SendMany
+func (sf *SingleFlight) SendMany(reqs []Request, cb Future, n uint64) {
+	for i, req := range reqs {
+		sf.Send(req, cb, n+uint64(i))
+	}
+}
+
+// SendTransaction implements Sender.SendTransaction. Transaction is sent to S as is.
+func (sf *SingleFlight) SendTransaction(reqs []Request, cb Future, n uint64) {
+	sf.S.SendTransaction(reqs, cb, n)
+}
+
+// Scanner implements Sender.Scanner
+func (sf *SingleFlight) Scanner(opts ScanOpts) Scanner {
+	return sf.S.Scanner(opts)
+}
+
+// EachShard implements Sender.EachShard
+func (sf *SingleFlight) EachShard(cb func(Sender, error) bool) {
+	sf.S.EachShard(cb)
+}
+
+// Close implements Sender.Close
+func (sf *SingleFlight) Close() {
+	sf.S.Close()
+}
+
+func (sf *SingleFlight) coalesce(req Request) bool {
+	if sf.Coalesce != nil {
+		return sf.Coalesce(req)
+	}
+	return ReplicaSafe(req.Cmd)
+}
+
+// Cancelled implements Future.Cancelled. Request is cancelled only if all its waiters are cancelled.
+func (f *flight) Cancelled() error {
+	f.sf.m.Lock()
+	defer f.sf.m.Unlock()
+	var err error
+	for _, w := range f.waiters {
+		if err = w.cb.Cancelled(); err == nil {
+			return nil
+		}
+	}
+	return err
+}
+
+// Resolve implements Future.Resolve. Later waiters receive copies of result.
+func (f *flight) Resolve(res interface{}, _ uint64) {
+	f.sf.m.Lock()
+	delete(f.sf.flights, f.key)
+	waiters := f.waiters
+	f.sf.m.Unlock()
+	for i, w := range waiters {
+		r := res
+		if i > 0 {
+			r = copyResult(res)
+		}
+		w.cb.Resolve(r, w.n)
+	}
+}
+
+// copyResult copies mutable parts of response: bulk strings and arrays.
+func copyResult(res interface{}) interface{} {
+	switch v := res.(type) {
+	case []byte:
+		c := make([]byte, len(v))
+		copy(c, v)
+		return c
+	case []interface{}:
+		c := make([]interface{}, len(v))
+		for i := range v {
+			c[i] = copyResult(v[i])
+		}
+		return c
+	}
+	return res
+}
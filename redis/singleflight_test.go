@@ -0,0 +1,60 @@
+package redis_test
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	. "github.com/joomcode/redispipe/redis"
+)
+
+func TestSingleFlight(t *testing.T) {
+	started := make(chan struct{}, 1)
+	release := make(chan struct{})
+	s := &fakeSender{handler: func(r Request) interface{} {
+		if r.Cmd == "GET" {
+			started <- struct{}{}
+			<-release
+			return []interface{}{[]byte("hot"), int64(1)}
+		}
+		return "OK"
+	}}
+	sf := NewSingleFlight(s)
+
+	const n = 10
+	var wg sync.WaitGroup
+	wg.Add(n)
+	results := make([]interface{}, n)
+	cb := FuncFuture(func(res interface{}, i uint64) {
+		results[i] = res
+		wg.Done()
+	})
+	go sf.Send(Req("GET", "hot"), cb, 0)
+	<-started
+	for i := 1; i < n; i++ {
+		sf.Send(Req("GET", "hot"), cb, uint64(i))
+	}
+	// not coalescable request is sent as is
+	assert.Equal(t, "OK", Sync{sf}.Do("SET", "hot", "1"))
+	close(release)
+	wg.Wait()
+
+	assert.Equal(t, []Request{Req("GET", "hot"), Req("SET", "hot", "1")}, s.sent())
+	for _, res := range results {
+		assert.Equal(t, []interface{}{[]byte("hot"), int64(1)}, res)
+	}
+	// every waiter owns its result
+	results[0].([]interface{})[0].([]byte)[0] = 'n'
+	assert.Equal(t, []byte("hot"), results[1].([]interface{})[0])
+
+	// request is sent again after previous one is resolved
+	assert.Equal(t, []interface{}{[]byte("hot"), int64(1)}, Sync{sf}.Do("GET", "hot"))
+	<-started
+	assert.Len(t, s.sent(), 3)
+
+	sf.Coalesce = func(Request) bool { return false }
+	Sync{sf}.Do("GET", "hot")
+	<-started
+	assert.Len(t, s.sent(), 4)
+}
@@ -117,6 +117,43 @@ func (s SyncCtx) Scanner(ctx context.Context, opts ScanOpts) SyncCtxIterator {
 	return SyncCtxIterator{ctx, s.S.Scanner(opts)}
 }
 
+// Bind returns SyncCtxBound which uses ctx for every request.
+func (s SyncCtx) Bind(ctx context.Context) SyncCtxBound {
+	return SyncCtxBound{s.S, ctx}
+}
+
+// SyncCtxBound is a SyncCtx bound to a context, so it need not be passed to every call.
+// It is convenient for request-scoped handler which makes several calls with the same deadline.
+type SyncCtxBound struct {
+	S   Sender
+	Ctx context.Context
+}
+
+// Do is convenient method to construct and send request. See SyncCtx.Do.
+func (s SyncCtxBound) Do(cmd string, args ...interface{}) interface{} {
+	return SyncCtx{s.S}.Send(s.Ctx, Request{cmd, args})
+}
+
+// Send sends request to redis. See SyncCtx.Send.
+func (s SyncCtxBound) Send(r Request) interface{} {
+	return SyncCtx{s.S}.Send(s.Ctx, r)
+}
+
+// SendMany sends several requests in "parallel". See SyncCtx.SendMany.
+func (s SyncCtxBound) SendMany(reqs []Request) []interface{} {
+	return SyncCtx{s.S}.SendMany(s.Ctx, reqs)
+}
+
+// SendTransaction sends several requests as MULTI+EXEC redis transaction. See SyncCtx.SendTransaction.
+func (s SyncCtxBound) SendTransaction(reqs []Request) ([]interface{}, error) {
+	return SyncCtx{s.S}.SendTransaction(s.Ctx, reqs)
+}
+
+// Scanner returns synchronous iterator over redis keyspace/key. See SyncCtx.Scanner.
+func (s SyncCtxBound) Scanner(opts ScanOpts) SyncCtxIterator {
+	return SyncCtx{s.S}.Scanner(s.Ctx, opts)
+}
+
 type active struct {
 	ctx context.Context
 	ch  chan struct{}
@@ -0,0 +1,38 @@
+package redis_test
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/joomcode/redispipe/redis"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSyncCtxBound(t *testing.T) {
+	s := &fakeSender{handler: func(r Request) interface{} { return r.Cmd }}
+	sc := SyncCtx{s}.Bind(context.Background())
+
+	assert.Equal(t, "GET", sc.Do("GET", "a"))
+	assert.Equal(t, []interface{}{"GET", "SET"}, sc.SendMany([]Request{Req("GET", "a"), Req("SET", "a", 1)}))
+	res, err := sc.SendTransaction([]Request{Req("INCR", "a")})
+	assert.NoError(t, err)
+	assert.Equal(t, []interface{}{"INCR"}, res)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	blocked := &blockedSender{fakeSender: s}
+	sc = SyncCtxBound{S: blocked, Ctx: ctx}
+	assert.True(t, IsOfType(AsError(sc.Do("GET", "a")), ErrRequestCancelled))
+	assert.True(t, IsOfType(AsError(sc.SendMany([]Request{Req("GET", "a")})[0]), ErrRequestCancelled))
+	_, err = sc.SendTransaction([]Request{Req("INCR", "a")})
+	assert.True(t, IsOfType(err, ErrRequestCancelled))
+}
+
+// blockedSender never resolves requests.
+type blockedSender struct {
+	*fakeSender
+}
+
+func (blockedSender) Send(Request, Future, uint64)              {}
+func (blockedSender) SendMany([]Request, Future, uint64)        {}
+func (blockedSender) SendTransaction([]Request, Future, uint64) {}
@@ -79,18 +79,39 @@ type Opts struct {
 	// Note, that MOVE and ASK redis errors will force configuration reloading,
 	// therefore there is not need to make it very frequent.
 	CheckInterval time.Duration
-	// MovedRetries - follow MOVED|ASK redirections this number of times
+	// MovedRetries - follow MOVED|ASK redirections this number of times for single request,
+	// then request fails with ErrTooManyRedirects.
 	// default: 3, min: 1, max: 10
 	MovedRetries int
 	// WaitToMigrate - wait this time if not all transaction keys were migrated
 	// from one shard to another and then repeat transaction.
 	// default: 20 millisecond, min: 100 microseconds, max: 100 milliseconds
 	WaitToMigrate time.Duration
+	// MaxKeysPerCommand - multi-key helpers (MGet, MSet, Del, etc) split group of keys of one slot
+	// into commands of at most this number of keys, so huge key sets don't produce giant commands.
+	// default: redis.DefaultChunkSize
+	MaxKeysPerCommand int
 	// Logger used for logging cluster events and account request stats
 	Logger Logger
 
 	// RoundRobinSeed - used to choose between master and replica.
 	RoundRobinSeed RoundRobinSeed
+	// NodeSelector - if set, it chooses node to send request with MasterAndSlaves or PreferSlaves policy,
+	// for example replica in the same availability zone. It is called with healthy nodes of slot's shard
+	// (with PreferSlaves master is included only if there is no healthy replica), and should return one of them.
+	// If chosen node has no usable connection, node is chosen with RoundRobinSeed.
+	// It is called for every request, so it should be fast.
+	// default: nil - node is chosen with RoundRobinSeed.
+	NodeSelector func(candidates []NodeInfo) NodeInfo
+}
+
+// NodeInfo describes node which could serve request (see Opts.NodeSelector).
+type NodeInfo struct {
+	// Addr is node's address as reported by CLUSTER SLOTS (it is hostname if cluster announces hostnames,
+	// see cluster-preferred-endpoint-type, so zone could be encoded in it).
+	Addr string
+	// Master is true for shard's master.
+	Master bool
 }
 
 // Cluster is implementation of redis.Sender which represents connection to redis-cluster.
@@ -108,6 +129,9 @@ type Cluster struct {
 	cancel context.CancelFunc
 
 	opts Opts
+	// rewrite is HostOpts.RequestRewriter. It is applied by cluster before slot is computed,
+	// so it is cleared in options of connections.
+	rewrite func(Request) Request
 
 	m sync.Mutex
 
@@ -131,6 +155,10 @@ type clusterConfig struct {
 	nodes   nodeMap
 
 	slots [redisclusterutil.NumSlots / 2]uint32
+
+	// ranges are slot ranges as reported by CLUSTER SLOTS, and version is incremented when they change.
+	ranges  []redisclusterutil.SlotsRange
+	version uint64
 }
 
 type shard struct {
@@ -175,6 +203,9 @@ func NewCluster(ctx context.Context, initAddrs []string, opts Opts) (*Cluster, e
 	}
 	cluster.ctx, cluster.cancel = context.WithCancel(ctx)
 
+	cluster.rewrite = cluster.opts.HostOpts.RequestRewriter
+	cluster.opts.HostOpts.RequestRewriter = nil
+
 	if cluster.opts.HostOpts.Logger == nil {
 		cluster.opts.HostOpts.Logger = defaultConnLogger{cluster}
 	}
@@ -211,6 +242,10 @@ func NewCluster(ctx context.Context, initAddrs []string, opts Opts) (*Cluster, e
 		cluster.opts.WaitToMigrate = 100 * time.Millisecond
 	}
 
+	if cluster.opts.MaxKeysPerCommand <= 0 {
+		cluster.opts.MaxKeysPerCommand = redis.DefaultChunkSize
+	}
+
 	config := &clusterConfig{
 		nodes:   make(nodeMap),
 		shards:  make(shardMap),
@@ -430,6 +465,16 @@ var dumb dumbcb
 // SendWithPolicy allows to choose master/replica policy for individual requests.
 // You can also call cluster.WithPolicy() to obtain redis.Sender with predefined policy.
 func (c *Cluster) SendWithPolicy(policy ReplicaPolicyEnum, req Request, cb Future, off uint64) {
+	c.sendWithPolicy(policy, req, cb, nil, off)
+}
+
+// SendWithMeta implements redis.MetaSender.SendWithMeta
+// Response meta reports whether response came from master or replica.
+func (c *Cluster) SendWithMeta(req Request, cb redis.FutureMeta, off uint64) {
+	c.sendWithPolicy(MasterOnly, req, cb, cb, off)
+}
+
+func (c *Cluster) sendWithPolicy(policy ReplicaPolicyEnum, req Request, cb Future, cbmeta redis.FutureMeta, off uint64) {
 	if cb == nil {
 		cb = &dumb
 	}
@@ -437,11 +482,13 @@ func (c *Cluster) SendWithPolicy(policy ReplicaPolicyEnum, req Request, cb Futur
 		cb.Resolve(c.errWrap(redis.ErrRequestCancelled, err).WithProperty(redis.EKRequest, req), off)
 		return
 	}
+	if c.rewrite != nil {
+		req = c.rewrite(req)
+	}
 
-	slot, ok := redisclusterutil.ReqSlot(req)
-	if !ok {
-		// Probably, redis-cluster is not configured properly yet, or it is broken at the moment.
-		err := c.err(redis.ErrNoSlotKey).WithProperty(redis.EKRequest, req)
+	slot, serr := redisclusterutil.CheckReqSlot(req)
+	if serr != nil {
+		err := c.addProps(serr.(*errorx.Error)).WithProperty(redis.EKRequest, req)
 		cb.Resolve(err, off)
 		return
 	}
@@ -459,6 +506,7 @@ func (c *Cluster) SendWithPolicy(policy ReplicaPolicyEnum, req Request, cb Futur
 		c:      c,
 		req:    req,
 		cb:     cb,
+		cbmeta: cbmeta,
 		off:    off,
 		slot:   slot,
 		policy: policy,
@@ -488,6 +536,8 @@ type request struct {
 	req Request
 	cb  Future
 	off uint64
+	// cbmeta is set when request was sent with SendWithMeta
+	cbmeta redis.FutureMeta
 
 	lastconn *redisconn.Connection   // last connection used for this request
 	seen     []*redisconn.Connection // all connection tried for this request so far
@@ -498,6 +548,7 @@ type request struct {
 	mayRetry bool
 	hardErrs uint8
 	redir    uint8
+	redirs   []string // addresses request were redirected to
 }
 
 var requestPool = sync.Pool{New: func() interface{} { return &request{} }}
@@ -509,7 +560,11 @@ func (r *request) resolve(res interface{}) {
 			err = r.c.addProps(err)
 			res = err
 		}
-		r.cb.Resolve(res, r.off)
+		if r.cbmeta != nil {
+			r.cbmeta.ResolveMeta(res, r.off, r.c.responseMeta(r.lastconn))
+		} else {
+			r.cb.Resolve(res, r.off)
+		}
 	}
 	*r = request{}
 	requestPool.Put(r)
@@ -589,6 +644,7 @@ func (r *request) Resolve(res interface{}, _ uint64) {
 			r.hardErrs = 0 // reset hardErrors because we are going to another physical shard.
 			r.seen = nil
 			addr := movedTo(err)
+			r.redirs = append(r.redirs, addr)
 			ask := false
 			if err.IsOfType(redis.ErrMoved) {
 				DebugEvent("moved")
@@ -612,7 +668,7 @@ func (r *request) Resolve(res interface{}, _ uint64) {
 			})
 			return
 		}
-		fallthrough
+		r.resolve(r.c.tooManyRedirects(err, r.redirs))
 	default:
 		// All other errors: just resolve.
 		r.resolve(err)
@@ -636,9 +692,16 @@ func (c *Cluster) SendTransaction(reqs []Request, cb Future, off uint64) {
 		cb.Resolve([]interface{}{}, off)
 		return
 	}
-	slot, ok := redisclusterutil.BatchSlot(reqs)
-	if !ok {
-		err := c.err(redis.ErrNoSlotKey).WithProperty(redis.EKRequests, reqs)
+	if c.rewrite != nil {
+		rewritten := make([]Request, len(reqs))
+		for i, req := range reqs {
+			rewritten[i] = c.rewrite(req)
+		}
+		reqs = rewritten
+	}
+	slot, serr := redisclusterutil.CheckBatchSlot(reqs)
+	if serr != nil {
+		err := c.addProps(serr.(*errorx.Error)).WithProperty(redis.EKRequests, reqs)
 		cb.Resolve(err, off)
 		return
 	}
@@ -678,6 +741,7 @@ type transaction struct {
 
 	hardErrs uint8
 	redir    uint8
+	redirs   []string // addresses transaction were redirected to
 	slot     uint16
 	asked    bool
 }
@@ -802,6 +866,7 @@ func (t *transaction) Resolve(res interface{}, n uint64) {
 			// Redis informs, that some, but not all, keys were migrated.
 			// Lets wait a bit for migration finalization.
 			t.redir++
+			t.redirs = append(t.redirs, t.lastconn.Addr())
 			t.hardErrs = 0
 			t.seen = nil
 			t.c.addWaitToMigrate(func() { t.send(t.lastconn, t.asked) })
@@ -812,6 +877,7 @@ func (t *transaction) Resolve(res interface{}, n uint64) {
 			// all keys are either moved (in this case, migration were finished),
 			// or asking (migration is in progress, but all keys were migrated).
 			t.redir++
+			t.redirs = append(t.redirs, moved)
 			t.hardErrs = 0
 			t.seen = nil
 			if moving {
@@ -838,6 +904,10 @@ func (t *transaction) Resolve(res interface{}, n uint64) {
 			t.sendMoved(moved, asking)
 			return
 		}
+		if moved != "" && moving != asking || err.IsOfType(redis.ErrTryAgain) {
+			t.resolve(t.c.tooManyRedirects(err, t.redirs))
+			return
+		}
 		fallthrough
 	default:
 		// all other kinds of error
@@ -858,6 +928,11 @@ func (t *transaction) sendMoved(addr string, asking bool) {
 	})
 }
 
+// tooManyRedirects wraps last redirection error of request which exhausted Opts.MovedRetries.
+func (c *Cluster) tooManyRedirects(err *errorx.Error, redirs []string) *errorx.Error {
+	return c.errWrap(ErrTooManyRedirects, err).WithProperty(EKRedirects, redirs)
+}
+
 func (c *Cluster) err(kind *errorx.Type) *errorx.Error {
 	return c.addProps(kind.NewWithNoMessage())
 }
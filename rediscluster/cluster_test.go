@@ -149,6 +149,236 @@ func (s *Suite) TestBasicOps() {
 	}
 }
 
+func (s *Suite) TestForEachMaster() {
+	cl, err := NewCluster(s.ctx, []string{"127.0.0.1:43210"}, clustopts)
+	s.r().Nil(err)
+	defer cl.Close()
+
+	var addrs []string
+	err = cl.ForEachMaster(func(addr string, sender redis.Sender) error {
+		addrs = append(addrs, addr)
+		s.Equal("PONG", redis.Sync{sender}.Do("PING"))
+		return nil
+	})
+	s.r().Nil(err)
+	s.Len(addrs, 3)
+
+	conn, err := cl.ConnForAddr(addrs[0])
+	s.r().Nil(err)
+	s.Equal("PONG", redis.Sync{conn}.Do("PING"))
+
+	_, err = cl.ConnForAddr("127.0.0.1:1")
+	s.True(errorx.IsOfType(err, ErrNoAliveConnection))
+
+	stop := errors.New("stop")
+	calls := 0
+	err = cl.ForEachMaster(func(string, redis.Sender) error {
+		calls++
+		return stop
+	})
+	s.Equal(stop, err)
+	s.Equal(1, calls)
+}
+
+func (s *Suite) TestDBSize() {
+	cl, err := NewCluster(s.ctx, []string{"127.0.0.1:43210"}, clustopts)
+	s.r().Nil(err)
+	defer cl.Close()
+
+	scl := redis.SyncCtx{cl}
+	for i := 0; i < 100; i++ {
+		s.Equal("OK", scl.Do(s.ctx, "SET", "dbsize"+strconv.Itoa(i), "1"))
+	}
+	sizes, err := cl.DBSize()
+	s.r().Nil(err)
+	s.Len(sizes, 3)
+	total := int64(0)
+	for _, n := range sizes {
+		total += n
+	}
+	s.True(total >= 100)
+
+	keys, err := cl.RandomKeys()
+	s.r().Nil(err)
+	s.Len(keys, 3)
+}
+
+func (s *Suite) TestRequestRewriter() {
+	opts := clustopts
+	opts.HostOpts.RequestRewriter = func(req Request) Request {
+		return redis.MapKeys(req, func(key string) string { return "tenant:" + key })
+	}
+	cl, err := NewCluster(s.ctx, []string{"127.0.0.1:43210"}, opts)
+	s.r().Nil(err)
+	defer cl.Close()
+
+	plaincl, err := NewCluster(s.ctx, []string{"127.0.0.1:43210"}, clustopts)
+	s.r().Nil(err)
+	defer plaincl.Close()
+
+	scl := redis.SyncCtx{cl}
+	plain := redis.SyncCtx{plaincl}
+	keys := make([]string, 20)
+	for i := range keys {
+		keys[i] = "rewrite" + strconv.Itoa(i)
+		// rewritten key determines slot, so request is not redirected.
+		s.Equal("OK", scl.Do(s.ctx, "SET", keys[i], strconv.Itoa(i)))
+		s.Equal([]byte(strconv.Itoa(i)), plain.Do(s.ctx, "GET", "tenant:"+keys[i]))
+		s.Nil(plain.Do(s.ctx, "GET", keys[i]))
+	}
+
+	res, err := scl.SendTransaction(s.ctx, []Request{
+		redis.Req("INCR", "{rewrite}counter"),
+		redis.Req("INCR", "{rewrite}counter"),
+	})
+	s.r().Nil(err)
+	s.Equal([]interface{}{int64(1), int64(2)}, res)
+	s.Equal([]byte("2"), plain.Do(s.ctx, "GET", "tenant:{rewrite}counter"))
+
+	vals, err := cl.MGet(keys)
+	s.r().Nil(err)
+	for i := range keys {
+		s.Equal([]byte(strconv.Itoa(i)), vals[i])
+	}
+	n, err := cl.Del(append(keys, "{rewrite}counter"))
+	s.r().Nil(err)
+	s.Equal(int64(len(keys)+1), n)
+}
+
+func (s *Suite) TestMGetDel() {
+	cl, err := NewCluster(s.ctx, []string{"127.0.0.1:43210"}, clustopts)
+	s.r().Nil(err)
+	defer cl.Close()
+
+	scl := redis.SyncCtx{cl}
+	keys := make([]string, 0, 51)
+	for i := 0; i < 50; i++ {
+		key := "mget" + strconv.Itoa(i)
+		s.Equal("OK", scl.Do(s.ctx, "SET", key, strconv.Itoa(i)))
+		keys = append(keys, key)
+	}
+	keys = append(keys, "mget-missing")
+
+	vals, err := cl.MGet(keys)
+	s.r().Nil(err)
+	s.r().Len(vals, len(keys))
+	for i := 0; i < 50; i++ {
+		s.Equal([]byte(strconv.Itoa(i)), vals[i])
+	}
+	s.Nil(vals[50])
+
+	n, err := cl.Touch(keys)
+	s.r().Nil(err)
+	s.Equal(int64(50), n)
+
+	n, err = cl.Unlink(keys[:25])
+	s.r().Nil(err)
+	s.Equal(int64(25), n)
+
+	n, err = cl.Del(keys)
+	s.r().Nil(err)
+	s.Equal(int64(25), n)
+	s.Nil(scl.Do(s.ctx, "GET", "mget0"))
+}
+
+func (s *Suite) TestMSetChunked() {
+	opts := clustopts
+	opts.MaxKeysPerCommand = 3
+	cl, err := NewCluster(s.ctx, []string{"127.0.0.1:43210"}, opts)
+	s.r().Nil(err)
+	defer cl.Close()
+
+	keys := make([]string, 20)
+	vals := make([]interface{}, 20)
+	for i := range keys {
+		// half of keys share a slot, so their group is chunked
+		if i%2 == 0 {
+			keys[i] = "{mset}" + strconv.Itoa(i)
+		} else {
+			keys[i] = "mset" + strconv.Itoa(i)
+		}
+		vals[i] = strconv.Itoa(i)
+	}
+	s.r().Nil(cl.MSet(keys, vals))
+
+	res, err := cl.MGet(keys)
+	s.r().Nil(err)
+	for i := range keys {
+		s.Equal([]byte(strconv.Itoa(i)), res[i])
+	}
+
+	n, err := cl.Del(keys)
+	s.r().Nil(err)
+	s.Equal(int64(len(keys)), n)
+
+	s.True(s.AsError(cl.MSet(keys, vals[:1])).IsOfType(redis.ErrArgumentCount))
+}
+
+func (s *Suite) TestMixedCaseCommands() {
+	cl, err := NewCluster(s.ctx, []string{"127.0.0.1:43210"}, clustopts)
+	s.r().Nil(err)
+	defer cl.Close()
+
+	scl := redis.SyncCtx{cl}
+	for i := 0; i < 20; i++ {
+		key := "mixedcase" + strconv.Itoa(i)
+		s.Equal("OK", scl.Do(s.ctx, "set", key, "1"))
+		s.Equal([]byte("1"), scl.Do(s.ctx, "Get", key))
+		s.Equal(int64(1), scl.Do(s.ctx, "Del", key))
+	}
+	s.True(s.AsError(scl.Do(s.ctx, "blpop", "mixedcase", 1)).IsOfType(redis.ErrCommandForbidden))
+}
+
+func (s *Suite) TestFlushAll() {
+	cl, err := NewCluster(s.ctx, []string{"127.0.0.1:43210"}, clustopts)
+	s.r().Nil(err)
+	defer cl.Close()
+
+	scl := redis.SyncCtx{cl}
+	for i := 0; i < 100; i++ {
+		s.Equal("OK", scl.Do(s.ctx, "SET", "flush"+strconv.Itoa(i), "1"))
+	}
+	err = cl.FlushAll(FlushOpts{})
+	s.True(errorx.IsOfType(err, ErrFlushNotConfirmed))
+	s.Equal([]byte("1"), scl.Do(s.ctx, "GET", "flush0"))
+
+	s.r().Nil(cl.FlushAll(FlushOpts{Confirm: true, Async: true}))
+	sizes, err := cl.DBSize()
+	s.r().Nil(err)
+	for _, n := range sizes {
+		s.Equal(int64(0), n)
+	}
+	s.Nil(scl.Do(s.ctx, "GET", "flush0"))
+}
+
+func (s *Suite) TestSessionReadYourWrites() {
+	cl, err := NewCluster(s.ctx, []string{"127.0.0.1:43210"}, clustopts)
+	s.r().Nil(err)
+	defer cl.Close()
+
+	sess := cl.Session(ForcePreferSlaves, 200*time.Millisecond)
+	getSource := func() redis.ResponseSource {
+		var meta redis.ResponseMeta
+		done := make(chan struct{})
+		sess.SendWithMeta(redis.Req("GET", "session"), redis.FuncFutureMeta(
+			func(res interface{}, _ uint64, m redis.ResponseMeta) {
+				meta = m
+				close(done)
+			}), 0)
+		<-done
+		return meta.Source
+	}
+
+	s.False(sess.Pinned())
+	s.Equal("OK", redis.Sync{sess}.Do("SET", "session", "1"))
+	s.True(sess.Pinned())
+	s.Equal(redis.SourceMaster, getSource())
+
+	time.Sleep(300 * time.Millisecond)
+	s.False(sess.Pinned())
+	s.Equal(redis.SourceReplica, getSource())
+}
+
 func (s *Suite) Test_justToCover() {
 	cl, err := NewCluster(nil, nil, clustopts)
 	s.r().Nil(cl)
@@ -344,6 +574,58 @@ func (s *Suite) TestScan() {
 	s.Len(allkeys, len(reqs), "length doesn't match", len(allkeys), len(reqs))
 }
 
+func (s *Suite) TestScanFailover() {
+	opts := longcheckopts
+	opts.HostOpts.IOTimeout = time.Second
+
+	cl, err := NewCluster(s.ctx, []string{"127.0.0.1:43210"}, opts)
+	s.r().Nil(err)
+	defer cl.Close()
+
+	sconn := redis.SyncCtx{cl}
+
+	reqs := make([]redis.Request, 0, NumSlots/4)
+	for i := 0; i < NumSlots; i += 4 {
+		reqs = append(reqs, redis.Req("SET", slotkey("scanfo:", s.keys[i], "k"), "1"))
+	}
+	ress := sconn.SendMany(s.ctx, reqs)
+	for _, res := range ress {
+		s.r().Nil(redis.AsError(res))
+	}
+
+	allkeys := make(map[string]struct{}, len(reqs))
+	scanner := sconn.Scanner(s.ctx, redis.ScanOpts{Match: "scanfo:*", Count: 100})
+	keys, err := scanner.Next()
+	s.r().Nil(err)
+	for _, key := range keys {
+		allkeys[key] = struct{}{}
+	}
+
+	s.cl.Node[0].Stop()
+	s.cl.WaitClusterOk()
+	time.Sleep(longcheckopts.CheckInterval)
+
+	// keys could be returned twice, but none is missed.
+	for {
+		keys, err := scanner.Next()
+		if err != nil {
+			s.Equal(redis.ScanEOF, err)
+			break
+		}
+		for _, key := range keys {
+			allkeys[key] = struct{}{}
+		}
+	}
+	s.Len(allkeys, len(reqs))
+
+	// return master
+	s.cl.Node[0].Start()
+	s.cl.WaitClusterOk()
+	s.cl.Node[3].Stop()
+	s.cl.WaitClusterOk()
+	s.cl.Node[3].Start()
+}
+
 type alwaysZero struct{}
 
 func (a alwaysZero) Current() uint32 {
@@ -412,6 +694,40 @@ func (s *Suite) TestFallbackToSlaveTimeout() {
 
 }
 
+func (s *Suite) TestNodeSelector() {
+	var calls, withMaster int32
+	opts := longcheckopts
+	opts.NodeSelector = func(candidates []NodeInfo) NodeInfo {
+		atomic.AddInt32(&calls, 1)
+		if candidates[0].Master {
+			atomic.AddInt32(&withMaster, 1)
+		}
+		return candidates[len(candidates)-1]
+	}
+	cl, err := NewCluster(s.ctx, []string{"127.0.0.1:43210"}, opts)
+	s.r().Nil(err)
+	defer cl.Close()
+
+	key := slotkey("selector", s.keys[1])
+	s.Equal("OK", redis.SyncCtx{cl}.Do(s.ctx, "SET", key, "1"))
+	s.Equal(int32(0), atomic.LoadInt32(&calls), "selector is not used for master only requests")
+
+	// replica could lag, so GET is retried.
+	sconn := redis.SyncCtx{cl.WithPolicy(MasterAndSlaves)}
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); {
+		if res := sconn.Do(s.ctx, "GET", key); res != nil {
+			s.Equal([]byte("1"), res)
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	s.True(atomic.LoadInt32(&withMaster) > 0)
+
+	atomic.StoreInt32(&withMaster, 0)
+	redis.SyncCtx{cl.WithPolicy(PreferSlaves)}.Do(s.ctx, "GET", key)
+	s.Equal(int32(0), atomic.LoadInt32(&withMaster))
+}
+
 func (s *Suite) TestGetMoved() {
 	cl, err := NewCluster(s.ctx, []string{"127.0.0.1:43210"}, longcheckopts)
 	s.r().Nil(err)
@@ -430,6 +746,39 @@ func (s *Suite) TestGetMoved() {
 	s.cl.MoveSlot(10999, 2, 1)
 }
 
+func (s *Suite) TestSlots() {
+	cl, err := NewCluster(s.ctx, []string{"127.0.0.1:43210"}, longcheckopts)
+	s.r().Nil(err)
+	defer cl.Close()
+
+	snap := cl.Slots()
+	s.True(snap.Version > 0)
+	covered := 0
+	for _, r := range snap.Ranges {
+		covered += r.To - r.From + 1
+		s.Equal(2, len(r.Addrs))
+	}
+	s.Equal(redisclusterutil.NumSlots, covered)
+
+	// snapshot is a copy
+	snap.Ranges[0].Addrs[0] = "mutated"
+	s.NotEqual("mutated", cl.Slots().Ranges[0].Addrs[0])
+
+	s.cl.MoveSlot(10994, 1, 2)
+	defer s.cl.MoveSlot(10994, 2, 1)
+	cl.ForceReloading()
+	for deadline := time.Now().Add(time.Second); cl.Slots().Version == snap.Version && time.Now().Before(deadline); {
+		time.Sleep(10 * time.Millisecond)
+	}
+	moved := cl.Slots()
+	s.r().NotEqual(snap.Version, moved.Version)
+	for _, r := range moved.Ranges {
+		if r.From <= 10994 && 10994 <= r.To {
+			s.Equal(s.cl.Node[2].Addr(), r.Addrs[0])
+		}
+	}
+}
+
 func (s *Suite) TestSetMoved() {
 	cl, err := NewCluster(s.ctx, []string{"127.0.0.1:43210"}, longcheckopts)
 	s.r().Nil(err)
@@ -499,6 +848,26 @@ func (s *Suite) TestAsk() {
 
 }
 
+func (s *Suite) TestTooManyRedirects() {
+	cl, err := NewCluster(s.ctx, []string{"127.0.0.1:43210"}, longcheckopts)
+	s.r().Nil(err)
+	defer cl.Close()
+
+	sconn := redis.SyncCtx{cl}
+
+	// slot is migrating, but target doesn't import it: source answers ASK for missing key,
+	// and target answers MOVED back to source.
+	s.cl.Node[1].DoSure("CLUSTER SETSLOT", 10993, "MIGRATING", s.cl.Node[2].NodeId)
+	defer s.cl.CancelMoveSlot(10993)
+
+	key := slotkey("redirects", s.keys[10993])
+	rerr := s.AsError(sconn.Do(s.ctx, "GET", key))
+	s.True(rerr.IsOfType(ErrTooManyRedirects))
+	s.True(rerr.Cause().(*errorx.Error).HasTrait(redis.ErrTraitClusterMove))
+	redirs, _ := rerr.Property(EKRedirects)
+	s.Equal(3, len(redirs.([]string)))
+}
+
 func (s *Suite) TestAskTransaction() {
 	opts := longcheckopts
 	opts.MovedRetries = 4
@@ -916,3 +1285,81 @@ Loop:
 	}
 	s.Equal(N, cnt, "Not all goroutines finished")
 }
+
+func (s *Suite) recvMessage(msgs <-chan redisconn.Message) redisconn.Message {
+	select {
+	case msg, ok := <-msgs:
+		s.r().True(ok, "subscription is closed")
+		return msg
+	case <-time.After(5 * time.Second):
+		s.T().Fatal("message is not received")
+		return redisconn.Message{}
+	}
+}
+
+func (s *Suite) TestSSubscribe() {
+	cl, err := NewCluster(s.ctx, []string{"127.0.0.1:43210"}, longcheckopts)
+	s.r().Nil(err)
+	defer cl.Close()
+
+	// channels on every node, two of them in same slot.
+	chans := []string{
+		slotkey("ssub", s.keys[100], "a"),
+		slotkey("ssub", s.keys[100], "b"),
+		slotkey("ssub", s.keys[6000]),
+		slotkey("ssub", s.keys[12000]),
+	}
+	ctx, cancel := context.WithCancel(s.ctx)
+	msgs, err := cl.SSubscribe(ctx, chans...)
+	s.r().Nil(err)
+
+	for _, ch := range chans {
+		// SPublish is routed to owner of channel's slot, so subscriber receives message.
+		n, err := redis.SPublish(cl, ch, "msg:"+ch)
+		s.r().Nil(err)
+		s.Equal(int64(1), n)
+		msg := s.recvMessage(msgs)
+		s.Equal(ch, msg.Channel)
+		s.Equal([]byte("msg:"+ch), msg.Data)
+	}
+	s.NotContains(DebugEvents(), "moved")
+
+	cancel()
+	for range msgs {
+	}
+}
+
+func (s *Suite) TestSSubscribeMoved() {
+	cl, err := NewCluster(s.ctx, []string{"127.0.0.1:43210"}, longcheckopts)
+	s.r().Nil(err)
+	defer cl.Close()
+
+	moved := slotkey("ssubmoved", s.keys[10997])
+	stayed := slotkey("ssubmoved", s.keys[6000])
+	msgs, err := cl.SSubscribe(s.ctx, moved, stayed)
+	s.r().Nil(err)
+
+	s.cl.MoveSlot(10997, 1, 2)
+	defer s.cl.MoveSlot(10997, 2, 1)
+
+	// subscription is re-established on new owner, and other channels of same node keep working.
+	deadline := time.Now().Add(5 * time.Second)
+	for _, ch := range []string{moved, stayed} {
+		for {
+			n, err := redis.SPublish(cl, ch, "after")
+			s.r().Nil(err)
+			if n == 1 {
+				break
+			}
+			s.r().True(time.Now().Before(deadline), "channel %s is not re-subscribed", ch)
+			time.Sleep(50 * time.Millisecond)
+		}
+		for {
+			msg := s.recvMessage(msgs)
+			if msg.Channel == ch {
+				s.Equal([]byte("after"), msg.Data)
+				break
+			}
+		}
+	}
+}
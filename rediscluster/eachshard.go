@@ -1,7 +1,10 @@
 package rediscluster
 
 import (
+	"sort"
+
 	"github.com/joomcode/redispipe/redis"
+	"github.com/joomcode/redispipe/redisconn"
 )
 
 // EachShard implements redis.Sender.EachShard
@@ -23,3 +26,68 @@ func (c *Cluster) EachShard(cb func(redis.Sender, error) bool) {
 		}
 	}
 }
+
+// ConnForAddr returns connection to cluster node (master or replica) by its address.
+// It is useful for administrative commands which should be sent to concrete node.
+// Node should be present in current cluster configuration.
+func (c *Cluster) ConnForAddr(addr string) (redis.Sender, error) {
+	conn := c.connForAddress(addr)
+	if conn == nil {
+		return nil, c.err(ErrNoAliveConnection).WithProperty(redis.EKAddress, addr)
+	}
+	return conn, nil
+}
+
+// SendWithAsking sends request to node at addr preceded with ASKING, so importing node accepts
+// request for slot which is being migrated to it. Redirections are not followed.
+// It is useful for slot migration tooling (for example, with redis.RestoreAsking or redis.Migrate).
+// If node is not present in current cluster configuration, connection to it is established.
+func (c *Cluster) SendWithAsking(addr string, req Request, cb Future, off uint64) {
+	c.ensureConnForAddress(addr, func(conn *redisconn.Connection, err error) {
+		if err != nil {
+			cb.Resolve(err, off)
+			return
+		}
+		conn.SendAsk(req, cb, off, true)
+	})
+}
+
+// ForEachMaster calls cb for every master of current cluster configuration (ordered by shard number).
+// Iteration stops on first error returned by cb, and this error is returned.
+// If there is no alive connection to some master, ErrNoAliveConnection is returned without calling cb.
+func (c *Cluster) ForEachMaster(cb func(addr string, sender redis.Sender) error) error {
+	for _, m := range c.masterConns() {
+		if m.conn == nil {
+			return c.err(ErrNoAliveConnection).WithProperty(redis.EKAddress, m.addr)
+		}
+		if err := cb(m.addr, m.conn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type masterConn struct {
+	addr string
+	conn *redisconn.Connection
+}
+
+// masterConns returns masters of current cluster configuration ordered by shard number.
+// conn is nil if there is no alive connection to master.
+func (c *Cluster) masterConns() []masterConn {
+	cfg := c.getConfig()
+	nums := make([]int, 0, len(cfg.shards))
+	for num := range cfg.shards {
+		nums = append(nums, int(num))
+	}
+	sort.Ints(nums)
+	masters := make([]masterConn, len(nums))
+	for i, num := range nums {
+		addr := cfg.shards[uint16(num)].addr[0]
+		masters[i].addr = addr
+		if node := cfg.nodes[addr]; node != nil {
+			masters[i].conn = node.getConn(c.opts.ConnHostPolicy, preferConnected, nil)
+		}
+	}
+	return masters
+}
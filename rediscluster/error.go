@@ -17,6 +17,14 @@ var (
 	ErrClusterConfigEmpty = ErrCluster.NewType("config_empty")
 	// ErrNoAliveConnection - no alive connection to shard
 	ErrNoAliveConnection = ErrCluster.NewType("no_alive_connection", redis.ErrTraitConnectivity)
+	// ErrPartialResult - command sent to every master failed on some of them (see DBSize).
+	ErrPartialResult = ErrCluster.NewType("partial_result")
+	// ErrFlushNotConfirmed - FlushAll or FlushDB is called without FlushOpts.Confirm.
+	ErrFlushNotConfirmed = ErrCluster.NewType("flush_not_confirmed")
+	// ErrTooManyRedirects - request were redirected with MOVED/ASK (or TRYAGAIN) Opts.MovedRetries times,
+	// and still were not served (for example, slot bounces between nodes during migration).
+	// It wraps last redirection error, and EKRedirects contains addresses request were redirected to.
+	ErrTooManyRedirects = ErrCluster.NewType("too_many_redirects")
 )
 
 var (
@@ -26,6 +34,10 @@ var (
 	EKClusterName = errorx.RegisterPrintableProperty("clusterName")
 	// EKPolicy - policy used to choose between master and replicas.
 	EKPolicy = errorx.RegisterPrintableProperty("policy")
+	// EKFailedNodes - errors of failed nodes, keyed by node address (map[string]error).
+	EKFailedNodes = errorx.RegisterProperty("failed_nodes")
+	// EKRedirects - addresses request were redirected to ([]string), see ErrTooManyRedirects.
+	EKRedirects = errorx.RegisterPrintableProperty("redirects")
 )
 
 func withNewProperty(err *errorx.Error, p errorx.Property, v interface{}) *errorx.Error {
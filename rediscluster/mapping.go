@@ -214,6 +214,11 @@ func (c *Cluster) connForSlot(slot uint16, policy ReplicaPolicyEnum, seen []*red
 		}
 		conn = node.getConn(c.opts.ConnHostPolicy, preferConnected, seen)
 	case MasterAndSlaves, PreferSlaves:
+		if c.opts.NodeSelector != nil {
+			if conn = c.selectedConn(shard, nodes, policy, seen); conn != nil {
+				break /*switch*/
+			}
+		}
 		n, a := uint32(len(shard.addr))*3, uint32(0)
 		if policy == PreferSlaves {
 			// with PreferSlaves policy, slaves are three times more preferred than master.
@@ -254,6 +259,28 @@ func (c *Cluster) connForSlot(slot uint16, policy ReplicaPolicyEnum, seen []*red
 	return conn, nil
 }
 
+// selectedConn returns connection to node of shard chosen with Opts.NodeSelector.
+func (c *Cluster) selectedConn(shard *shard, nodes nodeMap, policy ReplicaPolicyEnum, seen []*redisconn.Connection) *redisconn.Connection {
+	mask := atomic.LoadUint32(&shard.good)
+	candidates := make([]NodeInfo, 0, len(shard.addr))
+	for k, addr := range shard.addr {
+		if mask&(1<<uint(k)) != 0 && nodes[addr] != nil {
+			candidates = append(candidates, NodeInfo{Addr: addr, Master: k == 0})
+		}
+	}
+	if policy == PreferSlaves && len(candidates) > 1 && candidates[0].Master {
+		candidates = candidates[1:]
+	}
+	if len(candidates) == 0 {
+		return nil
+	}
+	node := nodes[c.opts.NodeSelector(candidates).Addr]
+	if node == nil {
+		return nil
+	}
+	return node.getConn(c.opts.ConnHostPolicy, preferConnected, seen)
+}
+
 func (c *Cluster) connForAddress(addr string) *redisconn.Connection {
 	node := c.getConfig().nodes[addr]
 	if node == nil {
@@ -344,3 +371,15 @@ func nextRng(state *uint32, mod uint32) uint32 {
 	*state = v*0x12345 + 1
 	return (v ^ v>>16) % mod
 }
+
+// responseMeta reports whether conn is connected to master or to replica according to current configuration.
+func (c *Cluster) responseMeta(conn *redisconn.Connection) redis.ResponseMeta {
+	if conn == nil {
+		return redis.ResponseMeta{}
+	}
+	meta := redis.ResponseMeta{Addr: conn.Addr(), Source: redis.SourceReplica}
+	if _, ok := c.getConfig().masters[meta.Addr]; ok {
+		meta.Source = redis.SourceMaster
+	}
+	return meta
+}
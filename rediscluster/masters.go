@@ -0,0 +1,122 @@
+package rediscluster
+
+import (
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/joomcode/redispipe/redis"
+)
+
+// DBSize returns number of keys on every master (DBSIZE), keyed by master address.
+//
+// Masters are queried concurrently. If some masters could not be queried, counts of other masters
+// are returned together with ErrPartialResult, which lists failed addresses in its message
+// and holds their errors in EKFailedNodes property.
+func (c *Cluster) DBSize() (map[string]int64, error) {
+	sizes := make(map[string]int64)
+	err := c.eachMasterDo(Request{"DBSIZE", nil}, func(addr string, res interface{}) error {
+		n, ok := res.(int64)
+		if !ok {
+			return c.err(redis.ErrResponseUnexpected).WithProperty(redis.EKResponse, res)
+		}
+		sizes[addr] = n
+		return nil
+	})
+	return sizes, err
+}
+
+// RandomKeys returns random key of every master (RANDOMKEY), keyed by master address.
+// Masters without keys are not included. Partial failure is handled as in DBSize.
+func (c *Cluster) RandomKeys() (map[string]string, error) {
+	keys := make(map[string]string)
+	err := c.eachMasterDo(Request{"RANDOMKEY", nil}, func(addr string, res interface{}) error {
+		switch v := res.(type) {
+		case nil:
+		case []byte:
+			keys[addr] = string(v)
+		default:
+			return c.err(redis.ErrResponseUnexpected).WithProperty(redis.EKResponse, res)
+		}
+		return nil
+	})
+	return keys, err
+}
+
+// FlushOpts are options for FlushAll and FlushDB.
+type FlushOpts struct {
+	// Confirm should be true, otherwise nothing is flushed and ErrFlushNotConfirmed is returned.
+	// It guards against accidental call of destructive command.
+	Confirm bool
+	// Async makes masters free memory in background (FLUSHALL ASYNC).
+	Async bool
+}
+
+// FlushAll removes all keys of cluster: FLUSHALL is sent to every master (replicas get it through replication).
+// Partial failure is returned as ErrPartialResult listing masters which were not flushed (see DBSize).
+func (c *Cluster) FlushAll(opts FlushOpts) error {
+	return c.flush("FLUSHALL", opts)
+}
+
+// FlushDB is same as FlushAll, but sends FLUSHDB. Cluster has only database 0, so it has the same effect.
+func (c *Cluster) FlushDB(opts FlushOpts) error {
+	return c.flush("FLUSHDB", opts)
+}
+
+func (c *Cluster) flush(cmd string, opts FlushOpts) error {
+	if !opts.Confirm {
+		return c.err(ErrFlushNotConfirmed)
+	}
+	req := Request{cmd, nil}
+	if opts.Async {
+		req.Args = []interface{}{"ASYNC"}
+	}
+	return c.eachMasterDo(req, func(addr string, res interface{}) error {
+		if res != "OK" {
+			return c.err(redis.ErrResponseUnexpected).WithProperty(redis.EKResponse, res)
+		}
+		return nil
+	})
+}
+
+// eachMasterDo sends req to all masters concurrently, and calls handle with every successful response
+// (one at a time). Errors of failed masters are collected into ErrPartialResult.
+func (c *Cluster) eachMasterDo(req Request, handle func(addr string, res interface{}) error) error {
+	masters := c.masterConns()
+	ress := make([]interface{}, len(masters))
+	var wg sync.WaitGroup
+	for i, m := range masters {
+		if m.conn == nil {
+			ress[i] = c.err(ErrNoAliveConnection).WithProperty(redis.EKAddress, m.addr)
+			continue
+		}
+		wg.Add(1)
+		m.conn.Send(req, redis.FuncFuture(func(res interface{}, n uint64) {
+			ress[n] = res
+			wg.Done()
+		}), uint64(i))
+	}
+	wg.Wait()
+
+	failed := make(map[string]error)
+	for i, m := range masters {
+		err := redis.AsError(ress[i])
+		if err == nil {
+			err = handle(m.addr, ress[i])
+		}
+		if err != nil {
+			failed[m.addr] = err
+		}
+	}
+	if len(failed) == 0 {
+		return nil
+	}
+	addrs := make([]string, 0, len(failed))
+	for addr := range failed {
+		addrs = append(addrs, addr)
+	}
+	sort.Strings(addrs)
+	return c.addProps(ErrPartialResult.New("%s failed on %d of %d masters: %s",
+		req.Cmd, len(failed), len(masters), strings.Join(addrs, ", "))).
+		WithProperty(EKFailedNodes, failed)
+}
@@ -0,0 +1,158 @@
+package rediscluster
+
+import (
+	"github.com/joomcode/redispipe/redis"
+	"github.com/joomcode/redispipe/rediscluster/redisclusterutil"
+)
+
+// MGet returns values of keys which could belong to different slots.
+// Keys are grouped by slot, and MGET of every group is sent concurrently (so requests to same node
+// are pipelined). Result is in order of keys: value is []byte, or nil if key doesn't exist.
+// If some group failed, its error is placed at indices of its keys, and ErrPartialResult is returned
+// together with results.
+func (c *Cluster) MGet(keys []string) ([]interface{}, error) {
+	groups, reqs := c.groupKeysBySlot("MGET", keys)
+	ress := redis.Sync{c}.SendMany(reqs)
+	result := make([]interface{}, len(keys))
+	failed := 0
+	for i, group := range groups {
+		vals, ok := ress[i].([]interface{})
+		if !ok || len(vals) != len(group) {
+			err := redis.AsError(ress[i])
+			if err == nil {
+				err = c.err(redis.ErrResponseUnexpected).WithProperty(redis.EKResponse, ress[i])
+			}
+			for _, idx := range group {
+				result[idx] = err
+			}
+			failed += len(group)
+			continue
+		}
+		for j, idx := range group {
+			result[idx] = vals[j]
+		}
+	}
+	if failed != 0 {
+		return result, c.addProps(ErrPartialResult.New("MGET failed for %d of %d keys", failed, len(keys)))
+	}
+	return result, nil
+}
+
+// MSet sets values of keys which could belong to different slots (keys[i] is set to values[i]).
+// Keys are grouped by slot as in MGet, so MSet is not atomic: if some group failed, other groups
+// could be applied, and ErrPartialResult is returned.
+func (c *Cluster) MSet(keys []string, values []interface{}) error {
+	if len(keys) != len(values) {
+		return c.err(redis.ErrArgumentCount).WithProperty(redis.EKRequest, Request{"MSET", values})
+	}
+	groups, reqs := c.groupKeysBySlot("MSET", keys)
+	for i, group := range groups {
+		args := make([]interface{}, 0, 2*len(group))
+		for _, idx := range group {
+			args = append(args, keys[idx], values[idx])
+		}
+		reqs[i].Args = args
+	}
+	ress := redis.Sync{c}.SendMany(reqs)
+	failed := 0
+	for i, group := range groups {
+		if ress[i] != "OK" {
+			failed += len(group)
+		}
+	}
+	if failed != 0 {
+		return c.addProps(ErrPartialResult.New("MSET failed for %d of %d keys", failed, len(keys)))
+	}
+	return nil
+}
+
+// Del removes keys which could belong to different slots, and returns number of removed keys.
+// Keys are grouped by slot as in MGet. If some group failed, number of keys removed by other groups
+// is returned together with ErrPartialResult.
+// If HostOpts.PreferUnlink is set, UNLINK is sent instead of DEL (and DEL is sent to servers which
+// don't know UNLINK).
+func (c *Cluster) Del(keys []string) (int64, error) {
+	if c.opts.HostOpts.PreferUnlink {
+		return c.countKeys("UNLINK", "DEL", keys)
+	}
+	return c.countKeys("DEL", "", keys)
+}
+
+// Unlink removes keys in background (UNLINK) and returns number of removed keys.
+// Keys are grouped by slot as in Del.
+func (c *Cluster) Unlink(keys []string) (int64, error) {
+	return c.countKeys("UNLINK", "", keys)
+}
+
+// Touch updates last access time of keys (TOUCH) and returns number of existing keys.
+// Keys are grouped by slot as in Del.
+func (c *Cluster) Touch(keys []string) (int64, error) {
+	return c.countKeys("TOUCH", "", keys)
+}
+
+// countKeys sends cmd for keys grouped by slot and sums integer replies.
+// If fallback is set, it is sent for groups which server answered that cmd is unknown.
+func (c *Cluster) countKeys(cmd, fallback string, keys []string) (int64, error) {
+	groups, reqs := c.groupKeysBySlot(cmd, keys)
+	ress := redis.Sync{c}.SendMany(reqs)
+	if fallback != "" {
+		var retry []int
+		for i, res := range ress {
+			if redis.IsUnknownCommand(redis.AsError(res)) {
+				reqs[i].Cmd = fallback
+				retry = append(retry, i)
+			}
+		}
+		if len(retry) != 0 {
+			retryReqs := make([]Request, len(retry))
+			for j, i := range retry {
+				retryReqs[j] = reqs[i]
+			}
+			retried := redis.Sync{c}.SendMany(retryReqs)
+			for j, i := range retry {
+				ress[i] = retried[j]
+			}
+		}
+	}
+	var count int64
+	failed := 0
+	for i, group := range groups {
+		n, ok := ress[i].(int64)
+		if !ok {
+			failed += len(group)
+			continue
+		}
+		count += n
+	}
+	if failed != 0 {
+		return count, c.addProps(ErrPartialResult.New("%s failed for %d of %d keys", cmd, failed, len(keys)))
+	}
+	return count, nil
+}
+
+// groupKeysBySlot groups keys by slot (in order of first appearance), and returns indices of keys
+// of every group together with request of cmd for every group.
+// Group is closed when it reaches Opts.MaxKeysPerCommand keys, and following keys of same slot
+// form new group.
+// If HostOpts.RequestRewriter is set, slot of rewritten key is used, since requests are rewritten on send.
+func (c *Cluster) groupKeysBySlot(cmd string, keys []string) ([][]int, []Request) {
+	var groups [][]int
+	var reqs []Request
+	bySlot := make(map[uint16]int)
+	for i, key := range keys {
+		slot := redisclusterutil.Slot(key)
+		if c.rewrite != nil {
+			slot, _ = redisclusterutil.ReqSlot(c.rewrite(Request{cmd, []interface{}{key}}))
+		}
+		g, ok := bySlot[slot]
+		if !ok || len(groups[g]) >= c.opts.MaxKeysPerCommand {
+			g = len(groups)
+			bySlot[slot] = g
+			groups = append(groups, nil)
+			reqs = append(reqs, Request{cmd, nil})
+		}
+		groups[g] = append(groups[g], i)
+		reqs[g].Args = append(reqs[g].Args, key)
+	}
+	return groups, reqs
+}
@@ -0,0 +1,121 @@
+package rediscluster
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/joomcode/redispipe/redis"
+	"github.com/joomcode/redispipe/rediscluster/redisclusterutil"
+	"github.com/joomcode/redispipe/redisconn"
+)
+
+// resubscribePause is a pause between attempts to re-establish shard subscription.
+const resubscribePause = 100 * time.Millisecond
+
+// SSubscribe subscribes to shard channels (SSUBSCRIBE, redis >= 7.0) and streams published messages
+// into returned channel.
+//
+// Channels are grouped by master owning their slots, and every group is subscribed through single
+// dedicated connection to that master (see redisconn.Connection.SSubscribe). When slot is migrated,
+// server drops subscription, and channels of dropped connection are re-subscribed on their current
+// owners (messages published meanwhile are lost).
+// Returned channel is closed when ctx is done or cluster is closed.
+func (c *Cluster) SSubscribe(ctx context.Context, channels ...string) (<-chan redisconn.Message, error) {
+	if ctx == nil {
+		return nil, c.err(redis.ErrContextIsNil)
+	}
+	if len(channels) == 0 {
+		return nil, c.addProps(redis.ErrArgumentType.New("nothing to subscribe"))
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	sub := &shardSubscription{
+		c:   c,
+		ctx: ctx,
+		out: make(chan redisconn.Message, 128),
+	}
+
+	// hold wait group, so out is not closed while initial subscription is in progress.
+	sub.wg.Add(1)
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-c.ctx.Done():
+		}
+		cancel()
+		sub.wg.Wait()
+		close(sub.out)
+	}()
+	defer sub.wg.Done()
+
+	if _, err := sub.subscribe(channels); err != nil {
+		cancel()
+		return nil, err
+	}
+	return sub.out, nil
+}
+
+// shardSubscription holds state of cluster wide shard subscription.
+type shardSubscription struct {
+	c   *Cluster
+	ctx context.Context
+	out chan redisconn.Message
+	wg  sync.WaitGroup
+}
+
+// subscribe groups channels by masters owning their slots and subscribes every group through
+// one connection. It returns channels of groups failed to subscribe and last error.
+func (sub *shardSubscription) subscribe(channels []string) ([]string, error) {
+	var conns []*redisconn.Connection
+	byConn := make(map[*redisconn.Connection][]string)
+	var failed []string
+	var lastErr error
+	for _, ch := range channels {
+		slot := uint16(redisclusterutil.Slot(ch))
+		conn, err := sub.c.connForSlot(slot, MasterOnly, nil)
+		if err != nil {
+			failed = append(failed, ch)
+			lastErr = err
+			continue
+		}
+		if _, ok := byConn[conn]; !ok {
+			conns = append(conns, conn)
+		}
+		byConn[conn] = append(byConn[conn], ch)
+	}
+	for _, conn := range conns {
+		chans := byConn[conn]
+		in, err := conn.SSubscribe(sub.ctx, chans...)
+		if err != nil {
+			failed = append(failed, chans...)
+			lastErr = err
+			continue
+		}
+		sub.wg.Add(1)
+		go sub.forward(chans, in)
+	}
+	return failed, lastErr
+}
+
+// forward copies messages from node subscription to out,
+// and re-subscribes its channels if subscription is dropped.
+func (sub *shardSubscription) forward(channels []string, in <-chan redisconn.Message) {
+	defer sub.wg.Done()
+	for msg := range in {
+		select {
+		case sub.out <- msg:
+		case <-sub.ctx.Done():
+			return
+		}
+	}
+	// subscription is dropped: either slot migrated or connection broken.
+	sub.c.ForceReloading()
+	for len(channels) > 0 {
+		select {
+		case <-sub.ctx.Done():
+			return
+		case <-time.After(resubscribePause):
+		}
+		channels, _ = sub.subscribe(channels)
+	}
+}
@@ -3,7 +3,7 @@ package redisclusterutil
 // copied from github.com/mediocregopher/radix.v2/cluster/crc16.go
 
 import (
-	"strings"
+	"github.com/joomcode/redispipe/redis"
 )
 
 var tab = [256]uint16{
@@ -58,10 +58,8 @@ func CRC16(buf []byte) uint16 {
 // Slot returns the cluster slot the given key will fall into, taking into
 // account curly braces within the key as per the spec.
 func Slot(key string) uint16 {
-	if start := strings.Index(key, "{"); start >= 0 {
-		if end := strings.Index(key[start+1:], "}"); end > 0 {
-			key = key[start+1 : start+1+end]
-		}
+	if tag, ok := redis.ExtractHashTag(key); ok {
+		key = tag
 	}
 	return CRC16([]byte(key)) % NumSlots
 }
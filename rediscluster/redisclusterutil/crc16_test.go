@@ -4,6 +4,8 @@ package redisclusterutil
 
 import (
 	"testing"
+
+	"github.com/joomcode/redispipe/redis"
 )
 
 func TestCRC16(t *testing.T) {
@@ -11,3 +13,25 @@ func TestCRC16(t *testing.T) {
 		t.Fatalf("checksum came out to %x not %x", c, 0x31c3)
 	}
 }
+
+func TestSlotHashTag(t *testing.T) {
+	if Slot("{user1}:a") != Slot("user1") || Slot("x{user1}y") != Slot("{user1}") {
+		t.Fatalf("keys with the same hash tag are in different slots")
+	}
+	if Slot("{}user1") != CRC16([]byte("{}user1"))%NumSlots {
+		t.Fatalf("empty hash tag should be ignored")
+	}
+}
+
+func TestReqSlotMixedCase(t *testing.T) {
+	for _, cmd := range []string{"GET", "Get", "get"} {
+		if slot, ok := ReqSlot(redis.Req(cmd, "user1")); !ok || slot != Slot("user1") {
+			t.Fatalf("%s is routed to slot %d instead of %d", cmd, slot, Slot("user1"))
+		}
+	}
+	for _, cmd := range []string{"EVALSHA", "EvalSha", "evalsha"} {
+		if slot, ok := ReqSlot(redis.Req(cmd, "sha", 1, "user1")); !ok || slot != Slot("user1") {
+			t.Fatalf("%s is routed to slot %d instead of %d", cmd, slot, Slot("user1"))
+		}
+	}
+}
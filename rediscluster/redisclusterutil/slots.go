@@ -7,31 +7,90 @@ import (
 )
 
 // ReqSlot returns slot number targeted by this command.
+// It returns false if request has no key, or if its keys belong to different slots (see CheckReqSlot).
 func ReqSlot(req redis.Request) (uint16, bool) {
+	slot, err := CheckReqSlot(req)
+	return slot, err == nil
+}
+
+// CheckReqSlot returns slot number targeted by this command.
+// All keys of request (see redis.CommandKeys) should belong to the same slot, otherwise ErrCrossSlot
+// is returned. Request which could be sent to any node (RANDOMKEY, EVAL without keys) gets random slot.
+// ErrNoSlotKey is returned if request has no key.
+func CheckReqSlot(req redis.Request) (uint16, error) {
+	slot, keyed, err := reqSlot(req)
+	if err == nil && !keyed {
+		slot = uint16(rand.Intn(NumSlots))
+	}
+	return slot, err
+}
+
+// reqSlot returns slot of request keys. keyed is false if request could be sent to any node.
+func reqSlot(req redis.Request) (slot uint16, keyed bool, err error) {
+	// most commands have single key, so keys are walked only for commands which could have several.
+	var keys []string
+	if redis.MultiKeyCommand(req.Cmd) {
+		if keys, err = redis.CommandKeys(req); err != nil {
+			return 0, false, err
+		}
+	}
 	key, ok := req.Key()
 	if key == "RANDOMKEY" && !ok {
-		return uint16(rand.Intn(NumSlots)), true
+		return 0, false, nil
 	}
-	return Slot(key), ok
+	if !ok {
+		return 0, false, redis.ErrNoSlotKey.New("no key to determine slot").WithProperty(redis.EKRequest, req)
+	}
+	slot = Slot(key)
+	for _, k := range keys {
+		if Slot(k) != slot {
+			return 0, false, redis.ErrCrossSlot.New("keys of request belong to different slots").
+				WithProperty(redis.EKRequest, req)
+		}
+	}
+	return slot, true, nil
 }
 
 // BatchSlot returns slot common for all requests in batch (if there is such common slot).
 func BatchSlot(reqs []redis.Request) (uint16, bool) {
+	slot, err := CheckBatchSlot(reqs)
+	return slot, err == nil
+}
+
+// CheckBatchSlot returns slot common for all keys of all requests in batch.
+// Requests without keys are skipped. ErrCrossSlot is returned if keys belong to different slots,
+// and ErrNoSlotKey if no request has key (batch of requests which could be sent to any node gets
+// random slot).
+func CheckBatchSlot(reqs []redis.Request) (uint16, error) {
 	var slot uint16
-	var set bool
+	var set, anyNode bool
 	for _, req := range reqs {
-		s, ok := ReqSlot(req)
-		if !ok {
+		s, keyed, err := reqSlot(req)
+		if err != nil {
+			if redis.IsOfType(err, redis.ErrNoSlotKey) {
+				continue
+			}
+			return 0, err
+		}
+		if !keyed {
+			anyNode = true
 			continue
 		}
 		if !set {
 			slot = s
 			set = true
 		} else if slot != s {
-			return 0, false
+			return 0, redis.ErrCrossSlot.New("keys of batch belong to different slots").
+				WithProperty(redis.EKRequests, reqs)
+		}
+	}
+	if !set {
+		if anyNode {
+			return uint16(rand.Intn(NumSlots)), nil
 		}
+		return 0, redis.ErrNoSlotKey.New("no key to determine slot").WithProperty(redis.EKRequests, reqs)
 	}
-	return slot, set
+	return slot, nil
 }
 
 // BatchKey returns first key from a batch that is targeted to common slot.
@@ -0,0 +1,78 @@
+package redisclusterutil
+
+import (
+	"testing"
+
+	"github.com/joomcode/redispipe/redis"
+)
+
+func TestCheckReqSlot(t *testing.T) {
+	slot, err := CheckReqSlot(redis.Req("MSET", "{a}1", 1, "{a}2", 2))
+	if err != nil || slot != Slot("a") {
+		t.Fatalf("MSET with common hashtag: %d %v", slot, err)
+	}
+	slot, err = CheckReqSlot(redis.Req("EVAL", "return 1", 2, "{b}1", "{b}2", "argv"))
+	if err != nil || slot != Slot("b") {
+		t.Fatalf("EVAL with common hashtag: %d %v", slot, err)
+	}
+
+	if _, err = CheckReqSlot(redis.Req("MSET", "a", 1, "b", 2)); !redis.IsOfType(err, redis.ErrCrossSlot) {
+		t.Fatalf("MSET cross slot: %v", err)
+	}
+	if _, err = CheckReqSlot(redis.Req("EVAL", "return 1", 2, "a", "b")); !redis.IsOfType(err, redis.ErrCrossSlot) {
+		t.Fatalf("EVAL cross slot: %v", err)
+	}
+	if _, err = CheckReqSlot(redis.Req("EVAL", "return 1", 3, "a")); !redis.IsOfType(err, redis.ErrArgumentType) {
+		t.Fatalf("EVAL malformed numkeys: %v", err)
+	}
+
+	// keyless scripts could be sent to any node
+	for _, req := range []redis.Request{
+		redis.Req("EVAL", "return 1", 0),
+		redis.Req("FCALL", "f", 0, "argv"),
+		redis.Req("RANDOMKEY"),
+	} {
+		if slot, err = CheckReqSlot(req); err != nil || slot >= NumSlots {
+			t.Fatalf("%v: %d %v", req, slot, err)
+		}
+	}
+
+	if _, err = CheckReqSlot(redis.Req("GET")); !redis.IsOfType(err, redis.ErrNoSlotKey) {
+		t.Fatalf("GET without key: %v", err)
+	}
+}
+
+func TestCheckBatchSlot(t *testing.T) {
+	slot, err := CheckBatchSlot([]redis.Request{
+		redis.Req("EVAL", "return 1", 0),
+		redis.Req("SET", "{a}1", 1),
+		redis.Req("MGET", "{a}1", "{a}2"),
+	})
+	if err != nil || slot != Slot("a") {
+		t.Fatalf("common slot: %d %v", slot, err)
+	}
+
+	_, err = CheckBatchSlot([]redis.Request{redis.Req("SET", "{a}1", 1), redis.Req("MGET", "{a}1", "b")})
+	if !redis.IsOfType(err, redis.ErrCrossSlot) {
+		t.Fatalf("cross slot: %v", err)
+	}
+
+	if slot, err = CheckBatchSlot([]redis.Request{redis.Req("EVAL", "return 1", 0)}); err != nil || slot >= NumSlots {
+		t.Fatalf("keyless batch: %d %v", slot, err)
+	}
+	if _, err = CheckBatchSlot(nil); !redis.IsOfType(err, redis.ErrNoSlotKey) {
+		t.Fatalf("empty batch: %v", err)
+	}
+}
+
+func TestReqSlotSingleKeyNoAlloc(t *testing.T) {
+	req := redis.Req("get", "{a}key")
+	allocs := testing.AllocsPerRun(100, func() {
+		if slot, ok := ReqSlot(req); !ok || slot != Slot("a") {
+			t.Fatalf("GET: %d %v", slot, ok)
+		}
+	})
+	if allocs != 0 {
+		t.Fatalf("single key command allocates: %v", allocs)
+	}
+}
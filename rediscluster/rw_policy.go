@@ -1,5 +1,7 @@
 package rediscluster
 
+import "github.com/joomcode/redispipe/redis"
+
 // PolicyMan wraps Cluster and change default policy for Send and SendMany methods.
 // PolicyMan implements redis.Sender.
 type PolicyMan struct {
@@ -26,3 +28,9 @@ func (p PolicyMan) SendMany(reqs []Request, cb Future, off uint64) {
 func (c *Cluster) WithPolicy(policy ReplicaPolicyEnum) PolicyMan {
 	return PolicyMan{c, policy}
 }
+
+// SendWithMeta implements redis.MetaSender.SendWithMeta
+// It sends request with specified default policy.
+func (p PolicyMan) SendWithMeta(req Request, cb redis.FutureMeta, off uint64) {
+	p.Cluster.sendWithPolicy(p.Policy, req, cb, cb, off)
+}
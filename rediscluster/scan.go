@@ -1,48 +1,64 @@
 package rediscluster
 
 import (
+	"sort"
+	"strings"
+
 	"github.com/joomcode/redispipe/redis"
 	"github.com/joomcode/redispipe/rediscluster/redisclusterutil"
 )
 
 // Scanner is an implementation of redis.Scanner.
 //
-// If it were called for SCAN command, it will iterate through all shards.
+// If it were called for SCAN command, it will iterate through all shards (one after another, ordered
+// by shard number). Shards are taken from cluster configuration at the moment Scanner is created.
+//
+// If master of a shard changes during scan (failover), scan of this shard is restarted on its new master
+// (one of addresses shard had when Scanner were created), since cursor is meaningless for other server.
+// So keys of this shard could be returned twice. If no address of shard is master anymore,
+// ErrNoAliveConnection is returned. Keys of slots migrated during scan could be missed or
+// returned twice (as with SCAN on single server during rehashing, caller should tolerate duplicates).
 type Scanner struct {
 	redis.ScannerBase
 
-	c     *Cluster
-	addrs []string
+	c *Cluster
+	// shards are addresses (master first) of shards to be scanned.
+	shards [][]string
+	// addr is a master current shard is being scanned on.
+	addr string
 }
 
 // Scanner implements redis.Sender.Scanner.
 func (c *Cluster) Scanner(opts redis.ScanOpts) redis.Scanner {
-	var addrs []string
+	var shards [][]string
 
-	if opts.Cmd == "" || opts.Cmd == "SCAN" {
-		cfg := c.getConfig()
-		addrs = make([]string, 0, len(cfg.masters))
-		for addr := range cfg.masters {
-			addrs = append(addrs, addr)
+	cfg := c.getConfig()
+	if opts.Cmd == "" || strings.EqualFold(opts.Cmd, "SCAN") {
+		nums := make([]int, 0, len(cfg.shards))
+		for num := range cfg.shards {
+			nums = append(nums, int(num))
 		}
-		if len(addrs) == 0 {
+		if len(nums) == 0 {
 			s := &Scanner{}
 			s.Err = c.err(ErrClusterConfigEmpty)
 			return s
 		}
+		sort.Ints(nums)
+		for _, num := range nums {
+			shards = append(shards, cfg.shards[uint16(num)].addr)
+		}
 	} else {
 		// other commands operates on single key
 		key := opts.Key
 		slot := redisclusterutil.Slot(key)
-		shard := c.getConfig().slot2shard(slot)
-		addrs = shard.addr[:1]
+		shards = [][]string{cfg.slot2shard(slot).addr}
 	}
 
 	return &Scanner{
 		ScannerBase: redis.ScannerBase{ScanOpts: opts},
 
-		c:     c,
-		addrs: addrs,
+		c:      c,
+		shards: shards,
 	}
 }
 
@@ -54,19 +70,46 @@ func (s *Scanner) Next(cb redis.Future) {
 		return
 	}
 	if s.IterLast() {
-		s.addrs = s.addrs[1:]
+		s.shards = s.shards[1:]
 		s.Iter = nil
+		s.addr = ""
 	}
-	if len(s.addrs) == 0 && s.Iter == nil {
+	if len(s.shards) == 0 && s.Iter == nil {
 		cb.Resolve(nil, 0)
 		return
 	}
-	conn := s.c.connForAddress(s.addrs[0])
+	master := s.c.currentMaster(s.shards[0])
+	if master == "" {
+		s.Err = s.c.err(ErrNoAliveConnection).
+			WithProperty(redis.EKAddress, s.shards[0][0])
+		cb.Resolve(s.Err, 0)
+		return
+	}
+	if s.addr != master {
+		if s.addr != "" {
+			// failover: cursor of previous master is not valid for new one.
+			s.Iter = nil
+		}
+		s.addr = master
+	}
+	conn := s.c.connForAddress(master)
 	if conn == nil {
 		s.Err = s.c.err(ErrNoAliveConnection).
-			WithProperty(redis.EKAddress, s.addrs[0])
+			WithProperty(redis.EKAddress, master)
 		cb.Resolve(s.Err, 0)
 		return
 	}
 	s.DoNext(cb, conn)
 }
+
+// currentMaster returns first of addrs which is master in current cluster configuration,
+// or empty string if there is no such address.
+func (c *Cluster) currentMaster(addrs []string) string {
+	cfg := c.getConfig()
+	for _, addr := range addrs {
+		if _, ok := cfg.masters[addr]; ok {
+			return addr
+		}
+	}
+	return ""
+}
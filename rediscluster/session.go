@@ -0,0 +1,75 @@
+package rediscluster
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/joomcode/redispipe/redis"
+)
+
+// Session wraps Cluster to give read-your-writes consistency to a logical session (request handler,
+// user session, job) while reads are served by replicas.
+// After session sends a command which is not replica safe (see redis.ReplicaSafe), all its requests are
+// sent to masters for PinWindow. Reads outside of the window use ReadPolicy.
+//
+// Replication is asynchronous, so the window should exceed usual replication lag. Pinned reads
+// don't benefit from replicas: they have master latency and add master load. Session doesn't wait
+// for replication (WAIT), so writes are not slowed down.
+// Session is safe for concurrent use, but it is meant to be short-lived: create one per logical session.
+// Session implements redis.Sender.
+type Session struct {
+	*Cluster
+	// ReadPolicy is a policy for reads outside of pin window.
+	ReadPolicy ReplicaPolicyEnum
+	// PinWindow is a time reads are sent to masters after last write.
+	PinWindow time.Duration
+
+	// pinnedUntil is a unix time in nanoseconds.
+	pinnedUntil int64
+}
+
+// Session returns new Session with specified read policy and pin window.
+func (c *Cluster) Session(readPolicy ReplicaPolicyEnum, pinWindow time.Duration) *Session {
+	return &Session{Cluster: c, ReadPolicy: readPolicy, PinWindow: pinWindow}
+}
+
+// Pinned returns true if session's reads are sent to masters at the moment.
+func (s *Session) Pinned() bool {
+	return time.Now().UnixNano() < atomic.LoadInt64(&s.pinnedUntil)
+}
+
+// policy returns policy for request, and pins session if request may write.
+func (s *Session) policy(req Request) ReplicaPolicyEnum {
+	if !redis.ReplicaSafe(req.Cmd) {
+		atomic.StoreInt64(&s.pinnedUntil, time.Now().Add(s.PinWindow).UnixNano())
+		return MasterOnly
+	}
+	if s.Pinned() {
+		return MasterOnly
+	}
+	return s.ReadPolicy
+}
+
+// Send implements redis.Sender.Send
+func (s *Session) Send(req Request, cb Future, off uint64) {
+	s.Cluster.SendWithPolicy(s.policy(req), req, cb, off)
+}
+
+// SendMany implements redis.Sender.SendMany
+func (s *Session) SendMany(reqs []Request, cb Future, off uint64) {
+	for i, req := range reqs {
+		s.Send(req, cb, off+uint64(i))
+	}
+}
+
+// SendTransaction implements redis.Sender.SendTransaction
+// Transaction is executed on master, and it pins session.
+func (s *Session) SendTransaction(reqs []Request, cb Future, off uint64) {
+	atomic.StoreInt64(&s.pinnedUntil, time.Now().Add(s.PinWindow).UnixNano())
+	s.Cluster.SendTransaction(reqs, cb, off)
+}
+
+// SendWithMeta implements redis.MetaSender.SendWithMeta
+func (s *Session) SendWithMeta(req Request, cb redis.FutureMeta, off uint64) {
+	s.Cluster.sendWithPolicy(s.policy(req), req, cb, cb, off)
+}
@@ -82,6 +82,11 @@ func (c *Cluster) updateMappings(slotRanges []redisclusterutil.SlotsRange) {
 		}
 	}
 
+	newConfig.ranges = slotRanges
+	if !equalRanges(oldConfig.ranges, slotRanges) {
+		newConfig.version++
+	}
+
 	newConfig.shards = make(shardMap, len(oldConfig.shards))
 	newConfig.masters = make(masterMap, len(oldConfig.masters))
 
@@ -166,6 +171,45 @@ func (c *Cluster) updateMappings(slotRanges []redisclusterutil.SlotsRange) {
 	})
 }
 
+// SlotsSnapshot is a copy of cluster's view of slots mapping (see Cluster.Slots).
+type SlotsSnapshot struct {
+	// Version is incremented every time mapping is changed, so caller could detect topology change.
+	// It is 0 until mapping is loaded.
+	Version uint64
+	// Ranges are slot ranges sorted by slot. First address of range is a master, and other are replicas.
+	// Slots not covered by any range are not served by cluster.
+	Ranges []redisclusterutil.SlotsRange
+}
+
+// Slots returns snapshot of current slots mapping, which is used to route requests.
+// It could be used by external routing instead of issuing CLUSTER SLOTS separately.
+// Snapshot is a copy, so it could be modified by caller.
+func (c *Cluster) Slots() SlotsSnapshot {
+	cfg := c.getConfig()
+	ranges := make([]redisclusterutil.SlotsRange, len(cfg.ranges))
+	for i, r := range cfg.ranges {
+		ranges[i] = redisclusterutil.SlotsRange{From: r.From, To: r.To, Addrs: append([]string(nil), r.Addrs...)}
+	}
+	return SlotsSnapshot{Version: cfg.version, Ranges: ranges}
+}
+
+func equalRanges(a, b []redisclusterutil.SlotsRange) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].From != b[i].From || a[i].To != b[i].To || len(a[i].Addrs) != len(b[i].Addrs) {
+			return false
+		}
+		for j := range a[i].Addrs {
+			if a[i].Addrs[j] != b[i].Addrs[j] {
+				return false
+			}
+		}
+	}
+	return true
+}
+
 func (s *shard) setReplicaInfo(res interface{}, n uint64) {
 	haserr := false
 	if err := redis.AsError(res); err != nil {
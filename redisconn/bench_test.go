@@ -3,6 +3,8 @@ package redisconn_test
 import (
 	"context"
 	"runtime"
+	"strconv"
+	"sync"
 	. "testing"
 
 	"github.com/joomcode/redispipe/redis"
@@ -185,3 +187,158 @@ func newRedigo() redigo.Conn {
 	}
 	return c
 }
+
+func BenchmarkMixedSizes(b *B) {
+	defer benchServer(45678)()
+	small := "bar"
+	large := string(make([]byte, 256*1024))
+
+	run := func(b *B, opts redisconn.Opts) {
+		opts.Logger = redisconn.NoopLogger{}
+		pipe, err := redisconn.Connect(context.Background(), "127.0.0.1:45678", opts)
+		if err != nil {
+			b.Fatal(err)
+		}
+		defer pipe.Close()
+		sync := redis.Sync{pipe}
+		b.ReportAllocs()
+		b.ResetTimer()
+		b.RunParallel(func(pb *PB) {
+			i := 0
+			for pb.Next() {
+				val := small
+				if i++; i%16 == 0 {
+					val = large
+				}
+				if res := sync.Do("SET", "foo", val); redis.AsError(res) != nil {
+					b.Fatal(res)
+				}
+			}
+		})
+	}
+
+	b.Run("no_pool", func(b *B) {
+		run(b, redisconn.Opts{})
+	})
+	b.Run("pool", func(b *B) {
+		run(b, redisconn.Opts{BufferPool: redisconn.NewBufferPool()})
+	})
+}
+
+func BenchmarkLargeMGet(b *B) {
+	defer benchServer(45678)()
+	val := string(make([]byte, 4*1024))
+	keys := make([]interface{}, 64)
+	for i := range keys {
+		keys[i] = "mget" + strconv.Itoa(i)
+	}
+
+	run := func(b *B, opts redisconn.Opts) {
+		opts.Logger = redisconn.NoopLogger{}
+		pipe, err := redisconn.Connect(context.Background(), "127.0.0.1:45678", opts)
+		if err != nil {
+			b.Fatal(err)
+		}
+		defer pipe.Close()
+		sync := redis.Sync{pipe}
+		for _, key := range keys {
+			sync.Do("SET", key, val)
+		}
+		b.ReportAllocs()
+		b.ResetTimer()
+		b.RunParallel(func(pb *PB) {
+			for pb.Next() {
+				if res := sync.Do("MGET", keys...); redis.AsError(res) != nil {
+					b.Fatal(res)
+				}
+			}
+		})
+	}
+
+	b.Run("reader", func(b *B) {
+		run(b, redisconn.Opts{})
+	})
+	b.Run("workers", func(b *B) {
+		run(b, redisconn.Opts{ResolveWorkers: runtime.GOMAXPROCS(0)})
+	})
+}
+
+func BenchmarkBulkLoadSizeHint(b *B) {
+	defer benchServer(45678)()
+	const n = 1000000
+	reqs := make([]redis.Request, n)
+	size := 0
+	for i := range reqs {
+		reqs[i] = redis.Req("SET", "bulk"+strconv.Itoa(i), "value")
+		sz, _ := redis.RequestSize(reqs[i])
+		size += sz
+	}
+
+	run := func(b *B, hint int) {
+		pipe, err := redisconn.Connect(context.Background(), "127.0.0.1:45678", redisconn.Opts{
+			Logger:     redisconn.NoopLogger{},
+			IOTimeout:  -1,
+			WritePause: -1,
+		})
+		if err != nil {
+			b.Fatal(err)
+		}
+		defer pipe.Close()
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			var wg sync.WaitGroup
+			wg.Add(n)
+			pipe.SendManyHint(reqs, redis.FuncFuture(func(res interface{}, _ uint64) {
+				wg.Done()
+			}), 0, hint)
+			wg.Wait()
+		}
+	}
+
+	b.Run("nohint", func(b *B) {
+		run(b, 0)
+	})
+	b.Run("hint", func(b *B) {
+		run(b, size)
+	})
+}
+
+// BenchmarkLargePipelineRetention sends huge pipelines and reports heap retained after them.
+// Futures slices of huge batches should not be kept in connection's pool.
+func BenchmarkLargePipelineRetention(b *B) {
+	defer benchServer(45678)()
+	const n = 200000
+	reqs := make([]redis.Request, n)
+	for i := range reqs {
+		reqs[i] = redis.Req("GET", "retention"+strconv.Itoa(i%1024))
+	}
+
+	pipe, err := redisconn.Connect(context.Background(), "127.0.0.1:45678", redisconn.Opts{
+		Logger:     redisconn.NoopLogger{},
+		IOTimeout:  -1,
+		WritePause: -1,
+	})
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer pipe.Close()
+	var mem runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&mem)
+	base := mem.HeapInuse
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var wg sync.WaitGroup
+		wg.Add(n)
+		pipe.SendMany(reqs, redis.FuncFuture(func(res interface{}, _ uint64) {
+			wg.Done()
+		}), 0)
+		wg.Wait()
+	}
+	b.StopTimer()
+	runtime.GC()
+	runtime.ReadMemStats(&mem)
+	b.ReportMetric(float64(int64(mem.HeapInuse)-int64(base))/(1<<20), "retained-MB")
+}
@@ -0,0 +1,83 @@
+package redisconn
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// defaultBreakerCooldown is a default of Opts.CircuitBreakerCooldown.
+const defaultBreakerCooldown = time.Second
+
+// circuitBreaker fails requests fast after series of connection failures (see Opts.CircuitBreakerFailures).
+// openUntil is 0 while breaker is closed. When it is open, single request (probe) is let through
+// every cooldown: any response read from socket closes breaker, and connection failure keeps it open.
+// failure is called under Connection.mutex, allow and success are called concurrently, so state
+// they share is atomic.
+type circuitBreaker struct {
+	threshold int32
+	window    int64
+	cooldown  int64
+
+	failures  int32
+	first     int64
+	openUntil int64
+}
+
+func newCircuitBreaker(threshold int, window, cooldown time.Duration) *circuitBreaker {
+	if cooldown <= 0 {
+		cooldown = defaultBreakerCooldown
+	}
+	return &circuitBreaker{
+		threshold: int32(threshold),
+		window:    int64(window),
+		cooldown:  int64(cooldown),
+	}
+}
+
+// allow reports whether request could be sent now. While breaker is open, it allows one probe
+// per cooldown.
+func (b *circuitBreaker) allow(now int64) bool {
+	until := atomic.LoadInt64(&b.openUntil)
+	if until == 0 {
+		return true
+	}
+	return now >= until && atomic.CompareAndSwapInt64(&b.openUntil, until, now+b.cooldown)
+}
+
+// success is called when response is read from socket.
+func (b *circuitBreaker) success() {
+	if atomic.LoadInt32(&b.failures) != 0 {
+		atomic.StoreInt32(&b.failures, 0)
+	}
+	if atomic.LoadInt64(&b.openUntil) != 0 {
+		atomic.StoreInt64(&b.openUntil, 0)
+	}
+}
+
+// failure is called when connection attempt fails or socket breaks. It returns true if breaker
+// were opened by this failure.
+func (b *circuitBreaker) failure(now int64) bool {
+	if atomic.LoadInt64(&b.openUntil) != 0 {
+		// probe failed
+		atomic.StoreInt64(&b.openUntil, now+b.cooldown)
+		return false
+	}
+	if atomic.LoadInt32(&b.failures) == 0 || b.window > 0 && now-b.first > b.window {
+		atomic.StoreInt32(&b.failures, 0)
+		b.first = now
+	}
+	if atomic.AddInt32(&b.failures, 1) < b.threshold {
+		return false
+	}
+	atomic.StoreInt32(&b.failures, 0)
+	atomic.StoreInt64(&b.openUntil, now+b.cooldown)
+	return true
+}
+
+// breakerFailure counts connection failure, and reports LogCircuitOpen if it opens breaker.
+// It is called under mutex.
+func (conn *Connection) breakerFailure(err error) {
+	if conn.breaker != nil && conn.breaker.failure(nownano()) {
+		conn.report(LogCircuitOpen{Failures: conn.opts.CircuitBreakerFailures, Error: err})
+	}
+}
@@ -0,0 +1,54 @@
+package redisconn
+
+import "sync"
+
+const (
+	minPoolBuf  = 4 * 1024
+	poolClasses = 9 // 4KB .. 1MB
+)
+
+// BufferPool is a pool of byte buffers used by writer to serialize requests.
+// Buffers are grouped by power of two size classes from 4KB to 1MB; larger buffers are not pooled.
+// Single BufferPool could be shared by many connections (see Opts.BufferPool).
+type BufferPool struct {
+	classes [poolClasses]sync.Pool
+}
+
+// NewBufferPool returns new BufferPool.
+func NewBufferPool() *BufferPool {
+	return &BufferPool{}
+}
+
+func poolClass(size int) int {
+	class := 0
+	for c := minPoolBuf; c < size; c <<= 1 {
+		class++
+	}
+	return class
+}
+
+// Get returns empty buffer with capacity at least size.
+func (p *BufferPool) Get(size int) []byte {
+	class := poolClass(size)
+	if class >= poolClasses {
+		return make([]byte, 0, size)
+	}
+	if b, ok := p.classes[class].Get().(*[]byte); ok {
+		return (*b)[:0]
+	}
+	return make([]byte, 0, minPoolBuf<<uint(class))
+}
+
+// Put returns buffer to pool. Buffer should not be used after that.
+func (p *BufferPool) Put(b []byte) {
+	if cap(b) < minPoolBuf || cap(b) > minPoolBuf<<(poolClasses-1) {
+		return
+	}
+	// buffer goes to largest class it satisfies.
+	class := 0
+	for c := minPoolBuf * 2; c <= cap(b); c <<= 1 {
+		class++
+	}
+	b = b[:0]
+	p.classes[class].Put(&b)
+}
@@ -0,0 +1,31 @@
+package redisconn_test
+
+import (
+	"testing"
+
+	. "github.com/joomcode/redispipe/redisconn"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBufferPool(t *testing.T) {
+	p := NewBufferPool()
+
+	b := p.Get(100)
+	assert.Equal(t, 0, len(b))
+	assert.Equal(t, 4096, cap(b))
+
+	b = p.Get(5000)
+	assert.Equal(t, 8192, cap(b))
+	p.Put(append(b, 1, 2, 3))
+	b = p.Get(5000)
+	assert.Equal(t, 0, len(b))
+	assert.True(t, cap(b) >= 5000)
+
+	b = p.Get(4 << 20)
+	assert.True(t, cap(b) >= 4<<20)
+	p.Put(b) // too large, just dropped
+
+	// buffer of odd capacity goes to class it fully satisfies
+	p.Put(make([]byte, 0, 12288))
+	assert.True(t, cap(p.Get(8192)) >= 8192)
+}
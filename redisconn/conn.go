@@ -3,8 +3,11 @@ package redisconn
 import (
 	"bufio"
 	"context"
+	"crypto/tls"
 	"fmt"
+	"io"
 	"net"
+	"strconv"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -19,11 +22,20 @@ const (
 	DoAsking = 1
 	// DoTransaction is a flag for Connection.SendBatchFlag signalling to wrap bunch of requests into MULTI/EXEC.
 	DoTransaction = 2
+	// DoCaching is a flag for Connection.SendBatchFlag signalling to send CLIENT CACHING YES before requests,
+	// so their keys are tracked in OPTIN tracking mode. It should not be combined with DoAsking
+	// (server resets both states on next command).
+	DoCaching = 4
+
+	// maxPooledFutures is a maximum capacity of futures slice recycled through oneconn.futpool.
+	// Larger slices (left by huge pipelines) are left to GC, so pool doesn't retain memory forever.
+	maxPooledFutures = 1024
 
 	connDisconnected = 0
 	connConnecting   = 1
 	connConnected    = 2
 	connClosed       = 3
+	connClosing      = 4
 
 	defaultIOTimeout  = 1 * time.Second
 	defaultWritePause = 50 * time.Microsecond
@@ -35,10 +47,32 @@ type Opts struct {
 	DB int
 	// Password for AUTH
 	Password string
-	// IOTimeout - timeout on read/write to socket.
+	// Username for AUTH (redis >= 6.0 ACL). If it is empty, AUTH is sent with password only
+	// (ie as "default" user).
+	Username string
+	// ClientName is set with CLIENT SETNAME (or with HELLO SETNAME) for every socket,
+	// so connection could be identified in CLIENT LIST.
+	// It may contain placeholders, which are substituted once per Connection (so name survives reconnect):
+	//   - {host} - hostname (ie pod name in Kubernetes);
+	//   - {id} - sequential number of Connection in process;
+	//   - {handle} - Opts.Handle formatted with fmt.Sprint.
+	// Spaces and special characters of substituted values are replaced with '_'. If name itself contains
+	// them, Connect fails with ErrClientName (since server would reject such name).
+	ClientName string
+	// IOTimeout - timeout on read from socket (default for ReadTimeout) and on connection handshake.
+	// Connection also pings server every IOTimeout/3 (or ReadTimeout/3, if it is less).
 	// If IOTimeout == 0, then it is set to 1 second
-	// If IOTimeout < 0, then timeout is disabled
+	// If IOTimeout < 0, then timeout is disabled, but socket is still re-established if keepalive PING
+	// is not answered in 3 seconds (so half-open socket is detected).
 	IOTimeout time.Duration
+	// ReadTimeout - timeout on read from socket. It catches slow or stuck commands.
+	// If ReadTimeout == 0, then IOTimeout is used.
+	// If ReadTimeout < 0, then read timeout is disabled, but socket is still re-established if keepalive PING
+	// is not answered in IOTimeout.
+	ReadTimeout time.Duration
+	// WriteTimeout - timeout on write to socket. It catches stuck send buffer (ie unhealthy network).
+	// If WriteTimeout <= 0, then write timeout is disabled (default).
+	WriteTimeout time.Duration
 	// DialTimeout is timeout for net.Dialer
 	// If it is <= 0 or >= IOTimeout, then IOTimeout
 	// If IOTimeout is disabled, then 5 seconds used (but without affect on ReconnectPause)
@@ -47,9 +81,25 @@ type Opts struct {
 	// If ReconnectPause < 0, then no reconnection will be performed.
 	// If ReconnectPause == 0, then DialTimeout * 2 is used
 	ReconnectPause time.Duration
+	// MaxReconnectAttempts - if set, connection is closed forever after this number of consecutive
+	// failed connection attempts. Pending requests and further requests are failed with ErrReconnectLimit.
+	// Counter is reset on successful connect.
+	// Default is 0 - reconnect forever.
+	MaxReconnectAttempts int
+	// ServerClosedPause - pause before reconnecting after server closed socket cleanly (ErrServerClosed),
+	// ie connection were killed with CLIENT KILL or by server timeout. It prevents immediate reconnection
+	// fighting with operator. Requests are rejected with ErrNotConnected during the pause.
+	// Default is 0 - reconnect immediately as after any other io error.
+	ServerClosedPause time.Duration
 	// TCPKeepAlive - KeepAlive parameter for net.Dialer
 	// default is IOTimeout / 3
 	TCPKeepAlive time.Duration
+	// TLSConfig - if set, connection is established over TLS. It is cloned, and ServerName is set to host
+	// of address if it is empty. Client certificate (for mutual TLS) and custom verification
+	// (VerifyPeerCertificate, VerifyConnection) are configured with it as usual.
+	// Certificate verification failure, either local or reported by server, is returned as ErrTLSVerify.
+	// It is not used by ConnectOnConn.
+	TLSConfig *tls.Config
 	// Handle is returned with Connection.Handle()
 	Handle interface{}
 	// WritePause - write loop pauses for this time to collect more requests.
@@ -58,12 +108,166 @@ type Opts struct {
 	WritePause time.Duration
 	// Logger
 	Logger Logger
+	// MaxConnLifetime - if set, connection is periodically replaced with new one after this time.
+	// New socket is established first, then requests in flight on old socket are drained
+	// (see CloseGracefully), so no request is lost.
+	MaxConnLifetime time.Duration
+	// QueueWhileConnecting - maximum time request may wait in queue while connection is being established.
+	// Request waiting longer is resolved with ErrNotConnected. Without the limit, request waits until
+	// dial attempt finishes (up to DialTimeout).
+	// Default is 0 - no limit.
+	QueueWhileConnecting time.Duration
 	// AsyncDial - do not establish connection immediately
 	AsyncDial bool
+	// FailFast - Connect returns error (and nil Connection) if first connection attempt fails,
+	// regardless of ReconnectPause. Reconnection after successful Connect is not affected.
+	// It is ignored if AsyncDial is set.
+	FailFast bool
+	// MaxPendingBytes - limit on size of requests queued but not yet written to socket.
+	// Request is rejected with ErrBufferFull if limit is exceeded. It protects from memory blowup
+	// with large values when server stalls.
+	// Size is computed at enqueue time with redis.RequestSize. Request is always accepted into empty queue,
+	// so single request larger than limit could be sent.
+	// Default is 0 - no limit.
+	MaxPendingBytes int
+	// MaxQueuedRequests - limit on number of requests queued but not yet written to socket.
+	// Request is rejected with ErrBufferFull if limit is exceeded, so burst of requests is not
+	// accumulated behind slow socket, and caller could shed load or send it elsewhere.
+	// Batch is accepted or rejected as whole. Request is always accepted into empty queue.
+	// Internal requests (ASKING, MULTI, etc) are counted as well.
+	// Default is 0 - no limit.
+	MaxQueuedRequests int
+	// RateLimit - maximum number of requests per second sent through connection.
+	// Requests over limit are not queued but rejected with ErrRateLimited (Send is asynchronous,
+	// so it doesn't wait for tokens). Requests of batch and transaction are counted individually.
+	// Default is 0 - no limit.
+	RateLimit float64
+	// RateBurst - number of requests which could be sent at once over RateLimit.
+	// Default is RateLimit (ie one second worth of requests), but at least 1.
+	RateBurst int
+	// PausedWriteThreshold - if set, connection reports LogPossiblyPaused when write command is answered
+	// slower than threshold while read commands queued before it were answered faster.
+	// It is a hint that writes are paused with CLIENT PAUSE WRITE (or replication is stuck with
+	// min-replicas-to-write). It is diagnostic only and doesn't change behavior.
+	// Default is 0 - disabled.
+	PausedWriteThreshold time.Duration
+	// BufferPool - pool of buffers for serialized requests. It could be shared between connections
+	// to reduce memory usage and allocations with many connections and variable request sizes.
+	// Default is nil - every connection keeps its own buffer.
+	BufferPool *BufferPool
+	// CheckDesync - writer appends ECHO with unique token after every written batch, and reader
+	// verifies its response. If it doesn't match, connection is closed with redis.ErrDesync,
+	// so mismatched responses are not delivered silently after protocol desynchronization.
+	// It costs one extra command per batch.
+	CheckDesync bool
+	// Protocol - if 3, default handshake negotiates RESP3 with HELLO 3. If server doesn't support
+	// HELLO, connection stays on RESP2. redis.ReadResponse returns RESP3 types as their RESP2 counterparts
+	// (map as flat list, double as bulk string, etc), and push frames (invalidations of client side caching)
+	// are skipped. But some commands reply in different shape: e.g. ZRANGE WITHSCORES and HRANDFIELD WITHVALUES
+	// return list of two-element arrays instead of flat list, and XREAD returns map instead of list of pairs.
+	// Parsers of redis package accept both shapes; raw responses should be handled by caller.
+	// Negotiated protocol is returned by Connection.Protocol.
+	// Default is 0 - RESP2 without HELLO.
+	Protocol int
+	// Handshake - custom connection setup. It is called for every new socket instead of default
+	// AUTH, PING and SELECT. It may queue requests with h.Default(), h.Auth(), h.Ping(), h.Select(), h.Do()
+	// and send them with h.Flush(); requests left queued are flushed after Handshake returns.
+	// Non-errorx error returned from Handshake is wrapped with ErrInit.
+	Handshake func(ctx context.Context, h *Handshake) error
+	// DecodeHook - if set, it is called with every successful response before future is resolved.
+	// If it returns ok, returned value is passed to future instead of raw response.
+	// It allows to transparently deserialize values (for example, by command or key prefix).
+	// Error responses are not passed to hook. Commands inside of transaction are answered with "QUEUED",
+	// and their results are passed to hook as single EXEC response.
+	// Hook is called from reader goroutine (or from resolve worker, see ResolveWorkers), so it should be fast.
+	DecodeHook func(req Request, raw interface{}) (interface{}, bool)
+	// Codec - serialization used by Connection.SetValue and Connection.GetValue.
+	// Default is redis.JSONCodec.
+	Codec redis.Codec
+	// ResolveWorkers - if set, responses are passed to this number of worker goroutines, which apply
+	// DecodeHook and resolve futures, so reader goroutine is busy only with parsing socket.
+	// It helps when callbacks or DecodeHook are heavy. Responses of single batch could be resolved
+	// in any order (as it happens with cluster anyway), so futures should be concurrency-safe.
+	// Default is 0 - futures are resolved by reader.
+	ResolveWorkers int
+	// SlowLogThreshold - if set, request answered slower than threshold is reported as LogSlowRequest.
+	// Latency is measured from enqueueing, so it includes time spent in queue before request is written,
+	// which is not visible in server's SLOWLOG. Only command name is reported, arguments are not.
+	// Default is 0 - disabled.
+	SlowLogThreshold time.Duration
+	// WireLogger - if set, it receives copy of all data sent to and received from redis, for debugging
+	// of protocol issues. Every write and read of socket is logged as separate line: address, direction
+	// (">" for sent data, "<" for received) and quoted data. Received data is logged in chunks as it is
+	// read from socket, so RESP frame could be split between lines.
+	// Credentials of AUTH and HELLO are replaced with placeholder, but keys and values are logged as is.
+	// It is very slow and should not be used in production.
+	WireLogger io.Writer
+	// HealthCheck - additional check called by control loop after every keepalive PING
+	// (ie 3 times per IOTimeout) with context limited by the same interval.
+	// If it returns error with redis.ErrTraitConnectivity trait, socket is closed and re-established.
+	// Other errors are reported as LogHealthCheckFailed. It could be used to detect replica
+	// which is promoted unexpectedly, or replication lag.
+	// Default is nil - only PING is sent.
+	HealthCheck func(ctx context.Context, c redis.Sender) error
 	// ScriptMode - enables blocking commands and turns default WritePause to -1.
 	// It will allow to use this connector in script like (ie single threaded) environment
 	// where it is ok to use blocking commands and pipelining gives no gain.
+	// Read timeout is extended by timeout of blocking commands in flight (see redis.BlockingTimeout).
 	ScriptMode bool
+	// OnQueueActivated - if set, it is called when queue of requests not yet written to socket becomes
+	// non-empty, ie at the moment writer is woken up. Frequent activations relative to number of requests
+	// mean poor batching (writer takes requests one by one). It is a low-level hook for metrics and
+	// custom write strategies.
+	// It is called under queue lock, so it should be fast and must not call methods of Connection.
+	OnQueueActivated func()
+	// RequeueDropped - maximum number of times request queued but not yet written to socket is re-queued
+	// to next socket when connection breaks, instead of being resolved with ErrDropped.
+	// Only read-only requests (see redis.ReplicaSafe) are re-queued, since they are idempotent.
+	// Write requests, transactions and requests in flight (which could be executed) are still failed.
+	// Requests are kept only until next connection attempt: if it fails, they are resolved with its error.
+	// Default is 0 - queued requests are failed.
+	RequeueDropped int
+	// OnReconnect - if set, it is called when socket is re-established: after previous socket broke
+	// (err is the error which broke it, attempt is a number of connection attempts it took), or after
+	// it were replaced because of MaxConnLifetime (err is nil). oldAddr and newAddr are remote addresses
+	// of previous and new sockets, so change of resolved address (failover) is visible.
+	// It is not called for first connection. It is called synchronously, so it should be fast.
+	OnReconnect func(attempt int, oldAddr, newAddr string, err error)
+	// ValidateArity - check number of arguments of request against command arity before it is queued
+	// (see redis.CheckArity). Request with wrong number of arguments is rejected with redis.ErrArgumentCount.
+	// Only commands known to static table or to CommandArity are checked.
+	ValidateArity bool
+	// CommandArity - arities of custom commands (or overrides of static table) for ValidateArity.
+	// Keys are upper-case command names, values are in COMMAND INFO format: positive is exact number
+	// of arguments including command name, negative is minimal number of arguments.
+	CommandArity map[string]int
+	// PreferUnlink - Del sends UNLINK instead of DEL (falling back to DEL if server doesn't know UNLINK),
+	// so large values are freed in background without blocking server.
+	PreferUnlink bool
+	// CircuitBreakerFailures - if set, circuit breaker is opened after this number of consecutive connection
+	// failures (failed connection attempts and broken sockets, without successful response in between).
+	// While breaker is open, requests fail fast with ErrCircuitOpen without being queued, except single
+	// probe request let through every CircuitBreakerCooldown (keepalive pings are always sent).
+	// Any response read from socket closes breaker. Opening is reported as LogCircuitOpen.
+	// Default is 0 - no circuit breaker.
+	CircuitBreakerFailures int
+	// CircuitBreakerWindow - failures are counted as consecutive only if they happen within this time
+	// since first of them. Default is 0 - no limit.
+	CircuitBreakerWindow time.Duration
+	// CircuitBreakerCooldown - interval between probe requests while circuit breaker is open.
+	// Default is 1 second.
+	CircuitBreakerCooldown time.Duration
+	// PubSubBuffer - capacity of channel returned by Subscribe, PSubscribe and SSubscribe.
+	// Default is 128.
+	PubSubBuffer int
+	// PubSubPolicy - what subscription does when its channel is full. Default is PubSubDropOldest.
+	PubSubPolicy PubSubPolicy
+	// RequestRewriter - if set, every request is passed through it before it is checked and queued.
+	// It could be used to apply common policy, for example, to prefix keys with tenant id (see redis.MapKeys).
+	// Requests of transaction are rewritten one by one. Requests sent by connection itself (handshake,
+	// keepalive pings) are not rewritten.
+	// rediscluster.Cluster rewrites requests itself before slot is computed, so rewritten keys determine routing.
+	RequestRewriter func(req Request) Request
 }
 
 // Connection is implementation of redis.Sender which represents single connection to single redis instance.
@@ -75,45 +279,162 @@ type Connection struct {
 	ctx    context.Context
 	cancel context.CancelFunc
 	state  uint32
+	// refuse is set by StopAccepting.
+	refuse uint32
+	// db is a selected database. It is initialized from Opts.DB and changed with Select.
+	db int32
+	// proto is a protocol negotiated by last handshake.
+	proto int32
+	// pending is a number of requests queued or in flight and not resolved yet.
+	pending int64
+	// eventsDropped is a number of events dropped from full Events() channel.
+	eventsDropped uint64
+	// idle is closed when pending drops to 0 (see Idle). It is nil if nobody waits for it.
+	idle    chan struct{}
+	idlemtx sync.Mutex
+	// block tracks blocking requests in flight (WAIT and WAITAOF, and all blocking commands in ScriptMode).
+	block blockState
+	// lastFastRead and pauseReported are used for PausedWriteThreshold detection.
+	lastFastRead  int64
+	pauseReported int64
 
 	addr  string
 	c     net.Conn
+	one   *oneconn
 	mutex sync.Mutex
+	// dialFailures is a number of consecutive failed connection attempts (guarded by mutex).
+	dialFailures int
+	// gaveUp is set when Opts.MaxReconnectAttempts is exceeded, just before connection is closed.
+	gaveUp *errorx.Error
+	// prevAddr and prevErr are remote address of broken socket and its error, used for Opts.OnReconnect
+	// (guarded by mutex).
+	prevAddr string
+	prevErr  error
 
 	futures   []future
+	futbytes  int
 	futsignal chan struct{}
 	futtimer  *time.Timer
 	futmtx    sync.Mutex
+	// sizeHint is a writer buffer size passed with SendManyHint (guarded by futmtx).
+	sizeHint int
+	// limiter is nil if Opts.RateLimit is not set.
+	limiter *rateLimiter
+	// breaker is nil if Opts.CircuitBreakerFailures is not set.
+	breaker *circuitBreaker
+	// pubsubDropped is a number of messages dropped by slow subscriptions (accessed atomically).
+	pubsubDropped int64
+	// subscribed counts active subscriptions to every channel or pattern (guarded by submtx).
+	subscribed map[string]int
+	submtx     sync.Mutex
+	// wirelog is nil if Opts.WireLogger is not set.
+	wirelog *wireLog
+	// resolveq is a queue of resolve workers, it is nil if Opts.ResolveWorkers is not set.
+	resolveq chan resolveTask
+	// readers tracks reader loops, so resolveq is closed after all of them are finished.
+	readers sync.WaitGroup
 
 	firstConn chan struct{}
 	opts      Opts
+	// parent and origOpts are arguments of Connect (opts are normalized), they are used by Clone.
+	parent   context.Context
+	origOpts Opts
+	// optsmtx guards fields of opts and origOpts changed with UpdateOpts after Connect:
+	// Username, Password, ReadTimeout, WriteTimeout and DialTimeout (rate limit is guarded with futmtx).
+	optsmtx sync.Mutex
+	// existing is a socket passed to ConnectOnConn. It is used by first openConnection only.
+	existing      net.Conn
+	existingTaken uint32
+
+	events chan Event
 }
 
 type oneconn struct {
-	c       net.Conn
+	// inflight is a number of requests written to socket and not resolved yet.
+	inflight int64
+
+	c net.Conn
+	w io.Writer
+	// r is read by reader goroutine only; CloseGracefully reads QUIT answer from it after reader exits.
+	r       *bufio.Reader
 	futures chan []future
 	control chan struct{}
 	err     error
 	erronce sync.Once
 	futpool chan []future
+	// drain is closed (once, with drainOnce) to stop writer after it flushes already queued requests.
+	drain      chan struct{}
+	drainOnce  sync.Once
+	writerDone chan struct{}
+	readerDone chan struct{}
+	// idle is signalled by reader when inflight drops to zero.
+	idle    chan struct{}
+	created time.Time
+	// readTimeout is Opts.ReadTimeout at the moment socket were established.
+	readTimeout time.Duration
 }
 
 // Connect establishes new connection to redis server.
 // Connect will be automatically closed if context will be cancelled or timeouted. But it could be closed explicitely
 // as well.
+//
+// Unless AsyncDial is set, first connection attempt is made synchronously. If it fails, error is returned
+// only if Opts.FailFast is set, Opts.ReconnectPause < 0, or error is permanent (ErrTraitInitPermanent,
+// like authentication failure). Otherwise Connection is returned without error, and it keeps reconnecting
+// in background: so nil error doesn't guarantee connectivity (see ConnectedNow).
+//
+// If ctx is already done, ErrContextClosed is returned immediately, and nothing is started.
 func Connect(ctx context.Context, addr string, opts Opts) (conn *Connection, err error) {
+	if addr == "" {
+		return nil, redis.ErrNoAddressProvided.New("address is not specified")
+	}
+	return connect(ctx, addr, opts, nil)
+}
+
+// ConnectOnConn creates Connection over already established net.Conn (for example, stream
+// of multiplexed tunnel, or end of net.Pipe in tests). Dial is skipped, but handshake is performed
+// as usual.
+// Since there is nothing to re-dial, connection is not re-established: Connection is closed when c
+// breaks (ie ReconnectPause < 0 and AsyncDial = false are forced, and MaxConnLifetime is ignored).
+// Monitor and Subscribe are not available, because they require dedicated socket.
+func ConnectOnConn(ctx context.Context, c net.Conn, opts Opts) (*Connection, error) {
+	if c == nil {
+		return nil, redis.ErrNoAddressProvided.New("connection is not specified")
+	}
+	opts.ReconnectPause = -1
+	opts.AsyncDial = false
+	opts.MaxConnLifetime = 0
+	conn, err := connect(ctx, c.RemoteAddr().String(), opts, c)
+	if err != nil {
+		c.Close()
+	}
+	return conn, err
+}
+
+func connect(ctx context.Context, addr string, opts Opts, existing net.Conn) (conn *Connection, err error) {
 	if ctx == nil {
 		return nil, redis.ErrContextIsNil.New("context is not specified")
 	}
-	if addr == "" {
-		return nil, redis.ErrNoAddressProvided.New("address is not specified")
+	if ctxerr := ctx.Err(); ctxerr != nil {
+		return nil, redis.ErrContextClosed.Wrap(ctxerr, "context is done before connect").
+			WithProperty(redis.EKAddress, addr)
 	}
-	conn = &Connection{
-		addr: addr,
-		opts: opts,
+	clientName, err := expandClientName(opts.ClientName, opts.Handle)
+	if err != nil {
+		return nil, err
 	}
+	conn = &Connection{
+		addr:     addr,
+		opts:     opts,
+		parent:   ctx,
+		origOpts: opts,
+		existing: existing,
+		db:       int32(opts.DB),
+	}
+	conn.opts.ClientName = clientName
 	conn.ctx, conn.cancel = context.WithCancel(ctx)
 
+	conn.events = make(chan Event, eventsBuffer)
 	conn.futsignal = make(chan struct{}, 1)
 	conn.futtimer = time.NewTimer(24 * time.Hour)
 	conn.futtimer.Stop()
@@ -124,9 +445,7 @@ func Connect(ctx context.Context, addr string, opts Opts) (conn *Connection, err
 		conn.opts.IOTimeout = 0
 	}
 
-	if conn.opts.DialTimeout <= 0 || conn.opts.DialTimeout > conn.opts.IOTimeout {
-		conn.opts.DialTimeout = conn.opts.IOTimeout
-	}
+	conn.normalizeTimeouts(&conn.opts)
 
 	if conn.opts.ReconnectPause == 0 {
 		conn.opts.ReconnectPause = conn.opts.DialTimeout * 2
@@ -139,6 +458,10 @@ func Connect(ctx context.Context, addr string, opts Opts) (conn *Connection, err
 		conn.opts.TCPKeepAlive = 0
 	}
 
+	if conn.opts.TLSConfig != nil {
+		conn.opts.TLSConfig = prepareTLSConfig(conn.opts.TLSConfig, conn.addr)
+	}
+
 	if conn.opts.WritePause == 0 {
 		if conn.opts.ScriptMode {
 			conn.opts.WritePause = -1
@@ -151,12 +474,34 @@ func Connect(ctx context.Context, addr string, opts Opts) (conn *Connection, err
 		conn.opts.Logger = DefaultLogger{}
 	}
 
+	if conn.opts.WireLogger != nil {
+		conn.wirelog = &wireLog{w: conn.opts.WireLogger, addr: addr}
+	}
+
+	if conn.opts.RateLimit > 0 {
+		conn.limiter = newRateLimiter(conn.opts.RateLimit, conn.opts.RateBurst)
+	}
+
+	if conn.opts.CircuitBreakerFailures > 0 {
+		conn.breaker = newCircuitBreaker(conn.opts.CircuitBreakerFailures,
+			conn.opts.CircuitBreakerWindow, conn.opts.CircuitBreakerCooldown)
+	}
+
+	if conn.opts.ResolveWorkers > 0 {
+		conn.startResolvers(conn.opts.ResolveWorkers)
+	}
+
 	if !conn.opts.AsyncDial {
-		if err = conn.createConnection(false, nil); err != nil {
-			if opts.ReconnectPause < 0 {
-				return nil, err
-			}
-			if cer, ok := err.(*errorx.Error); ok && cer.HasTrait(ErrTraitInitPermanent) {
+		// reader could fail and start reconnection before createConnection returns.
+		conn.mutex.Lock()
+		err = conn.createConnection(false, nil)
+		conn.mutex.Unlock()
+		if err != nil {
+			cer, ok := err.(*errorx.Error)
+			if opts.FailFast || opts.ReconnectPause < 0 || conn.gaveUp != nil || ok && cer.HasTrait(ErrTraitInitPermanent) {
+				if conn.resolveq != nil {
+					conn.stopResolvers()
+				}
 				return nil, err
 			}
 		}
@@ -248,6 +593,16 @@ func (conn *Connection) Ping() error {
 	return nil
 }
 
+// DoAsync sends request and returns redis.Promise for its result.
+func (conn *Connection) DoAsync(cmd string, args ...interface{}) *redis.Promise {
+	return redis.DoAsync(conn, cmd, args...)
+}
+
+// Pipeline returns redis.Pipeline over this connection.
+func (conn *Connection) Pipeline() *redis.Pipeline {
+	return redis.NewPipeline(conn)
+}
+
 // dumb redis.Future implementation
 type dumbcb struct{}
 
@@ -256,6 +611,374 @@ func (d dumbcb) Resolve(interface{}, uint64) {}
 
 var dumb dumbcb
 
+// txStart is a future of first request of transaction batch (ASKING, CLIENT CACHING or MULTI).
+// size is a number of futures following it up to EXEC inclusive.
+type txStart struct {
+	dumbcb
+	size int
+}
+
+// keepalive is a future of PING sent by control loop. It is not counted by rate limiter.
+type keepalive chan struct{}
+
+func (k keepalive) Cancelled() error            { return nil }
+func (k keepalive) Resolve(interface{}, uint64) { close(k) }
+
+// desyncCheck is a future of ECHO request appended by writer if Opts.CheckDesync is set.
+type desyncCheck struct {
+	token string
+}
+
+func (d *desyncCheck) Cancelled() error            { return nil }
+func (d *desyncCheck) Resolve(interface{}, uint64) {}
+
+// Role asks server for its replication role (ROLE command).
+func (conn *Connection) Role() (redis.RoleInfo, error) {
+	return redis.RoleResponse(redis.Sync{conn}.Do("ROLE"))
+}
+
+// ClientInfo asks server for description of this connection (CLIENT INFO, redis >= 6.2).
+// It is useful to check name, database and protocol negotiated by handshake.
+func (conn *Connection) ClientInfo() (redis.ClientInfo, error) {
+	return redis.ClientInfoResponse(redis.Sync{conn}.Do("CLIENT INFO"))
+}
+
+// MemoryStats asks server for memory usage report (MEMORY STATS) and decodes it into map
+// (see redis.MemoryStatsResponse).
+func (conn *Connection) MemoryStats() (map[string]interface{}, error) {
+	return redis.MemoryStatsResponse(redis.Sync{conn}.Do("MEMORY", "STATS"))
+}
+
+// MemoryDoctor asks server for human-readable advice about memory usage (MEMORY DOCTOR).
+func (conn *Connection) MemoryDoctor() (string, error) {
+	res := redis.Sync{conn}.Do("MEMORY", "DOCTOR")
+	switch v := res.(type) {
+	case []byte:
+		return string(v), nil
+	case string:
+		return v, nil
+	case error:
+		return "", v
+	}
+	return "", conn.addProps(redis.ErrResponseUnexpected.NewWithNoMessage()).WithProperty(redis.EKResponse, res)
+}
+
+// XInfoStream returns description of stream at key (see redis.XInfoStream).
+func (conn *Connection) XInfoStream(key string) (redis.StreamInfo, error) {
+	return redis.XInfoStream(conn, key)
+}
+
+// XInfoGroups returns consumer groups of stream at key (see redis.XInfoGroups).
+func (conn *Connection) XInfoGroups(key string) ([]redis.GroupInfo, error) {
+	return redis.XInfoGroups(conn, key)
+}
+
+// Del removes keys and returns number of removed keys.
+// It sends UNLINK instead of DEL if Opts.PreferUnlink is set (see redis.UnlinkOrDel).
+func (conn *Connection) Del(keys ...string) (int64, error) {
+	if conn.opts.PreferUnlink {
+		return redis.UnlinkOrDel(conn, keys...)
+	}
+	return redis.Del(conn, keys...)
+}
+
+// Unlink removes keys in background (UNLINK) and returns number of removed keys.
+func (conn *Connection) Unlink(keys ...string) (int64, error) {
+	return redis.Unlink(conn, keys...)
+}
+
+// Touch updates last access time of keys (TOUCH) and returns number of existing keys.
+func (conn *Connection) Touch(keys ...string) (int64, error) {
+	return redis.Touch(conn, keys...)
+}
+
+// Persist removes time to live of key (see redis.Persist).
+// It returns false if key has no time to live or doesn't exist.
+func (conn *Connection) Persist(key string) (bool, error) {
+	return redis.Persist(conn, key)
+}
+
+// SetNX sets key to value only if it doesn't exist, with time to live ttl (see redis.SetNX).
+// Returned bool is true if key were set, ie lock is acquired.
+func (conn *Connection) SetNX(key string, value interface{}, ttl time.Duration) (bool, error) {
+	return redis.SetNX(conn, key, value, ttl)
+}
+
+// ReleaseLock deletes key only if its value is still token (see redis.ReleaseLock).
+func (conn *Connection) ReleaseLock(key string, token interface{}) (bool, error) {
+	return redis.ReleaseLock(conn, key, token)
+}
+
+// SetValue encodes v with Opts.Codec and stores it at key (see redis.SetValue).
+func (conn *Connection) SetValue(key string, v interface{}) error {
+	return redis.SetValue(conn, conn.codec(), key, v)
+}
+
+// GetValue reads value at key and decodes it into dest with Opts.Codec (see redis.GetValue).
+// Returned bool is false if key doesn't exist.
+// Note that DecodeHook should not decode GET responses of such keys, since GetValue expects raw bytes.
+func (conn *Connection) GetValue(key string, dest interface{}) (bool, error) {
+	return redis.GetValue(conn, conn.codec(), key, dest)
+}
+
+func (conn *Connection) codec() redis.Codec {
+	if conn.opts.Codec != nil {
+		return conn.opts.Codec
+	}
+	return redis.JSONCodec{}
+}
+
+// Clone connects to the same address with the same options modified by override (if it is not nil).
+// Currently selected database is used unless override changes Opts.DB.
+// Clone's context is derived from the same parent context as conn's one, so closing conn doesn't
+// close clone. It is useful to derive connection for blocking commands or pubsub.
+// Connection created with ConnectOnConn could not be cloned.
+func (conn *Connection) Clone(override func(*Opts)) (*Connection, error) {
+	if conn.existing != nil {
+		return nil, redis.ErrNoAddressProvided.New("connection created over existing net.Conn could not be cloned")
+	}
+	conn.optsmtx.Lock()
+	opts := conn.origOpts
+	conn.optsmtx.Unlock()
+	opts.DB = conn.DB()
+	if override != nil {
+		override(&opts)
+	}
+	return Connect(conn.parent, conn.addr, opts)
+}
+
+// UpdateOpts changes options of established connection. update is called with copy of options
+// passed to Connect (with DB set to currently selected database), and only following fields
+// are applied, other changes are ignored:
+//   - RateLimit and RateBurst take effect immediately;
+//   - DB is switched immediately with Select, and its error is returned;
+//   - Username, Password, ReadTimeout, WriteTimeout and DialTimeout are used for next socket (ie after reconnect
+//     or rotation with MaxConnLifetime). Use Reset to re-authenticate with new Password immediately.
+//
+// Updated options are inherited by Clone.
+func (conn *Connection) UpdateOpts(update func(*Opts)) error {
+	conn.optsmtx.Lock()
+	opts := conn.origOpts
+	opts.DB = conn.DB()
+	update(&opts)
+	conn.origOpts.Username = opts.Username
+	conn.origOpts.Password = opts.Password
+	conn.origOpts.ReadTimeout = opts.ReadTimeout
+	conn.origOpts.WriteTimeout = opts.WriteTimeout
+	conn.origOpts.DialTimeout = opts.DialTimeout
+	conn.origOpts.RateLimit = opts.RateLimit
+	conn.origOpts.RateBurst = opts.RateBurst
+	conn.normalizeTimeouts(&opts)
+	conn.opts.Username = opts.Username
+	conn.opts.Password = opts.Password
+	conn.opts.ReadTimeout = opts.ReadTimeout
+	conn.opts.WriteTimeout = opts.WriteTimeout
+	conn.opts.DialTimeout = opts.DialTimeout
+	conn.opts.RateLimit = opts.RateLimit
+	conn.opts.RateBurst = opts.RateBurst
+	conn.optsmtx.Unlock()
+
+	conn.futmtx.Lock()
+	if opts.RateLimit > 0 {
+		conn.limiter = conn.limiter.retune(opts.RateLimit, opts.RateBurst)
+	} else {
+		conn.limiter = nil
+	}
+	conn.futmtx.Unlock()
+
+	if opts.DB != conn.DB() {
+		return conn.Select(opts.DB)
+	}
+	return nil
+}
+
+// EffectiveOpts returns copy of options connection actually uses, ie with defaults and limits applied
+// by Connect (and changes made with UpdateOpts), and DB set to currently selected database.
+// Password is redacted.
+func (conn *Connection) EffectiveOpts() Opts {
+	opts := conn.liveOpts()
+	opts.DB = conn.DB()
+	if opts.Password != "" {
+		opts.Password = redactedPassword
+	}
+	return opts
+}
+
+// redactedPassword replaces password in options returned by EffectiveOpts.
+const redactedPassword = "[redacted]"
+
+// liveOpts returns copy of options, which is safe to use concurrently with UpdateOpts.
+func (conn *Connection) liveOpts() Opts {
+	conn.optsmtx.Lock()
+	defer conn.optsmtx.Unlock()
+	return conn.opts
+}
+
+// normalizeTimeouts applies defaults to read, write and dial timeouts.
+// opts.IOTimeout should be normalized already.
+func (conn *Connection) normalizeTimeouts(opts *Opts) {
+	io := conn.opts.IOTimeout
+	// negative ReadTimeout is kept, so EffectiveOpts reports it as disabled rather than as "use IOTimeout".
+	if opts.ReadTimeout == 0 {
+		opts.ReadTimeout = io
+	}
+
+	if opts.WriteTimeout < 0 {
+		opts.WriteTimeout = 0
+	}
+
+	if opts.DialTimeout <= 0 || (io > 0 && opts.DialTimeout > io) {
+		opts.DialTimeout = io
+	}
+}
+
+// Protocol returns RESP version negotiated by last handshake: 3 if HELLO 3 succeeded
+// (see Opts.Protocol and Handshake.Hello), 2 otherwise.
+func (conn *Connection) Protocol() int {
+	if proto := atomic.LoadInt32(&conn.proto); proto != 0 {
+		return int(proto)
+	}
+	return 2
+}
+
+// DB returns number of currently selected database (Opts.DB, or database set with Select).
+func (conn *Connection) DB() int {
+	return int(atomic.LoadInt32(&conn.db))
+}
+
+// Select switches connection to database db (SELECT), and remembers it, so it is selected
+// after reconnect as well. Requests enqueued before Select are executed in previous database.
+func (conn *Connection) Select(db int) error {
+	old := atomic.SwapInt32(&conn.db, int32(db))
+	err := redis.AsError(redis.Sync{conn}.Do("SELECT", db))
+	if rerr := redis.AsErrorx(err); rerr != nil && rerr.IsOfType(redis.ErrResult) {
+		// server refused to select db, so previous one remains selected.
+		atomic.CompareAndSwapInt32(&conn.db, int32(db), old)
+	}
+	return err
+}
+
+// Reset sends RESET command (redis >= 6.2) which returns connection to a clean state
+// (UNWATCH, DISCARD, deselect db, de-auth, RESP2, no tracking and client name), and then replays
+// full handshake: Opts.Handshake if it is set, otherwise HELLO (per Opts.Protocol), AUTH,
+// CLIENT SETNAME and SELECT of current database.
+// Requests already sent are answered first; requests sent during Reset are queued and executed
+// after it, so no other request could be executed in between.
+// On servers without RESET support, UNWATCH is issued instead.
+// If handshake fails (for example, password were changed), error is returned and socket is
+// re-established as after io error.
+func (conn *Connection) Reset() error {
+	conn.mutex.Lock()
+	one := conn.one
+	conn.mutex.Unlock()
+	if one == nil || atomic.LoadUint32(&conn.state) != connConnected {
+		return conn.err(ErrNotConnected)
+	}
+	timeout := conn.drainTimeout()
+	if !conn.drainAndReplace(one, nil, nil, timeout) {
+		err := conn.addProps(redis.ErrIO.New("requests in flight were not answered before reset"))
+		// state of socket is unknown, so new one is established with full handshake.
+		one.setErr(err, conn)
+		return err
+	}
+
+	// Writer and reader are stopped, so RESET and handshake are performed directly on socket.
+	h := &Handshake{conn: conn, c: one.c, w: one.w, r: one.r, timeout: timeout, proto: 2}
+	res := one.rawRequest(redis.Req("RESET"), time.Now().Add(timeout))
+	rerr := redis.AsErrorx(res)
+	var err error
+	switch {
+	case rerr == nil:
+	case redis.IsUnknownCommand(rerr):
+		h.Do(redis.Req("UNWATCH"), nil)
+	case rerr.IsOfType(redis.ErrResult):
+		// RESET is refused (for example, by ACL), so socket state is not changed.
+		err = conn.addProps(rerr)
+	default:
+		err = conn.errWrap(ErrConnSetup, rerr)
+	}
+	broken := err != nil && !rerr.IsOfType(redis.ErrResult)
+	if err == nil {
+		one.c.SetReadDeadline(time.Now().Add(timeout))
+		if err = conn.handshake(conn.ctx, h); err != nil {
+			if _, ok := err.(*errorx.Error); !ok {
+				err = conn.errWrap(ErrInit, err)
+			}
+			broken = true
+		}
+		one.c.SetReadDeadline(time.Time{})
+	}
+
+	conn.mutex.Lock()
+	defer conn.mutex.Unlock()
+	if conn.one != one || atomic.LoadUint32(&conn.state) != connConnected {
+		// socket were replaced or connection were closed meanwhile.
+		if err == nil && atomic.LoadUint32(&conn.state) != connConnected {
+			err = conn.err(ErrNotConnected)
+		}
+		return err
+	}
+	if !broken {
+		atomic.StoreInt32(&conn.proto, int32(h.proto))
+	}
+	conn.start(one.c, one.r)
+	conn.one.created = one.created
+	if broken {
+		conn.one.setErr(err, conn)
+	} else {
+		conn.wakeWriter()
+	}
+	return err
+}
+
+// ForceReconnect closes current socket and establishes new one, as if socket failed with io error.
+// Requests in flight are resolved with redis.ErrIO, and queued requests with ErrDropped.
+// It is a way to recover connection which is suspected to be desynchronized, without losing
+// Connection object and its options. It does nothing if socket is not established at the moment.
+// If reconnection is disabled (ReconnectPause < 0 or ConnectOnConn), connection is closed.
+func (conn *Connection) ForceReconnect() {
+	conn.mutex.Lock()
+	one := conn.one
+	conn.mutex.Unlock()
+	if one == nil {
+		return
+	}
+	one.setErr(redis.ErrIO.New("reconnect forced"), conn)
+}
+
+// WaitAOF waits until preceding writes are fsynced to AOF of numlocal (0 or 1) local servers and
+// numreplicas replicas (see redis.WaitAOF). Zero timeout waits forever.
+// Read timeout is extended by timeout while WAITAOF is in flight. Fewer acknowledgements than
+// requested is not an error.
+func (conn *Connection) WaitAOF(numlocal, numreplicas int, timeout time.Duration) (local, replicas int, err error) {
+	return redis.WaitAOF(conn, numlocal, numreplicas, timeout)
+}
+
+// CancelAll immediately fails all requests: requests in flight are resolved with err (wrapped into
+// redis.ErrIO unless it is *errorx.Error), and queued requests with ErrDropped caused by it
+// (Opts.RequeueDropped is not applied). Since responses to requests in flight will not be read,
+// socket is closed and re-established as with ForceReconnect, but Connection is not closed.
+// Requests sent after CancelAll returns are not affected.
+func (conn *Connection) CancelAll(err error) {
+	rerr, ok := err.(*errorx.Error)
+	if !ok {
+		rerr = conn.errWrap(redis.ErrIO, err)
+	}
+	conn.mutex.Lock()
+	one := conn.one
+	conn.mutex.Unlock()
+	if one != nil {
+		// writer is stopped first, so it will not fetch queued requests we are going to drop.
+		one.setErr(rerr, conn)
+	}
+	if !rerr.HasTrait(redis.ErrTraitNotSent) {
+		rerr = conn.errWrap(ErrDropped, rerr)
+	}
+	conn.futmtx.Lock()
+	defer conn.futmtx.Unlock()
+	conn.dropFutures(conn.addProps(rerr))
+}
+
 // Send implements redis.Sender.Send
 // It sends request asynchronously. At some moment in a future it will call cb.Resolve(result, n)
 // But if cb is cancelled, then cb.Resolve will be called immediately.
@@ -263,6 +986,30 @@ func (conn *Connection) Send(req Request, cb Future, n uint64) {
 	conn.SendAsk(req, cb, n, false)
 }
 
+// SendDiscard sends request ignoring its result: errors (including connection errors) are silently dropped.
+// It is same as Send(req, redis.Discard, 0).
+func (conn *Connection) SendDiscard(req Request) {
+	conn.SendAsk(req, redis.Discard, 0, false)
+}
+
+// SendWithMeta implements redis.MetaSender.SendWithMeta
+// Single connection doesn't know role of server, so only address is reported.
+func (conn *Connection) SendWithMeta(req Request, cb redis.FutureMeta, n uint64) {
+	conn.SendAsk(req, redis.WithMeta(cb, redis.ResponseMeta{Addr: conn.addr}), n, false)
+}
+
+// checkRequest checks request could be serialized and is allowed to be sent,
+// and checks its arity if Opts.ValidateArity is set.
+func (conn *Connection) checkRequest(req Request) error {
+	if err := redis.CheckRequest(req, conn.opts.ScriptMode); err != nil {
+		return err
+	}
+	if conn.opts.ValidateArity {
+		return redis.CheckArity(req, conn.opts.CommandArity)
+	}
+	return nil
+}
+
 // SendAsk is a helper method for redis-cluster client implementation.
 // If asking==true, it will send request with ASKING request sent before.
 func (conn *Connection) SendAsk(req Request, cb Future, n uint64, asking bool) {
@@ -270,7 +1017,7 @@ func (conn *Connection) SendAsk(req Request, cb Future, n uint64, asking bool) {
 		cb = &dumb
 	}
 	if err := conn.doSend(req, cb, n, asking); err != nil {
-		cb.Resolve(err, n)
+		resolveReq(cb, req, err, n)
 	}
 }
 
@@ -279,8 +1026,13 @@ func (conn *Connection) doSend(req Request, cb Future, n uint64, asking bool) *e
 		return conn.err(redis.ErrRequestCancelled)
 	}
 
+	_, isKeepalive := cb.(keepalive)
+	if conn.opts.RequestRewriter != nil && !isKeepalive {
+		req = conn.opts.RequestRewriter(req)
+	}
+
 	// Since we do not pack request here, we need to be sure it could be packed
-	if err := redis.CheckRequest(req, conn.opts.ScriptMode); err != nil {
+	if err := conn.checkRequest(req); err != nil {
 		return conn.addProps(err.(*errorx.Error))
 	}
 
@@ -292,29 +1044,37 @@ func (conn *Connection) doSend(req Request, cb Future, n uint64, asking bool) *e
 	// Note: we do not check for connConnecting, ie we will try to send request after connection established.
 	switch atomic.LoadUint32(&conn.state) {
 	case connClosed:
-		return conn.errWrap(redis.ErrContextClosed, conn.ctx.Err())
+		return conn.closedErr()
 	case connDisconnected:
 		return conn.err(ErrNotConnected)
+	case connClosing:
+		return conn.err(redis.ErrContextClosed)
+	}
+	if atomic.LoadUint32(&conn.refuse) != 0 {
+		return conn.err(redis.ErrContextClosed)
+	}
+	if conn.breaker != nil && !isKeepalive && !conn.breaker.allow(nownano()) {
+		return conn.err(ErrCircuitOpen)
+	}
+	if conn.limiter != nil && !isKeepalive && !conn.limiter.take(1) {
+		return conn.err(ErrRateLimited)
+	}
+	if err := conn.reservePending(conn.requestSize(req), 1); err != nil {
+		return err
 	}
 	futures := conn.futures
 	if asking {
 		// send ASKING request before actual
-		futures = append(futures, future{&dumb, 0, 0, Request{"ASKING", nil}})
+		futures = append(futures, future{&dumb, 0, 0, Request{"ASKING", nil}, 0})
 	}
-	futures = append(futures, future{cb, n, nownano(), req})
+	futures = append(futures, future{cb, n, nownano(), req, 0})
 
 	// should notify writer about this shard having queries.
 	// Since we are under shard lock, it is safe to send notification before assigning futures.
 	if len(conn.futures) == 0 {
-		if conn.opts.WritePause > 0 {
-			conn.futtimer.Reset(conn.opts.WritePause)
-		} else {
-			select {
-			case conn.futsignal <- struct{}{}:
-			default:
-			}
-		}
+		conn.activateQueue()
 	}
+	atomic.AddInt64(&conn.pending, int64(len(futures)-len(conn.futures)))
 	conn.futures = futures
 	return nil
 }
@@ -334,6 +1094,18 @@ func (conn *Connection) SendMany(requests []Request, cb Future, start uint64) {
 	}
 }
 
+// SendManyHint is like SendMany, but also passes approximate total size of serialized requests
+// to writer, so its buffer is allocated once instead of being grown repeatedly.
+// It is useful for huge pipelines (like bulk loads). Hint is advisory: buffer still grows if it is exceeded.
+func (conn *Connection) SendManyHint(requests []Request, cb Future, start uint64, sizeHint int) {
+	conn.futmtx.Lock()
+	if sizeHint > conn.sizeHint {
+		conn.sizeHint = sizeHint
+	}
+	conn.futmtx.Unlock()
+	conn.SendMany(requests, cb, start)
+}
+
 // SendBatch sends several requests in preserved order.
 // They will be serialized to network in the order passed.
 func (conn *Connection) SendBatch(requests []Request, cb Future, start uint64) {
@@ -343,6 +1115,7 @@ func (conn *Connection) SendBatch(requests []Request, cb Future, start uint64) {
 // SendBatchFlags sends several requests in preserved order with addition ASKING, MULTI+EXEC commands.
 // If flag&DoAsking != 0 , then "ASKING" command is prepended.
 // If flag&DoTransaction != 0, then "MULTI" command is prepended, and "EXEC" command appended.
+// If flag&DoCaching != 0, then "CLIENT CACHING YES" command is prepended.
 // Note: cb.Resolve will be also called with start+len(requests) index with result of EXEC command.
 // It is mostly helper method for SendTransaction for single connect and cluster implementations.
 //
@@ -352,9 +1125,17 @@ func (conn *Connection) SendBatchFlags(requests []Request, cb Future, start uint
 	var err *errorx.Error
 	var commonerr *errorx.Error
 	errpos := -1
+	if rewrite := conn.opts.RequestRewriter; rewrite != nil {
+		// requests slice belongs to caller, so it should not be modified.
+		rewritten := make([]Request, len(requests))
+		for i, req := range requests {
+			rewritten[i] = rewrite(req)
+		}
+		requests = rewritten
+	}
 	// check arguments of all commands. If single request is malformed, then all requests will be aborted.
 	for i, req := range requests {
-		if rerr := redis.CheckRequest(req, conn.opts.ScriptMode); rerr != nil {
+		if rerr := conn.checkRequest(req); rerr != nil {
 			err = conn.addProps(rerr.(*errorx.Error))
 			commonerr = conn.errWrap(redis.ErrBatchFormat, err)
 			errpos = i
@@ -371,9 +1152,9 @@ func (conn *Connection) SendBatchFlags(requests []Request, cb Future, start uint
 		commonerr = commonerr.WithProperty(redis.EKRequests, requests)
 		for i := 0; i < len(requests); i++ {
 			if i != errpos {
-				cb.Resolve(commonerr.WithProperty(redis.EKRequest, requests[i]), start+uint64(i))
+				resolveReq(cb, requests[i], commonerr.WithProperty(redis.EKRequest, requests[i]), start+uint64(i))
 			} else {
-				cb.Resolve(err.WithProperty(redis.EKRequests, requests), start+uint64(i))
+				resolveReq(cb, requests[i], err.WithProperty(redis.EKRequests, requests), start+uint64(i))
 			}
 		}
 		if flags&DoTransaction != 0 {
@@ -403,48 +1184,85 @@ func (conn *Connection) doSendBatch(requests []Request, cb Future, start uint64,
 	// Note: we do not check for connConnecting, ie we will try to send request after connection established.
 	switch atomic.LoadUint32(&conn.state) {
 	case connClosed:
-		return conn.errWrap(redis.ErrContextClosed, conn.ctx.Err())
+		return conn.closedErr()
 	case connDisconnected:
 		return conn.err(ErrNotConnected)
+	case connClosing:
+		return conn.err(redis.ErrContextClosed)
+	}
+	if atomic.LoadUint32(&conn.refuse) != 0 {
+		return conn.err(redis.ErrContextClosed)
+	}
+
+	if conn.breaker != nil && !conn.breaker.allow(nownano()) {
+		return conn.err(ErrCircuitOpen)
+	}
+	if conn.limiter != nil && !conn.limiter.take(len(requests)) {
+		return conn.err(ErrRateLimited)
+	}
+
+	size := 0
+	for _, req := range requests {
+		size += conn.requestSize(req)
+	}
+	if err := conn.reservePending(size, len(requests)); err != nil {
+		return err
 	}
 
 	futures := conn.futures
+	first := len(futures)
+	if flags&DoCaching != 0 {
+		// send CLIENT CACHING YES request before actual
+		futures = append(futures, future{&dumb, 0, 0, Request{"CLIENT", []interface{}{"CACHING", "YES"}}, 0})
+	}
 	if flags&DoAsking != 0 {
 		// send ASKING request before actual
-		futures = append(futures, future{&dumb, 0, 0, Request{"ASKING", nil}})
+		futures = append(futures, future{&dumb, 0, 0, Request{"ASKING", nil}, 0})
 	}
 	if flags&DoTransaction != 0 {
 		// send MULTI request for transaction start
-		futures = append(futures, future{&dumb, 0, 0, Request{"MULTI", nil}})
+		futures = append(futures, future{&dumb, 0, 0, Request{"MULTI", nil}, 0})
 	}
 
 	now := nownano()
 
 	for i, req := range requests {
-		futures = append(futures, future{cb, start + uint64(i), now, req})
+		futures = append(futures, future{cb, start + uint64(i), now, req, 0})
 	}
 
 	if flags&DoTransaction != 0 {
 		// send EXEC request for transaction end
-		futures = append(futures, future{cb, start + uint64(len(requests)), now, Request{"EXEC", nil}})
+		futures = append(futures, future{cb, start + uint64(len(requests)), now, Request{"EXEC", nil}, 0})
+		// mark transaction start, so writer could skip it if it is cancelled before written.
+		futures[first].Future = &txStart{size: len(futures) - first - 1}
 	}
 
 	// should notify writer about this shard having queries
 	// Since we are under shard lock, it is safe to send notification before assigning futures.
 	if len(conn.futures) == 0 {
-		if conn.opts.WritePause > 0 {
-			conn.futtimer.Reset(conn.opts.WritePause)
-		} else {
-			select {
-			case conn.futsignal <- struct{}{}:
-			default:
-			}
-		}
+		conn.activateQueue()
 	}
+	atomic.AddInt64(&conn.pending, int64(len(futures)-len(conn.futures)))
 	conn.futures = futures
 	return nil
 }
 
+// activateQueue notifies writer that queue became non-empty.
+// Should be called with futmtx held.
+func (conn *Connection) activateQueue() {
+	if conn.opts.WritePause > 0 {
+		conn.futtimer.Reset(conn.opts.WritePause)
+	} else {
+		select {
+		case conn.futsignal <- struct{}{}:
+		default:
+		}
+	}
+	if conn.opts.OnQueueActivated != nil {
+		conn.opts.OnQueueActivated()
+	}
+}
+
 // wrapped preserves Cancelled method of wrapped future, but redefines Resolve to react only on result of EXEC.
 type transactionFuture struct {
 	Future
@@ -472,16 +1290,131 @@ func (conn *Connection) String() string {
 
 // setup connection to redis
 func (conn *Connection) dial() error {
+	connection, r, err := conn.openConnection(conn.ctx, conn.liveOpts().ReadTimeout)
+	if err != nil {
+		return err
+	}
+
+	conn.start(connection, r)
+	return nil
+}
+
+// start launches writer and reader loops over established connection.
+// Should be called with conn.mutex held.
+func (conn *Connection) start(connection net.Conn, r *bufio.Reader) {
+	conn.c = connection
+	conn.block.reset()
+
+	opts := conn.liveOpts()
+	w := newDeadlineIO(connection, 0, opts.WriteTimeout)
+	if conn.wirelog != nil {
+		w = wireTap{w, conn.wirelog}
+	}
+	one := &oneconn{
+		c: connection,
+		w: w,
+		r: r,
+		// We intentionally limit futures channel capacity:
+		// this way we will force to write some first request eagerly to network,
+		// and pause until first response returns.
+		// During this time, many new request will be buffered, and then we will
+		// be switching to steady state pipelining: new requests will be written
+		// with the same speed responses will arrive.
+		futures:     make(chan []future, 64),
+		control:     make(chan struct{}),
+		futpool:     make(chan []future, 128),
+		drain:       make(chan struct{}),
+		writerDone:  make(chan struct{}),
+		readerDone:  make(chan struct{}),
+		idle:        make(chan struct{}, 1),
+		created:     time.Now(),
+		readTimeout: opts.ReadTimeout,
+	}
+	conn.one = one
+
+	conn.readers.Add(1)
+	go conn.writer(one)
+	go conn.reader(r, one)
+}
+
+// openConnection dials to redis and performs handshake (AUTH, PING and SELECT, or Opts.Handshake).
+// readTimeout is used for all reads from returned reader, and it is disabled if readTimeout <= 0.
+func (conn *Connection) openConnection(ctx context.Context, readTimeout time.Duration) (net.Conn, *bufio.Reader, error) {
 	var connection net.Conn
 	var err error
 
-	// detect network and actual address
-	network := "tcp"
-	address := conn.addr
-	timeout := conn.opts.DialTimeout
+	timeout := conn.liveOpts().DialTimeout
 	if timeout <= 0 || timeout > 5*time.Second {
 		timeout = 5 * time.Second
 	}
+	if conn.existing != nil {
+		// connection created with ConnectOnConn could not dial
+		if !atomic.CompareAndSwapUint32(&conn.existingTaken, 0, 1) {
+			return nil, nil, conn.addProps(ErrDial.New("existing connection is already used"))
+		}
+		connection = conn.existing
+	} else if connection, err = conn.dialSocket(ctx, timeout); err != nil {
+		return nil, nil, err
+	}
+
+	dc := newDeadlineIO(connection, readTimeout, 0)
+	if d, ok := dc.(*deadlineIO); ok {
+		// timeout of blocking commands extends read deadline.
+		d.block = &conn.block
+	}
+	if conn.wirelog != nil {
+		dc = wireTap{dc, conn.wirelog}
+	}
+	r := bufio.NewReaderSize(dc, 128*1024)
+	// Handshake should not wait forever even if io timeouts are disabled
+	// (or if it is streaming connection without read timeout).
+	hsTimeout := conn.opts.IOTimeout
+	if hsTimeout <= 0 {
+		hsTimeout = timeout
+	}
+	if readTimeout <= 0 {
+		connection.SetReadDeadline(time.Now().Add(hsTimeout))
+	}
+
+	h := &Handshake{conn: conn, c: connection, w: dc, r: r, timeout: hsTimeout, proto: 2}
+	if err = conn.handshake(ctx, h); err != nil {
+		connection.Close()
+		if _, ok := err.(*errorx.Error); !ok {
+			err = conn.errWrap(ErrInit, err)
+		}
+		if conn.opts.TLSConfig != nil && conn.existing == nil && tlsVerifyFailed(err) {
+			// with TLS 1.3 server rejects client certificate after client considers handshake complete.
+			err = conn.errWrap(ErrTLSVerify, err)
+		}
+		return nil, nil, err
+	}
+	atomic.StoreInt32(&conn.proto, int32(h.proto))
+
+	if readTimeout <= 0 {
+		// Disarm handshake timeout
+		connection.SetReadDeadline(time.Time{})
+	}
+
+	return connection, r, nil
+}
+
+// handshake performs Opts.Handshake or default handshake, and flushes requests left queued.
+func (conn *Connection) handshake(ctx context.Context, h *Handshake) error {
+	if conn.opts.Handshake != nil {
+		if err := conn.opts.Handshake(ctx, h); err != nil {
+			return err
+		}
+	} else {
+		h.Default()
+	}
+	return h.Flush()
+}
+
+// dialSocket dials to conn.addr.
+func (conn *Connection) dialSocket(ctx context.Context, timeout time.Duration) (net.Conn, error) {
+	// detect network and actual address
+	network := "tcp"
+	address := conn.addr
 	if address[0] == '.' || address[0] == '/' {
 		network = "unix"
 	} else if address[0:7] == "unix://" {
@@ -499,100 +1432,14 @@ func (conn *Connection) dial() error {
 		FallbackDelay: timeout / 2,
 		KeepAlive:     conn.opts.TCPKeepAlive,
 	}
-	connection, err = dialer.DialContext(conn.ctx, network, address)
+	connection, err := dialer.DialContext(ctx, network, address)
 	if err != nil {
-		return conn.errWrap(ErrDial, err)
+		return nil, conn.errWrap(ErrDial, err)
 	}
-
-	dc := newDeadlineIO(connection, conn.opts.IOTimeout)
-	r := bufio.NewReaderSize(dc, 128*1024)
-
-	// Password request
-	var req []byte
-	if conn.opts.Password != "" {
-		req, _ = redis.AppendRequest(req, redis.Req("AUTH", conn.opts.Password))
-	}
-	const pingReq = "*1\r\n$4\r\nPING\r\n"
-	// Ping request
-	req = append(req, pingReq...)
-	// Select request
-	if conn.opts.DB != 0 {
-		req, _ = redis.AppendRequest(req, redis.Req("SELECT", conn.opts.DB))
-	}
-	// Force timeout
-	if conn.opts.IOTimeout > 0 {
-		connection.SetWriteDeadline(time.Now().Add(conn.opts.IOTimeout))
-	}
-	if _, err = dc.Write(req); err != nil {
-		connection.Close()
-		return conn.errWrap(ErrConnSetup, err)
+	if conn.opts.TLSConfig != nil {
+		return conn.tlsHandshake(ctx, connection, conn.opts.TLSConfig, timeout)
 	}
-	// Disarm timeout
-	connection.SetWriteDeadline(time.Time{})
-
-	var res interface{}
-	// Password response
-	if conn.opts.Password != "" {
-		res = redis.ReadResponse(r)
-		if err := redis.AsErrorx(res); err != nil {
-			connection.Close()
-			if !err.IsOfType(redis.ErrIO) {
-				return conn.errWrap(ErrAuth, err)
-			}
-			return conn.errWrap(ErrConnSetup, err)
-		}
-	}
-	// PING Response
-	res = redis.ReadResponse(r)
-	if err := redis.AsErrorx(res); err != nil {
-		connection.Close()
-		if !err.IsOfType(redis.ErrIO) {
-			return conn.errWrap(ErrInit, err)
-		}
-		return conn.errWrap(ErrConnSetup, err)
-	}
-	if str, ok := res.(string); !ok || str != "PONG" {
-		connection.Close()
-		return conn.addProps(ErrInit.New("ping response mismatch")).
-			WithProperty(redis.EKResponse, res)
-	}
-	// SELECT DB Response
-	if conn.opts.DB != 0 {
-		res = redis.ReadResponse(r)
-		if err := redis.AsErrorx(res); err != nil {
-			connection.Close()
-			if !err.IsOfType(redis.ErrIO) {
-				return conn.errWrap(ErrInit, err)
-			}
-			return conn.errWrap(ErrConnSetup, err)
-		}
-		if str, ok := res.(string); !ok || str != "OK" {
-			connection.Close()
-			return ErrInit.New("SELECT db response mismatch").
-				WithProperty(EKDb, conn.opts.DB).
-				WithProperty(redis.EKResponse, res)
-		}
-	}
-
-	conn.c = connection
-
-	one := &oneconn{
-		c: connection,
-		// We intentionally limit futures channel capacity:
-		// this way we will force to write some first request eagerly to network,
-		// and pause until first response returns.
-		// During this time, many new request will be buffered, and then we will
-		// be switching to steady state pipelining: new requests will be written
-		// with the same speed responses will arrive.
-		futures: make(chan []future, 64),
-		control: make(chan struct{}),
-		futpool: make(chan []future, 128),
-	}
-
-	go conn.writer(one)
-	go conn.reader(r, one)
-
-	return nil
+	return connection, nil
 }
 
 func (conn *Connection) createConnection(reconnect bool, wg *sync.WaitGroup) error {
@@ -606,8 +1453,15 @@ func (conn *Connection) createConnection(reconnect bool, wg *sync.WaitGroup) err
 			wg.Done()
 			wg = nil
 		}
+		stopExpire := conn.expireQueuedWhileConnecting()
 		err = conn.dial()
+		stopExpire()
 		if err == nil {
+			if conn.prevAddr != "" && conn.opts.OnReconnect != nil {
+				conn.opts.OnReconnect(conn.dialFailures+1, conn.prevAddr, conn.c.RemoteAddr().String(), conn.prevErr)
+			}
+			conn.prevAddr, conn.prevErr = "", nil
+			conn.dialFailures = 0
 			atomic.StoreUint32(&conn.state, connConnected)
 			conn.report(LogConnected{
 				LocalAddr:  conn.c.LocalAddr().String(),
@@ -617,6 +1471,7 @@ func (conn *Connection) createConnection(reconnect bool, wg *sync.WaitGroup) err
 		}
 
 		conn.report(LogConnectFailed{Error: err})
+		conn.breakerFailure(err)
 		// stop accepting request
 		atomic.StoreUint32(&conn.state, connDisconnected)
 		// revoke accumulated requests
@@ -624,13 +1479,28 @@ func (conn *Connection) createConnection(reconnect bool, wg *sync.WaitGroup) err
 		conn.dropFutures(err)
 		conn.futmtx.Unlock()
 
+		conn.dialFailures++
+		if max := conn.opts.MaxReconnectAttempts; max > 0 && conn.dialFailures >= max {
+			// give up: connection will be closed by control loop
+			conn.gaveUp = conn.errWrap(ErrReconnectLimit, err).WithProperty(EKAttempts, conn.dialFailures)
+			conn.cancel()
+			return conn.gaveUp
+		}
+
 		// If you doesn't use reconnection, quit
 		if !reconnect {
 			return err
 		}
 		conn.mutex.Unlock()
-		// do not spend CPU on useless attempts
-		time.Sleep(now.Add(conn.opts.ReconnectPause).Sub(time.Now()))
+		// do not spend CPU on useless attempts, but don't delay Close.
+		pause := time.NewTimer(now.Add(conn.opts.ReconnectPause).Sub(time.Now()))
+		select {
+		case <-pause.C:
+		case <-conn.ctx.Done():
+			pause.Stop()
+			conn.mutex.Lock()
+			return conn.closedErr()
+		}
 		conn.mutex.Lock()
 	}
 	if wg != nil {
@@ -642,6 +1512,15 @@ func (conn *Connection) createConnection(reconnect bool, wg *sync.WaitGroup) err
 	return err
 }
 
+// closedErr returns error for requests to closed connection.
+func (conn *Connection) closedErr() *errorx.Error {
+	// gaveUp is set before context is cancelled, and state is set to connClosed after that.
+	if conn.gaveUp != nil {
+		return conn.gaveUp
+	}
+	return conn.errWrap(redis.ErrContextClosed, conn.ctx.Err())
+}
+
 // dropFutures revokes all accumulated requests
 // Should be called with all shards locked.
 func (conn *Connection) dropFutures(err error) {
@@ -660,6 +1539,68 @@ func (conn *Connection) dropFutures(err error) {
 		conn.resolve(fut, err)
 	}
 	conn.futures = nil
+	conn.futbytes = 0
+}
+
+// requeueFutures keeps queued read-only requests for next socket (see Opts.RequeueDropped),
+// and revokes all other requests with err.
+// Should be called with futmtx held.
+func (conn *Connection) requeueFutures(err error) {
+	var kept, dropped []future
+	prefixed := false
+	for i := 0; i < len(conn.futures); i++ {
+		fut := conn.futures[i]
+		if tx, ok := fut.Future.(*txStart); ok {
+			// transaction is dropped as whole
+			dropped = append(dropped, conn.futures[i:i+tx.size+1]...)
+			i += tx.size
+			continue
+		}
+		if fut.Future == Future(&dumb) {
+			// ASKING or CLIENT CACHING affects following request, so it is dropped as well.
+			dropped = append(dropped, fut)
+			prefixed = true
+			continue
+		}
+		if prefixed || fut.requeued >= conn.opts.RequeueDropped || !redis.ReplicaSafe(fut.req.Cmd) ||
+			fut.Cancelled() != nil {
+			dropped = append(dropped, fut)
+		} else {
+			fut.requeued++
+			kept = append(kept, fut)
+		}
+		prefixed = false
+	}
+	conn.futures = dropped
+	conn.dropFutures(err)
+	if len(kept) == 0 {
+		return
+	}
+	conn.futures = kept
+	for _, fut := range kept {
+		conn.futbytes += conn.requestSize(fut.req)
+	}
+	// wake up writer of next socket
+	select {
+	case conn.futsignal <- struct{}{}:
+	default:
+	}
+}
+
+// reservePending accounts size and number of enqueued requests against MaxPendingBytes
+// and MaxQueuedRequests.
+// Should be called with futmtx held.
+func (conn *Connection) reservePending(size, count int) *errorx.Error {
+	if conn.opts.MaxPendingBytes > 0 && len(conn.futures) > 0 &&
+		conn.futbytes+size > conn.opts.MaxPendingBytes {
+		return conn.err(ErrBufferFull).WithProperty(EKPendingBytes, conn.futbytes)
+	}
+	if conn.opts.MaxQueuedRequests > 0 && len(conn.futures) > 0 &&
+		len(conn.futures)+count > conn.opts.MaxQueuedRequests {
+		return conn.err(ErrBufferFull).WithProperty(EKQueuedRequests, len(conn.futures))
+	}
+	conn.futbytes += size
+	return nil
 }
 
 func (conn *Connection) closeConnection(neterr *errorx.Error, forever bool) {
@@ -673,11 +1614,13 @@ func (conn *Connection) closeConnection(neterr *errorx.Error, forever bool) {
 			LocalAddr:  conn.c.LocalAddr().String(),
 			RemoteAddr: conn.c.RemoteAddr().String(),
 		})
+		conn.prevAddr, conn.prevErr = conn.c.RemoteAddr().String(), neterr
 	}
 
 	if conn.c != nil {
 		conn.c.Close()
 		conn.c = nil
+		conn.one = nil
 	}
 
 	conn.futmtx.Lock()
@@ -688,31 +1631,133 @@ func (conn *Connection) closeConnection(neterr *errorx.Error, forever bool) {
 		close(conn.futsignal)
 	}
 
-	conn.dropFutures(neterr)
+	if !neterr.HasTrait(redis.ErrTraitNotSent) {
+		// queued requests were not written to socket, so they are definitely not sent
+		// (unlike requests in flight, which are resolved with neterr itself).
+		neterr = conn.errWrap(ErrDropped, neterr)
+	}
+	if conn.opts.RequeueDropped > 0 && !forever {
+		conn.requeueFutures(neterr)
+	} else {
+		conn.dropFutures(neterr)
+	}
 }
 
-func (conn *Connection) control() {
-	timeout := conn.opts.IOTimeout / 3
+// pingInterval returns interval of keepalive PING: third of least of IOTimeout and ReadTimeout,
+// so read deadline of idle socket is not exceeded.
+func (conn *Connection) pingInterval() time.Duration {
+	timeout := conn.opts.IOTimeout
+	if rto := conn.liveOpts().ReadTimeout; rto > 0 && (timeout <= 0 || rto < timeout) {
+		timeout = rto
+	}
+	timeout /= 3
 	if timeout <= 0 {
 		timeout = time.Second
 	}
-	t := time.NewTicker(timeout)
+	return timeout
+}
+
+func (conn *Connection) control() {
+	timeout := conn.pingInterval()
+	t := time.NewTimer(timeout)
 	defer t.Stop()
 	for {
 		select {
 		case <-conn.ctx.Done():
 			conn.mutex.Lock()
 			defer conn.mutex.Unlock()
-			closeErr := conn.errWrap(redis.ErrContextClosed, conn.ctx.Err())
-			conn.closeConnection(closeErr, true)
+			conn.closeConnection(conn.closedErr(), true)
+			if conn.resolveq != nil {
+				conn.stopResolvers()
+			}
+			conn.wakeIdle(true)
 			return
 		case <-t.C:
 		}
-		// send PING at least 3 times per IO timeout, therefore read deadline will not be exceeded
-		if err := conn.Ping(); err != nil {
-			// I really don't know what to do here :-(
+		if conn.opts.MaxConnLifetime > 0 {
+			conn.rotateIfExpired()
+		}
+		// send PING at least 3 times per read timeout, therefore read deadline will not be exceeded
+		ping := make(keepalive)
+		conn.Send(redis.Req("PING"), ping, 0)
+		conn.waitKeepalive(ping, 3*timeout)
+		if conn.opts.HealthCheck != nil {
+			conn.healthCheck(timeout)
+		}
+		// ReadTimeout could be changed with UpdateOpts.
+		timeout = conn.pingInterval()
+		t.Reset(timeout)
+	}
+}
+
+// waitKeepalive waits for response to keepalive PING.
+// If socket has no read deadline (IOTimeout or ReadTimeout is disabled), read from half-open socket
+// never fails, so socket is re-established if PING is not answered in limit
+// (unless blocking requests are in flight, since they legitimately delay PING).
+func (conn *Connection) waitKeepalive(ping keepalive, limit time.Duration) {
+	conn.mutex.Lock()
+	one := conn.one
+	conn.mutex.Unlock()
+	if one == nil || one.readTimeout > 0 {
+		<-ping
+		return
+	}
+	t := time.NewTimer(limit)
+	defer t.Stop()
+	select {
+	case <-ping:
+		return
+	case <-t.C:
+	}
+	if conn.block.idle() {
+		one.setErr(redis.ErrIO.New("keepalive ping timeout"), conn)
+	}
+	<-ping
+}
+
+// healthCheck calls Opts.HealthCheck. Socket is re-established if check fails with connectivity error.
+func (conn *Connection) healthCheck(timeout time.Duration) {
+	conn.mutex.Lock()
+	one := conn.one
+	conn.mutex.Unlock()
+	if one == nil || atomic.LoadUint32(&conn.state) != connConnected {
+		return
+	}
+	ctx, cancel := context.WithTimeout(conn.ctx, timeout)
+	err := conn.opts.HealthCheck(ctx, conn)
+	cancel()
+	if err == nil {
+		return
+	}
+	if xerr := redis.AsErrorxChain(err); xerr != nil && xerr.HasTrait(redis.ErrTraitConnectivity) {
+		one.setErr(xerr, conn)
+		return
+	}
+	conn.report(LogHealthCheckFailed{Error: err})
+}
+
+// skipCancelledTransactions removes transactions whose future is cancelled from batch,
+// and resolves them with ErrRequestCancelled. Transaction is checked as whole,
+// so nothing (not even MULTI) is written to socket.
+func (conn *Connection) skipCancelledTransactions(futures []future) []future {
+	j := 0
+	for i := 0; i < len(futures); i++ {
+		tx, ok := futures[i].Future.(*txStart)
+		if !ok || futures[i+tx.size].Cancelled() == nil {
+			futures[j] = futures[i]
+			j++
+			continue
+		}
+		err := conn.err(redis.ErrRequestCancelled)
+		for _, fut := range futures[i : i+tx.size+1] {
+			conn.resolve(fut, err.WithProperty(redis.EKRequest, fut.req))
 		}
+		i += tx.size
 	}
+	for i := j; i < len(futures); i++ {
+		futures[i] = future{}
+	}
+	return futures[:j]
 }
 
 // setErr is called by either read or write loop in case of error
@@ -735,7 +1780,7 @@ func (one *oneconn) setErr(neterr error, conn *Connection) {
 func (conn *Connection) reconnect(neterr *errorx.Error, c net.Conn) {
 	conn.mutex.Lock()
 	defer conn.mutex.Unlock()
-	if atomic.LoadUint32(&conn.state) == connClosed {
+	if state := atomic.LoadUint32(&conn.state); state == connClosed || state == connClosing {
 		return
 	}
 	if conn.opts.ReconnectPause < 0 {
@@ -744,6 +1789,15 @@ func (conn *Connection) reconnect(neterr *errorx.Error, c net.Conn) {
 	}
 	if conn.c == c {
 		conn.closeConnection(neterr, false)
+		conn.breakerFailure(neterr)
+		if pause := conn.opts.ServerClosedPause; pause > 0 && neterr.IsOfType(ErrServerClosed) {
+			conn.mutex.Unlock()
+			select {
+			case <-conn.ctx.Done():
+			case <-time.After(pause):
+			}
+			conn.mutex.Lock()
+		}
 		conn.createConnection(true, nil)
 	}
 }
@@ -753,8 +1807,11 @@ func (conn *Connection) reconnect(neterr *errorx.Error, c net.Conn) {
 // It is root of good pipelined performance: trade latency for throughtput.
 func (conn *Connection) writer(one *oneconn) {
 	var packet []byte
+	var lastSize int
 	var futures []future
 	var ok bool
+	var draining bool
+	var seq uint64
 
 	defer func() {
 		// on method exit send last futures to read loop.
@@ -764,6 +1821,7 @@ func (conn *Connection) writer(one *oneconn) {
 		}
 		// And inform read loop that our reader-writer pair is dying.
 		close(one.futures)
+		close(one.writerDone)
 	}()
 
 	round := 1023
@@ -779,22 +1837,56 @@ func (conn *Connection) writer(one *oneconn) {
 		case <-one.control:
 			// this reader-writer pair is obsolete
 			return
+		case <-one.drain:
+			// flush already queued requests and stop
+			draining = true
 		}
 
 		conn.futmtx.Lock()
 		// fetch requests from shard, and replace it with empty buffer with non-zero capacity
 		futures, conn.futures = conn.futures, futures
+		conn.futbytes = 0
+		sizeHint := conn.sizeHint
+		conn.sizeHint = 0
 		conn.futmtx.Unlock()
 
 		if len(futures) == 0 {
+			if draining {
+				return
+			}
 			// There are multiple ways to come here, and most of them are through dropFutures.
 			// Lets just ignore them.
 			continue
 		}
 
+		futures = conn.skipCancelledTransactions(futures)
+		if len(futures) == 0 {
+			continue
+		}
+
+		if conn.opts.CheckDesync {
+			seq++
+			token := "redispipe:" + strconv.FormatUint(seq, 10)
+			futures = append(futures, future{&desyncCheck{token}, 0, 0, Request{"ECHO", []interface{}{token}}, 0})
+			atomic.AddInt64(&conn.pending, 1)
+		}
+
 		// serialize requests
+		pool := conn.opts.BufferPool
+		if pool != nil {
+			if sizeHint > lastSize {
+				lastSize = sizeHint
+			}
+			packet = pool.Get(lastSize)
+		} else if sizeHint > cap(packet) {
+			packet = make([]byte, 0, sizeHint)
+		}
+		blocking := false
 		for _, fut := range futures {
 			var err error
+			if conn.mayBlock(fut.req) && conn.block.written(fut.req) {
+				blocking = true
+			}
 			if packet, err = redis.AppendRequest(packet, fut.req); err != nil {
 				// since we checked arguments in doSend and doSendBatch, error here is a signal of programmer error.
 				// lets just panic and die.
@@ -802,59 +1894,105 @@ func (conn *Connection) writer(one *oneconn) {
 			}
 		}
 
-		if _, err := one.c.Write(packet); err != nil {
+		if blocking && one.readTimeout > 0 {
+			// reader could already wait for response with short deadline, so it should be extended.
+			rto := one.readTimeout
+			one.c.SetReadDeadline(conn.block.deadline(time.Now().Add(rto), rto))
+		}
+		if _, err := one.w.Write(packet); err != nil {
 			one.setErr(err, conn)
 			return
 		}
 
-		// every 1023 writes check our buffer.
-		// If it is too large, then lets GC to free it.
-		if round--; round == 0 {
-			round = 1023
-			if cap(packet) > 128*1024 {
-				packet = nil
+		if pool != nil {
+			// socket doesn't retain written buffer, so it could be reused by other connection.
+			lastSize = len(packet)
+			pool.Put(packet)
+			packet = nil
+		} else {
+			// every 1023 writes check our buffer.
+			// If it is too large, then lets GC to free it.
+			if round--; round == 0 {
+				round = 1023
+				if cap(packet) > 128*1024 {
+					packet = nil
+				}
 			}
+			// otherwise, reuse buffer
+			packet = packet[:0]
 		}
-		// otherwise, reuse buffer
-		packet = packet[:0]
 
+		atomic.AddInt64(&one.inflight, int64(len(futures)))
 		one.futures <- futures
+		if draining {
+			futures = nil
+			return
+		}
 
 		select {
 		// reuse request buffer
 		case futures = <-one.futpool:
 		default:
-			// or allocate new one
-			futures = make([]future, 0, len(futures)*2)
+			// or allocate new one (it grows on demand, so huge batch doesn't need huge initial capacity)
+			size := len(futures) * 2
+			if size > maxPooledFutures {
+				size = maxPooledFutures
+			}
+			futures = make([]future, 0, size)
 		}
 	}
 }
 
 func (conn *Connection) reader(r *bufio.Reader, one *oneconn) {
+	defer conn.readers.Done()
+	defer close(one.readerDone)
 	var futures []future
 	var i int
 	var res interface{}
+	var attrs []interface{}
 	var ok bool
 
 	for {
-		// try to read response from buffered socket.
-		// Here is IOTimeout handled as well (through deadlineIO wrapper around socket).
-		res = redis.ReadResponse(r)
-		if rerr := redis.AsErrorx(res); rerr != nil {
-			if !rerr.IsOfType(redis.ErrResult) {
-				// it is not redis-sended error, then close connection
-				// (most probably, it is already closed. But also it could be timeout).
+		// wait for response in buffered socket.
+		// Here is ReadTimeout handled as well (through deadlineIO wrapper around socket).
+		head, err := r.Peek(1)
+		if err == io.EOF {
+			// server closed socket between responses (CLIENT KILL, timeout, shutdown).
+			one.setErr(conn.errWrap(ErrServerClosed, err), conn)
+			break
+		} else if err != nil {
+			one.setErr(conn.errWrap(redis.ErrIO, err), conn)
+			break
+		}
+		respType := head[0]
+		if respType == '>' {
+			// RESP3 push frame is not an answer to request, so it is skipped.
+			if rerr := redis.AsErrorx(redis.ReadResponse(r)); rerr != nil && !rerr.IsOfType(redis.ErrResult) {
 				one.setErr(rerr, conn)
 				break
 			}
+			continue
+		}
+		if respType == '|' {
+			// RESP3 attributes precede answer: keep them for its future
+			// and look at type of answer itself.
+			more, rerr := redis.ReadAttributes(r)
+			if rerr != nil {
+				one.setErr(rerr, conn)
+				break
+			}
+			attrs = append(attrs, more...)
+			continue
 		}
 		if i == len(futures) {
 			// this batch of requests exhausted,
 			// lets recycle it
 			i = 0
-			select {
-			case one.futpool <- futures[:0]:
-			default:
+			if cap(futures) <= maxPooledFutures {
+				select {
+				case one.futpool <- futures[:0]:
+				default:
+				}
 			}
 			// and fetch next one.
 			futures, ok = <-one.futures
@@ -862,6 +2000,32 @@ func (conn *Connection) reader(r *bufio.Reader, one *oneconn) {
 				break
 			}
 		}
+		// read response, streaming bulk string into sink or limiting its size if request wants it.
+		if sf, ok := futures[i].Future.(redis.StreamingFuture); ok {
+			res = redis.ReadResponseTo(r, sf.Sink())
+		} else if lf, ok := futures[i].Future.(redis.LimitedFuture); ok {
+			res = redis.ReadResponseLimit(r, lf.MaxResponseBytes())
+		} else {
+			res = redis.ReadResponse(r)
+		}
+		if rerr := redis.AsErrorx(res); rerr != nil {
+			if !rerr.IsOfType(redis.ErrResult) && !rerr.IsOfType(redis.ErrSinkWrite) {
+				// it is not redis-sended error, then close connection
+				// (most probably, it is already closed. But also it could be timeout).
+				one.setErr(rerr, conn)
+				break
+			}
+		}
+		if conn.breaker != nil {
+			conn.breaker.success()
+		}
+		if dc, ok := futures[i].Future.(*desyncCheck); ok {
+			if b, ok := res.([]byte); !ok || string(b) != dc.token {
+				one.setErr(conn.addProps(redis.ErrDesync.New("unexpected response to desync check")).
+					WithProperty(redis.EKResponse, res), conn)
+				break
+			}
+		}
 		// fetch request corresponding to answer
 		fut := futures[i]
 		futures[i] = future{}
@@ -870,7 +2034,25 @@ func (conn *Connection) reader(r *bufio.Reader, one *oneconn) {
 		if rerr := redis.AsErrorx(res); rerr != nil {
 			res = conn.addProps(rerr).WithProperty(redis.EKRequest, fut.req)
 		}
-		conn.resolve(fut, res)
+		if conn.opts.PausedWriteThreshold > 0 && fut.start != 0 {
+			conn.detectWritePause(fut)
+		}
+		if conn.mayBlock(fut.req) {
+			conn.block.answered(fut.req)
+		}
+		if attrs != nil {
+			if af, ok := fut.Future.(redis.AttributesFuture); ok {
+				af.ResolveAttributes(attrs, fut.N)
+			}
+			attrs = nil
+		}
+		conn.dispatchResolve(fut, res, respType)
+		if atomic.AddInt64(&one.inflight, -1) == 0 {
+			select {
+			case one.idle <- struct{}{}:
+			default:
+			}
+		}
 	}
 
 	// oops, connection is broken.
@@ -1,11 +1,18 @@
 package redisconn_test
 
 import (
+	"bufio"
+	"bytes"
 	"context"
 	"errors"
+	"fmt"
+	"io"
+	"net"
 	"runtime"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -13,6 +20,7 @@ import (
 
 	"github.com/joomcode/redispipe/redis"
 	. "github.com/joomcode/redispipe/redisconn"
+	"github.com/joomcode/redispipe/redisdumb"
 	"github.com/joomcode/redispipe/testbed"
 	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
@@ -60,325 +68,3257 @@ var defopts = Opts{
 	IOTimeout: 10 * time.Millisecond,
 }
 
+func TestConnectHandshakeDeadline(t *testing.T) {
+	// server accepts connection but never answers
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer ln.Close()
+	go func() {
+		var conns []net.Conn
+		defer func() {
+			for _, c := range conns {
+				c.Close()
+			}
+		}()
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			conns = append(conns, c)
+		}
+	}()
+
+	opts := Opts{
+		IOTimeout:      -1,
+		DialTimeout:    100 * time.Millisecond,
+		ReconnectPause: -1,
+		Logger:         NoopLogger{},
+	}
+	start := time.Now()
+	_, err = Connect(context.Background(), ln.Addr().String(), opts)
+	require.Error(t, err)
+	require.True(t, errorx.IsOfType(err, ErrConnSetup))
+	require.WithinDuration(t, start, time.Now(), time.Second)
+}
+
+// fakeServer is a tiny server which answers PING, GET and QUIT.
+func fakeServer(server net.Conn) {
+	defer server.Close()
+	// net.Pipe is unbuffered, so whole pipelined batch should be read before answering.
+	r := bufio.NewReaderSize(server, 1<<20)
+	for {
+		req, ok := redis.ReadResponse(r).([]interface{})
+		if !ok {
+			return
+		}
+		switch string(req[0].([]byte)) {
+		case "PING":
+			server.Write([]byte("+PONG\r\n"))
+		case "GET":
+			server.Write([]byte("$3\r\nbar\r\n"))
+		case "QUIT":
+			server.Write([]byte("+OK\r\n"))
+			return
+		default:
+			server.Write([]byte("-ERR unknown command\r\n"))
+		}
+	}
+}
+
+func TestRequestRewriter(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer ln.Close()
+	received := make(chan string, 8)
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func(c net.Conn) {
+				defer c.Close()
+				r := bufio.NewReader(c)
+				for {
+					req, ok := redis.ReadResponse(r).([]interface{})
+					if !ok {
+						return
+					}
+					if string(req[0].([]byte)) == "PING" {
+						c.Write([]byte("+PONG\r\n"))
+						continue
+					}
+					received <- string(req[1].([]byte))
+					c.Write([]byte("$3\r\nbar\r\n"))
+				}
+			}(c)
+		}
+	}()
+
+	conn, err := Connect(context.Background(), ln.Addr().String(), Opts{
+		Logger: NoopLogger{},
+		RequestRewriter: func(req Request) Request {
+			if req.Cmd == "FOO" {
+				// rewritten request is checked before sending
+				return Request{"GET", []interface{}{struct{}{}}}
+			}
+			return redis.MapKeys(req, func(key string) string { return "t1:" + key })
+		},
+	})
+	require.NoError(t, err)
+	defer conn.Close()
+
+	sync := redis.Sync{conn}
+	require.Equal(t, []byte("bar"), sync.Do("GET", "a"))
+	require.Equal(t, "t1:a", <-received)
+
+	reqs := []Request{redis.Req("GET", "b"), redis.Req("GET", "c")}
+	require.Equal(t, []interface{}{[]byte("bar"), []byte("bar")}, sync.SendMany(reqs))
+	require.Equal(t, "t1:b", <-received)
+	require.Equal(t, "t1:c", <-received)
+	// requests of caller are not modified
+	require.Equal(t, []Request{redis.Req("GET", "b"), redis.Req("GET", "c")}, reqs)
+
+	err = redis.AsError(sync.Do("FOO"))
+	require.Error(t, err)
+	require.True(t, err.(*errorx.Error).IsOfType(redis.ErrArgumentType), err.Error())
+}
+
+func TestRequestFuture(t *testing.T) {
+	client, server := net.Pipe()
+	go fakeServer(server)
+	conn, err := ConnectOnConn(context.Background(), client, Opts{Logger: NoopLogger{}})
+	require.NoError(t, err)
+	defer conn.Close()
+
+	reqs := []Request{
+		redis.Req("GET", "a"),
+		redis.Req("FOO", "b"),
+		redis.Req("GET", struct{}{}),
+	}
+	got := make([]Request, len(reqs))
+	ress := make([]interface{}, len(reqs))
+	var wg sync.WaitGroup
+	wg.Add(len(reqs))
+	cb := redis.FuncFutureReq(func(req Request, res interface{}, n uint64) {
+		got[n], ress[n] = req, res
+		wg.Done()
+	})
+	for i, req := range reqs {
+		conn.Send(req, cb, uint64(i))
+	}
+	wg.Wait()
+	require.Equal(t, reqs, got)
+	require.Equal(t, []byte("bar"), ress[0])
+	require.True(t, redis.AsErrorx(ress[1]).IsOfType(redis.ErrResult))
+	require.True(t, redis.AsErrorx(ress[2]).IsOfType(redis.ErrArgumentType))
+
+	// requests of batch are reported as well
+	wg.Add(2)
+	conn.SendBatch(reqs[:2], cb, 0)
+	wg.Wait()
+	require.Equal(t, reqs[:2], got[:2])
+}
+
+func TestConnectDoneContext(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer ln.Close()
+	accepted := make(chan struct{}, 1)
+	go func() {
+		if c, err := ln.Accept(); err == nil {
+			accepted <- struct{}{}
+			c.Close()
+		}
+	}()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	before := runtime.NumGoroutine()
+	for _, async := range []bool{false, true} {
+		conn, err := Connect(ctx, ln.Addr().String(), Opts{Logger: NoopLogger{}, AsyncDial: async})
+		require.Nil(t, conn)
+		require.Error(t, err)
+		require.True(t, err.(*errorx.Error).IsOfType(redis.ErrContextClosed), err.Error())
+	}
+	require.True(t, runtime.NumGoroutine() <= before)
+	select {
+	case <-accepted:
+		require.Fail(t, "connection is dialed")
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestConnectFailFast(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := ln.Addr().String()
+	ln.Close()
+
+	// by default connection keeps reconnecting in background
+	conn, err := Connect(context.Background(), addr, Opts{Logger: NoopLogger{}, ReconnectPause: time.Millisecond})
+	require.NoError(t, err)
+	require.NotNil(t, conn)
+	require.False(t, conn.ConnectedNow())
+	conn.Close()
+
+	conn, err = Connect(context.Background(), addr, Opts{
+		Logger:         NoopLogger{},
+		ReconnectPause: time.Millisecond,
+		FailFast:       true,
+	})
+	require.Error(t, err)
+	require.Nil(t, conn)
+	require.True(t, err.(*errorx.Error).IsOfType(ErrDial), err.Error())
+}
+
+func TestNotConnectedErrorsIs(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := ln.Addr().String()
+	ln.Close()
+
+	conn, err := Connect(context.Background(), addr, Opts{
+		Logger:         NoopLogger{},
+		AsyncDial:      true,
+		ReconnectPause: time.Minute,
+	})
+	require.NoError(t, err)
+	defer conn.Close()
+
+	err = conn.Reset()
+	wrapped := fmt.Errorf("middleware: %w", redis.Std(err))
+	require.True(t, errors.Is(wrapped, redis.KindOf(ErrNotConnected)), "%v", err)
+	require.False(t, errors.Is(wrapped, redis.KindOf(ErrDial)))
+
+	var xerr *errorx.Error
+	require.True(t, errors.As(wrapped, &xerr))
+	require.Equal(t, err, xerr)
+}
+
+func TestCloseDuringReconnectPause(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := ln.Addr().String()
+	ln.Close()
+
+	before := runtime.NumGoroutine()
+	conn, err := Connect(context.Background(), addr, Opts{
+		Logger:         NoopLogger{},
+		AsyncDial:      true,
+		ReconnectPause: time.Minute,
+	})
+	require.NoError(t, err)
+	// first attempt failed, and connection waits before next one.
+	for failed := false; !failed; {
+		select {
+		case ev := <-conn.Events():
+			_, failed = ev.Event.(LogConnectFailed)
+		case <-time.After(time.Second):
+			require.Fail(t, "connect failure is not reported")
+		}
+	}
+	conn.Close()
+	// reconnecting goroutine exits without waiting for the rest of pause.
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > before && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	require.True(t, runtime.NumGoroutine() <= before)
+}
+
+func TestEventsDropOldest(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer ln.Close()
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go fakeServer(c)
+		}
+	}()
+
+	conn, err := Connect(context.Background(), ln.Addr().String(), Opts{Logger: NoopLogger{}})
+	require.NoError(t, err)
+	defer conn.Close()
+
+	// every reconnect reports at least disconnect and connect, so buffer overflows.
+	for i := 0; i < 50; i++ {
+		conn.ForceReconnect()
+		// wait for reconnection: requests are dropped meanwhile.
+		for j := 0; redis.AsError(redis.Sync{conn}.Do("GET", "foo")) != nil; j++ {
+			require.True(t, j < 1000, "connection is not re-established")
+			time.Sleep(time.Millisecond)
+		}
+	}
+	require.True(t, conn.EventsDropped() > 0)
+
+	last := time.Now()
+	var ev Event
+	for n := len(conn.Events()); n > 0; n-- {
+		ev = <-conn.Events()
+	}
+	// newest events are kept: last one is connect of last socket.
+	_, ok := ev.Event.(LogConnected)
+	require.True(t, ok, "%#v", ev.Event)
+	require.True(t, last.Sub(ev.Time) < time.Second)
+}
+
+func TestConnectOnConn(t *testing.T) {
+	client, server := net.Pipe()
+	go fakeServer(server)
+
+	conn, err := ConnectOnConn(context.Background(), client, Opts{Logger: NoopLogger{}})
+	require.NoError(t, err)
+	defer conn.Close()
+	require.Equal(t, []byte("bar"), redis.Sync{conn}.Do("GET", "foo"))
+
+	// server closes socket, and connection is closed instead of reconnecting
+	require.Equal(t, "OK", redis.Sync{conn}.Do("QUIT"))
+	select {
+	case <-conn.Ctx().Done():
+	case <-time.After(time.Second):
+		require.Fail(t, "connection is not closed")
+	}
+	require.Error(t, redis.AsError(redis.Sync{conn}.Do("GET", "foo")))
+}
+
+func TestCloseGracefullyQuit(t *testing.T) {
+	for _, hang := range []bool{false, true} {
+		client, server := net.Pipe()
+		quit := make(chan struct{})
+		go func() {
+			defer server.Close()
+			r := bufio.NewReaderSize(server, 1<<20)
+			for {
+				req, ok := redis.ReadResponse(r).([]interface{})
+				if !ok {
+					return
+				}
+				switch string(req[0].([]byte)) {
+				case "GET":
+					server.Write([]byte("$3\r\nbar\r\n"))
+				case "QUIT":
+					close(quit)
+					if !hang {
+						server.Write([]byte("+OK\r\n"))
+					}
+				default:
+					server.Write([]byte("+PONG\r\n"))
+				}
+			}
+		}()
+
+		conn, err := ConnectOnConn(context.Background(), client, Opts{Logger: NoopLogger{}})
+		require.NoError(t, err)
+		require.Equal(t, []byte("bar"), redis.Sync{conn}.Do("GET", "foo"))
+
+		start := time.Now()
+		require.True(t, conn.CloseGracefully(200*time.Millisecond))
+		// hung server doesn't block shutdown longer than timeout.
+		require.True(t, time.Since(start) < time.Second)
+		select {
+		case <-quit:
+		default:
+			require.Fail(t, "QUIT is not sent")
+		}
+	}
+}
+
+func TestDrainDoesNotHoldMutex(t *testing.T) {
+	client, server := net.Pipe()
+	release := make(chan struct{})
+	go func() {
+		defer server.Close()
+		r := bufio.NewReaderSize(server, 1<<20)
+		for {
+			req, ok := redis.ReadResponse(r).([]interface{})
+			if !ok {
+				return
+			}
+			switch string(req[0].([]byte)) {
+			case "GET":
+				<-release
+				server.Write([]byte("$3\r\nbar\r\n"))
+			case "QUIT":
+				server.Write([]byte("+OK\r\n"))
+				return
+			default:
+				server.Write([]byte("+PONG\r\n"))
+			}
+		}
+	}()
+
+	conn, err := ConnectOnConn(context.Background(), client, Opts{Logger: NoopLogger{}, IOTimeout: -1})
+	require.NoError(t, err)
+	res := make(chan interface{}, 2)
+	conn.Send(redis.Req("GET", "foo"), redis.FuncFuture(func(r interface{}, _ uint64) { res <- r }), 0)
+	for conn.InFlightCount() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	idle := conn.Idle()
+
+	closed := make(chan bool, 1)
+	go func() { closed <- conn.CloseGracefully(5 * time.Second) }()
+	time.Sleep(20 * time.Millisecond)
+	start := time.Now()
+	conn.RemoteAddr()
+	require.True(t, time.Since(start) < 100*time.Millisecond, "mutex is held during drain")
+
+	select {
+	case <-idle:
+		require.Fail(t, "connection is idle with request in flight")
+	default:
+	}
+	close(release)
+	require.Equal(t, []byte("bar"), <-res)
+	require.True(t, <-closed)
+	<-idle
+
+	// request answered during drain is resolved exactly once.
+	select {
+	case r := <-res:
+		require.Fail(t, "request is resolved twice", "%v", r)
+	case <-time.After(50 * time.Millisecond):
+	}
+	<-conn.Idle()
+}
+
+func TestResetReplaysHandshake(t *testing.T) {
+	var mu sync.Mutex
+	var log []string
+	var noReset, wrongPass bool
+	client, server := net.Pipe()
+	go func() {
+		defer server.Close()
+		r := bufio.NewReaderSize(server, 1<<20)
+		for {
+			req, ok := redis.ReadResponse(r).([]interface{})
+			if !ok {
+				return
+			}
+			args := make([]string, len(req))
+			for i, a := range req {
+				args[i] = string(a.([]byte))
+			}
+			mu.Lock()
+			log = append(log, strings.Join(args, " "))
+			noRst, wrong := noReset, wrongPass
+			mu.Unlock()
+			switch args[0] {
+			case "HELLO":
+				if wrong {
+					server.Write([]byte("-WRONGPASS invalid username-password pair\r\n"))
+				} else {
+					server.Write([]byte("%1\r\n+proto\r\n:3\r\n"))
+				}
+			case "RESET":
+				if noRst {
+					server.Write([]byte("-ERR unknown command 'RESET'\r\n"))
+				} else {
+					server.Write([]byte("+RESET\r\n"))
+				}
+			case "GET":
+				server.Write([]byte("$3\r\nbar\r\n"))
+			case "PING":
+				server.Write([]byte("+PONG\r\n"))
+			default:
+				server.Write([]byte("+OK\r\n"))
+			}
+		}
+	}()
+	takeLog := func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		l := log
+		log = nil
+		return l
+	}
+
+	conn, err := ConnectOnConn(context.Background(), client, Opts{
+		Logger:     NoopLogger{},
+		IOTimeout:  -1,
+		Protocol:   3,
+		Password:   "secret",
+		ClientName: "app",
+		Handshake: func(ctx context.Context, h *Handshake) error {
+			h.Default()
+			h.Tracking(TrackingOpts{})
+			return nil
+		},
+	})
+	require.NoError(t, err)
+	defer conn.Close()
+	handshake := []string{"HELLO 3 AUTH default secret SETNAME app", "CLIENT TRACKING ON"}
+	require.Equal(t, handshake, takeLog())
+
+	require.NoError(t, conn.Reset())
+	require.Equal(t, append([]string{"RESET"}, handshake...), takeLog())
+	require.Equal(t, 3, conn.Protocol())
+	require.Equal(t, []byte("bar"), redis.Sync{conn}.Do("GET", "foo"))
+	takeLog()
+
+	// server without RESET
+	mu.Lock()
+	noReset = true
+	mu.Unlock()
+	require.NoError(t, conn.Reset())
+	require.Equal(t, append([]string{"RESET", "UNWATCH"}, handshake...), takeLog())
+	require.Equal(t, []byte("bar"), redis.Sync{conn}.Do("GET", "foo"))
+	takeLog()
+
+	// authentication failure is returned as is, without UNWATCH fallback
+	mu.Lock()
+	noReset, wrongPass = false, true
+	mu.Unlock()
+	err = conn.Reset()
+	require.True(t, redis.IsOfType(err, ErrAuth), "%v", err)
+	require.Equal(t, []string{"RESET", handshake[0]}, takeLog())
+	select {
+	case <-conn.Ctx().Done():
+	case <-time.After(time.Second):
+		require.Fail(t, "connection is not closed after failed handshake")
+	}
+}
+
+func TestClientNameTemplate(t *testing.T) {
+	names := make(chan string, 2)
+	connect := func() *Connection {
+		client, server := net.Pipe()
+		go func() {
+			defer server.Close()
+			r := bufio.NewReaderSize(server, 1<<20)
+			for {
+				req, ok := redis.ReadResponse(r).([]interface{})
+				if !ok {
+					return
+				}
+				if len(req) == 3 && strings.EqualFold(string(req[1].([]byte)), "SETNAME") {
+					names <- string(req[2].([]byte))
+					server.Write([]byte("+OK\r\n"))
+				} else {
+					server.Write([]byte("+PONG\r\n"))
+				}
+			}
+		}()
+		conn, err := ConnectOnConn(context.Background(), client, Opts{
+			Logger:     NoopLogger{},
+			ClientName: "api-{id}-{handle}",
+			Handle:     "blocking pool\n",
+		})
+		require.NoError(t, err)
+		return conn
+	}
+
+	conn1 := connect()
+	defer conn1.Close()
+	conn2 := connect()
+	defer conn2.Close()
+	name1, name2 := <-names, <-names
+	require.True(t, strings.HasPrefix(name1, "api-") && strings.HasSuffix(name1, "-blocking_pool_"), name1)
+	require.True(t, name1 != name2, name1)
+	require.Equal(t, name1, conn1.EffectiveOpts().ClientName)
+	require.Equal(t, name2, conn2.EffectiveOpts().ClientName)
+
+	client, server := net.Pipe()
+	defer server.Close()
+	_, err := ConnectOnConn(context.Background(), client, Opts{Logger: NoopLogger{}, ClientName: "bad name"})
+	require.True(t, redis.IsOfType(err, ErrClientName), "%v", err)
+}
+
+func TestEffectiveOpts(t *testing.T) {
+	client, server := net.Pipe()
+	go fakeServer(server)
+
+	conn, err := ConnectOnConn(context.Background(), client, Opts{
+		Logger:      NoopLogger{},
+		IOTimeout:   2 * time.Second,
+		DialTimeout: 5 * time.Second,
+		ReadTimeout: -1,
+		RateLimit:   10,
+	})
+	require.NoError(t, err)
+	defer conn.Close()
+
+	opts := conn.EffectiveOpts()
+	require.Equal(t, 2*time.Second, opts.IOTimeout)
+	require.Equal(t, 2*time.Second, opts.DialTimeout)
+	require.Equal(t, time.Duration(0), opts.WriteTimeout)
+	// disabled ReadTimeout is reported as is, so options could be passed to Connect again.
+	require.Equal(t, time.Duration(-1), opts.ReadTimeout)
+	require.Equal(t, "", opts.Password)
+
+	require.NoError(t, conn.UpdateOpts(func(o *Opts) {
+		o.Password = "secret"
+		o.RateLimit = 1000
+		o.RateBurst = 10
+	}))
+	opts = conn.EffectiveOpts()
+	require.True(t, opts.Password != "" && opts.Password != "secret", opts.Password)
+	require.Equal(t, float64(1000), opts.RateLimit)
+	require.Equal(t, 10, opts.RateBurst)
+}
+
+func TestIdleWithShortReadTimeout(t *testing.T) {
+	client, server := net.Pipe()
+	go fakeServer(server)
+
+	// keepalive PING should be sent often enough for ReadTimeout, not only for IOTimeout.
+	conn, err := ConnectOnConn(context.Background(), client, Opts{
+		Logger:      NoopLogger{},
+		IOTimeout:   3 * time.Second,
+		ReadTimeout: 200 * time.Millisecond,
+	})
+	require.NoError(t, err)
+	defer conn.Close()
+
+	time.Sleep(1500 * time.Millisecond)
+	require.True(t, conn.ConnectedNow())
+	require.Equal(t, []byte("bar"), redis.Sync{conn}.Do("GET", "foo"))
+}
+
+func TestMaxReconnectAttempts(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := l.Addr().String()
+	l.Close()
+
+	conn, err := Connect(context.Background(), addr, Opts{
+		AsyncDial:            true,
+		ReconnectPause:       time.Millisecond,
+		MaxReconnectAttempts: 3,
+		Logger:               NoopLogger{},
+	})
+	require.NoError(t, err)
+	select {
+	case <-conn.Ctx().Done():
+	case <-time.After(time.Second):
+		require.Fail(t, "connection is not closed")
+	}
+	deadline := time.Now().Add(time.Second)
+	for {
+		res := redis.Sync{conn}.Do("GET", "foo")
+		xerr, ok := res.(*errorx.Error)
+		require.True(t, ok)
+		if xerr.IsOfType(ErrReconnectLimit) {
+			attempts, _ := xerr.Property(EKAttempts)
+			require.Equal(t, 3, attempts)
+			break
+		}
+		require.True(t, time.Now().Before(deadline), "unexpected error %v", xerr)
+		time.Sleep(time.Millisecond)
+	}
+
+	// synchronous dial gives up immediately
+	_, err = Connect(context.Background(), addr, Opts{MaxReconnectAttempts: 1, Logger: NoopLogger{}})
+	require.Error(t, err)
+	require.True(t, err.(*errorx.Error).IsOfType(ErrReconnectLimit))
+}
+
+func TestUpdateOpts(t *testing.T) {
+	client, server := net.Pipe()
+	go fakeServer(server)
+
+	conn, err := ConnectOnConn(context.Background(), client, Opts{Logger: NoopLogger{}})
+	require.NoError(t, err)
+	defer conn.Close()
+
+	require.NoError(t, conn.UpdateOpts(func(opts *Opts) {
+		opts.RateLimit = 0.001
+		opts.RateBurst = 1
+		opts.ReadTimeout = time.Second
+	}))
+	require.Equal(t, []byte("bar"), redis.Sync{conn}.Do("GET", "foo"))
+	res := redis.Sync{conn}.Do("GET", "foo")
+	require.True(t, res.(*errorx.Error).IsOfType(ErrRateLimited))
+
+	// concurrent updates and requests are race-free
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			conn.UpdateOpts(func(opts *Opts) {
+				opts.Password = strconv.Itoa(i)
+				opts.RateLimit = 0
+			})
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		redis.Sync{conn}.Do("GET", "foo")
+	}
+	wg.Wait()
+	require.Equal(t, []byte("bar"), redis.Sync{conn}.Do("GET", "foo"))
+}
+
+func TestResolveWorkers(t *testing.T) {
+	client, server := net.Pipe()
+	go fakeServer(server)
+
+	var decoded int64
+	conn, err := ConnectOnConn(context.Background(), client, Opts{
+		Logger:         NoopLogger{},
+		ResolveWorkers: 4,
+		DecodeHook: func(req Request, raw interface{}) (interface{}, bool) {
+			b, ok := raw.([]byte)
+			if ok {
+				atomic.AddInt64(&decoded, 1)
+			}
+			return string(b), ok
+		},
+	})
+	require.NoError(t, err)
+
+	reqs := make([]Request, 100)
+	for i := range reqs {
+		reqs[i] = redis.Req("GET", "foo")
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for _, res := range (redis.Sync{conn}).SendMany(reqs) {
+				require.Equal(t, "bar", res)
+			}
+		}()
+	}
+	wg.Wait()
+	require.Equal(t, int64(1000), atomic.LoadInt64(&decoded))
+
+	conn.Close()
+	<-conn.Ctx().Done()
+	require.Error(t, redis.AsError(redis.Sync{conn}.Do("GET", "foo")))
+}
+
+func TestMaxQueuedRequests(t *testing.T) {
+	client, server := net.Pipe()
+	defer server.Close()
+	go func() {
+		// answer handshake PING and stall, so requests are accumulated in queue.
+		redis.ReadResponse(bufio.NewReader(server))
+		server.Write([]byte("+PONG\r\n"))
+	}()
+
+	conn, err := ConnectOnConn(context.Background(), client, Opts{
+		Logger:            NoopLogger{},
+		MaxQueuedRequests: 3,
+	})
+	require.NoError(t, err)
+	defer conn.Close()
+
+	results := make(chan interface{}, 10)
+	future := redis.FuncFuture(func(res interface{}, _ uint64) { results <- res })
+	var rejected *errorx.Error
+	for i := 0; i < 10 && rejected == nil; i++ {
+		conn.Send(redis.Req("GET", "foo"), future, 0)
+		select {
+		case res := <-results:
+			rejected = res.(*errorx.Error)
+		case <-time.After(5 * time.Millisecond):
+		}
+	}
+	require.NotNil(t, rejected)
+	require.True(t, rejected.IsOfType(ErrBufferFull))
+	queued, _ := rejected.Property(EKQueuedRequests)
+	require.Equal(t, 3, queued)
+
+	// batch is rejected as whole
+	res := redis.Sync{conn}.SendMany([]Request{redis.Req("GET", "a"), redis.Req("GET", "b")})
+	require.True(t, res[0].(*errorx.Error).IsOfType(ErrBufferFull))
+	require.True(t, res[1].(*errorx.Error).IsOfType(ErrBufferFull))
+}
+
+func TestMaxPendingBytes(t *testing.T) {
+	client, server := net.Pipe()
+	defer server.Close()
+	release := make(chan struct{})
+	go func() {
+		// answer handshake PING and stall until released, so requests are accumulated in queue.
+		r := bufio.NewReaderSize(server, 1<<20)
+		redis.ReadResponse(r)
+		server.Write([]byte("+PONG\r\n"))
+		<-release
+		for {
+			if _, ok := redis.ReadResponse(r).([]interface{}); !ok {
+				return
+			}
+			server.Write([]byte("+OK\r\n"))
+		}
+	}()
+
+	conn, err := ConnectOnConn(context.Background(), client, Opts{
+		Logger:          NoopLogger{},
+		IOTimeout:       5 * time.Second,
+		MaxPendingBytes: 1000,
+	})
+	require.NoError(t, err)
+	defer conn.Close()
+
+	value := strings.Repeat("x", 600)
+	results := make(chan interface{}, 10)
+	future := redis.FuncFuture(func(res interface{}, _ uint64) { results <- res })
+	var rejected *errorx.Error
+	sent := 0
+	for ; sent < 10 && rejected == nil; sent++ {
+		conn.Send(redis.Req("SET", "foo", value), future, 0)
+		select {
+		case res := <-results:
+			rejected = res.(*errorx.Error)
+		case <-time.After(5 * time.Millisecond):
+		}
+	}
+	require.NotNil(t, rejected)
+	require.True(t, rejected.IsOfType(ErrBufferFull))
+	pending, _ := rejected.Property(EKPendingBytes)
+	require.True(t, pending.(int) > 0 && pending.(int) <= 1000, "%v", pending)
+
+	// budget is released after queued requests are written and answered.
+	close(release)
+	for i := 0; i < sent-1; i++ {
+		require.Equal(t, "OK", <-results)
+	}
+	require.Equal(t, "OK", redis.Sync{conn}.Do("SET", "foo", value))
+}
+
+func TestProtocol(t *testing.T) {
+	// server without HELLO support
+	client, server := net.Pipe()
+	go fakeServer(server)
+	conn, err := ConnectOnConn(context.Background(), client, Opts{Logger: NoopLogger{}, Protocol: 3})
+	require.NoError(t, err)
+	require.Equal(t, 2, conn.Protocol())
+	require.Equal(t, []byte("bar"), redis.Sync{conn}.Do("GET", "foo"))
+	conn.Close()
+
+	// RESP3 server, which sends push frame before answer
+	client, server = net.Pipe()
+	go func() {
+		defer server.Close()
+		r := bufio.NewReader(server)
+		for {
+			req, ok := redis.ReadResponse(r).([]interface{})
+			if !ok {
+				return
+			}
+			switch string(req[0].([]byte)) {
+			case "HELLO":
+				server.Write([]byte("%2\r\n$6\r\nserver\r\n$5\r\nredis\r\n$5\r\nproto\r\n:3\r\n"))
+			case "PING":
+				server.Write([]byte("+PONG\r\n"))
+			case "GET":
+				server.Write([]byte(">2\r\n$10\r\ninvalidate\r\n*1\r\n$3\r\nfoo\r\n_\r\n"))
+			}
+		}
+	}()
+	conn, err = ConnectOnConn(context.Background(), client, Opts{Logger: NoopLogger{}, Protocol: 3})
+	require.NoError(t, err)
+	defer conn.Close()
+	require.Equal(t, 3, conn.Protocol())
+	require.Nil(t, redis.Sync{conn}.Do("GET", "foo"))
+}
+
+func TestHelloAuth(t *testing.T) {
+	serve := func(server net.Conn, resp3, wrongpass bool, cmds chan<- string) {
+		defer server.Close()
+		r := bufio.NewReader(server)
+		for {
+			req, ok := redis.ReadResponse(r).([]interface{})
+			if !ok {
+				return
+			}
+			args := make([]string, len(req))
+			for i, a := range req {
+				args[i] = string(a.([]byte))
+			}
+			cmds <- strings.Join(args, " ")
+			switch {
+			case args[0] == "HELLO" && !resp3:
+				server.Write([]byte("-ERR unknown command 'HELLO'\r\n"))
+			case args[0] == "HELLO" && wrongpass:
+				server.Write([]byte("-WRONGPASS invalid username-password pair or user is disabled.\r\n"))
+			case args[0] == "HELLO":
+				server.Write([]byte("%1\r\n$5\r\nproto\r\n:3\r\n"))
+			case args[0] == "PING":
+				server.Write([]byte("+PONG\r\n"))
+			default:
+				server.Write([]byte("+OK\r\n"))
+			}
+		}
+	}
+	opts := Opts{
+		Logger:     NoopLogger{},
+		Protocol:   3,
+		Username:   "app",
+		Password:   "secret",
+		ClientName: "worker",
+	}
+	collect := func(cmds chan string) []string {
+		var res []string
+		for {
+			select {
+			case cmd := <-cmds:
+				res = append(res, cmd)
+			default:
+				return res
+			}
+		}
+	}
+
+	// RESP3 server: single round trip
+	cmds := make(chan string, 10)
+	client, server := net.Pipe()
+	go serve(server, true, false, cmds)
+	conn, err := ConnectOnConn(context.Background(), client, opts)
+	require.NoError(t, err)
+	require.Equal(t, 3, conn.Protocol())
+	require.Equal(t, []string{"HELLO 3 AUTH app secret SETNAME worker"}, collect(cmds))
+	conn.Close()
+
+	// old server: fall back to separate commands
+	client, server = net.Pipe()
+	go serve(server, false, false, cmds)
+	conn, err = ConnectOnConn(context.Background(), client, opts)
+	require.NoError(t, err)
+	require.Equal(t, 2, conn.Protocol())
+	require.Equal(t, []string{
+		"HELLO 3 AUTH app secret SETNAME worker",
+		"AUTH app secret",
+		"CLIENT SETNAME worker",
+		"PING",
+	}, collect(cmds))
+	conn.Close()
+
+	// wrong password is not a reason to fall back
+	client, server = net.Pipe()
+	go serve(server, true, true, cmds)
+	_, err = ConnectOnConn(context.Background(), client, opts)
+	require.Error(t, err)
+	require.True(t, err.(*errorx.Error).IsOfType(ErrAuth), "%v", err)
+}
+
+func TestDroppedRequestsNotSent(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer ln.Close()
+	go func() {
+		// first socket answers handshake, then stalls and breaks, so writer is stuck on huge request.
+		c, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		redis.ReadResponse(bufio.NewReader(c))
+		c.Write([]byte("+PONG\r\n"))
+		time.Sleep(100 * time.Millisecond)
+		c.Close()
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go fakeServer(c)
+		}
+	}()
+
+	conn, err := Connect(context.Background(), ln.Addr().String(), Opts{
+		Logger:     NoopLogger{},
+		IOTimeout:  time.Second,
+		WritePause: -1,
+	})
+	require.NoError(t, err)
+	defer conn.Close()
+
+	huge := make(chan interface{}, 1)
+	conn.Send(redis.Req("SET", "foo", make([]byte, 64<<20)), redis.FuncFuture(func(res interface{}, _ uint64) {
+		huge <- res
+	}), 0)
+	time.Sleep(20 * time.Millisecond)
+	res := redis.Sync{conn}.Do("GET", "foo")
+
+	rerr := res.(*errorx.Error)
+	require.True(t, rerr.IsOfType(ErrDropped), "%v", rerr)
+	require.True(t, rerr.HasTrait(redis.ErrTraitNotSent))
+	rerr = (<-huge).(*errorx.Error)
+	require.True(t, rerr.IsOfType(redis.ErrIO), "%v", rerr)
+	require.False(t, rerr.HasTrait(redis.ErrTraitNotSent))
+}
+
+func TestRequeueDropped(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer ln.Close()
+	go func() {
+		// first socket answers handshake, then stalls and breaks, so writer is stuck on huge request.
+		c, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		redis.ReadResponse(bufio.NewReader(c))
+		c.Write([]byte("+PONG\r\n"))
+		time.Sleep(100 * time.Millisecond)
+		c.Close()
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go fakeServer(c)
+		}
+	}()
+
+	conn, err := Connect(context.Background(), ln.Addr().String(), Opts{
+		Logger:         NoopLogger{},
+		IOTimeout:      time.Second,
+		WritePause:     -1,
+		RequeueDropped: 1,
+	})
+	require.NoError(t, err)
+	defer conn.Close()
+
+	conn.Send(redis.Req("SET", "foo", make([]byte, 64<<20)), redis.FuncFuture(func(interface{}, uint64) {}), 0)
+	time.Sleep(20 * time.Millisecond)
+
+	var wg sync.WaitGroup
+	res := make([]interface{}, 3)
+	wg.Add(3)
+	cb := redis.FuncFuture(func(r interface{}, n uint64) {
+		res[n] = r
+		wg.Done()
+	})
+	conn.Send(redis.Req("GET", "foo"), cb, 0)
+	conn.Send(redis.Req("SET", "foo", "bar"), cb, 1)
+	conn.SendAsk(redis.Req("GET", "foo"), cb, 2, true)
+	wg.Wait()
+
+	// read is replayed on new socket
+	require.Equal(t, []byte("bar"), res[0])
+	// write and read with ASKING are failed as usual
+	for _, r := range res[1:] {
+		rerr := r.(*errorx.Error)
+		require.True(t, rerr.IsOfType(ErrDropped), "%v", rerr)
+	}
+}
+
+func TestKeepaliveWithoutIOTimeout(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer ln.Close()
+	go func() {
+		// first socket answers handshake and then goes silent, but stays open.
+		c, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer c.Close()
+		redis.ReadResponse(bufio.NewReader(c))
+		c.Write([]byte("+PONG\r\n"))
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go fakeServer(c)
+		}
+	}()
+
+	conn, err := Connect(context.Background(), ln.Addr().String(), Opts{
+		Logger:    NoopLogger{},
+		IOTimeout: -1,
+	})
+	require.NoError(t, err)
+	defer conn.Close()
+
+	res := make(chan interface{}, 1)
+	conn.Send(redis.Req("GET", "foo"), redis.FuncFuture(func(r interface{}, _ uint64) { res <- r }), 0)
+	select {
+	case r := <-res:
+		rerr := r.(*errorx.Error)
+		require.True(t, rerr.IsOfType(redis.ErrIO), "%v", rerr)
+	case <-time.After(6 * time.Second):
+		require.Fail(t, "half-open socket is not detected")
+	}
+	deadline := time.Now().Add(time.Second)
+	for !conn.ConnectedNow() && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	require.Equal(t, []byte("bar"), redis.Sync{conn}.Do("GET", "foo"))
+}
+
+func TestMaxResponseBytes(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer ln.Close()
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go fakeServer(c)
+		}
+	}()
+
+	reconnected := make(chan struct{}, 1)
+	conn, err := Connect(context.Background(), ln.Addr().String(), Opts{
+		Logger:      NoopLogger{},
+		OnReconnect: func(int, string, string, error) { reconnected <- struct{}{} },
+	})
+	require.NoError(t, err)
+	defer conn.Close()
+
+	// "$3\r\nbar\r\n" fits 9 bytes
+	res := make(chan interface{}, 1)
+	conn.Send(redis.Req("GET", "foo"), redis.WithMaxResponseBytes(redis.FuncFuture(func(r interface{}, _ uint64) {
+		res <- r
+	}), 9), 0)
+	require.Equal(t, []byte("bar"), <-res)
+
+	conn.Send(redis.Req("GET", "foo"), redis.WithMaxResponseBytes(redis.FuncFuture(func(r interface{}, _ uint64) {
+		res <- r
+	}), 8), 0)
+	err = redis.AsError(<-res)
+	require.Error(t, err)
+	require.True(t, err.(*errorx.Error).IsOfType(redis.ErrResponseTooLarge), err.Error())
+
+	// connection is reset, and works after reconnect
+	select {
+	case <-reconnected:
+	case <-time.After(time.Second):
+		require.Fail(t, "connection is not reconnected")
+	}
+	require.Equal(t, []byte("bar"), redis.Sync{conn}.Do("GET", "foo"))
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer ln.Close()
+	var healthy int32
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func(c net.Conn) {
+				defer c.Close()
+				r := bufio.NewReader(c)
+				for {
+					req, ok := redis.ReadResponse(r).([]interface{})
+					if !ok {
+						return
+					}
+					switch string(req[0].([]byte)) {
+					case "PING":
+						c.Write([]byte("+PONG\r\n"))
+					case "GET":
+						if atomic.LoadInt32(&healthy) == 0 {
+							// node breaks socket on every request
+							return
+						}
+						c.Write([]byte("$3\r\nbar\r\n"))
+					}
+				}
+			}(c)
+		}
+	}()
+
+	conn, err := Connect(context.Background(), ln.Addr().String(), Opts{
+		Logger:                 NoopLogger{},
+		IOTimeout:              10 * time.Second,
+		ReconnectPause:         time.Millisecond,
+		CircuitBreakerFailures: 3,
+		CircuitBreakerCooldown: 200 * time.Millisecond,
+	})
+	require.NoError(t, err)
+	defer conn.Close()
+
+	waitOpen := time.After(2 * time.Second)
+	for opened := false; !opened; {
+		select {
+		case ev := <-conn.Events():
+			_, opened = ev.Event.(LogCircuitOpen)
+		case <-time.After(10 * time.Millisecond):
+			redis.Sync{conn}.Do("GET", "foo")
+		case <-waitOpen:
+			require.Fail(t, "circuit breaker is not opened")
+		}
+	}
+
+	// requests fail fast while breaker is open, even though socket is re-established
+	for i := 0; i < 100 && !conn.ConnectedNow(); i++ {
+		time.Sleep(time.Millisecond)
+	}
+	err = redis.AsError(redis.Sync{conn}.Do("GET", "foo"))
+	require.Error(t, err)
+	require.True(t, err.(*errorx.Error).IsOfType(ErrCircuitOpen), err.Error())
+
+	// probe after cooldown closes breaker when node recovers
+	atomic.StoreInt32(&healthy, 1)
+	time.Sleep(250 * time.Millisecond)
+	require.Equal(t, []byte("bar"), redis.Sync{conn}.Do("GET", "foo"))
+	require.Equal(t, []byte("bar"), redis.Sync{conn}.Do("GET", "foo"))
+}
+
+func TestOnReconnect(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer ln.Close()
+	go func() {
+		// first socket answers handshake and is closed then.
+		c, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		redis.ReadResponse(bufio.NewReader(c))
+		c.Write([]byte("+PONG\r\n"))
+		time.Sleep(50 * time.Millisecond)
+		c.Close()
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go fakeServer(c)
+		}
+	}()
+
+	type reconnect struct {
+		attempt          int
+		oldAddr, newAddr string
+		err              error
+	}
+	events := make(chan reconnect, 2)
+	conn, err := Connect(context.Background(), ln.Addr().String(), Opts{
+		Logger: NoopLogger{},
+		OnReconnect: func(attempt int, oldAddr, newAddr string, err error) {
+			events <- reconnect{attempt, oldAddr, newAddr, err}
+		},
+	})
+	require.NoError(t, err)
+	defer conn.Close()
+
+	select {
+	case ev := <-events:
+		require.Equal(t, 1, ev.attempt)
+		require.Equal(t, ln.Addr().String(), ev.oldAddr)
+		require.Equal(t, ln.Addr().String(), ev.newAddr)
+		require.True(t, ev.err.(*errorx.Error).IsOfType(redis.ErrIO), "%v", ev.err)
+	case <-time.After(time.Second):
+		require.Fail(t, "OnReconnect is not called")
+	}
+	require.Equal(t, []byte("bar"), redis.Sync{conn}.Do("GET", "foo"))
+	require.Equal(t, 0, len(events))
+}
+
+func TestSubscribeCancelUnsubscribes(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer ln.Close()
+	unsubscribed := make(chan string, 2)
+	silent := make(chan struct{})
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func(c net.Conn) {
+				defer c.Close()
+				r := bufio.NewReader(c)
+				var channels []string
+				for {
+					req, ok := redis.ReadResponse(r).([]interface{})
+					if !ok {
+						return
+					}
+					cmd := string(req[0].([]byte))
+					switch cmd {
+					case "PING":
+						c.Write([]byte("+PONG\r\n"))
+					case "SUBSCRIBE":
+						for _, ch := range req[1:] {
+							channels = append(channels, string(ch.([]byte)))
+							c.Write([]byte("*3\r\n$9\r\nsubscribe\r\n$" + strconv.Itoa(len(ch.([]byte))) + "\r\n" +
+								string(ch.([]byte)) + "\r\n:" + strconv.Itoa(len(channels)) + "\r\n"))
+						}
+						if channels[0] == "dead" {
+							// server goes silent, but socket stays open
+							<-silent
+							return
+						}
+						c.Write([]byte("*3\r\n$7\r\nmessage\r\n$1\r\na\r\n$2\r\nhi\r\n"))
+					case "UNSUBSCRIBE":
+						unsubscribed <- cmd
+						for i, ch := range channels {
+							c.Write([]byte("*3\r\n$11\r\nunsubscribe\r\n$" + strconv.Itoa(len(ch)) + "\r\n" + ch +
+								"\r\n:" + strconv.Itoa(len(channels)-i-1) + "\r\n"))
+						}
+					}
+				}
+			}(c)
+		}
+	}()
+	defer close(silent)
+
+	conn, err := Connect(context.Background(), ln.Addr().String(), Opts{Logger: NoopLogger{}, IOTimeout: 200 * time.Millisecond})
+	require.NoError(t, err)
+	defer conn.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	msgs, err := conn.Subscribe(ctx, "a", "b")
+	require.NoError(t, err)
+	require.Equal(t, Message{Channel: "a", Data: []byte("hi")}, <-msgs)
+	cancel()
+	select {
+	case <-unsubscribed:
+	case <-time.After(time.Second):
+		require.Fail(t, "UNSUBSCRIBE is not sent")
+	}
+	select {
+	case _, ok := <-msgs:
+		require.False(t, ok)
+	case <-time.After(time.Second):
+		require.Fail(t, "channel is not closed after unsubscribe")
+	}
+
+	// server doesn't confirm unsubscribe: subscription is closed after IOTimeout.
+	ctx, cancel = context.WithCancel(context.Background())
+	msgs, err = conn.Subscribe(ctx, "dead")
+	require.NoError(t, err)
+	start := time.Now()
+	cancel()
+	select {
+	case _, ok := <-msgs:
+		require.False(t, ok)
+	case <-time.After(time.Second):
+		require.Fail(t, "channel is not closed")
+	}
+	require.WithinDuration(t, start, time.Now(), 500*time.Millisecond)
+}
+
+func TestSubscribePolicy(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer ln.Close()
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func(c net.Conn) {
+				defer c.Close()
+				r := bufio.NewReader(c)
+				for {
+					req, ok := redis.ReadResponse(r).([]interface{})
+					if !ok {
+						return
+					}
+					switch string(req[0].([]byte)) {
+					case "PING":
+						c.Write([]byte("+PONG\r\n"))
+					case "SUBSCRIBE":
+						c.Write([]byte("*3\r\n$9\r\nsubscribe\r\n$1\r\na\r\n:1\r\n"))
+						for i := 0; i < 10; i++ {
+							c.Write([]byte("*3\r\n$7\r\nmessage\r\n$1\r\na\r\n$1\r\n" + strconv.Itoa(i) + "\r\n"))
+						}
+					}
+				}
+			}(c)
+		}
+	}()
+
+	receive := func(policy PubSubPolicy, dropped int64) []string {
+		conn, err := Connect(context.Background(), ln.Addr().String(), Opts{
+			Logger:       NoopLogger{},
+			PubSubBuffer: 3,
+			PubSubPolicy: policy,
+		})
+		require.NoError(t, err)
+		defer conn.Close()
+		msgs, err := conn.Subscribe(context.Background(), "a")
+		require.NoError(t, err)
+		// let reader process all messages before consumer starts receiving.
+		for deadline := time.Now().Add(time.Second); conn.DroppedMessages() < dropped && time.Now().Before(deadline); {
+			time.Sleep(time.Millisecond)
+		}
+		if dropped == 0 {
+			time.Sleep(50 * time.Millisecond)
+		}
+		var got []string
+		for len(got)+int(dropped) < 10 {
+			select {
+			case msg := <-msgs:
+				got = append(got, string(msg.Data))
+			case <-time.After(time.Second):
+				require.Fail(t, "message is not received")
+			}
+		}
+		require.Equal(t, dropped, conn.DroppedMessages())
+		return got
+	}
+
+	require.Equal(t, []string{"7", "8", "9"}, receive(PubSubDropOldest, 7))
+	require.Equal(t, []string{"0", "1", "2"}, receive(PubSubDropNewest, 7))
+	require.Equal(t, []string{"0", "1", "2", "3", "4", "5", "6", "7", "8", "9"}, receive(PubSubBlock, 0))
+}
+
+func TestSubscribeInvalidations(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer ln.Close()
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func(c net.Conn) {
+				defer c.Close()
+				r := bufio.NewReader(c)
+				for {
+					req, ok := redis.ReadResponse(r).([]interface{})
+					if !ok {
+						return
+					}
+					switch string(req[0].([]byte)) {
+					case "PING":
+						c.Write([]byte("+PONG\r\n"))
+					case "SUBSCRIBE":
+						c.Write([]byte("*3\r\n$9\r\nsubscribe\r\n$20\r\n__redis__:invalidate\r\n:1\r\n"))
+						c.Write([]byte("*3\r\n$7\r\nmessage\r\n$20\r\n__redis__:invalidate\r\n" +
+							"*2\r\n$1\r\na\r\n$1\r\nb\r\n"))
+						// FLUSHALL invalidates all keys with nil payload
+						c.Write([]byte("*3\r\n$7\r\nmessage\r\n$20\r\n__redis__:invalidate\r\n*-1\r\n"))
+					}
+				}
+			}(c)
+		}
+	}()
+
+	conn, err := Connect(context.Background(), ln.Addr().String(), Opts{Logger: NoopLogger{}})
+	require.NoError(t, err)
+	defer conn.Close()
+	msgs, err := conn.Subscribe(context.Background(), InvalidateChannel)
+	require.NoError(t, err)
+
+	receive := func() Message {
+		select {
+		case msg := <-msgs:
+			return msg
+		case <-time.After(time.Second):
+			require.Fail(t, "message is not received")
+		}
+		return Message{}
+	}
+	msg := receive()
+	require.Equal(t, InvalidateChannel, msg.Channel)
+	require.Equal(t, []string{"a", "b"}, msg.Keys)
+	require.False(t, msg.InvalidateAll)
+	msg = receive()
+	require.Nil(t, msg.Keys)
+	require.True(t, msg.InvalidateAll)
+}
+
+// pubsubReply formats subscription reply (subscribe, sunsubscribe, etc).
+func pubsubReply(kind, channel string, count int) string {
+	return "*3\r\n$" + strconv.Itoa(len(kind)) + "\r\n" + kind + "\r\n$" + strconv.Itoa(len(channel)) + "\r\n" +
+		channel + "\r\n:" + strconv.Itoa(count) + "\r\n"
+}
+
+func TestSubscribeKeepalive(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer ln.Close()
+	pings := make(chan struct{}, 100)
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func(c net.Conn) {
+				defer c.Close()
+				r := bufio.NewReader(c)
+				for {
+					req, ok := redis.ReadResponse(r).([]interface{})
+					if !ok {
+						return
+					}
+					switch string(req[0].([]byte)) {
+					case "PING":
+						c.Write([]byte("+PONG\r\n"))
+					case "SUBSCRIBE":
+						name := string(req[1].([]byte))
+						c.Write([]byte(pubsubReply("subscribe", name, 1)))
+						if name == "dead" {
+							// socket stays open, but nothing is answered anymore.
+							for redis.AsError(redis.ReadResponse(r)) == nil {
+								pings <- struct{}{}
+							}
+							return
+						}
+						for {
+							req, ok := redis.ReadResponse(r).([]interface{})
+							if !ok {
+								return
+							}
+							if string(req[0].([]byte)) == "PING" {
+								pings <- struct{}{}
+								c.Write([]byte("*2\r\n$4\r\npong\r\n$0\r\n\r\n"))
+							}
+						}
+					}
+				}
+			}(c)
+		}
+	}()
+
+	conn, err := Connect(context.Background(), ln.Addr().String(), Opts{Logger: NoopLogger{}, IOTimeout: 150 * time.Millisecond})
+	require.NoError(t, err)
+	defer conn.Close()
+
+	alive, err := conn.Subscribe(context.Background(), "alive")
+	require.NoError(t, err)
+	dead, err := conn.Subscribe(context.Background(), "dead")
+	require.NoError(t, err)
+
+	// half-open subscription socket is detected by unanswered PING.
+	select {
+	case _, ok := <-dead:
+		require.False(t, ok)
+	case <-time.After(time.Second):
+		require.Fail(t, "half-open subscription is not closed")
+	}
+	select {
+	case <-alive:
+		require.Fail(t, "answered subscription is closed")
+	default:
+	}
+	require.True(t, len(pings) > 0)
+}
+
+func TestSSubscribeGroupsBySlot(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer ln.Close()
+	commands := make(chan []string, 10)
+	migrate := make(chan struct{})
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func(c net.Conn) {
+				defer c.Close()
+				r := bufio.NewReader(c)
+				count := 0
+				for {
+					req, ok := redis.ReadResponse(r).([]interface{})
+					if !ok {
+						return
+					}
+					switch string(req[0].([]byte)) {
+					case "PING":
+						c.Write([]byte("+PONG\r\n"))
+					case "SSUBSCRIBE":
+						var names []string
+						for _, ch := range req[1:] {
+							count++
+							names = append(names, string(ch.([]byte)))
+							c.Write([]byte(pubsubReply("ssubscribe", string(ch.([]byte)), count)))
+						}
+						commands <- names
+						if count == 3 {
+							go func() {
+								<-migrate
+								// slot of "b" is migrated, and server drops its subscription.
+								c.Write([]byte(pubsubReply("sunsubscribe", "b", 2)))
+							}()
+						}
+					}
+				}
+			}(c)
+		}
+	}()
+
+	conn, err := Connect(context.Background(), ln.Addr().String(), Opts{Logger: NoopLogger{}})
+	require.NoError(t, err)
+	defer conn.Close()
+
+	msgs, err := conn.SSubscribe(context.Background(), "{a}1", "b", "{a}2")
+	require.NoError(t, err)
+	// one SSUBSCRIBE per slot through the same socket.
+	require.Equal(t, []string{"{a}1", "{a}2"}, <-commands)
+	require.Equal(t, []string{"b"}, <-commands)
+	require.Equal(t, []string{"b", "{a}1", "{a}2"}, conn.SubscribedChannels())
+
+	// subscription is closed when server drops channels of any slot.
+	close(migrate)
+	select {
+	case _, ok := <-msgs:
+		require.False(t, ok)
+	case <-time.After(time.Second):
+		require.Fail(t, "subscription is not closed")
+	}
+	require.Equal(t, []string{}, conn.SubscribedChannels())
+
+	conn.Close()
+	_, err = conn.SSubscribe(context.Background(), "b")
+	require.True(t, err.(*errorx.Error).IsOfType(redis.ErrContextClosed), "%v", err)
+}
+
+func TestServerClosedPause(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer ln.Close()
+	accepted := make(chan time.Time, 2)
+	go func() {
+		// first socket answers handshake and is closed by "server".
+		c, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		redis.ReadResponse(bufio.NewReader(c))
+		c.Write([]byte("+PONG\r\n"))
+		c.Close()
+		accepted <- time.Now()
+		c, err = ln.Accept()
+		if err != nil {
+			return
+		}
+		accepted <- time.Now()
+		fakeServer(c)
+	}()
+
+	conn, err := Connect(context.Background(), ln.Addr().String(), Opts{
+		Logger:            NoopLogger{},
+		ServerClosedPause: 200 * time.Millisecond,
+	})
+	require.NoError(t, err)
+	defer conn.Close()
+
+	var ev Event
+	for ev = range conn.Events() {
+		if _, ok := ev.Event.(LogDisconnected); ok {
+			break
+		}
+	}
+	rerr := ev.Error.(*errorx.Error)
+	require.True(t, rerr.IsOfType(ErrServerClosed), "%v", rerr)
+	require.True(t, rerr.IsOfType(redis.ErrIO))
+	require.False(t, rerr.HasTrait(redis.ErrTraitNotSent))
+
+	closed := <-accepted
+	reconnected := <-accepted
+	require.True(t, reconnected.Sub(closed) >= 150*time.Millisecond, "%v", reconnected.Sub(closed))
+}
+
+type typedResult struct {
+	res      interface{}
+	respType byte
+}
+
+type typedResultFuture chan typedResult
+
+func (f typedResultFuture) Cancelled() error                  { return nil }
+func (f typedResultFuture) Resolve(res interface{}, n uint64) { f <- typedResult{res, 0} }
+func (f typedResultFuture) ResolveTyped(res interface{}, n uint64, respType byte) {
+	f <- typedResult{res, respType}
+}
+
+func TestTypedFutureIntegerVsBulk(t *testing.T) {
+	client, server := net.Pipe()
+	go func() {
+		defer server.Close()
+		r := bufio.NewReader(server)
+		for {
+			req, ok := redis.ReadResponse(r).([]interface{})
+			if !ok {
+				return
+			}
+			switch string(req[0].([]byte)) {
+			case "PING":
+				server.Write([]byte("+PONG\r\n"))
+			case "INCR":
+				server.Write([]byte(":42\r\n"))
+			case "GET":
+				server.Write([]byte("$2\r\n42\r\n"))
+			}
+		}
+	}()
+	conn, err := ConnectOnConn(context.Background(), client, Opts{Logger: NoopLogger{}})
+	require.NoError(t, err)
+	defer conn.Close()
+
+	f := make(typedResultFuture, 1)
+	conn.Send(redis.Req("INCR", "counter"), f, 0)
+	require.Equal(t, typedResult{int64(42), ':'}, <-f)
+	conn.Send(redis.Req("GET", "counter"), f, 0)
+	require.Equal(t, typedResult{[]byte("42"), '$'}, <-f)
+}
+
+type streamingFuture struct {
+	sink io.Writer
+	res  chan interface{}
+}
+
+func (f streamingFuture) Cancelled() error                  { return nil }
+func (f streamingFuture) Resolve(res interface{}, n uint64) { f.res <- res }
+func (f streamingFuture) Sink() io.Writer                   { return f.sink }
+
+type failingWriter struct{}
+
+func (failingWriter) Write(p []byte) (int, error) { return 0, errors.New("sink is full") }
+
+func TestStreamingFuture(t *testing.T) {
+	big := strings.Repeat("x", 100000)
+	client, server := net.Pipe()
+	go func() {
+		defer server.Close()
+		r := bufio.NewReaderSize(server, 1<<20)
+		for {
+			req, ok := redis.ReadResponse(r).([]interface{})
+			if !ok {
+				return
+			}
+			var val string
+			switch string(req[0].([]byte)) {
+			case "PING":
+				server.Write([]byte("+PONG\r\n"))
+				continue
+			case "GET":
+				if val = "value:" + string(req[1].([]byte)); string(req[1].([]byte)) == "big" {
+					val = big
+				}
+			}
+			server.Write([]byte("$" + strconv.Itoa(len(val)) + "\r\n" + val + "\r\n"))
+		}
+	}()
+	conn, err := ConnectOnConn(context.Background(), client, Opts{Logger: NoopLogger{}})
+	require.NoError(t, err)
+	defer conn.Close()
+
+	// streamed replies are pipelined with regular ones, and every reply reaches its future.
+	var buf bytes.Buffer
+	streamed := streamingFuture{sink: &buf, res: make(chan interface{}, 1)}
+	failed := streamingFuture{sink: failingWriter{}, res: make(chan interface{}, 1)}
+	regular := []*redis.Promise{redis.NewPromise(), redis.NewPromise()}
+	conn.Send(redis.Req("GET", "big"), streamed, 0)
+	conn.Send(redis.Req("GET", "a"), regular[0], 0)
+	conn.Send(redis.Req("GET", "big"), failed, 0)
+	conn.Send(redis.Req("GET", "b"), regular[1], 0)
+
+	require.Equal(t, redis.StreamedBulk{Len: int64(len(big))}, <-streamed.res)
+	require.Equal(t, big, buf.String())
+	res, err := regular[0].Await()
+	require.NoError(t, err)
+	require.Equal(t, []byte("value:a"), res)
+	err = redis.AsError(<-failed.res)
+	require.Error(t, err)
+	require.True(t, err.(*errorx.Error).IsOfType(redis.ErrSinkWrite), err.Error())
+	// failed sink doesn't break connection: rest of bulk string is discarded.
+	res, err = regular[1].Await()
+	require.NoError(t, err)
+	require.Equal(t, []byte("value:b"), res)
+	require.True(t, conn.ConnectedNow())
+}
+
+type attrsFuture struct {
+	typedResultFuture
+	attrs chan []interface{}
+}
+
+func (f attrsFuture) ResolveAttributes(attrs []interface{}, n uint64) { f.attrs <- attrs }
+
+func TestAttributesFuture(t *testing.T) {
+	client, server := net.Pipe()
+	go func() {
+		defer server.Close()
+		r := bufio.NewReader(server)
+		for {
+			req, ok := redis.ReadResponse(r).([]interface{})
+			if !ok {
+				return
+			}
+			if string(req[0].([]byte)) == "PING" {
+				server.Write([]byte("+PONG\r\n"))
+				continue
+			}
+			server.Write([]byte("|1\r\n+key-popularity\r\n:100\r\n:42\r\n"))
+		}
+	}()
+	conn, err := ConnectOnConn(context.Background(), client, Opts{Logger: NoopLogger{}})
+	require.NoError(t, err)
+	defer conn.Close()
+
+	f := attrsFuture{make(typedResultFuture, 1), make(chan []interface{}, 1)}
+	conn.Send(redis.Req("INCR", "counter"), f, 0)
+	require.Equal(t, typedResult{int64(42), ':'}, <-f.typedResultFuture)
+	require.Equal(t, []interface{}{"key-popularity", int64(100)}, <-f.attrs)
+
+	// attributes are skipped for plain future, and type of answer itself is passed.
+	tf := make(typedResultFuture, 1)
+	conn.Send(redis.Req("INCR", "counter"), tf, 0)
+	require.Equal(t, typedResult{int64(42), ':'}, <-tf)
+}
+
+func TestForceReconnect(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer ln.Close()
+	accepted := make(chan struct{}, 2)
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			accepted <- struct{}{}
+			go fakeServer(c)
+		}
+	}()
+
+	conn, err := Connect(context.Background(), ln.Addr().String(), Opts{Logger: NoopLogger{}})
+	require.NoError(t, err)
+	defer conn.Close()
+	<-accepted
+	require.Equal(t, []byte("bar"), redis.Sync{conn}.Do("GET", "foo"))
+
+	conn.ForceReconnect()
+	select {
+	case <-accepted:
+	case <-time.After(time.Second):
+		require.Fail(t, "connection is not re-established")
+	}
+	require.Equal(t, []byte("bar"), redis.Sync{conn}.Do("GET", "foo"))
+}
+
+func TestCancelAll(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer ln.Close()
+	accepted := make(chan struct{}, 2)
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			accepted <- struct{}{}
+			go func(c net.Conn) {
+				defer c.Close()
+				r := bufio.NewReader(c)
+				for {
+					req, ok := redis.ReadResponse(r).([]interface{})
+					if !ok {
+						return
+					}
+					switch {
+					case string(req[0].([]byte)) == "PING":
+						c.Write([]byte("+PONG\r\n"))
+					case string(req[1].([]byte)) != "hang":
+						c.Write([]byte("$3\r\nbar\r\n"))
+					}
+				}
+			}(c)
+		}
+	}()
+
+	conn, err := Connect(context.Background(), ln.Addr().String(), Opts{Logger: NoopLogger{}, IOTimeout: -1})
+	require.NoError(t, err)
+	defer conn.Close()
+	<-accepted
+
+	errPoison := errors.New("poisoned")
+	results := make(chan interface{}, 3)
+	for i := 0; i < 3; i++ {
+		conn.Send(redis.Req("GET", "hang"), redis.FuncFuture(func(res interface{}, _ uint64) {
+			results <- res
+		}), 0)
+	}
+	for conn.InFlightCount() != 3 {
+		time.Sleep(time.Millisecond)
+	}
+	conn.CancelAll(errPoison)
+	for i := 0; i < 3; i++ {
+		select {
+		case res := <-results:
+			err := redis.AsErrorx(res)
+			require.NotNil(t, err)
+			require.True(t, err.IsOfType(redis.ErrIO), err.Error())
+			require.Equal(t, errPoison, err.Cause())
+		case <-time.After(time.Second):
+			require.Fail(t, "request is not cancelled")
+		}
+	}
+
+	// connection is re-established
+	select {
+	case <-accepted:
+	case <-time.After(time.Second):
+		require.Fail(t, "connection is not re-established")
+	}
+	require.Equal(t, []byte("bar"), redis.Sync{conn}.Do("GET", "foo"))
+	select {
+	case res := <-results:
+		require.Fail(t, "request resolved twice", "%v", res)
+	default:
+	}
+}
+
+func TestWaitAOFExtendsReadTimeout(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer ln.Close()
+	accepted := make(chan struct{}, 2)
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			accepted <- struct{}{}
+			go func(c net.Conn) {
+				defer c.Close()
+				r := bufio.NewReader(c)
+				for {
+					req, ok := redis.ReadResponse(r).([]interface{})
+					if !ok {
+						return
+					}
+					if string(req[0].([]byte)) == "WAITAOF" {
+						// replicas are slow to fsync: answer comes much later than IOTimeout
+						time.Sleep(200 * time.Millisecond)
+						c.Write([]byte("*2\r\n:1\r\n:0\r\n"))
+					} else {
+						c.Write([]byte("+PONG\r\n"))
+					}
+				}
+			}(c)
+		}
+	}()
+
+	conn, err := Connect(context.Background(), ln.Addr().String(), Opts{
+		Logger:    NoopLogger{},
+		IOTimeout: 50 * time.Millisecond,
+	})
+	require.NoError(t, err)
+	defer conn.Close()
+	<-accepted
+
+	local, replicas, err := conn.WaitAOF(1, 1, 500*time.Millisecond)
+	require.NoError(t, err)
+	require.Equal(t, 1, local)
+	require.Equal(t, 0, replicas)
+	select {
+	case <-accepted:
+		require.Fail(t, "connection is re-established")
+	default:
+	}
+}
+
+func TestValidateArity(t *testing.T) {
+	client, server := net.Pipe()
+	go fakeServer(server)
+	conn, err := ConnectOnConn(context.Background(), client, Opts{
+		Logger:        NoopLogger{},
+		ValidateArity: true,
+		CommandArity:  map[string]int{"MY.CMD": 3},
+	})
+	require.NoError(t, err)
+	defer conn.Close()
+
+	sconn := redis.Sync{conn}
+	require.Equal(t, []byte("bar"), sconn.Do("GET", "foo"))
+	res := sconn.Do("GET")
+	require.True(t, redis.IsOfType(redis.AsError(res), redis.ErrArgumentCount), "%v", res)
+	res = sconn.Do("MY.CMD", "a")
+	require.True(t, redis.IsOfType(redis.AsError(res), redis.ErrArgumentCount), "%v", res)
+
+	ress := sconn.SendMany([]redis.Request{redis.Req("GET", "foo"), redis.Req("GET")})
+	require.True(t, redis.IsOfType(redis.AsError(ress[1]), redis.ErrArgumentCount), "%v", ress[1])
+	_, err = sconn.SendTransaction([]redis.Request{redis.Req("GET", "foo"), redis.Req("SET", "foo")})
+	require.True(t, redis.IsOfType(err, redis.ErrBatchFormat), "%v", err)
+}
+
+func TestSendManyHint(t *testing.T) {
+	client, server := net.Pipe()
+	go fakeServer(server)
+	conn, err := ConnectOnConn(context.Background(), client, Opts{Logger: NoopLogger{}})
+	require.NoError(t, err)
+	defer conn.Close()
+
+	reqs := make([]Request, 1000)
+	for i := range reqs {
+		reqs[i] = redis.Req("GET", "key"+strconv.Itoa(i))
+	}
+	res := make([]interface{}, len(reqs))
+	var wg sync.WaitGroup
+	wg.Add(len(reqs))
+	// hint is smaller than actual size, so buffer still grows.
+	conn.SendManyHint(reqs, redis.FuncFuture(func(r interface{}, n uint64) {
+		res[n] = r
+		wg.Done()
+	}), 0, 4096)
+	wg.Wait()
+	for _, r := range res {
+		require.Equal(t, []byte("bar"), r)
+	}
+}
+
+func TestInFlightCount(t *testing.T) {
+	client, server := net.Pipe()
+	release := make(chan struct{})
+	go func() {
+		defer server.Close()
+		r := bufio.NewReaderSize(server, 1<<20)
+		var held int
+		for {
+			req, ok := redis.ReadResponse(r).([]interface{})
+			if !ok {
+				return
+			}
+			if string(req[0].([]byte)) == "PING" {
+				server.Write([]byte("+PONG\r\n"))
+				continue
+			}
+			// GETs are answered only after release
+			if held++; held == 10 {
+				<-release
+				for ; held > 0; held-- {
+					server.Write([]byte("$3\r\nbar\r\n"))
+				}
+			}
+		}
+	}()
+	conn, err := ConnectOnConn(context.Background(), client, Opts{Logger: NoopLogger{}, IOTimeout: -1})
+	require.NoError(t, err)
+	defer conn.Close()
+	require.Equal(t, 0, conn.InFlightCount())
+
+	var wg sync.WaitGroup
+	wg.Add(10)
+	for i := 0; i < 10; i++ {
+		conn.Send(redis.Req("GET", "foo"), redis.FuncFuture(func(interface{}, uint64) { wg.Done() }), 0)
+	}
+	deadline := time.Now().Add(time.Second)
+	for conn.InFlightCount() != 10 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	require.Equal(t, 10, conn.InFlightCount())
+	close(release)
+	wg.Wait()
+	// counter is decremented right after future is resolved
+	for conn.InFlightCount() != 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	require.Equal(t, 0, conn.InFlightCount())
+}
+
+func TestOnQueueActivated(t *testing.T) {
+	client, server := net.Pipe()
+	go fakeServer(server)
+	var activations int32
+	conn, err := ConnectOnConn(context.Background(), client, Opts{
+		Logger:           NoopLogger{},
+		IOTimeout:        -1,
+		OnQueueActivated: func() { atomic.AddInt32(&activations, 1) },
+	})
+	require.NoError(t, err)
+	defer conn.Close()
+
+	// synchronous requests are written one by one, so every one activates queue.
+	for i := 0; i < 3; i++ {
+		require.Equal(t, []byte("bar"), redis.Sync{conn}.Do("GET", "foo"))
+	}
+	require.Equal(t, int32(3), atomic.LoadInt32(&activations))
+
+	reqs := make([]Request, 10)
+	for i := range reqs {
+		reqs[i] = redis.Req("GET", "foo")
+	}
+	for _, res := range (redis.Sync{conn}).SendMany(reqs) {
+		require.Equal(t, []byte("bar"), res)
+	}
+	require.Equal(t, int32(4), atomic.LoadInt32(&activations))
+}
+
+type lockedBuffer struct {
+	m sync.Mutex
+	b bytes.Buffer
+}
+
+func (l *lockedBuffer) Write(p []byte) (int, error) {
+	l.m.Lock()
+	defer l.m.Unlock()
+	return l.b.Write(p)
+}
+
+func (l *lockedBuffer) String() string {
+	l.m.Lock()
+	defer l.m.Unlock()
+	return l.b.String()
+}
+
+func TestPausedWriteThreshold(t *testing.T) {
+	client, server := net.Pipe()
+	go func() {
+		defer server.Close()
+		r := bufio.NewReaderSize(server, 1<<20)
+		for {
+			req, ok := redis.ReadResponse(r).([]interface{})
+			if !ok {
+				return
+			}
+			switch string(req[0].([]byte)) {
+			case "PING":
+				server.Write([]byte("+PONG\r\n"))
+			case "GET":
+				server.Write([]byte("$3\r\nbar\r\n"))
+			case "SET":
+				// writes are paused, but reads are served.
+				time.Sleep(60 * time.Millisecond)
+				server.Write([]byte("+OK\r\n"))
+			}
+		}
+	}()
+
+	conn, err := ConnectOnConn(context.Background(), client, Opts{
+		Logger:               NoopLogger{},
+		IOTimeout:            5 * time.Second,
+		PausedWriteThreshold: 30 * time.Millisecond,
+	})
+	require.NoError(t, err)
+	defer conn.Close()
+
+	paused := func() int {
+		n := 0
+		for i := len(conn.Events()); i > 0; i-- {
+			if _, ok := (<-conn.Events()).Event.(LogPossiblyPaused); ok {
+				n++
+			}
+		}
+		return n
+	}
+	readThenWrite := func() {
+		res := make(chan interface{}, 2)
+		cb := redis.FuncFuture(func(r interface{}, _ uint64) { res <- r })
+		conn.Send(redis.Req("GET", "foo"), cb, 0)
+		conn.Send(redis.Req("SET", "foo", "bar"), cb, 1)
+		<-res
+		<-res
+	}
+
+	// slow write alone is not reported: server may be just slow.
+	require.Equal(t, "OK", redis.Sync{conn}.Do("SET", "foo", "bar"))
+	require.Equal(t, 0, paused())
+
+	readThenWrite()
+	require.Equal(t, 1, paused())
+
+	// it is reported at most once per second.
+	readThenWrite()
+	require.Equal(t, 0, paused())
+	time.Sleep(time.Second)
+	readThenWrite()
+	require.Equal(t, 1, paused())
+}
+
+func TestWireLogger(t *testing.T) {
+	client, server := net.Pipe()
+	go fakeServer(server)
+
+	var wire lockedBuffer
+	conn, err := ConnectOnConn(context.Background(), client, Opts{Logger: NoopLogger{}, WireLogger: &wire})
+	require.NoError(t, err)
+	defer conn.Close()
+	require.Equal(t, []byte("bar"), redis.Sync{conn}.Do("GET", "foo"))
+
+	log := wire.String()
+	// handshake
+	require.Contains(t, log, `pipe > "*1\r\n$4\r\nPING\r\n"`)
+	require.Contains(t, log, `pipe < "+PONG\r\n"`)
+	// regular request
+	require.Contains(t, log, `pipe > "*2\r\n$3\r\nGET\r\n$3\r\nfoo\r\n"`)
+	require.Contains(t, log, `pipe < "$3\r\nbar\r\n"`)
+}
+
+func TestWireLoggerRedactsCredentials(t *testing.T) {
+	for _, proto := range []int{2, 3} {
+		client, server := net.Pipe()
+		go func() {
+			defer server.Close()
+			r := bufio.NewReader(server)
+			for {
+				req, ok := redis.ReadResponse(r).([]interface{})
+				if !ok {
+					return
+				}
+				switch string(req[0].([]byte)) {
+				case "HELLO":
+					server.Write([]byte("%1\r\n$5\r\nproto\r\n:3\r\n"))
+				case "PING":
+					server.Write([]byte("+PONG\r\n"))
+				default:
+					server.Write([]byte("+OK\r\n"))
+				}
+			}
+		}()
+
+		var wire lockedBuffer
+		conn, err := ConnectOnConn(context.Background(), client, Opts{
+			Logger:     NoopLogger{},
+			WireLogger: &wire,
+			Protocol:   proto,
+			Username:   "user",
+			Password:   "secret",
+		})
+		require.NoError(t, err)
+		require.Equal(t, "OK", redis.Sync{conn}.Do("SET", "auth", "value"))
+		conn.Close()
+
+		log := wire.String()
+		require.False(t, strings.Contains(log, "secret"), log)
+		require.Contains(t, log, `$4\r\nauth\r\n$5\r\nvalue\r\n`)
+		if proto == 3 {
+			require.Contains(t, log, `$5\r\nHELLO\r\n$1\r\n3\r\n$4\r\nAUTH\r\n$10\r\n[redacted]\r\n$10\r\n[redacted]\r\n`)
+		} else {
+			require.Contains(t, log, `$4\r\nAUTH\r\n$10\r\n[redacted]\r\n$10\r\n[redacted]\r\n`)
+		}
+	}
+}
+
 func (s *Suite) ping(conn *Connection, timeout time.Duration) interface{} {
 	start := time.Now()
 	res := redis.Sync{conn}.Do("PING")
-	done := time.Now()
-	if timeout == 0 {
-		timeout = defopts.IOTimeout
+	done := time.Now()
+	if timeout == 0 {
+		timeout = defopts.IOTimeout
+	}
+	s.r().WithinDuration(start, done, timeout*5/4)
+	return res
+}
+
+func (s *Suite) goodPing(conn *Connection, timeout time.Duration) {
+	s.Equal("PONG", s.ping(conn, timeout))
+}
+
+func (s *Suite) badPing(conn *Connection, kind *errorx.Type, timeout time.Duration) {
+	res := s.ping(conn, timeout)
+	rerr := s.AsError(res)
+	s.True(rerr.IsOfType(kind))
+}
+
+func (s *Suite) waitReconnect(conn *Connection) {
+	start := time.Now()
+	for {
+		at := time.Now()
+		res := redis.Sync{conn}.Do("PING")
+		done := time.Now()
+		s.r().WithinDuration(at, done, defopts.IOTimeout*3/2)
+		if rerr := redis.AsErrorx(res); rerr != nil {
+			s.True(rerr.IsOfType(ErrNotConnected))
+			s.r().WithinDuration(start, at, defopts.IOTimeout*2)
+		} else {
+			s.Equal("PONG", res)
+			s.r().WithinDuration(start, at, defopts.IOTimeout*3)
+			break
+		}
+		runtime.Gosched()
+	}
+}
+
+func TestConn(t *testing.T) {
+	suite.Run(t, new(Suite))
+}
+
+func (s *Suite) TestConnects() {
+	conn, err := Connect(s.ctx, s.s.Addr(), defopts)
+	s.r().Nil(err)
+	defer conn.Close()
+	s.goodPing(conn, 0)
+}
+
+func (s *Suite) TestConnectsDb() {
+	conn1, err := Connect(s.ctx, s.s.Addr(), defopts)
+	s.r().Nil(err)
+	defer conn1.Close()
+
+	sync1 := redis.Sync{conn1}
+	res := sync1.Do("SET", "db", 0)
+	s.r().NoError(redis.AsError(res))
+	res = sync1.Do("GET", "db")
+	s.r().Equal(res, []byte("0"))
+
+	opts2 := defopts
+	opts2.DB = 1
+	conn2, err := Connect(s.ctx, s.s.Addr(), opts2)
+	s.r().Nil(err)
+
+	sync2 := redis.Sync{conn2}
+	res = sync2.Do("GET", "db")
+	s.r().Nil(res)
+	res = sync2.Do("SET", "db", 1)
+	s.r().NoError(redis.AsError(res))
+	res = sync2.Do("GET", "db")
+	s.r().Equal(res, []byte("1"))
+
+	res = sync1.Do("GET", "db")
+	s.r().Equal(res, []byte("0"))
+}
+
+func (s *Suite) TestResetKeepsDb() {
+	opts := defopts
+	opts.DB = 1
+	conn, err := Connect(s.ctx, s.s.Addr(), opts)
+	s.r().Nil(err)
+	defer conn.Close()
+
+	sconn := redis.Sync{conn}
+	s.r().NoError(redis.AsError(sconn.Do("SET", "reset", 1)))
+	s.r().NoError(conn.Reset())
+	s.r().Equal([]byte("1"), sconn.Do("GET", "reset"))
+}
+
+func (s *Suite) TestSelect() {
+	conn, err := Connect(s.ctx, s.s.Addr(), defopts)
+	s.r().Nil(err)
+	defer conn.Close()
+
+	sconn := redis.Sync{conn}
+	s.r().NoError(redis.AsError(sconn.Do("SET", "select", 0)))
+	s.r().NoError(conn.Select(2))
+	s.Equal(2, conn.DB())
+	s.r().NoError(redis.AsError(sconn.Do("SET", "select", 2)))
+
+	s.Error(conn.Select(1024))
+	s.Equal(2, conn.DB())
+
+	// database is selected after reconnect as well
+	sconn.Do("QUIT")
+	time.Sleep(50 * time.Millisecond)
+	s.Equal([]byte("2"), sconn.Do("GET", "select"))
+}
+
+func (s *Suite) TestClone() {
+	conn, err := Connect(s.ctx, s.s.Addr(), defopts)
+	s.r().Nil(err)
+	defer conn.Close()
+	s.r().NoError(conn.Select(3))
+
+	clone, err := conn.Clone(func(opts *Opts) {
+		opts.ScriptMode = true
+	})
+	s.r().Nil(err)
+	defer clone.Close()
+	s.Equal(3, clone.DB())
+	s.Equal(conn.Addr(), clone.Addr())
+
+	redis.Sync{conn}.Do("DEL", "clone")
+	redis.Sync{conn}.Do("RPUSH", "clone", "1")
+	s.Equal([]interface{}{[]byte("clone"), []byte("1")},
+		redis.Sync{clone}.Do("BLPOP", "missing", "clone", 0.01))
+	s.Nil(redis.Sync{clone}.Do("BLPOP", "missing", 0.01))
+
+	// clone is independent of original connection
+	conn.Close()
+	s.Equal("PONG", redis.Sync{clone}.Do("PING"))
+}
+
+func (s *Suite) TestMemoryStats() {
+	conn, err := Connect(s.ctx, s.s.Addr(), defopts)
+	s.r().Nil(err)
+	defer conn.Close()
+
+	s.Equal("OK", redis.Sync{conn}.Do("SET", "memstats", "1"))
+	stats, err := conn.MemoryStats()
+	s.r().Nil(err)
+	s.IsType(int64(0), stats["total.allocated"])
+	db, ok := stats["db."+strconv.Itoa(conn.DB())].(map[string]interface{})
+	s.r().True(ok, "%v", stats)
+	s.IsType(int64(0), db["overhead.hashtable.main"])
+
+	advice, err := conn.MemoryDoctor()
+	s.r().Nil(err)
+	s.NotEmpty(advice)
+}
+
+func (s *Suite) TestPersist() {
+	conn, err := Connect(s.ctx, s.s.Addr(), defopts)
+	s.r().Nil(err)
+	defer conn.Close()
+
+	sync := redis.Sync{conn}
+	sync.Do("DEL", "persist", "persist_missing")
+	s.Equal("OK", sync.Do("SET", "persist", "1", "EX", 100))
+	ok, err := conn.Persist("persist")
+	s.r().Nil(err)
+	s.True(ok)
+	s.Equal(int64(-1), sync.Do("TTL", "persist"))
+
+	// key has no ttl already
+	ok, err = conn.Persist("persist")
+	s.r().Nil(err)
+	s.False(ok)
+
+	ok, err = conn.Persist("persist_missing")
+	s.r().Nil(err)
+	s.False(ok)
+}
+
+func (s *Suite) TestFailedWithWrongDB() {
+	opts := defopts
+	opts.DB = 1024
+	conn, err := Connect(s.ctx, s.s.Addr(), opts)
+	s.r().Nil(conn)
+	s.r().Error(err)
+}
+
+func (s *Suite) TestFailedWithNonEmptyPassword() {
+	opts := defopts
+	opts.Password = "asdf"
+	conn, err := Connect(s.ctx, s.s.Addr(), opts)
+	s.r().Nil(conn)
+	s.r().Error(err)
+	s.r().True(redis.AsErrorx(err).IsOfType(ErrAuth))
+}
+
+func (s *Suite) Test_justToCover() {
+	// this test just to increase code coverage
+	opts := defopts
+	opts.Handle = &struct{}{}
+	opts.IOTimeout = -1
+	opts.TCPKeepAlive = -1
+
+	conn, err := Connect(nil, s.s.Addr(), opts)
+	s.r().Nil(conn)
+	s.r().Error(err)
+	conn, err = Connect(s.ctx, "", opts)
+	s.r().Nil(conn)
+	s.r().Error(err)
+
+	conn, err = Connect(s.ctx, "tcp://"+s.s.Addr(), opts)
+	s.r().Nil(err)
+	defer conn.Close()
+	s.r().Equal("tcp://"+s.s.Addr(), conn.Addr())
+	s.r().NotNil(conn.Ctx())
+	s.r().NotEqual(s.ctx, conn.Ctx()) // because it is derived from
+	s.r().True(conn.MayBeConnected())
+	s.r().True(conn.ConnectedNow())
+	s.r().Equal(s.s.Addr(), conn.RemoteAddr())
+	s.r().True(strings.HasPrefix(conn.LocalAddr(), "127.0.0.1:"))
+	s.r().Equal(opts.Handle, conn.Handle())
+
+	var c cancelledFuture
+	conn.Send(redis.Req("GET", "a"), &c, 0)
+	s.r().Equal(1, c.cnt)
+	s.r().Error(redis.AsError(c.res))
+	conn.SendTransaction([]redis.Request{}, &c, 0)
+	s.r().Equal(2, c.cnt)
+	s.r().Error(redis.AsError(c.res))
+
+	conn.Send(redis.Req("GET", make(chan int)), nil, 0)
+	conn.SendMany([]redis.Request{redis.Req("GET", 1)}, nil, 0)
+}
+
+type cancelledFuture struct {
+	cnt int
+	res interface{}
+}
+
+func (c *cancelledFuture) Cancelled() error {
+	return errors.New("cancelled")
+}
+
+func (c *cancelledFuture) Resolve(res interface{}, n uint64) {
+	c.res = res
+	c.cnt++
+}
+
+func (s *Suite) TestSendMany_FailedWholeBatchBecauseOfOne() {
+	conn, err := Connect(s.ctx, s.s.Addr(), defopts)
+	s.r().Nil(err)
+	defer conn.Close()
+
+	results := redis.Sync{conn}.SendMany([]redis.Request{
+		redis.Req("GET", "a"),
+		redis.Req("GET", "b"),
+		redis.Req("DO_BAD_THING", make(chan int)),
+		redis.Req("SYNC"),
+	})
+	s.r().Len(results, 4)
+	for _, res := range results {
+		s.r().Error(redis.AsError(res))
+	}
+}
+
+func (s *Suite) TestStopped_DoesntConnectWithNegativeReconnectPause() {
+	s.s.Stop()
+	opts := defopts
+	opts.ReconnectPause = -1
+	_, err := Connect(s.ctx, s.s.Addr(), opts)
+	s.r().NotNil(err)
+	rerr := s.AsError(err)
+	s.True(rerr.IsOfType(ErrDial))
+}
+
+func (s *Suite) TestStopped_Reconnects() {
+	s.s.Stop()
+
+	conn, err := Connect(s.ctx, s.s.Addr(), defopts)
+	s.r().Nil(err)
+	defer conn.Close()
+
+	s.badPing(conn, ErrNotConnected, 0)
+
+	s.s.Start()
+	s.waitReconnect(conn)
+
+	s.s.Stop()
+	time.Sleep(1 * time.Millisecond)
+	s.badPing(conn, ErrNotConnected, 0)
+
+	s.s.Start()
+	s.waitReconnect(conn)
+}
+
+func (s *Suite) TestStopped_Reconnects2() {
+	conn, err := Connect(s.ctx, s.s.Addr(), defopts)
+	s.r().Nil(err)
+	defer conn.Close()
+
+	s.goodPing(conn, 0)
+
+	s.s.Stop()
+	time.Sleep(1 * time.Millisecond)
+	s.badPing(conn, ErrNotConnected, 0)
+
+	s.s.Start()
+	s.waitReconnect(conn)
+
+	s.s.Stop()
+	time.Sleep(1 * time.Millisecond)
+	s.badPing(conn, ErrNotConnected, 0)
+
+	s.s.Start()
+	s.waitReconnect(conn)
+}
+
+func (s *Suite) TestTimeout() {
+	conn, err := Connect(s.ctx, s.s.Addr(), defopts)
+	s.r().Nil(err)
+	defer conn.Close()
+
+	s.goodPing(conn, 0)
+
+	s.s.Pause()
+	events := 0
+	start := time.Now()
+	for events != 7 {
+		res := s.ping(conn, 0)
+		rerr := s.AsError(res)
+		switch {
+		case rerr.IsOfType(redis.ErrIO):
+			events |= 1
+		case rerr.IsOfType(ErrConnSetup):
+			events |= 2
+		case rerr.IsOfType(ErrNotConnected):
+			events |= 4
+		}
+		s.r().WithinDuration(start, time.Now(), defopts.IOTimeout*10)
+	}
+
+	s.s.Resume()
+	s.waitReconnect(conn)
+}
+
+func (s *Suite) TestTransaction() {
+	conn, err := Connect(s.ctx, s.s.Addr(), defopts)
+	s.r().Nil(err)
+	defer conn.Close()
+
+	sconn := redis.SyncCtx{conn}
+
+	// transaction just works
+	res, err := sconn.SendTransaction(s.ctx, []redis.Request{
+		redis.Req("PING"),
+		redis.Req("PING", "asdf"),
+	})
+	s.Nil(err)
+	if s.IsType([]interface{}{}, res) && s.Len(res, 2) {
+		s.r().Equal("PONG", res[0])
+		s.r().Equal([]byte("asdf"), res[1])
+	}
+
+	s.s.DoSure("SET", "tran:x", 1)
+
+	// transaction daesn't execute in case of wrong command
+	res, err = sconn.SendTransaction(s.ctx, []redis.Request{
+		redis.Req("INCR", "tran:x"),
+		redis.Req("PANG"),
+	})
+	s.NotNil(err)
+	rerr := s.AsError(err)
+	s.True(rerr.IsOfType(redis.ErrResult))
+	s.True(strings.HasPrefix(rerr.Message(), "EXECABORT"))
+
+	s.Equal([]byte("1"), s.s.DoSure("GET", "tran:x"))
+
+	// transaction is executed partially (that is redis's behavior):
+	// - first command executed well
+	// - second command returns with error.
+	res, err = sconn.SendTransaction(s.ctx, []redis.Request{
+		redis.Req("INCR", "tran:x"),
+		redis.Req("HSET", "tran:x", "y", "1"),
+	})
+	s.Nil(err)
+	if s.IsType([]interface{}{}, res) && s.Len(res, 2) {
+		s.r().Equal(int64(2), res[0])
+		rerr := s.AsError(res[1])
+		s.True(rerr.IsOfType(redis.ErrResult))
+		s.True(strings.HasPrefix(rerr.Message(), "WRONGTYPE"))
+	}
+
+	s.Equal([]byte("2"), s.s.DoSure("GET", "tran:x"))
+}
+
+func (s *Suite) TestScan() {
+	conn, err := Connect(s.ctx, s.s.Addr(), defopts)
+	s.r().Nil(err)
+	defer conn.Close()
+
+	sconn := redis.SyncCtx{conn}
+	for i := 0; i < 1000; i++ {
+		sconn.Do(s.ctx, "SET", "scan:"+strconv.Itoa(i), i)
+	}
+
+	allkeys := make(map[string]struct{}, 1000)
+	for scanner := sconn.Scanner(s.ctx, redis.ScanOpts{Match: "scan:*"}); ; {
+		keys, err := scanner.Next()
+		if err != nil {
+			s.Equal(redis.ScanEOF, err)
+			break
+		}
+		for _, key := range keys {
+			_, ok := allkeys[key]
+			s.False(ok)
+			allkeys[key] = struct{}{}
+		}
+	}
+	s.Len(allkeys, 1000)
+}
+
+func (s *Suite) TestMaxConnLifetime() {
+	opts := defopts
+	opts.MaxConnLifetime = 30 * time.Millisecond
+	conn, err := Connect(s.ctx, s.s.Addr(), opts)
+	s.r().Nil(err)
+	defer conn.Close()
+
+	firstAddr := conn.LocalAddr()
+	sconn := redis.Sync{conn}
+	finish := time.Now().Add(200 * time.Millisecond)
+	errs := make(chan error, 8)
+	for g := 0; g < 8; g++ {
+		go func() {
+			var err error
+			for time.Now().Before(finish) && err == nil {
+				err = redis.AsError(sconn.Do("INCR", "lifetime"))
+			}
+			errs <- err
+		}()
+	}
+	for g := 0; g < 8; g++ {
+		s.Nil(<-errs)
+	}
+	s.NotEqual(firstAddr, conn.LocalAddr())
+}
+
+func (s *Suite) TestCloseGracefully() {
+	conn, err := Connect(s.ctx, s.s.Addr(), defopts)
+	s.r().Nil(err)
+
+	const N = 1000
+	results := make([]interface{}, N)
+	var wg sync.WaitGroup
+	wg.Add(N)
+	for i := 0; i < N; i++ {
+		conn.Send(redis.Req("PING"), redis.FuncFuture(func(res interface{}, n uint64) {
+			results[n] = res
+			wg.Done()
+		}), uint64(i))
+	}
+	s.True(conn.CloseGracefully(time.Second))
+	wg.Wait()
+	for _, res := range results {
+		s.Equal("PONG", res)
+	}
+
+	res := redis.Sync{conn}.Do("PING")
+	s.True(s.AsError(res).IsOfType(redis.ErrContextClosed))
+}
+
+func (s *Suite) TestCheckDesync() {
+	opts := defopts
+	opts.CheckDesync = true
+	conn, err := Connect(s.ctx, s.s.Addr(), opts)
+	s.r().Nil(err)
+	defer conn.Close()
+
+	const N = 1000
+	results := make([]interface{}, N)
+	var wg sync.WaitGroup
+	wg.Add(N)
+	for i := 0; i < N; i++ {
+		conn.Send(redis.Req("ECHO", i), redis.FuncFuture(func(res interface{}, n uint64) {
+			results[n] = res
+			wg.Done()
+		}), uint64(i))
 	}
-	s.r().WithinDuration(start, done, timeout*5/4)
-	return res
+	wg.Wait()
+	for i, res := range results {
+		s.Equal([]byte(strconv.Itoa(i)), res)
+	}
+	s.True(conn.ConnectedNow())
+	s.Equal(0, conn.Pending())
 }
 
-func (s *Suite) goodPing(conn *Connection, timeout time.Duration) {
-	s.Equal("PONG", s.ping(conn, timeout))
+func (s *Suite) TestCustomHandshake() {
+	opts := defopts
+	opts.Handshake = func(ctx context.Context, h *Handshake) error {
+		h.Default()
+		h.Do(redis.Req("CLIENT", "SETNAME", "handshake"), nil)
+		return nil
+	}
+	conn, err := Connect(s.ctx, s.s.Addr(), opts)
+	s.r().Nil(err)
+	defer conn.Close()
+	s.Equal([]byte("handshake"), redis.Sync{conn}.Do("CLIENT", "GETNAME"))
+
+	opts.Handshake = func(ctx context.Context, h *Handshake) error {
+		h.Do(redis.Req("UNKNOWN_COMMAND"), nil)
+		return h.Flush()
+	}
+	_, err = Connect(s.ctx, s.s.Addr(), opts)
+	s.r().NotNil(err)
+	s.True(errorx.IsOfType(err, ErrInit))
 }
 
-func (s *Suite) badPing(conn *Connection, kind *errorx.Type, timeout time.Duration) {
-	res := s.ping(conn, timeout)
-	rerr := s.AsError(res)
-	s.True(rerr.IsOfType(kind))
+func (s *Suite) TestStopAcceptingAndIdle() {
+	conn, err := Connect(s.ctx, s.s.Addr(), defopts)
+	s.r().Nil(err)
+	defer conn.Close()
+
+	const N = 1000
+	var answered int32
+	for i := 0; i < N; i++ {
+		conn.Send(redis.Req("PING"), redis.FuncFuture(func(res interface{}, n uint64) {
+			atomic.AddInt32(&answered, 1)
+		}), uint64(i))
+	}
+	conn.StopAccepting()
+	res := redis.Sync{conn}.Do("PING")
+	s.True(s.AsError(res).IsOfType(redis.ErrContextClosed))
+
+	idle := false
+	select {
+	case <-conn.Idle():
+		idle = true
+	case <-time.After(time.Second):
+	}
+	s.True(idle)
+	s.Equal(0, conn.Pending())
+	s.Equal(int32(N), atomic.LoadInt32(&answered))
 }
 
-func (s *Suite) waitReconnect(conn *Connection) {
+func (s *Suite) TestQueueWhileConnecting() {
+	s.s.Pause()
+	defer s.s.Resume()
+
+	opts := defopts
+	opts.IOTimeout = 200 * time.Millisecond
+	opts.DialTimeout = 200 * time.Millisecond
+	opts.AsyncDial = true
+	opts.QueueWhileConnecting = 20 * time.Millisecond
+	conn, err := Connect(s.ctx, s.s.Addr(), opts)
+	s.r().Nil(err)
+	defer conn.Close()
+
 	start := time.Now()
+	res := redis.Sync{conn}.Do("PING")
+	s.True(s.AsError(res).IsOfType(ErrNotConnected))
+	s.r().WithinDuration(start, time.Now(), 100*time.Millisecond)
+}
+
+func (s *Suite) TestEvents() {
+	conn, err := Connect(s.ctx, s.s.Addr(), defopts)
+	s.r().Nil(err)
+
+	ev := <-conn.Events()
+	s.IsType(LogConnecting{}, ev.Event)
+	ev = <-conn.Events()
+	s.IsType(LogConnected{}, ev.Event)
+	s.False(ev.Time.IsZero())
+
+	conn.Close()
+	ev = <-conn.Events()
+	s.IsType(LogContextClosed{}, ev.Event)
+	s.Error(ev.Error)
+}
+
+func (s *Suite) TestHealthCheck() {
+	var checks int32
+	opts := defopts
+	opts.HealthCheck = func(ctx context.Context, c redis.Sender) error {
+		switch atomic.AddInt32(&checks, 1) {
+		case 1:
+			return errors.New("replication lag")
+		case 2:
+			return redis.ErrIO.New("replica is promoted")
+		}
+		return redis.AsError(redis.SyncCtx{c}.Do(ctx, "PING"))
+	}
+	conn, err := Connect(s.ctx, s.s.Addr(), opts)
+	s.r().Nil(err)
+	defer conn.Close()
+
+	var failed, disconnected bool
+	timeout := time.After(time.Second)
+	for !(failed && disconnected) {
+		select {
+		case ev := <-conn.Events():
+			switch ev.Event.(type) {
+			case LogHealthCheckFailed:
+				failed = true
+				s.Equal("replication lag", ev.Error.Error())
+			case LogDisconnected:
+				disconnected = true
+			}
+		case <-timeout:
+			s.r().True(failed && disconnected, "health check failures are not reported")
+		}
+	}
+	s.Equal("PONG", redis.Sync{conn}.Do("PING"))
+}
+
+func (s *Suite) TestTransactionCancelledBeforeWrite() {
+	opts := defopts
+	opts.IOTimeout = time.Second
+	opts.WritePause = 200 * time.Millisecond
+	conn, err := Connect(s.ctx, s.s.Addr(), opts)
+	s.r().Nil(err)
+	defer conn.Close()
+
+	redis.Sync{conn}.Do("DEL", "txcancel")
+	ctx, cancel := context.WithTimeout(s.ctx, 20*time.Millisecond)
+	defer cancel()
+	_, err = redis.SyncCtx{conn}.SendTransaction(ctx, []Request{
+		redis.Req("SET", "txcancel", "1"),
+		redis.Req("INCR", "txcancel"),
+	})
+	s.True(redis.IsOfType(err, redis.ErrRequestCancelled))
+
+	// transaction is not written at all, so connection is not left in MULTI state.
+	s.Nil(redis.Sync{conn}.Do("GET", "txcancel"))
+	s.Equal(0, conn.Pending())
+}
+
+func (s *Suite) TestSlowLog() {
+	opts := defopts
+	opts.IOTimeout = time.Second
+	// requests wait in queue for WritePause, so they are slow from client's point of view.
+	opts.WritePause = 20 * time.Millisecond
+	opts.SlowLogThreshold = 10 * time.Millisecond
+	conn, err := Connect(s.ctx, s.s.Addr(), opts)
+	s.r().Nil(err)
+	defer conn.Close()
+
+	redis.Sync{conn}.Do("GET", "slowlog")
+	timeout := time.After(time.Second)
 	for {
-		at := time.Now()
-		res := redis.Sync{conn}.Do("PING")
-		done := time.Now()
-		s.r().WithinDuration(at, done, defopts.IOTimeout*3/2)
-		if rerr := redis.AsErrorx(res); rerr != nil {
-			s.True(rerr.IsOfType(ErrNotConnected))
-			s.r().WithinDuration(start, at, defopts.IOTimeout*2)
-		} else {
-			s.Equal("PONG", res)
-			s.r().WithinDuration(start, at, defopts.IOTimeout*3)
+		select {
+		case ev := <-conn.Events():
+			if slow, ok := ev.Event.(LogSlowRequest); ok {
+				s.Equal("GET", slow.Cmd)
+				s.True(slow.Latency >= opts.SlowLogThreshold)
+				return
+			}
+		case <-timeout:
+			s.r().True(false, "slow request is not reported")
+		}
+	}
+}
+
+func (s *Suite) TestMonitor() {
+	conn, err := Connect(s.ctx, s.s.Addr(), defopts)
+	s.r().Nil(err)
+	defer conn.Close()
+
+	ctx, cancel := context.WithCancel(s.ctx)
+	lines, err := conn.Monitor(ctx)
+	s.r().Nil(err)
+
+	res := redis.Sync{conn}.Do("MONITOR")
+	s.True(s.AsError(res).IsOfType(redis.ErrCommandForbidden))
+
+	redis.Sync{conn}.Do("SET", "monitor", "val")
+	found := false
+	for line := range lines {
+		if strings.Contains(line, `"SET" "monitor" "val"`) {
+			found = true
 			break
 		}
-		runtime.Gosched()
+	}
+	s.True(found)
+
+	cancel()
+	for range lines {
+	}
+}
+
+type typedFuture chan byte
+
+func (f typedFuture) Cancelled() error                  { return nil }
+func (f typedFuture) Resolve(res interface{}, n uint64) { f <- 0 }
+func (f typedFuture) ResolveTyped(res interface{}, n uint64, respType byte) {
+	f <- respType
+}
+
+func (s *Suite) TestTypedFuture() {
+	conn, err := Connect(s.ctx, s.s.Addr(), defopts)
+	s.r().Nil(err)
+	defer conn.Close()
+
+	for _, c := range []struct {
+		req      redis.Request
+		respType byte
+	}{
+		{redis.Req("SET", "typed", "OK"), '+'},
+		{redis.Req("GET", "typed"), '$'},
+		{redis.Req("STRLEN", "typed"), ':'},
+		{redis.Req("MGET", "typed"), '*'},
+		{redis.Req("UNKNOWN_COMMAND"), '-'},
+	} {
+		f := make(typedFuture, 1)
+		conn.Send(c.req, f, 0)
+		s.Equal(c.respType, <-f)
 	}
 }
 
-func TestConn(t *testing.T) {
-	suite.Run(t, new(Suite))
+func (s *Suite) TestBlockingTimeoutExtendsReadTimeout() {
+	opts := defopts
+	opts.ScriptMode = true
+	conn, err := Connect(s.ctx, s.s.Addr(), opts)
+	s.r().Nil(err)
+	defer conn.Close()
+
+	sconn := redis.Sync{conn}
+	sconn.Do("DEL", "bqueue", "bprocessing")
+
+	// timeout is 10 times larger than IOTimeout
+	_, ok, err := redis.BLMove(conn, "bqueue", "bprocessing", "RIGHT", "LEFT", 100*time.Millisecond)
+	s.r().Nil(err)
+	s.False(ok)
+	s.True(conn.ConnectedNow())
+
+	s.Equal(int64(1), sconn.Do("LPUSH", "bqueue", "job"))
+	job, ok, err := redis.BRPopLPush(conn, "bqueue", "bprocessing", time.Second)
+	s.r().Nil(err)
+	s.True(ok)
+	s.Equal("job", job)
 }
 
-func (s *Suite) TestConnects() {
+func (s *Suite) TestFunctions() {
 	conn, err := Connect(s.ctx, s.s.Addr(), defopts)
 	s.r().Nil(err)
 	defer conn.Close()
-	s.goodPing(conn, 0)
-}
 
-func (s *Suite) TestConnectsDb() {
-	conn1, err := Connect(s.ctx, s.s.Addr(), defopts)
+	lib := "#!lua name=testlib\n" +
+		"redis.register_function{function_name='getkey', callback=function(keys, args) " +
+		"return redis.call('GET', keys[1]) end, flags={'no-writes'}}"
+	err = redis.LoadFunctions(conn, lib)
+	if err != nil && strings.Contains(err.Error(), "unknown command") {
+		s.T().Skip("redis < 7.0")
+	}
 	s.r().Nil(err)
-	defer conn1.Close()
-
-	sync1 := redis.Sync{conn1}
-	res := sync1.Do("SET", "db", 0)
-	s.r().NoError(redis.AsError(res))
-	res = sync1.Do("GET", "db")
-	s.r().Equal(res, []byte("0"))
 
-	opts2 := defopts
-	opts2.DB = 1
-	conn2, err := Connect(s.ctx, s.s.Addr(), opts2)
+	redis.Sync{conn}.Do("SET", "fkey", "fval")
+	res, err := redis.FCallRO(conn, "getkey", []string{"fkey"}, nil)
 	s.r().Nil(err)
-
-	sync2 := redis.Sync{conn2}
-	res = sync2.Do("GET", "db")
-	s.r().Nil(res)
-	res = sync2.Do("SET", "db", 1)
-	s.r().NoError(redis.AsError(res))
-	res = sync2.Do("GET", "db")
-	s.r().Equal(res, []byte("1"))
-
-	res = sync1.Do("GET", "db")
-	s.r().Equal(res, []byte("0"))
+	s.Equal([]byte("fval"), res)
 }
 
-func (s *Suite) TestFailedWithWrongDB() {
+func (s *Suite) TestCachingOptIn() {
+	inv, err := Connect(s.ctx, s.s.Addr(), defopts)
+	s.r().Nil(err)
+	defer inv.Close()
+	id, ok := redis.Sync{inv}.Do("CLIENT", "ID").(int64)
+	s.r().True(ok)
+
 	opts := defopts
-	opts.DB = 1024
+	opts.Handshake = func(ctx context.Context, h *Handshake) error {
+		h.Default()
+		h.Tracking(TrackingOpts{Redirect: id, OptIn: true})
+		return nil
+	}
 	conn, err := Connect(s.ctx, s.s.Addr(), opts)
-	s.r().Nil(conn)
-	s.r().Error(err)
+	if err != nil && strings.Contains(err.Error(), "unknown subcommand") {
+		s.T().Skip("redis < 6.0")
+	}
+	s.r().Nil(err)
+	defer conn.Close()
+
+	redis.Sync{conn}.Do("SET", "cached", "1")
+	s.Equal([]byte("1"), redis.Sync{conn.Caching()}.Do("GET", "cached"))
+	res, err := redis.Sync{conn.Caching()}.SendTransaction([]Request{redis.Req("GET", "cached")})
+	s.Nil(err)
+	s.Equal([]interface{}{[]byte("1")}, res)
 }
 
-func (s *Suite) TestFailedWithNonEmptyPassword() {
+func (s *Suite) TestTrackingNoLoop() {
+	// invalidations are redirected to plain client, so they are read in order with deadline.
+	inv := redisdumb.Conn{Addr: s.s.Addr()}
+	defer inv.Close()
+	id, ok := inv.Do("CLIENT", "ID").(int64)
+	s.r().True(ok)
+	s.r().Nil(redis.AsError(inv.Do("SUBSCRIBE", InvalidateChannel)))
+
 	opts := defopts
-	opts.Password = "asdf"
+	opts.Handshake = func(ctx context.Context, h *Handshake) error {
+		h.Default()
+		h.Tracking(TrackingOpts{Redirect: id, BCast: true, Prefixes: []string{"noloop:"}, NoLoop: true})
+		return nil
+	}
 	conn, err := Connect(s.ctx, s.s.Addr(), opts)
-	s.r().Nil(conn)
-	s.r().Error(err)
-	s.r().True(redis.AsErrorx(err).IsOfType(ErrAuth))
-}
+	if err != nil && strings.Contains(err.Error(), "unknown subcommand") {
+		s.T().Skip("redis < 6.0")
+	}
+	s.r().Nil(err)
+	defer conn.Close()
 
-func (s *Suite) Test_justToCover() {
-	// this test just to increase code coverage
-	opts := defopts
-	opts.Handle = &struct{}{}
-	opts.IOTimeout = -1
-	opts.TCPKeepAlive = -1
+	s.Equal("OK", redis.Sync{conn}.Do("SET", "noloop:self", "1"))
+	s.Equal("OK", s.s.Do("SET", "noloop:other", "1"))
 
-	conn, err := Connect(nil, s.s.Addr(), opts)
-	s.r().Nil(conn)
-	s.r().Error(err)
-	conn, err = Connect(s.ctx, "", opts)
-	s.r().Nil(conn)
-	s.r().Error(err)
+	// invalidation of own write would arrive first.
+	inv.C.SetReadDeadline(time.Now().Add(time.Second))
+	msg, ok := redis.ReadResponse(inv.R).([]interface{})
+	s.r().True(ok)
+	s.r().Equal(3, len(msg))
+	s.Equal([]interface{}{[]byte("noloop:other")}, msg[2])
+}
 
-	conn, err = Connect(s.ctx, "tcp://"+s.s.Addr(), opts)
+func (s *Suite) TestRateLimit() {
+	opts := defopts
+	opts.RateLimit = 10
+	opts.RateBurst = 5
+	conn, err := Connect(s.ctx, s.s.Addr(), opts)
 	s.r().Nil(err)
 	defer conn.Close()
-	s.r().Equal("tcp://"+s.s.Addr(), conn.Addr())
-	s.r().NotNil(conn.Ctx())
-	s.r().NotEqual(s.ctx, conn.Ctx()) // because it is derived from
-	s.r().True(conn.MayBeConnected())
-	s.r().True(conn.ConnectedNow())
-	s.r().Equal(s.s.Addr(), conn.RemoteAddr())
-	s.r().True(strings.HasPrefix(conn.LocalAddr(), "127.0.0.1:"))
-	s.r().Equal(opts.Handle, conn.Handle())
-
-	var c cancelledFuture
-	conn.Send(redis.Req("GET", "a"), &c, 0)
-	s.r().Equal(1, c.cnt)
-	s.r().Error(redis.AsError(c.res))
-	conn.SendTransaction([]redis.Request{}, &c, 0)
-	s.r().Equal(2, c.cnt)
-	s.r().Error(redis.AsError(c.res))
 
-	conn.Send(redis.Req("GET", make(chan int)), nil, 0)
-	conn.SendMany([]redis.Request{redis.Req("GET", 1)}, nil, 0)
-}
+	// handshake doesn't consume tokens
+	for i := 0; i < 5; i++ {
+		s.Equal("PONG", redis.Sync{conn}.Do("PING"))
+	}
+	res := redis.Sync{conn}.Do("PING")
+	s.True(s.AsError(res).IsOfType(ErrRateLimited))
+	s.True(s.AsError(res).HasTrait(redis.ErrTraitNotSent))
 
-type cancelledFuture struct {
-	cnt int
-	res interface{}
+	time.Sleep(150 * time.Millisecond)
+	s.Equal("PONG", redis.Sync{conn}.Do("PING"))
 }
 
-func (c *cancelledFuture) Cancelled() error {
-	return errors.New("cancelled")
-}
+func (s *Suite) TestClientInfo() {
+	opts := defopts
+	opts.ClientName = "suite-client-info"
+	opts.DB = 1
+	conn, err := Connect(s.ctx, s.s.Addr(), opts)
+	s.r().Nil(err)
+	defer conn.Close()
 
-func (c *cancelledFuture) Resolve(res interface{}, n uint64) {
-	c.res = res
-	c.cnt++
+	info, err := conn.ClientInfo()
+	s.r().Nil(err)
+	s.Equal("suite-client-info", info.Name)
+	s.Equal(1, info.DB)
+	s.True(info.ID > 0)
 }
 
-func (s *Suite) TestSendMany_FailedWholeBatchBecauseOfOne() {
+func (s *Suite) TestLock() {
 	conn, err := Connect(s.ctx, s.s.Addr(), defopts)
 	s.r().Nil(err)
 	defer conn.Close()
 
-	results := redis.Sync{conn}.SendMany([]redis.Request{
-		redis.Req("GET", "a"),
-		redis.Req("GET", "b"),
-		redis.Req("DO_BAD_THING", make(chan int)),
-		redis.Req("SYNC"),
-	})
-	s.r().Len(results, 4)
-	for _, res := range results {
-		s.r().Error(redis.AsError(res))
-	}
-}
+	ok, err := conn.SetNX("lock", "token1", time.Second)
+	s.r().Nil(err)
+	s.True(ok)
+	ok, err = conn.SetNX("lock", "token2", time.Second)
+	s.r().Nil(err)
+	s.False(ok)
 
-func (s *Suite) TestStopped_DoesntConnectWithNegativeReconnectPause() {
-	s.s.Stop()
-	opts := defopts
-	opts.ReconnectPause = -1
-	_, err := Connect(s.ctx, s.s.Addr(), opts)
-	s.r().NotNil(err)
-	rerr := s.AsError(err)
-	s.True(rerr.IsOfType(ErrDial))
+	ok, err = conn.ReleaseLock("lock", "token2")
+	s.r().Nil(err)
+	s.False(ok)
+	ok, err = conn.ReleaseLock("lock", "token1")
+	s.r().Nil(err)
+	s.True(ok)
+	s.Nil(redis.Sync{conn}.Do("GET", "lock"))
 }
 
-func (s *Suite) TestStopped_Reconnects() {
-	s.s.Stop()
-
+func (s *Suite) TestSetGetValue() {
 	conn, err := Connect(s.ctx, s.s.Addr(), defopts)
 	s.r().Nil(err)
 	defer conn.Close()
 
-	s.badPing(conn, ErrNotConnected, 0)
-
-	s.s.Start()
-	s.waitReconnect(conn)
-
-	s.s.Stop()
-	time.Sleep(1 * time.Millisecond)
-	s.badPing(conn, ErrNotConnected, 0)
+	type value struct {
+		Name string
+		Tags []string
+	}
+	s.r().Nil(conn.SetValue("typed", value{"v", []string{"a", "b"}}))
+	var got value
+	ok, err := conn.GetValue("typed", &got)
+	s.r().Nil(err)
+	s.True(ok)
+	s.Equal(value{"v", []string{"a", "b"}}, got)
 
-	s.s.Start()
-	s.waitReconnect(conn)
+	redis.Sync{conn}.Do("DEL", "typed")
+	ok, err = conn.GetValue("typed", &got)
+	s.Nil(err)
+	s.False(ok)
 }
 
-func (s *Suite) TestStopped_Reconnects2() {
+func (s *Suite) TestDumpRestoreIdleTime() {
 	conn, err := Connect(s.ctx, s.s.Addr(), defopts)
 	s.r().Nil(err)
 	defer conn.Close()
 
-	s.goodPing(conn, 0)
-
-	s.s.Stop()
-	time.Sleep(1 * time.Millisecond)
-	s.badPing(conn, ErrNotConnected, 0)
-
-	s.s.Start()
-	s.waitReconnect(conn)
+	s.Equal("OK", redis.Sync{conn}.Do("SET", "dump:src", "\x00\xffvalue"))
+	payload, ok, err := redis.Dump(conn, "dump:src")
+	s.r().Nil(err)
+	s.True(ok)
 
-	s.s.Stop()
-	time.Sleep(1 * time.Millisecond)
-	s.badPing(conn, ErrNotConnected, 0)
+	s.Equal("OK", redis.Sync{conn}.Do("SET", "dump:dst", "old"))
+	err = redis.Restore(conn, "dump:dst", 0, payload, redis.RestoreOpts{Replace: true, IdleTime: time.Hour})
+	s.r().Nil(err)
+	s.Equal([]byte("\x00\xffvalue"), redis.Sync{conn}.Do("GET", "dump:dst"))
 
-	s.s.Start()
-	s.waitReconnect(conn)
+	// GET above touched the key, so restore it once more and inspect without reading value.
+	err = redis.Restore(conn, "dump:dst", 0, payload, redis.RestoreOpts{Replace: true, IdleTime: time.Hour})
+	s.r().Nil(err)
+	info, err := redis.ObjectInspect(conn, "dump:dst")
+	s.r().Nil(err)
+	s.True(info.IdleTime >= time.Hour, "%v", info.IdleTime)
 }
 
-func (s *Suite) TestTimeout() {
+func (s *Suite) TestXInfo() {
 	conn, err := Connect(s.ctx, s.s.Addr(), defopts)
 	s.r().Nil(err)
 	defer conn.Close()
 
-	s.goodPing(conn, 0)
+	redis.Sync{conn}.Do("DEL", "xinfo:events")
+	_, err = conn.XInfoStream("xinfo:events")
+	s.AsError(err).IsOfType(redis.ErrKeyNotFound)
 
-	s.s.Pause()
-	events := 0
-	start := time.Now()
-	for events != 7 {
-		res := s.ping(conn, 0)
-		rerr := s.AsError(res)
-		switch {
-		case rerr.IsOfType(redis.ErrIO):
-			events |= 1
-		case rerr.IsOfType(ErrConnSetup):
-			events |= 2
-		case rerr.IsOfType(ErrNotConnected):
-			events |= 4
-		}
-		s.r().WithinDuration(start, time.Now(), defopts.IOTimeout*10)
-	}
+	s.Equal([]byte("1-0"), redis.Sync{conn}.Do("XADD", "xinfo:events", "1-0", "a", "1"))
+	s.Equal([]byte("2-0"), redis.Sync{conn}.Do("XADD", "xinfo:events", "2-0", "b", "2"))
+	s.r().Nil(redis.StreamGroup{S: conn, Stream: "xinfo:events", Group: "workers"}.CreateGroup("0", false))
 
-	s.s.Resume()
-	s.waitReconnect(conn)
+	info, err := conn.XInfoStream("xinfo:events")
+	s.r().Nil(err)
+	s.Equal(int64(2), info.Length)
+	s.Equal(int64(1), info.Groups)
+	s.Equal("2-0", info.LastGeneratedID)
+	s.r().NotNil(info.FirstEntry)
+	s.Equal(map[string]string{"a": "1"}, info.FirstEntry.Fields)
+	s.r().NotNil(info.LastEntry)
+	s.Equal("2-0", info.LastEntry.ID)
+
+	groups, err := conn.XInfoGroups("xinfo:events")
+	s.r().Nil(err)
+	s.Equal(1, len(groups))
+	s.Equal("workers", groups[0].Name)
+	s.Equal("0-0", groups[0].LastDeliveredID)
 }
 
-func (s *Suite) TestTransaction() {
+func (s *Suite) TestLuaRateLimiter() {
 	conn, err := Connect(s.ctx, s.s.Addr(), defopts)
 	s.r().Nil(err)
 	defer conn.Close()
 
-	sconn := redis.SyncCtx{conn}
-
-	// transaction just works
-	res, err := sconn.SendTransaction(s.ctx, []redis.Request{
-		redis.Req("PING"),
-		redis.Req("PING", "asdf"),
-	})
-	s.Nil(err)
-	if s.IsType([]interface{}{}, res) && s.Len(res, 2) {
-		s.r().Equal("PONG", res[0])
-		s.r().Equal([]byte("asdf"), res[1])
+	l := redis.NewRateLimiter(conn, "ratelimit:")
+	redis.Sync{conn}.Do("DEL", "ratelimit:lua")
+	for i := 0; i < 3; i++ {
+		allowed, remaining, _, err := l.Allow("lua", 3, time.Second)
+		s.r().Nil(err)
+		s.True(allowed)
+		s.Equal(2-i, remaining)
 	}
+	allowed, _, retry, err := l.Allow("lua", 3, time.Second)
+	s.r().Nil(err)
+	s.False(allowed)
+	s.True(retry > 0 && retry <= 334*time.Millisecond, "%v", retry)
 
-	s.s.DoSure("SET", "tran:x", 1)
-
-	// transaction daesn't execute in case of wrong command
-	res, err = sconn.SendTransaction(s.ctx, []redis.Request{
-		redis.Req("INCR", "tran:x"),
-		redis.Req("PANG"),
-	})
-	s.NotNil(err)
-	rerr := s.AsError(err)
-	s.True(rerr.IsOfType(redis.ErrResult))
-	s.True(strings.HasPrefix(rerr.Message(), "EXECABORT"))
-
-	s.Equal([]byte("1"), s.s.DoSure("GET", "tran:x"))
+	time.Sleep(retry)
+	allowed, _, _, err = l.Allow("lua", 3, time.Second)
+	s.r().Nil(err)
+	s.True(allowed)
+}
 
-	// transaction is executed partially (that is redis's behavior):
-	// - first command executed well
-	// - second command returns with error.
-	res, err = sconn.SendTransaction(s.ctx, []redis.Request{
-		redis.Req("INCR", "tran:x"),
-		redis.Req("HSET", "tran:x", "y", "1"),
-	})
-	s.Nil(err)
-	if s.IsType([]interface{}{}, res) && s.Len(res, 2) {
-		s.r().Equal(int64(2), res[0])
-		rerr := s.AsError(res[1])
-		s.True(rerr.IsOfType(redis.ErrResult))
-		s.True(strings.HasPrefix(rerr.Message(), "WRONGTYPE"))
+func (s *Suite) TestDecodeHook() {
+	opts := defopts
+	opts.DecodeHook = func(req Request, raw interface{}) (interface{}, bool) {
+		key, _ := req.Key()
+		if req.Cmd != "GET" || !strings.HasPrefix(key, "num:") {
+			return nil, false
+		}
+		b, ok := raw.([]byte)
+		if !ok {
+			return nil, false
+		}
+		n, err := strconv.Atoi(string(b))
+		return n, err == nil
 	}
+	conn, err := Connect(s.ctx, s.s.Addr(), opts)
+	s.r().Nil(err)
+	defer conn.Close()
 
-	s.Equal([]byte("2"), s.s.DoSure("GET", "tran:x"))
+	sconn := redis.Sync{conn}
+	sconn.Do("SET", "num:1", "42")
+	sconn.Do("SET", "str:1", "42")
+	s.Equal(42, sconn.Do("GET", "num:1"))
+	s.Equal([]byte("42"), sconn.Do("GET", "str:1"))
+	s.Nil(sconn.Do("GET", "num:missing"))
+	s.Equal("OK", sconn.Do("SET", "num:2", "1"))
 }
 
-func (s *Suite) TestScan() {
+func (s *Suite) TestSubscribe() {
 	conn, err := Connect(s.ctx, s.s.Addr(), defopts)
 	s.r().Nil(err)
 	defer conn.Close()
 
-	sconn := redis.SyncCtx{conn}
-	for i := 0; i < 1000; i++ {
-		sconn.Do(s.ctx, "SET", "scan:"+strconv.Itoa(i), i)
-	}
+	ctx, cancel := context.WithCancel(s.ctx)
+	msgs, err := conn.Subscribe(ctx, "news", "sport")
+	s.r().Nil(err)
+	pmsgs, err := conn.PSubscribe(ctx, "n*")
+	s.r().Nil(err)
 
-	allkeys := make(map[string]struct{}, 1000)
-	for scanner := sconn.Scanner(s.ctx, redis.ScanOpts{Match: "scan:*"}); ; {
-		keys, err := scanner.Next()
-		if err != nil {
-			s.Equal(redis.ScanEOF, err)
-			break
-		}
-		for _, key := range keys {
-			_, ok := allkeys[key]
-			s.False(ok)
-			allkeys[key] = struct{}{}
-		}
+	res := redis.Sync{conn}.Do("SUBSCRIBE", "news")
+	s.True(s.AsError(res).IsOfType(redis.ErrCommandForbidden))
+
+	s.Equal(int64(2), redis.Sync{conn}.Do("PUBLISH", "news", "hello"))
+	s.Equal(Message{Channel: "news", Data: []byte("hello")}, <-msgs)
+	s.Equal(Message{Pattern: "n*", Channel: "news", Data: []byte("hello")}, <-pmsgs)
+
+	cancel()
+	for range msgs {
+	}
+	for range pmsgs {
 	}
-	s.Len(allkeys, 1000)
 }
 
 // stress test for "good case" when redis works without issues.
@@ -3,31 +3,125 @@ package redisconn
 import (
 	"io"
 	"net"
+	"strings"
+	"sync"
 	"time"
+
+	"github.com/joomcode/redispipe/redis"
 )
 
-// deadlineIO is a wrapper that sets read deadline before each Read.
+// deadlineIO is a wrapper that sets read deadline before each Read and write deadline before each Write.
 type deadlineIO struct {
-	to time.Duration
-	c  net.Conn
+	rto   time.Duration
+	wto   time.Duration
+	c     net.Conn
+	block *blockState
+}
+
+// blockState tracks blocking requests in flight (see Connection.mayBlock), so read deadline is extended
+// by their timeout.
+type blockState struct {
+	m sync.Mutex
+	// count is a number of blocking requests written and not answered yet.
+	count int
+	// until is a nownano() time of latest blocking request's timeout. Negative means "forever".
+	until int64
 }
 
-func newDeadlineIO(c net.Conn, to time.Duration) io.ReadWriter {
-	if to > 0 {
-		return &deadlineIO{c: c, to: to}
+// mayBlock returns true if request should be tracked by blockState: blocking commands are allowed only
+// in ScriptMode, but WAIT and WAITAOF are allowed always.
+func (conn *Connection) mayBlock(req Request) bool {
+	return conn.opts.ScriptMode || strings.EqualFold(req.Cmd, "WAIT") || strings.EqualFold(req.Cmd, "WAITAOF")
+}
+
+// written registers blocking request written to socket. It returns true if request is blocking.
+func (b *blockState) written(req Request) bool {
+	timeout, ok := redis.BlockingTimeout(req)
+	if !ok {
+		return false
+	}
+	until := int64(-1)
+	if timeout > 0 {
+		until = nownano() + int64(timeout)
+	}
+	b.m.Lock()
+	b.count++
+	if b.until >= 0 && (until < 0 || until > b.until) {
+		b.until = until
+	}
+	b.m.Unlock()
+	return true
+}
+
+// answered unregisters answered blocking request.
+func (b *blockState) answered(req Request) {
+	if _, ok := redis.BlockingTimeout(req); !ok {
+		return
+	}
+	b.m.Lock()
+	if b.count > 0 {
+		b.count--
+	}
+	if b.count == 0 {
+		b.until = 0
+	}
+	b.m.Unlock()
+}
+
+// idle returns true if there is no blocking requests in flight.
+func (b *blockState) idle() bool {
+	b.m.Lock()
+	defer b.m.Unlock()
+	return b.count == 0
+}
+
+func (b *blockState) reset() {
+	b.m.Lock()
+	b.count, b.until = 0, 0
+	b.m.Unlock()
+}
+
+// deadline returns extended read deadline.
+func (b *blockState) deadline(deadline time.Time, rto time.Duration) time.Time {
+	b.m.Lock()
+	defer b.m.Unlock()
+	switch {
+	case b.count == 0:
+		return deadline
+	case b.until < 0:
+		return time.Time{}
+	}
+	if ext := epoch.Add(time.Duration(b.until) + rto); ext.After(deadline) {
+		return ext
+	}
+	return deadline
+}
+
+func newDeadlineIO(c net.Conn, rto, wto time.Duration) io.ReadWriter {
+	if rto > 0 || wto > 0 {
+		return &deadlineIO{c: c, rto: rto, wto: wto}
 	}
 	return c
 }
 
 // Write implements io.Writer.
-// It doesn't set write deadline.
+// It sets write deadline before each call to Write (if write timeout is set).
 func (d *deadlineIO) Write(b []byte) (int, error) {
+	if d.wto > 0 {
+		d.c.SetWriteDeadline(time.Now().Add(d.wto))
+	}
 	return d.c.Write(b)
 }
 
 // Read implements io.Reader
-// It sets read deadline before each call to Read.
+// It sets read deadline before each call to Read (if read timeout is set).
 func (d *deadlineIO) Read(b []byte) (int, error) {
-	d.c.SetReadDeadline(time.Now().Add(d.to))
+	if d.rto > 0 {
+		deadline := time.Now().Add(d.rto)
+		if d.block != nil {
+			deadline = d.block.deadline(deadline, d.rto)
+		}
+		d.c.SetReadDeadline(deadline)
+	}
 	return d.c.Read(b)
 }
@@ -5,5 +5,11 @@ Connection is "wrapper" around a single tcp (unix-socket) connection. All reques
 single connection, and responses are asynchronously read from it.
 Connection is thread-safe, meaning it doesn't need external synchronization.
 Connect is responsible for reconnection, but it does not retry requests in the case of networking problems.
+
+Since socket is shared by all users of Connection, server side state of connection should not span several
+calls. SendTransaction writes MULTI, commands and EXEC as a single batch, so nothing is interleaved with them.
+Sequences which need own socket (WATCH followed by MULTI/EXEC, blocking commands) should use dedicated
+connection: derive it with Clone and ScriptMode set, and close it when sequence is done. Subscribe, PSubscribe,
+SSubscribe and Monitor open dedicated socket themselves.
 */
 package redisconn
@@ -0,0 +1,294 @@
+package redisconn
+
+import (
+	"bufio"
+	"net"
+	"sync/atomic"
+	"time"
+
+	"github.com/joomcode/redispipe/redis"
+)
+
+// drainTimeout returns bounded wait time for draining requests in flight.
+func (conn *Connection) drainTimeout() time.Duration {
+	if conn.opts.IOTimeout > 0 {
+		return conn.opts.IOTimeout
+	}
+	return time.Second
+}
+
+// drainAndReplace stops writer of old socket after it flushes already queued requests,
+// waits (no longer than timeout) for all written requests to be answered, and then
+// replaces socket with newConn (if newConn is not nil). Old socket is closed then.
+// Requests sent during drain are queued and will be written to new socket.
+// If timeout exceeded, requests in flight are resolved with ErrIO.
+// It is used for planned replacement of healthy socket (MaxConnLifetime), and by CloseGracefully
+// and Reset. Reconnect after socket failure doesn't drain, since broken socket will not be answered.
+//
+// If newConn is nil, old socket is left in place, so it could be closed or reused by caller.
+// Should be called without conn.mutex held: other users of connection are not blocked during drain.
+// If old is not current socket anymore (or connection is not connected) after drain, newConn is closed.
+func (conn *Connection) drainAndReplace(old *oneconn, newConn net.Conn, r *bufio.Reader, timeout time.Duration) bool {
+	if old == nil {
+		if newConn != nil {
+			newConn.Close()
+		}
+		return false
+	}
+
+	old.drainOnce.Do(func() { close(old.drain) })
+	drained := old.waitDrained(timeout)
+
+	if newConn == nil {
+		return drained
+	}
+
+	conn.mutex.Lock()
+	defer conn.mutex.Unlock()
+	if conn.one != old || atomic.LoadUint32(&conn.state) != connConnected {
+		// connection were re-established or closed meanwhile
+		newConn.Close()
+		return drained
+	}
+	oldc := conn.c
+	conn.start(newConn, r)
+	if conn.opts.OnReconnect != nil {
+		conn.opts.OnReconnect(1, oldc.RemoteAddr().String(), newConn.RemoteAddr().String(), nil)
+	}
+	conn.report(LogConnected{
+		LocalAddr:  newConn.LocalAddr().String(),
+		RemoteAddr: newConn.RemoteAddr().String(),
+	})
+	conn.wakeWriter()
+
+	// Socket is not current anymore, so setErr will not trigger reconnection.
+	// It just resolves remaining requests (if drain were not complete) with error.
+	old.setErr(redis.ErrIO.New("connection replaced"), conn)
+	oldc.Close()
+	return drained
+}
+
+// waitDrained waits (no longer than timeout) until writer is stopped by drain and all written requests
+// are answered. It returns false on timeout or if socket is broken (then all its requests are already
+// resolved with error).
+func (one *oneconn) waitDrained(timeout time.Duration) bool {
+	t := time.NewTimer(timeout)
+	defer t.Stop()
+	select {
+	case <-one.writerDone:
+	case <-one.control:
+		return false
+	case <-t.C:
+		return false
+	}
+	for atomic.LoadInt64(&one.inflight) != 0 {
+		select {
+		case <-one.idle:
+		case <-one.control:
+			return false
+		case <-t.C:
+			return false
+		}
+	}
+	return true
+}
+
+// wakeWriter wakes up writer if requests were queued (for example, during drain).
+func (conn *Connection) wakeWriter() {
+	conn.futmtx.Lock()
+	if len(conn.futures) != 0 {
+		select {
+		case conn.futsignal <- struct{}{}:
+		default:
+		}
+	}
+	conn.futmtx.Unlock()
+}
+
+// rotateIfExpired replaces socket if it is older than MaxConnLifetime.
+func (conn *Connection) rotateIfExpired() {
+	conn.mutex.Lock()
+	one := conn.one
+	conn.mutex.Unlock()
+	if one == nil || atomic.LoadUint32(&conn.state) != connConnected ||
+		time.Since(one.created) < conn.opts.MaxConnLifetime {
+		return
+	}
+
+	// establish new socket before draining, so requests will be delayed only for drain time.
+	newConn, r, err := conn.openConnection(conn.ctx, conn.liveOpts().ReadTimeout)
+	if err != nil {
+		// Will try on next tick. If server is really down, old socket will fail by itself.
+		return
+	}
+	conn.drainAndReplace(one, newConn, r, conn.drainTimeout())
+}
+
+// CloseGracefully stops accepting new requests, waits (no longer than timeout) for already sent
+// requests to be answered, and closes connection then.
+// If all requests were answered, QUIT is sent and its answer is awaited (within the same timeout),
+// so server sees clean disconnect.
+// It returns true if all requests were answered before timeout.
+func (conn *Connection) CloseGracefully(timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	conn.mutex.Lock()
+	one := conn.one
+	closing := atomic.CompareAndSwapUint32(&conn.state, connConnected, connClosing)
+	conn.mutex.Unlock()
+	drained := true
+	if closing {
+		drained = conn.drainAndReplace(one, nil, nil, timeout)
+		if drained {
+			one.rawRequest(redis.Req("QUIT"), deadline)
+		}
+	}
+	conn.Close()
+	return drained
+}
+
+// rawRequest writes req directly to drained socket and reads its answer (waiting not after deadline).
+// Writer should be stopped already, so reader exits on the answer (futures channel is closed)
+// leaving it in buffer, and answer is read from there. RESP3 push frames are skipped.
+// Reader is not restarted: socket should be closed, or reused with Connection.start.
+func (one *oneconn) rawRequest(req Request, deadline time.Time) interface{} {
+	if !time.Now().Before(deadline) {
+		return redis.ErrIO.New("deadline exceeded")
+	}
+	buf, err := redis.AppendRequest(nil, req)
+	if err != nil {
+		return err
+	}
+	one.c.SetDeadline(deadline)
+	defer one.c.SetDeadline(time.Time{})
+	if _, err := one.w.Write(buf); err != nil {
+		return redis.ErrIO.WrapWithNoMessage(err)
+	}
+	t := time.NewTimer(time.Until(deadline))
+	defer t.Stop()
+	select {
+	case <-one.readerDone:
+	case <-t.C:
+		return redis.ErrIO.New("deadline exceeded")
+	}
+	for {
+		head, err := one.r.Peek(1)
+		if err != nil {
+			return redis.ErrIO.WrapWithNoMessage(err)
+		}
+		res := redis.ReadResponse(one.r)
+		if head[0] != '>' {
+			return res
+		}
+	}
+}
+
+// StopAccepting makes connection to reject new requests with ErrContextClosed.
+// Already queued requests are still sent and answered, so it could be used on shutdown
+// together with Idle: stop accepting, wait for Idle, then Close.
+func (conn *Connection) StopAccepting() {
+	atomic.StoreUint32(&conn.refuse, 1)
+}
+
+// Pending returns number of requests queued or in flight and not answered yet
+// (including auxiliary ASKING and MULTI).
+func (conn *Connection) Pending() int {
+	return int(atomic.LoadInt64(&conn.pending))
+}
+
+// InFlightCount returns number of requests written to current socket and not answered yet.
+// Unlike Pending, it doesn't count requests queued for writing, so it tells slow server
+// (InFlightCount grows) from slow writing (Pending grows while InFlightCount doesn't).
+// It is 0 if socket is not established.
+func (conn *Connection) InFlightCount() int {
+	conn.mutex.Lock()
+	one := conn.one
+	conn.mutex.Unlock()
+	if one == nil {
+		return 0
+	}
+	return int(atomic.LoadInt64(&one.inflight))
+}
+
+// Idle returns channel which is closed when connection has no pending requests,
+// or when connection is closed.
+// Note: if new requests are still accepted, connection could become busy again right after.
+func (conn *Connection) Idle() <-chan struct{} {
+	conn.idlemtx.Lock()
+	defer conn.idlemtx.Unlock()
+	if conn.Pending() == 0 || conn.ctx.Err() != nil {
+		ch := make(chan struct{})
+		close(ch)
+		return ch
+	}
+	if conn.idle == nil {
+		conn.idle = make(chan struct{})
+	}
+	return conn.idle
+}
+
+// expireQueuedWhileConnecting starts periodic expiration of requests queued longer than
+// QueueWhileConnecting. Returned function stops it.
+func (conn *Connection) expireQueuedWhileConnecting() func() {
+	limit := conn.opts.QueueWhileConnecting
+	if limit <= 0 {
+		return func() {}
+	}
+	period := limit / 4
+	if period < time.Millisecond {
+		period = time.Millisecond
+	}
+	t := time.NewTicker(period)
+	done := make(chan struct{})
+	go func() {
+		defer t.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-t.C:
+				conn.expireQueued(nownano() - int64(limit))
+			}
+		}
+	}()
+	return func() { close(done) }
+}
+
+// expireQueued resolves requests queued before cutoff with ErrNotConnected.
+// Auxiliary requests (ASKING, MULTI) are expired together with request following them.
+func (conn *Connection) expireQueued(cutoff int64) {
+	var expired []future
+	conn.futmtx.Lock()
+	if atomic.LoadUint32(&conn.state) != connConnecting {
+		conn.futmtx.Unlock()
+		return
+	}
+	keep := conn.futures[:0]
+	group := 0
+	for i, fut := range conn.futures {
+		if fut.start == 0 {
+			group++
+			continue
+		}
+		if fut.start < cutoff {
+			expired = append(expired, conn.futures[i-group:i+1]...)
+			conn.futbytes -= conn.requestSize(fut.req)
+		} else {
+			keep = append(keep, conn.futures[i-group:i+1]...)
+		}
+		group = 0
+	}
+	keep = append(keep, conn.futures[len(conn.futures)-group:]...)
+	for i := len(keep); i < len(conn.futures); i++ {
+		conn.futures[i] = future{}
+	}
+	conn.futures = keep
+	conn.futmtx.Unlock()
+
+	if len(expired) == 0 {
+		return
+	}
+	err := conn.err(ErrNotConnected).WithProperty(EKQueueTime, conn.opts.QueueWhileConnecting)
+	for _, fut := range expired {
+		conn.resolve(fut, err.WithProperty(redis.EKRequest, fut.req))
+	}
+}
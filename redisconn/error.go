@@ -19,6 +19,31 @@ var (
 	ErrInit = ErrConnection.NewType("initialization_error", ErrTraitInitPermanent)
 	// ErrConnSetup - other connection initialization error (including io errors)
 	ErrConnSetup = ErrConnection.NewType("initialization_temp_error")
+	// ErrDropped - request were queued, but connection broke before request were written to socket.
+	// Request is definitely not sent, so it could be safely retried. Cause is a connection error.
+	ErrDropped = ErrConnection.NewType("request_dropped")
+	// ErrTLSVerify - TLS certificate verification failed: server certificate were rejected by Opts.TLSConfig
+	// (including its VerifyPeerCertificate and VerifyConnection), or server rejected client certificate.
+	// It is not transient, so reconnect policy could back off instead of retrying quickly.
+	ErrTLSVerify = ErrConnection.NewType("tls_verify_failed", ErrTraitInitPermanent)
+	// ErrCircuitOpen - request is rejected because circuit breaker is open (see Opts.CircuitBreakerFailures).
+	ErrCircuitOpen = ErrConnection.NewType("circuit_open")
+	// ErrReconnectLimit - connection is closed after Opts.MaxReconnectAttempts failed connection attempts.
+	ErrReconnectLimit = ErrConnection.NewType("reconnect_limit_exceeded")
+
+	// ErrClientName - Opts.ClientName contains spaces or special characters, so server would reject it.
+	ErrClientName = redis.ErrOpts.NewType("bad_client_name")
+
+	// ErrBufferFull - too many bytes or requests are queued and not written yet
+	// (see Opts.MaxPendingBytes and Opts.MaxQueuedRequests).
+	// Request is not sent.
+	ErrBufferFull = redis.Errors.NewType("buffer_full", redis.ErrTraitNotSent)
+	// ErrRateLimited - request exceeds rate limit (see Opts.RateLimit). Request is not sent.
+	ErrRateLimited = redis.Errors.NewType("rate_limited", redis.ErrTraitNotSent)
+	// ErrServerClosed - server closed socket cleanly between responses (eg CLIENT KILL).
+	// It is a subtype of redis.ErrIO: requests in flight could be executed or not.
+	// See Opts.ServerClosedPause.
+	ErrServerClosed = redis.ErrIO.NewSubtype("server_closed")
 
 	// ErrTraitInitPermanent signals about non-transient error in initial communication with redis.
 	// It means that either authentication fails or selected database doesn't exists or redis
@@ -31,6 +56,14 @@ var (
 	EKConnection = errorx.RegisterProperty("connection")
 	// EKDb - db number to select.
 	EKDb = errorx.RegisterPrintableProperty("db")
+	// EKQueueTime - limit of waiting for connection which request exceeded.
+	EKQueueTime = errorx.RegisterPrintableProperty("queue_time")
+	// EKPendingBytes - approximate size of queued requests.
+	EKPendingBytes = errorx.RegisterPrintableProperty("pending_bytes")
+	// EKQueuedRequests - number of requests queued and not written yet.
+	EKQueuedRequests = errorx.RegisterPrintableProperty("queued_requests")
+	// EKAttempts - number of failed connection attempts.
+	EKAttempts = errorx.RegisterPrintableProperty("attempts")
 )
 
 func withNewProperty(err *errorx.Error, p errorx.Property, v interface{}) *errorx.Error {
@@ -0,0 +1,283 @@
+package redisconn
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/joomcode/errorx"
+
+	"github.com/joomcode/redispipe/redis"
+)
+
+// Handshake is an initial conversation with redis over freshly established socket.
+// Requests are queued with Auth, Ping, Select and Do, and are written together (pipelined) on Flush.
+// Handshake is used by Connection to set up every socket, and it is passed to Opts.Handshake
+// for custom connection setup.
+type Handshake struct {
+	conn *Connection
+	c    net.Conn
+	w    io.Writer
+	r    *bufio.Reader
+	// timeout for writing requests
+	timeout time.Duration
+	req     []byte
+	checks  []func(res interface{}) error
+	err     error
+	// proto is a protocol negotiated with HELLO (2 if HELLO is not sent or rejected).
+	proto int
+	// hello is true if last HELLO were accepted.
+	hello bool
+}
+
+// Do queues request. check is called with its response on Flush. If check returns error
+// which is not *errorx.Error, it is wrapped with ErrInit.
+// If check is nil, any redis error is considered as failure (and is wrapped with ErrInit).
+func (h *Handshake) Do(req Request, check func(res interface{}) error) {
+	if h.err != nil {
+		return
+	}
+	var err error
+	if h.req, err = redis.AppendRequest(h.req, req); err != nil {
+		h.err = h.conn.addProps(err.(*errorx.Error))
+		return
+	}
+	if check == nil {
+		check = func(res interface{}) error {
+			if err := redis.AsErrorx(res); err != nil {
+				return h.conn.errWrap(ErrInit, err)
+			}
+			return nil
+		}
+	}
+	h.checks = append(h.checks, check)
+}
+
+// Auth queues AUTH request. Error response is returned from Flush as ErrAuth.
+func (h *Handshake) Auth(password string) {
+	h.AuthUser("", password)
+}
+
+// AuthUser queues AUTH request with username (redis >= 6.0 ACL). If username is empty, only password is sent.
+// Error response is returned from Flush as ErrAuth.
+func (h *Handshake) AuthUser(username, password string) {
+	h.Do(authRequest(username, password), func(res interface{}) error {
+		if err := redis.AsErrorx(res); err != nil {
+			return h.conn.errWrap(ErrAuth, err)
+		}
+		return nil
+	})
+}
+
+func authRequest(username, password string) Request {
+	if username == "" {
+		return redis.Req("AUTH", password)
+	}
+	return redis.Req("AUTH", username, password)
+}
+
+// SetName queues CLIENT SETNAME request.
+func (h *Handshake) SetName(name string) {
+	h.Do(redis.Req("CLIENT SETNAME", name), nil)
+}
+
+// Ping queues PING request and checks it is answered with PONG.
+func (h *Handshake) Ping() {
+	h.Do(redis.Req("PING"), func(res interface{}) error {
+		if err := redis.AsErrorx(res); err != nil {
+			return h.conn.errWrap(ErrInit, err)
+		}
+		if str, ok := res.(string); !ok || str != "PONG" {
+			return h.conn.addProps(ErrInit.New("ping response mismatch")).
+				WithProperty(redis.EKResponse, res)
+		}
+		return nil
+	})
+}
+
+// Hello queues HELLO request switching connection to protocol proto (2 or 3).
+// If server rejects HELLO (redis < 6.0 doesn't know it), connection stays on RESP2 without error.
+// Negotiated protocol is returned by Connection.Protocol.
+// Note: HELLO should be sent after AUTH if server requires password.
+func (h *Handshake) Hello(proto int) {
+	h.HelloAuth(proto, "", "", "")
+}
+
+// HelloAuth queues HELLO request which also authenticates (if password is not empty) and sets client name
+// (if name is not empty), so socket is set up in single round trip. Empty username means "default" user.
+// Authentication failure is returned from Flush as ErrAuth. If server rejects HELLO by other reason
+// (redis < 6.0, or protocol is not supported), it is not an error: connection stays on RESP2,
+// and HelloAccepted returns false, so AUTH and SETNAME should be sent separately.
+func (h *Handshake) HelloAuth(proto int, username, password, name string) {
+	args := []interface{}{proto}
+	if password != "" {
+		if username == "" {
+			username = "default"
+		}
+		args = append(args, "AUTH", username, password)
+	}
+	if name != "" {
+		args = append(args, "SETNAME", name)
+	}
+	h.Do(redis.Request{Cmd: "HELLO", Args: args}, func(res interface{}) error {
+		h.hello = false
+		if err := redis.AsErrorx(res); err != nil {
+			if err.IsOfType(redis.ErrResult) {
+				if password != "" && redis.ErrorPrefix(err) == "WRONGPASS" {
+					return h.conn.errWrap(ErrAuth, err)
+				}
+				h.proto = 2
+				return nil
+			}
+			return h.conn.errWrap(ErrInit, err)
+		}
+		// reply is a map of server properties, including negotiated "proto".
+		h.hello = true
+		h.proto = proto
+		if arr, ok := res.([]interface{}); ok {
+			for i := 0; i+1 < len(arr); i += 2 {
+				if key, _ := redis.ArgToString(arr[i]); key == "proto" {
+					if v, ok := arr[i+1].(int64); ok {
+						h.proto = int(v)
+					}
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// HelloAccepted returns true if last HELLO were accepted by server.
+// It is valid after Flush.
+func (h *Handshake) HelloAccepted() bool {
+	return h.hello
+}
+
+// Select queues SELECT request.
+func (h *Handshake) Select(db int) {
+	h.Do(redis.Req("SELECT", db), func(res interface{}) error {
+		if err := redis.AsErrorx(res); err != nil {
+			return h.conn.errWrap(ErrInit, err).WithProperty(EKDb, db)
+		}
+		if str, ok := res.(string); !ok || str != "OK" {
+			return h.conn.addProps(ErrInit.New("SELECT db response mismatch")).
+				WithProperty(EKDb, db).
+				WithProperty(redis.EKResponse, res)
+		}
+		return nil
+	})
+}
+
+// Default queues default handshake: AUTH (if Opts.Password is set), CLIENT SETNAME (if Opts.ClientName is set),
+// PING and SELECT (if Opts.DB or database set with Connection.Select is not 0).
+// If Opts.Protocol is 3, then single HELLO 3 with AUTH and SETNAME is flushed first instead of AUTH, SETNAME
+// and PING, and they are queued only if server rejects HELLO.
+func (h *Handshake) Default() {
+	opts := h.conn.liveOpts()
+	if opts.Protocol == 3 {
+		h.HelloAuth(3, opts.Username, opts.Password, opts.ClientName)
+		if err := h.Flush(); err != nil {
+			h.err = err
+			return
+		}
+	}
+	if !h.hello {
+		if opts.Password != "" {
+			h.AuthUser(opts.Username, opts.Password)
+		}
+		if opts.ClientName != "" {
+			h.SetName(opts.ClientName)
+		}
+		h.Ping()
+	}
+	if db := h.conn.DB(); db != 0 {
+		h.Select(db)
+	}
+}
+
+// Flush writes queued requests and checks their responses.
+// It returns first failed check's error, or ErrConnSetup on io error.
+// All responses are read even if some check failed.
+func (h *Handshake) Flush() error {
+	if h.err != nil {
+		return h.err
+	}
+	if len(h.checks) == 0 {
+		return nil
+	}
+	req, checks := h.req, h.checks
+	h.req, h.checks = h.req[:0], nil
+
+	// Force timeout
+	h.c.SetWriteDeadline(time.Now().Add(h.timeout))
+	if _, err := h.w.Write(req); err != nil {
+		return h.conn.errWrap(ErrConnSetup, err)
+	}
+	// Disarm timeout
+	h.c.SetWriteDeadline(time.Time{})
+
+	var failed error
+	for _, check := range checks {
+		res := redis.ReadResponse(h.r)
+		if err := redis.AsErrorx(res); err != nil && err.IsOfType(redis.ErrIO) {
+			return h.conn.errWrap(ErrConnSetup, err)
+		}
+		if err := check(res); err != nil && failed == nil {
+			if _, ok := err.(*errorx.Error); !ok {
+				err = h.conn.errWrap(ErrInit, err)
+			}
+			failed = err
+		}
+	}
+	return failed
+}
+
+// clientSeq is a counter for {id} placeholder of Opts.ClientName.
+var clientSeq uint64
+
+// expandClientName substitutes placeholders of Opts.ClientName and checks result is acceptable
+// by CLIENT SETNAME.
+func expandClientName(name string, handle interface{}) (string, error) {
+	if strings.Contains(name, "{") {
+		var pairs []string
+		if strings.Contains(name, "{host}") {
+			host, _ := os.Hostname()
+			pairs = append(pairs, "{host}", sanitizeClientName(host))
+		}
+		if strings.Contains(name, "{id}") {
+			pairs = append(pairs, "{id}", strconv.FormatUint(atomic.AddUint64(&clientSeq, 1), 10))
+		}
+		if strings.Contains(name, "{handle}") {
+			h := ""
+			if handle != nil {
+				h = fmt.Sprint(handle)
+			}
+			pairs = append(pairs, "{handle}", sanitizeClientName(h))
+		}
+		if pairs != nil {
+			name = strings.NewReplacer(pairs...).Replace(name)
+		}
+	}
+	for i := 0; i < len(name); i++ {
+		if name[i] < '!' || name[i] > '~' {
+			return "", ErrClientName.New("client name %q should not contain spaces, newlines or special characters", name)
+		}
+	}
+	return name, nil
+}
+
+// sanitizeClientName replaces characters rejected by CLIENT SETNAME with '_'.
+func sanitizeClientName(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r < '!' || r > '~' {
+			return '_'
+		}
+		return r
+	}, s)
+}
@@ -1,6 +1,10 @@
 package redisconn
 
-import "log"
+import (
+	"log"
+	"sync/atomic"
+	"time"
+)
 
 // Logger is a type for custom event and stat reporter.
 type Logger interface {
@@ -45,14 +49,94 @@ type LogContextClosed struct {
 	Error error // - ctx.Err()
 }
 
-func (LogConnecting) logEvent()    {}
-func (LogConnected) logEvent()     {}
-func (LogConnectFailed) logEvent() {}
-func (LogDisconnected) logEvent()  {}
-func (LogContextClosed) logEvent() {}
+// LogPossiblyPaused is logged when write command is answered slower than Opts.PausedWriteThreshold
+// while reads are answered fast. Probably, writes are paused with CLIENT PAUSE WRITE.
+// It is reported at most once per second.
+type LogPossiblyPaused struct {
+	Cmd     string        // - slow write command
+	Latency time.Duration // - its latency
+}
+
+// LogHealthCheckFailed is logged when Opts.HealthCheck fails with error which is not connectivity error.
+type LogHealthCheckFailed struct {
+	Error error // - failure reason
+}
+
+// LogSlowRequest is logged when request is answered slower than Opts.SlowLogThreshold.
+// Latency includes time request spent in queue before it were written.
+type LogSlowRequest struct {
+	Cmd     string        // - command name (arguments are not reported)
+	Latency time.Duration // - time from enqueueing to answer
+}
+
+// LogCircuitOpen is logged when circuit breaker is opened (see Opts.CircuitBreakerFailures).
+type LogCircuitOpen struct {
+	Failures int   // - number of consecutive failures
+	Error    error // - last failure
+}
+
+func (LogConnecting) logEvent()        {}
+func (LogConnected) logEvent()         {}
+func (LogConnectFailed) logEvent()     {}
+func (LogDisconnected) logEvent()      {}
+func (LogContextClosed) logEvent()     {}
+func (LogPossiblyPaused) logEvent()    {}
+func (LogHealthCheckFailed) logEvent() {}
+func (LogSlowRequest) logEvent()       {}
+func (LogCircuitOpen) logEvent()       {}
+
+// Event is a connection event with timestamp, as delivered through Connection.Events().
+type Event struct {
+	Time  time.Time
+	Event LogEvent
+	// Error is an error associated with event (if any).
+	Error error
+}
+
+// eventsBuffer is a capacity of Connection.Events() channel.
+const eventsBuffer = 64
+
+// Events returns channel of connection events (the same events passed to Logger.Report).
+// Slow consumer never blocks connection: if channel's buffer is full, oldest event is dropped
+// to make room for new one, so consumer sees most recent state (see EventsDropped).
+// Channel is never closed: LogContextClosed event signals connection is closed.
+func (conn *Connection) Events() <-chan Event {
+	return conn.events
+}
+
+// EventsDropped returns number of events dropped because Events() channel were full.
+func (conn *Connection) EventsDropped() uint64 {
+	return atomic.LoadUint64(&conn.eventsDropped)
+}
 
 func (conn *Connection) report(event LogEvent) {
 	conn.opts.Logger.Report(conn, event)
+
+	ev := Event{Time: time.Now(), Event: event}
+	switch e := event.(type) {
+	case LogConnectFailed:
+		ev.Error = e.Error
+	case LogDisconnected:
+		ev.Error = e.Error
+	case LogContextClosed:
+		ev.Error = e.Error
+	case LogHealthCheckFailed:
+		ev.Error = e.Error
+	case LogCircuitOpen:
+		ev.Error = e.Error
+	}
+	for {
+		select {
+		case conn.events <- ev:
+			return
+		default:
+		}
+		select {
+		case <-conn.events:
+			atomic.AddUint64(&conn.eventsDropped, 1)
+		default:
+		}
+	}
 }
 
 // DefaultLogger is default implementation of Logger
@@ -73,6 +157,15 @@ func (d DefaultLogger) Report(conn *Connection, event LogEvent) {
 			ev.LocalAddr, ev.RemoteAddr, ev.Error.Error())
 	case LogContextClosed:
 		log.Printf("redis: connect to %s explicitly closed: %s", conn.Addr(), ev.Error.Error())
+	case LogPossiblyPaused:
+		log.Printf("redis: writes to %s are possibly paused: %s took %s", conn.Addr(), ev.Cmd, ev.Latency)
+	case LogSlowRequest:
+		log.Printf("redis: slow request to %s: %s took %s", conn.Addr(), ev.Cmd, ev.Latency)
+	case LogHealthCheckFailed:
+		log.Printf("redis: health check of %s failed: %s", conn.Addr(), ev.Error.Error())
+	case LogCircuitOpen:
+		log.Printf("redis: circuit breaker of %s is open after %d failures: %s", conn.Addr(), ev.Failures,
+			ev.Error.Error())
 	default:
 		log.Printf("redis: unexpected event: %#v", event)
 	}
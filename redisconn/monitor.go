@@ -0,0 +1,84 @@
+package redisconn
+
+import (
+	"context"
+	"time"
+
+	"github.com/joomcode/redispipe/redis"
+)
+
+// Monitor opens dedicated connection to the same redis instance, sends MONITOR command
+// and streams all following server's replies (ie. logged commands) into returned channel.
+//
+// MONITOR switches connection into streaming mode, so it could not share pipelined connection:
+// that is why separate socket is used. Channel is closed when ctx is done, when Connection is closed,
+// or when dedicated connection breaks. Monitor connection is not re-established.
+//
+// Slow reader will block streaming, and redis will buffer output for monitor client,
+// so channel should be drained promptly.
+func (conn *Connection) Monitor(ctx context.Context) (<-chan string, error) {
+	if ctx == nil {
+		return nil, conn.err(redis.ErrContextIsNil)
+	}
+	if conn.ctx.Err() != nil {
+		return nil, conn.err(ErrNotConnected)
+	}
+
+	connection, r, err := conn.openConnection(ctx, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	req, _ := redis.AppendRequest(nil, redis.Req("MONITOR"))
+	if conn.opts.IOTimeout > 0 {
+		connection.SetDeadline(time.Now().Add(conn.opts.IOTimeout))
+	}
+	if _, err := connection.Write(req); err != nil {
+		connection.Close()
+		return nil, conn.errWrap(ErrConnSetup, err)
+	}
+	res := redis.ReadResponse(r)
+	if err := redis.AsErrorx(res); err != nil {
+		connection.Close()
+		return nil, conn.addProps(err)
+	}
+	if str, ok := res.(string); !ok || str != "OK" {
+		connection.Close()
+		return nil, conn.addProps(redis.ErrResponseUnexpected.New("MONITOR response mismatch")).
+			WithProperty(redis.EKResponse, res)
+	}
+	connection.SetDeadline(time.Time{})
+
+	ch := make(chan string, 128)
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-conn.ctx.Done():
+		case <-done:
+		}
+		connection.Close()
+	}()
+	go func() {
+		defer close(ch)
+		defer close(done)
+		for {
+			res := redis.ReadResponse(r)
+			if redis.AsError(res) != nil {
+				return
+			}
+			line, ok := res.(string)
+			if !ok {
+				continue
+			}
+			select {
+			case ch <- line:
+			case <-ctx.Done():
+				return
+			case <-conn.ctx.Done():
+				return
+			}
+		}
+	}()
+	return ch, nil
+}
@@ -0,0 +1,349 @@
+package redisconn
+
+import (
+	"context"
+	"net"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/joomcode/redispipe/redis"
+	"github.com/joomcode/redispipe/rediscluster/redisclusterutil"
+)
+
+// Message is a message received through subscription.
+type Message struct {
+	// Pattern is a matched pattern (for PSubscribe only).
+	Pattern string
+	// Channel is a channel message were published to.
+	Channel string
+	// Data is a message payload.
+	Data []byte
+	// Keys are invalidated keys, if message is published to InvalidateChannel.
+	Keys []string
+	// InvalidateAll is set for message published to InvalidateChannel, which invalidates all keys.
+	InvalidateAll bool
+}
+
+// PubSubPolicy is a policy of message delivery when channel returned by Subscribe is full
+// (see Opts.PubSubPolicy).
+type PubSubPolicy int
+
+const (
+	// PubSubDropOldest - oldest message in channel is dropped to make room for new one.
+	// So consumer which falls behind sees most recent messages.
+	PubSubDropOldest PubSubPolicy = iota
+	// PubSubDropNewest - new message is dropped, and channel keeps older ones.
+	PubSubDropNewest
+	// PubSubBlock - reading from socket is paused until consumer receives message. No message is lost,
+	// but redis buffers output for slow subscriber, and disconnects it if client-output-buffer-limit
+	// for pubsub is exceeded.
+	PubSubBlock
+)
+
+// defaultPubSubBuffer is a default of Opts.PubSubBuffer.
+const defaultPubSubBuffer = 128
+
+// Subscribe subscribes to channels (SUBSCRIBE) and streams published messages into returned channel.
+//
+// Subscribed connection could not execute regular commands, so subscription uses dedicated socket
+// (like Monitor does). Dedicated socket is checked with PING as regular one, so half-open socket is
+// detected. Channel is closed when ctx is done, when Connection is closed, when dedicated
+// connection breaks, or when server drops all subscriptions. Subscription is not re-established.
+// When ctx is done, subscription is cancelled with UNSUBSCRIBE (PUNSUBSCRIBE, SUNSUBSCRIBE), and socket
+// is closed after server confirms it (or after IOTimeout, if socket is already dead). Messages received
+// meanwhile are dropped.
+//
+// Channel has capacity of Opts.PubSubBuffer. When it is full, messages are dropped according to
+// Opts.PubSubPolicy (oldest one by default), so slow consumer never stalls reading from socket;
+// number of dropped messages is returned by DroppedMessages. With PubSubBlock policy nothing is dropped,
+// but slow consumer blocks reading from socket, and redis buffers output for subscriber (and disconnects
+// it if client-output-buffer-limit for pubsub is exceeded).
+func (conn *Connection) Subscribe(ctx context.Context, channels ...string) (<-chan Message, error) {
+	return conn.subscribe(ctx, "SUBSCRIBE", [][]string{channels})
+}
+
+// PSubscribe subscribes to channel patterns (PSUBSCRIBE). See Subscribe for details.
+func (conn *Connection) PSubscribe(ctx context.Context, patterns ...string) (<-chan Message, error) {
+	return conn.subscribe(ctx, "PSUBSCRIBE", [][]string{patterns})
+}
+
+// SSubscribe subscribes to shard channels (SSUBSCRIBE, redis >= 7.0). See Subscribe for details.
+// Server accepts only channels of the same slot in one SSUBSCRIBE, so channels are grouped by slot,
+// and SSUBSCRIBE is sent for every group through the same dedicated socket.
+// In cluster, server drops subscription to channels of slot migrated to other node; returned channel
+// is closed then (even if channels of other slots are still subscribed), so caller could re-subscribe
+// all channels on their current owners.
+func (conn *Connection) SSubscribe(ctx context.Context, channels ...string) (<-chan Message, error) {
+	var groups [][]string
+	bySlot := make(map[uint16]int)
+	for _, ch := range channels {
+		slot := redisclusterutil.Slot(ch)
+		i, ok := bySlot[slot]
+		if !ok {
+			i = len(groups)
+			bySlot[slot] = i
+			groups = append(groups, nil)
+		}
+		groups[i] = append(groups[i], ch)
+	}
+	return conn.subscribe(ctx, "SSUBSCRIBE", groups)
+}
+
+// subscribe sends cmd for every group of names through dedicated socket, and streams messages.
+func (conn *Connection) subscribe(ctx context.Context, cmd string, groups [][]string) (<-chan Message, error) {
+	if ctx == nil {
+		return nil, conn.err(redis.ErrContextIsNil)
+	}
+	if conn.ctx.Err() != nil {
+		return nil, conn.closedErr()
+	}
+	var req []byte
+	var requests []redis.Request
+	for _, names := range groups {
+		args := make([]interface{}, len(names))
+		for i, name := range names {
+			args[i] = name
+		}
+		request := redis.Request{Cmd: cmd, Args: args}
+		if len(names) == 0 {
+			return nil, conn.addProps(redis.ErrArgumentType.New("nothing to subscribe")).
+				WithProperty(redis.EKRequest, request)
+		}
+		req, _ = redis.AppendRequest(req, request)
+		requests = append(requests, request)
+	}
+	if len(requests) == 0 {
+		return nil, conn.addProps(redis.ErrArgumentType.New("nothing to subscribe")).
+			WithProperty(redis.EKRequest, redis.Request{Cmd: cmd})
+	}
+
+	connection, r, err := conn.openConnection(ctx, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	if conn.opts.IOTimeout > 0 {
+		connection.SetDeadline(time.Now().Add(conn.opts.IOTimeout))
+	}
+	if _, err := connection.Write(req); err != nil {
+		connection.Close()
+		return nil, conn.errWrap(ErrConnSetup, err)
+	}
+	// every channel is confirmed with separate reply.
+	for i, names := range groups {
+		for range names {
+			res := redis.ReadResponse(r)
+			if err := redis.AsErrorx(res); err != nil {
+				connection.Close()
+				return nil, conn.addProps(err).WithProperty(redis.EKRequest, requests[i])
+			}
+			if kind, _ := pubsubKind(res); kind != strings.ToLower(cmd) {
+				connection.Close()
+				return nil, conn.addProps(redis.ErrResponseUnexpected.New("%s response mismatch", cmd)).
+					WithProperty(redis.EKResponse, res)
+			}
+		}
+	}
+	connection.SetDeadline(time.Time{})
+
+	size := conn.opts.PubSubBuffer
+	if size <= 0 {
+		size = defaultPubSubBuffer
+	}
+	ch := make(chan Message, size)
+	done := make(chan struct{})
+	active := make(map[string]struct{})
+	for _, names := range groups {
+		for _, name := range names {
+			active[name] = struct{}{}
+		}
+	}
+	conn.trackSubscription(active, 1)
+	// lastRead is nownano() of last reply, it is checked by keepalive.
+	lastRead := nownano()
+	go func() {
+		defer connection.Close()
+		interval := conn.pingInterval()
+		t := time.NewTicker(interval)
+		defer t.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				conn.unsubscribe(connection, cmd, done)
+				return
+			case <-conn.ctx.Done():
+				return
+			case <-done:
+				return
+			case <-t.C:
+			}
+			// socket is considered dead if PING is not answered within 3 intervals.
+			if nownano()-atomic.LoadInt64(&lastRead) > int64(3*interval) {
+				return
+			}
+			if !conn.pingSubscription(connection, interval) {
+				return
+			}
+		}
+	}()
+	go func() {
+		defer close(ch)
+		defer close(done)
+		defer func() { conn.trackSubscription(active, -1) }()
+		for {
+			res := redis.ReadResponse(r)
+			if redis.AsError(res) != nil {
+				return
+			}
+			atomic.StoreInt64(&lastRead, nownano())
+			kind, arr := pubsubKind(res)
+			var msg Message
+			switch {
+			case (kind == "message" || kind == "smessage") && len(arr) == 3:
+				msg.Channel, _ = redis.ArgToString(arr[1])
+				msg.Data, _ = arr[2].([]byte)
+				if msg.Channel == InvalidateChannel {
+					msg.Keys, msg.InvalidateAll = invalidatedKeys(arr[2])
+				}
+			case kind == "pmessage" && len(arr) == 4:
+				msg.Pattern, _ = redis.ArgToString(arr[1])
+				msg.Channel, _ = redis.ArgToString(arr[2])
+				msg.Data, _ = arr[3].([]byte)
+			case strings.HasSuffix(kind, "unsubscribe") && len(arr) == 3:
+				// server dropped subscription (for example, slot were migrated).
+				// Shard subscription is closed as whole, so all its channels could be re-subscribed.
+				if cnt, _ := arr[2].(int64); cnt == 0 || (kind == "sunsubscribe" && ctx.Err() == nil) {
+					return
+				}
+				if name, _ := redis.ArgToString(arr[1]); ctx.Err() == nil {
+					if _, ok := active[name]; ok {
+						delete(active, name)
+						conn.trackSubscription(map[string]struct{}{name: {}}, -1)
+					}
+				}
+				continue
+			default:
+				continue
+			}
+			if ctx.Err() != nil {
+				// subscription is cancelled: messages are dropped until UNSUBSCRIBE is confirmed.
+				continue
+			}
+			if !conn.deliver(ctx, ch, msg) {
+				return
+			}
+		}
+	}()
+	return ch, nil
+}
+
+// deliver sends msg to ch according to Opts.PubSubPolicy.
+// It returns false if Connection is closed while it waits for consumer.
+func (conn *Connection) deliver(ctx context.Context, ch chan Message, msg Message) bool {
+	switch conn.opts.PubSubPolicy {
+	case PubSubBlock:
+		select {
+		case ch <- msg:
+		case <-ctx.Done():
+		case <-conn.ctx.Done():
+			return false
+		}
+	case PubSubDropNewest:
+		select {
+		case ch <- msg:
+		default:
+			atomic.AddInt64(&conn.pubsubDropped, 1)
+		}
+	default:
+		for {
+			select {
+			case ch <- msg:
+				return true
+			default:
+			}
+			// consumer could receive message meanwhile, so nothing is dropped then.
+			select {
+			case <-ch:
+				atomic.AddInt64(&conn.pubsubDropped, 1)
+			default:
+			}
+		}
+	}
+	return true
+}
+
+// DroppedMessages returns number of messages dropped by subscriptions of this connection because
+// their consumers were slow (see Opts.PubSubPolicy).
+func (conn *Connection) DroppedMessages() int64 {
+	return atomic.LoadInt64(&conn.pubsubDropped)
+}
+
+// SubscribedChannels returns sorted channels and patterns of active subscriptions
+// (made with Subscribe, PSubscribe and SSubscribe) of this connection.
+func (conn *Connection) SubscribedChannels() []string {
+	conn.submtx.Lock()
+	names := make([]string, 0, len(conn.subscribed))
+	for name := range conn.subscribed {
+		names = append(names, name)
+	}
+	conn.submtx.Unlock()
+	sort.Strings(names)
+	return names
+}
+
+// trackSubscription adds delta to subscription counters of names.
+func (conn *Connection) trackSubscription(names map[string]struct{}, delta int) {
+	conn.submtx.Lock()
+	defer conn.submtx.Unlock()
+	if conn.subscribed == nil {
+		conn.subscribed = make(map[string]int)
+	}
+	for name := range names {
+		if conn.subscribed[name] += delta; conn.subscribed[name] <= 0 {
+			delete(conn.subscribed, name)
+		}
+	}
+}
+
+// pingSubscription sends PING through subscription socket. Subscribed connection answers PING
+// with "pong" message (or with PONG in RESP3), which is skipped by reader.
+func (conn *Connection) pingSubscription(connection net.Conn, timeout time.Duration) bool {
+	req, _ := redis.AppendRequest(nil, redis.Req("PING"))
+	connection.SetWriteDeadline(time.Now().Add(timeout))
+	_, err := connection.Write(req)
+	return err == nil
+}
+
+// unsubscribe sends UNSUBSCRIBE (or PUNSUBSCRIBE, SUNSUBSCRIBE) for all channels of subscription,
+// and waits for reader to receive confirmation (reader closes done then).
+func (conn *Connection) unsubscribe(connection net.Conn, cmd string, done chan struct{}) {
+	timeout := conn.opts.IOTimeout
+	if timeout <= 0 {
+		timeout = defaultIOTimeout
+	}
+	// SUBSCRIBE -> UNSUBSCRIBE, PSUBSCRIBE -> PUNSUBSCRIBE, SSUBSCRIBE -> SUNSUBSCRIBE
+	req, _ := redis.AppendRequest(nil, redis.Req(cmd[:len(cmd)-len("SUBSCRIBE")]+"UNSUBSCRIBE"))
+	connection.SetWriteDeadline(time.Now().Add(timeout))
+	if _, err := connection.Write(req); err != nil {
+		return
+	}
+	t := time.NewTimer(timeout)
+	defer t.Stop()
+	select {
+	case <-done:
+	case <-t.C:
+	case <-conn.ctx.Done():
+	}
+}
+
+// pubsubKind returns kind of pubsub reply ("message", "subscribe", etc) and reply itself.
+func pubsubKind(res interface{}) (string, []interface{}) {
+	arr, ok := res.([]interface{})
+	if !ok || len(arr) == 0 {
+		return "", nil
+	}
+	kind, _ := redis.ArgToString(arr[0])
+	return kind, arr
+}
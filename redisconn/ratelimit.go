@@ -0,0 +1,54 @@
+package redisconn
+
+// rateLimiter is a token bucket limiting rate of requests (see Opts.RateLimit).
+// It is not synchronized: it is used under Connection.futmtx.
+type rateLimiter struct {
+	rate   float64 // tokens per nanosecond
+	burst  float64
+	tokens float64
+	last   int64
+}
+
+func newRateLimiter(perSecond float64, burst int) *rateLimiter {
+	if burst <= 0 {
+		burst = int(perSecond)
+		if burst < 1 {
+			burst = 1
+		}
+	}
+	return &rateLimiter{
+		rate:   perSecond / 1e9,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   nownano(),
+	}
+}
+
+// take reports whether n requests could be sent now, and consumes tokens for them.
+// Batch larger than burst is allowed when bucket is full, and it leaves bucket in debt.
+func (l *rateLimiter) take(n int) bool {
+	now := nownano()
+	l.tokens += float64(now-l.last) * l.rate
+	l.last = now
+	if l.tokens > l.burst {
+		l.tokens = l.burst
+	}
+	if l.tokens < float64(n) && l.tokens < l.burst {
+		return false
+	}
+	l.tokens -= float64(n)
+	return true
+}
+
+// retune returns limiter with new rate and burst, which keeps tokens accumulated by l
+// (but no more than new burst). l could be nil.
+func (l *rateLimiter) retune(perSecond float64, burst int) *rateLimiter {
+	n := newRateLimiter(perSecond, burst)
+	if l != nil {
+		l.take(0)
+		if l.tokens < n.tokens {
+			n.tokens = l.tokens
+		}
+	}
+	return n
+}
@@ -1,6 +1,7 @@
 package redisconn
 
 import (
+	"sync/atomic"
 	"time"
 
 	"github.com/joomcode/redispipe/redis"
@@ -18,6 +19,8 @@ type future struct {
 
 	start int64
 	req   Request
+	// requeued is a number of times request were re-queued after connection break (see Opts.RequeueDropped).
+	requeued int
 }
 
 var epoch = time.Now()
@@ -27,8 +30,95 @@ func nownano() int64 {
 }
 
 func (c *Connection) resolve(f future, res interface{}) {
-	if f.start != 0 && f.req.Cmd != "" {
-		c.opts.Logger.ReqStat(c, f.req, res, nownano()-f.start)
+	c.stat(f, res)
+	resolveReq(f.Future, f.req, res, f.N)
+	c.donePending()
+}
+
+// donePending accounts resolved request, and wakes Idle waiters if no requests left.
+func (c *Connection) donePending() {
+	if atomic.AddInt64(&c.pending, -1) == 0 {
+		c.wakeIdle(false)
+	}
+}
+
+// wakeIdle closes channel returned by Idle if there is no pending requests (or if connection is closed).
+func (c *Connection) wakeIdle(closed bool) {
+	c.idlemtx.Lock()
+	defer c.idlemtx.Unlock()
+	if c.idle != nil && (closed || atomic.LoadInt64(&c.pending) == 0) {
+		close(c.idle)
+		c.idle = nil
+	}
+}
+
+// resolveReq resolves cb, passing request to it if it is redis.RequestFuture.
+func resolveReq(cb Future, req Request, res interface{}, n uint64) {
+	if rf, ok := cb.(redis.RequestFuture); ok {
+		rf.ResolveReq(req, res, n)
+		return
+	}
+	cb.Resolve(res, n)
+}
+
+// stat passes request latency to Logger.ReqStat, and reports it if it exceeds Opts.SlowLogThreshold.
+func (c *Connection) stat(f future, res interface{}) {
+	if f.start == 0 || f.req.Cmd == "" {
+		return
+	}
+	nanos := nownano() - f.start
+	c.opts.Logger.ReqStat(c, f.req, res, nanos)
+	if c.opts.SlowLogThreshold > 0 && nanos > int64(c.opts.SlowLogThreshold) {
+		c.report(LogSlowRequest{Cmd: f.req.Cmd, Latency: time.Duration(nanos)})
+	}
+}
+
+// resolveTyped resolves future with response read from socket, passing its RESP type
+// if future is redis.TypedFuture.
+func (c *Connection) resolveTyped(f future, res interface{}, respType byte) {
+	tf, ok := f.Future.(redis.TypedFuture)
+	if !ok {
+		c.resolve(f, res)
+		return
+	}
+	c.stat(f, res)
+	tf.ResolveTyped(res, f.N, respType)
+	c.donePending()
+}
+
+// detectWritePause tracks latencies of read and write commands for PausedWriteThreshold.
+// Responses come in order, so if read queued before slow write were answered fast,
+// then server is responsive and only writes are stalled.
+func (c *Connection) detectWritePause(f future) {
+	now := nownano()
+	latency := now - f.start
+	threshold := int64(c.opts.PausedWriteThreshold)
+	switch {
+	case redis.ReplicaSafe(f.req.Cmd):
+		if latency < threshold {
+			atomic.StoreInt64(&c.lastFastRead, now)
+		}
+	case redis.WriteCommand(f.req.Cmd):
+		if latency < threshold || atomic.LoadInt64(&c.lastFastRead) < f.start {
+			return
+		}
+		last := atomic.LoadInt64(&c.pauseReported)
+		if last != 0 && now-last < int64(time.Second) {
+			return
+		}
+		if atomic.CompareAndSwapInt64(&c.pauseReported, last, now) {
+			c.report(LogPossiblyPaused{Cmd: f.req.Cmd, Latency: time.Duration(latency)})
+		}
+	}
+}
+
+// requestSize returns serialized size of request for MaxPendingBytes accounting.
+// It is not computed if MaxPendingBytes is not set.
+func (conn *Connection) requestSize(req Request) int {
+	if conn.opts.MaxPendingBytes <= 0 {
+		return 0
 	}
-	f.Future.Resolve(res, f.N)
+	// request is already checked with redis.CheckRequest, so error is not expected.
+	size, _ := redis.RequestSize(req)
+	return size
 }
@@ -0,0 +1,53 @@
+package redisconn
+
+import (
+	"github.com/joomcode/redispipe/redis"
+)
+
+// resolveTask is a response passed from reader to resolve workers (see Opts.ResolveWorkers).
+type resolveTask struct {
+	fut      future
+	res      interface{}
+	respType byte
+}
+
+// startResolvers launches n workers which apply DecodeHook and resolve futures off the reader goroutine.
+func (conn *Connection) startResolvers(n int) {
+	conn.resolveq = make(chan resolveTask, n*128)
+	for i := 0; i < n; i++ {
+		go func() {
+			for t := range conn.resolveq {
+				conn.decodeAndResolve(t.fut, t.res, t.respType)
+			}
+		}()
+	}
+}
+
+// stopResolvers stops workers after all readers are finished and all queued responses are resolved.
+// Should be called after connection is closed forever, so no new reader will be started.
+func (conn *Connection) stopResolvers() {
+	go func() {
+		conn.readers.Wait()
+		close(conn.resolveq)
+	}()
+}
+
+// dispatchResolve resolves future with response, either in resolve worker or in place.
+// Internal futures (ASKING, MULTI, pings, etc) are always resolved in place.
+func (conn *Connection) dispatchResolve(fut future, res interface{}, respType byte) {
+	if conn.resolveq != nil && fut.start != 0 {
+		conn.resolveq <- resolveTask{fut, res, respType}
+		return
+	}
+	conn.decodeAndResolve(fut, res, respType)
+}
+
+// decodeAndResolve applies Opts.DecodeHook to response and resolves future.
+func (conn *Connection) decodeAndResolve(fut future, res interface{}, respType byte) {
+	if conn.opts.DecodeHook != nil && fut.start != 0 && redis.AsError(res) == nil {
+		if decoded, ok := conn.opts.DecodeHook(fut.req, res); ok {
+			res = decoded
+		}
+	}
+	conn.resolveTyped(fut, res, respType)
+}
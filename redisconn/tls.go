@@ -0,0 +1,110 @@
+package redisconn
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net"
+	"reflect"
+	"time"
+
+	"github.com/joomcode/errorx"
+)
+
+// tlsVerifyError marks errors returned by custom verification callbacks of tls.Config.
+type tlsVerifyError struct {
+	err error
+}
+
+func (e tlsVerifyError) Error() string { return e.err.Error() }
+func (e tlsVerifyError) Unwrap() error { return e.err }
+
+// prepareTLSConfig clones config, sets ServerName to host of address if it is not set,
+// and wraps verification callbacks, so their errors are recognized as verification failures.
+func prepareTLSConfig(config *tls.Config, address string) *tls.Config {
+	config = config.Clone()
+	if config.ServerName == "" {
+		if host, _, err := net.SplitHostPort(address); err == nil {
+			config.ServerName = host
+		}
+	}
+	if verify := config.VerifyPeerCertificate; verify != nil {
+		config.VerifyPeerCertificate = func(raw [][]byte, chains [][]*x509.Certificate) error {
+			if err := verify(raw, chains); err != nil {
+				return tlsVerifyError{err}
+			}
+			return nil
+		}
+	}
+	if verify := config.VerifyConnection; verify != nil {
+		config.VerifyConnection = func(state tls.ConnectionState) error {
+			if err := verify(state); err != nil {
+				return tlsVerifyError{err}
+			}
+			return nil
+		}
+	}
+	return config
+}
+
+// tlsHandshake performs TLS handshake over dialed socket.
+// Certificate verification failure (local or reported by server) is returned as ErrTLSVerify,
+// other failures as ErrConnSetup.
+func (conn *Connection) tlsHandshake(ctx context.Context, c net.Conn, config *tls.Config, timeout time.Duration) (net.Conn, error) {
+	tc := tls.Client(c, config)
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	if err := tc.HandshakeContext(ctx); err != nil {
+		c.Close()
+		if tlsVerifyFailed(err) {
+			return nil, conn.errWrap(ErrTLSVerify, err)
+		}
+		return nil, conn.errWrap(ErrConnSetup, err)
+	}
+	return tc, nil
+}
+
+// tlsVerifyFailed checks if err is a certificate verification failure: either local verification
+// of server certificate, or alert sent by server which rejected client certificate.
+// Server alert could be received after client side of handshake is complete (TLS 1.3),
+// so it is checked for errors of initial requests as well.
+func tlsVerifyFailed(err error) bool {
+	var (
+		verr     tlsVerifyError
+		certErr  *tls.CertificateVerificationError
+		authErr  x509.UnknownAuthorityError
+		hostErr  x509.HostnameError
+		invalErr x509.CertificateInvalidError
+		alertErr tls.AlertError
+		opErr    *net.OpError
+	)
+	// *errorx.Error doesn't implement Unwrap, so errors.As doesn't look into its cause.
+	for {
+		xerr, ok := err.(*errorx.Error)
+		if !ok {
+			break
+		}
+		err = xerr.Cause()
+	}
+	switch {
+	case errors.As(err, &verr), errors.As(err, &certErr), errors.As(err, &authErr),
+		errors.As(err, &hostErr), errors.As(err, &invalErr):
+		return true
+	case errors.As(err, &alertErr):
+		return certAlert(uint64(alertErr))
+	case errors.As(err, &opErr) && opErr.Op == "remote error":
+		// crypto/tls reports received alert as net.OpError with unexported uint8 alert type.
+		if v := reflect.ValueOf(opErr.Err); v.Kind() == reflect.Uint8 {
+			return certAlert(v.Uint())
+		}
+	}
+	return false
+}
+
+// certAlert checks if TLS alert is about certificate: bad_certificate, unsupported_certificate,
+// certificate_revoked, certificate_expired, certificate_unknown, unknown_ca, access_denied
+// or certificate_required.
+func certAlert(code uint64) bool {
+	return code >= 42 && code <= 49 && code != 47 || code == 116
+}
@@ -0,0 +1,146 @@
+package redisconn_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"math/big"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/joomcode/errorx"
+	"github.com/stretchr/testify/require"
+
+	"github.com/joomcode/redispipe/redis"
+	. "github.com/joomcode/redispipe/redisconn"
+)
+
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pool *x509.CertPool
+}
+
+func newTestCA(t *testing.T) *testCA {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+	return &testCA{cert: cert, key: key, pool: pool}
+}
+
+func (ca *testCA) issue(t *testing.T, name string, usage x509.ExtKeyUsage) tls.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, &key.PublicKey, ca.key)
+	require.NoError(t, err)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+func TestTLSClientCertificate(t *testing.T) {
+	ca := newTestCA(t)
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{
+		Certificates: []tls.Certificate{ca.issue(t, "server", x509.ExtKeyUsageServerAuth)},
+		ClientCAs:    ca.pool,
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+	})
+	require.NoError(t, err)
+	defer ln.Close()
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go fakeServer(c)
+		}
+	}()
+	addr := ln.Addr().String()
+
+	connect := func(config *tls.Config) (*Connection, error) {
+		return Connect(context.Background(), addr, Opts{
+			TLSConfig:      config,
+			ReconnectPause: -1,
+			Logger:         NoopLogger{},
+		})
+	}
+	requireVerifyErr := func(err error) {
+		require.Error(t, err)
+		xerr, ok := err.(*errorx.Error)
+		require.True(t, ok, err.Error())
+		require.True(t, xerr.IsOfType(ErrTLSVerify), err.Error())
+		require.True(t, xerr.HasTrait(ErrTraitInitPermanent))
+	}
+
+	clientCert := ca.issue(t, "client", x509.ExtKeyUsageClientAuth)
+	conn, err := connect(&tls.Config{RootCAs: ca.pool, Certificates: []tls.Certificate{clientCert}})
+	require.NoError(t, err)
+	require.Equal(t, []byte("bar"), redis.Sync{conn}.Do("GET", "foo"))
+	conn.Close()
+
+	// server rejects client without certificate
+	_, err = connect(&tls.Config{RootCAs: ca.pool})
+	requireVerifyErr(err)
+
+	// server certificate is issued by unknown authority
+	_, err = connect(&tls.Config{Certificates: []tls.Certificate{clientCert}})
+	requireVerifyErr(err)
+
+	// custom verification (eg of SPIFFE ID) rejects server
+	errIdentity := errors.New("unexpected server identity")
+	_, err = connect(&tls.Config{
+		RootCAs:      ca.pool,
+		Certificates: []tls.Certificate{clientCert},
+		VerifyPeerCertificate: func(raw [][]byte, chains [][]*x509.Certificate) error {
+			return errIdentity
+		},
+	})
+	requireVerifyErr(err)
+	require.True(t, redis.Is(err, errIdentity))
+
+	// plain network failure is not a verification error
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go func() {
+		c, err := l.Accept()
+		if err == nil {
+			c.Close()
+		}
+	}()
+	_, err = Connect(context.Background(), l.Addr().String(), Opts{
+		TLSConfig:      &tls.Config{RootCAs: ca.pool},
+		ReconnectPause: -1,
+		Logger:         NoopLogger{},
+	})
+	l.Close()
+	require.Error(t, err)
+	require.False(t, err.(*errorx.Error).IsOfType(ErrTLSVerify), err.Error())
+}
@@ -0,0 +1,109 @@
+package redisconn
+
+import (
+	"github.com/joomcode/redispipe/redis"
+)
+
+// TrackingOpts - options for server assisted client side caching (CLIENT TRACKING).
+type TrackingOpts struct {
+	// Redirect - id of client (CLIENT ID) which receives invalidation messages
+	// on __redis__:invalidate channel. It is required with RESP2 protocol.
+	Redirect int64
+	// Prefixes - key prefixes to track in broadcasting mode.
+	Prefixes []string
+	// BCast enables broadcasting mode: invalidations are sent for all keys matching Prefixes.
+	BCast bool
+	// OptIn tracks only keys read right after CLIENT CACHING YES (see Connection.Caching).
+	OptIn bool
+	// OptOut tracks all keys except read right after CLIENT CACHING NO.
+	OptOut bool
+	// NoLoop suppresses invalidations of keys modified by this connection itself.
+	NoLoop bool
+}
+
+// InvalidateChannel is a channel invalidation messages are published to, when tracking is redirected
+// (TrackingOpts.Redirect) to client subscribed to it. Keys of such messages are in Message.Keys.
+const InvalidateChannel = "__redis__:invalidate"
+
+// Args returns arguments of CLIENT TRACKING ON command.
+func (t TrackingOpts) Args() []interface{} {
+	args := []interface{}{"TRACKING", "ON"}
+	if t.Redirect != 0 {
+		args = append(args, "REDIRECT", t.Redirect)
+	}
+	for _, prefix := range t.Prefixes {
+		args = append(args, "PREFIX", prefix)
+	}
+	if t.BCast {
+		args = append(args, "BCAST")
+	}
+	if t.OptIn {
+		args = append(args, "OPTIN")
+	}
+	if t.OptOut {
+		args = append(args, "OPTOUT")
+	}
+	if t.NoLoop {
+		args = append(args, "NOLOOP")
+	}
+	return args
+}
+
+// invalidatedKeys parses payload of invalidation message: it is array of keys, or nil if all keys
+// are invalidated (FLUSHALL, FLUSHDB).
+func invalidatedKeys(payload interface{}) (keys []string, all bool) {
+	arr, ok := payload.([]interface{})
+	if !ok {
+		return nil, payload == nil
+	}
+	keys = make([]string, 0, len(arr))
+	for _, k := range arr {
+		if key, ok := redis.ArgToString(k); ok {
+			keys = append(keys, key)
+		}
+	}
+	return keys, false
+}
+
+// Tracking queues CLIENT TRACKING ON request.
+// Use it in Opts.Handshake, so tracking is re-enabled after reconnect.
+func (h *Handshake) Tracking(opts TrackingOpts) {
+	h.Do(Request{"CLIENT", opts.Args()}, nil)
+}
+
+// Caching returns sender which sends every request right after CLIENT CACHING YES,
+// so keys read by request are tracked when connection tracks in OPTIN mode.
+//
+// CLIENT CACHING YES affects only next command on the same socket, so pair is queued atomically
+// (like SendBatch does) and could not be split by concurrent requests. That is why it is available
+// for single Connection only: cluster client may route requests to different shards. Use it over
+// connection to particular shard (for example, obtained with rediscluster.Cluster.ConnForAddr).
+//
+// Transaction sent through Caching is tracked as whole. Response to CLIENT CACHING YES is ignored,
+// so if tracking is not enabled in OPTIN mode, requests are executed without tracking.
+func (conn *Connection) Caching() redis.Sender {
+	return cachingSender{conn}
+}
+
+type cachingSender struct {
+	*Connection
+}
+
+func (cs cachingSender) Send(req Request, cb Future, n uint64) {
+	cs.SendBatchFlags([]Request{req}, cb, n, DoCaching)
+}
+
+// SendMany sends requests one by one, since CLIENT CACHING YES affects only one following request.
+func (cs cachingSender) SendMany(reqs []Request, cb Future, start uint64) {
+	for i, req := range reqs {
+		cs.Send(req, cb, start+uint64(i))
+	}
+}
+
+func (cs cachingSender) SendTransaction(reqs []Request, cb Future, off uint64) {
+	cs.SendBatchFlags(reqs, transactionFuture{cb, len(reqs), off}, 0, DoTransaction|DoCaching)
+}
+
+func (cs cachingSender) EachShard(cb func(redis.Sender, error) bool) {
+	cb(cs, nil)
+}
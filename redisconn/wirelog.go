@@ -0,0 +1,86 @@
+package redisconn
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/joomcode/redispipe/redis"
+)
+
+// wireLog writes copies of sent and received bytes to Opts.WireLogger.
+// Writer and reader goroutines (and dedicated sockets) share it, so writes are serialized.
+type wireLog struct {
+	m    sync.Mutex
+	w    io.Writer
+	addr string
+}
+
+func (l *wireLog) log(dir string, p []byte) {
+	l.m.Lock()
+	fmt.Fprintf(l.w, "%s %s %q\n", l.addr, dir, p)
+	l.m.Unlock()
+}
+
+// wireTap copies all data passed through socket to wireLog.
+type wireTap struct {
+	io.ReadWriter
+	log *wireLog
+}
+
+func (t wireTap) Read(p []byte) (int, error) {
+	n, err := t.ReadWriter.Read(p)
+	if n > 0 {
+		t.log.log("<", p[:n])
+	}
+	return n, err
+}
+
+func (t wireTap) Write(p []byte) (int, error) {
+	t.log.log(">", redactAuth(p))
+	return t.ReadWriter.Write(p)
+}
+
+// redactAuth replaces credentials of AUTH and HELLO ... AUTH requests in written packet
+// with placeholder, so they are not exposed in wire log.
+func redactAuth(p []byte) []byte {
+	if !bytes.Contains(bytes.ToUpper(p), []byte("AUTH")) {
+		return p
+	}
+	r := bufio.NewReader(bytes.NewReader(p))
+	var out []byte
+	for {
+		if _, err := r.Peek(1); err != nil {
+			return out
+		}
+		req, ok := redis.ReadResponse(r).([]interface{})
+		if !ok || len(req) == 0 {
+			// packet consists of complete requests, so it is not expected.
+			return p
+		}
+		cmd, _ := req[0].([]byte)
+		args := req[1:]
+		switch strings.ToUpper(string(cmd)) {
+		case "AUTH":
+			for i := range args {
+				args[i] = redactedPassword
+			}
+		case "HELLO":
+			for i := 0; i < len(args); i++ {
+				if b, ok := args[i].([]byte); ok && strings.EqualFold(string(b), "AUTH") {
+					for j := i + 1; j < len(args) && j <= i+2; j++ {
+						args[j] = redactedPassword
+					}
+					i += 2
+				}
+			}
+		}
+		var err error
+		if out, err = redis.AppendRequest(out, Request{string(cmd), args}); err != nil {
+			return p
+		}
+	}
+}
@@ -0,0 +1,154 @@
+// Package redistest contains helpers for testing code built on top of redis.Sender.
+package redistest
+
+import (
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/joomcode/errorx"
+
+	"github.com/joomcode/redispipe/redis"
+	"github.com/joomcode/redispipe/redisconn"
+)
+
+// FaultKind is a kind of injected fault.
+type FaultKind int
+
+const (
+	// FaultDelay delays response delivery by Fault.Duration.
+	FaultDelay FaultKind = iota
+	// FaultError resolves request with synthetic io error without sending it.
+	FaultError
+	// FaultDisconnect simulates connection loss: all requests during Fault.Duration
+	// are resolved with redisconn.ErrNotConnected.
+	FaultDisconnect
+)
+
+// Fault describes single fault rule.
+type Fault struct {
+	// Kind of fault
+	Kind FaultKind
+	// Cmd - fault is applied only to this command (case insensitive). Empty means any command.
+	// Transactions are matched by "EXEC".
+	Cmd string
+	// Probability of fault for matched request. Zero means 1 (ie fault always happens).
+	Probability float64
+	// Duration is a delay for FaultDelay and disconnection time for FaultDisconnect.
+	Duration time.Duration
+}
+
+// ErrInjected is an error used as a cause for injected faults.
+var ErrInjected = redis.Errors.NewType("injected_fault")
+
+// FaultSender wraps real redis.Sender and injects delays and errors into requests.
+// It is intended for testing application's timeout and error handling.
+// Scanner, EachShard and Close are passed to wrapped sender as is.
+type FaultSender struct {
+	redis.Sender
+
+	faults []Fault
+
+	m            sync.Mutex
+	rnd          *rand.Rand
+	disconnected time.Time
+}
+
+// NewFaultSender returns FaultSender over s.
+// seed is used for probability decisions, so runs with same seed and same request order are reproducible.
+func NewFaultSender(s redis.Sender, seed int64, faults ...Fault) *FaultSender {
+	return &FaultSender{
+		Sender: s,
+		faults: faults,
+		rnd:    rand.New(rand.NewSource(seed)),
+	}
+}
+
+// pick returns error (if request should be failed) and delay for the request.
+func (fs *FaultSender) pick(cmd string) (*errorx.Error, time.Duration) {
+	fs.m.Lock()
+	defer fs.m.Unlock()
+	now := time.Now()
+	if now.Before(fs.disconnected) {
+		return redisconn.ErrNotConnected.WrapWithNoMessage(ErrInjected.New("disconnected")), 0
+	}
+	var delay time.Duration
+	for _, f := range fs.faults {
+		if f.Cmd != "" && !strings.EqualFold(f.Cmd, cmd) {
+			continue
+		}
+		if f.Probability > 0 && fs.rnd.Float64() >= f.Probability {
+			continue
+		}
+		switch f.Kind {
+		case FaultDelay:
+			delay += f.Duration
+		case FaultError:
+			return redis.ErrIO.WrapWithNoMessage(ErrInjected.New("io error")), 0
+		case FaultDisconnect:
+			fs.disconnected = now.Add(f.Duration)
+			return redisconn.ErrNotConnected.WrapWithNoMessage(ErrInjected.New("disconnected")), 0
+		}
+	}
+	return nil, delay
+}
+
+// Send implements redis.Sender.Send
+func (fs *FaultSender) Send(req redis.Request, cb redis.Future, n uint64) {
+	if err := cb.Cancelled(); err != nil {
+		cb.Resolve(redis.ErrRequestCancelled.WrapWithNoMessage(err).WithProperty(redis.EKRequest, req), n)
+		return
+	}
+	err, delay := fs.pick(req.Cmd)
+	if err != nil {
+		cb.Resolve(err.WithProperty(redis.EKRequest, req), n)
+		return
+	}
+	if delay > 0 {
+		cb = delayed{cb, delay}
+	}
+	fs.Sender.Send(req, cb, n)
+}
+
+// SendMany implements redis.Sender.SendMany
+// Faults are applied to each request independently.
+func (fs *FaultSender) SendMany(reqs []redis.Request, cb redis.Future, n uint64) {
+	for i, req := range reqs {
+		fs.Send(req, cb, n+uint64(i))
+	}
+}
+
+// SendTransaction implements redis.Sender.SendTransaction
+// Faults are matched against "EXEC" command.
+func (fs *FaultSender) SendTransaction(reqs []redis.Request, cb redis.Future, n uint64) {
+	if err := cb.Cancelled(); err != nil {
+		cb.Resolve(redis.ErrRequestCancelled.WrapWithNoMessage(err).WithProperty(redis.EKRequests, reqs), n)
+		return
+	}
+	err, delay := fs.pick("EXEC")
+	if err != nil {
+		cb.Resolve(err.WithProperty(redis.EKRequests, reqs), n)
+		return
+	}
+	if delay > 0 {
+		cb = delayed{cb, delay}
+	}
+	fs.Sender.SendTransaction(reqs, cb, n)
+}
+
+// delayed postpones resolving of wrapped future.
+// If future is cancelled during delay, it is resolved with ErrRequestCancelled.
+type delayed struct {
+	redis.Future
+	delay time.Duration
+}
+
+func (d delayed) Resolve(res interface{}, n uint64) {
+	time.AfterFunc(d.delay, func() {
+		if err := d.Future.Cancelled(); err != nil {
+			res = redis.ErrRequestCancelled.WrapWithNoMessage(err)
+		}
+		d.Future.Resolve(res, n)
+	})
+}
@@ -0,0 +1,56 @@
+package redistest_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/joomcode/redispipe/redis"
+	"github.com/joomcode/redispipe/redisconn"
+	. "github.com/joomcode/redispipe/redistest"
+	"github.com/stretchr/testify/assert"
+)
+
+// okSender resolves every request with "OK".
+type okSender struct{ redis.Sender }
+
+func (okSender) Send(r redis.Request, cb redis.Future, n uint64) { cb.Resolve("OK", n) }
+
+func TestFaultSender(t *testing.T) {
+	fs := NewFaultSender(okSender{}, 1,
+		Fault{Kind: FaultError, Cmd: "get"},
+		Fault{Kind: FaultDelay, Cmd: "SET", Duration: 20 * time.Millisecond},
+		Fault{Kind: FaultDisconnect, Cmd: "QUIT", Duration: 20 * time.Millisecond},
+	)
+	sync := redis.Sync{fs}
+
+	res := sync.Do("GET", "a")
+	assert.True(t, redis.AsErrorx(res).IsOfType(redis.ErrIO))
+
+	start := time.Now()
+	assert.Equal(t, "OK", sync.Do("SET", "a", 1))
+	assert.True(t, time.Since(start) >= 20*time.Millisecond)
+
+	assert.Equal(t, "OK", sync.Do("PING"))
+
+	res = sync.Do("QUIT")
+	assert.True(t, redis.AsErrorx(res).IsOfType(redisconn.ErrNotConnected))
+	res = sync.Do("PING")
+	assert.True(t, redis.AsErrorx(res).IsOfType(redisconn.ErrNotConnected))
+	time.Sleep(30 * time.Millisecond)
+	assert.Equal(t, "OK", sync.Do("PING"))
+}
+
+func TestFaultSenderProbabilityIsReproducible(t *testing.T) {
+	run := func() []bool {
+		fs := NewFaultSender(okSender{}, 42, Fault{Kind: FaultError, Probability: 0.5})
+		var fails []bool
+		for i := 0; i < 32; i++ {
+			fails = append(fails, redis.AsError(redis.Sync{fs}.Do("PING")) != nil)
+		}
+		return fails
+	}
+	fails := run()
+	assert.Equal(t, fails, run())
+	assert.Contains(t, fails, true)
+	assert.Contains(t, fails, false)
+}
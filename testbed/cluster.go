@@ -17,24 +17,19 @@ type Node struct {
 // Cluster is a tool for starting/stopping redis cluster for tests.
 type Cluster struct {
 	Node []Node
+	// Replicas is a number of nodes which are replicas, rest of nodes are masters.
+	Replicas int
 }
 
 // NewCluster instantiate cluster of 6 nodes (3 masters and 3 slaves).
 // Master are on ports startport, startport+1, startport+2,
 // and slaves are on ports startport+3, startport+4, startport+5
 func NewCluster(startport uint16) *Cluster {
-	cl := &Cluster{}
+	cl := &Cluster{Replicas: 3}
 	cl.Node = make([]Node, 6)
 	for i := range cl.Node {
 		cl.Node[i].Port = startport + uint16(i)
-		cl.Node[i].Args = []string{
-			"--cluster-enabled", "yes",
-			"--cluster-config-file", "node-" + cl.Node[i].PortStr() + ".conf",
-			"--cluster-node-timeout", "200",
-			"--cluster-slave-validity-factor", "1000",
-			"--slave-serve-stale-data", "yes",
-			"--cluster-require-full-coverage", "no",
-		}
+		cl.Node[i].Args = clusterArgs(cl.Node[i].PortStr())
 		cl.Node[i].Start()
 		cl.Node[i].SetupNodeId()
 		cl.Node[i].DoSure("CLUSTER SET-CONFIG-EPOCH", i+1)
@@ -56,6 +51,27 @@ func NewCluster(startport uint16) *Cluster {
 	return cl
 }
 
+// clusterArgs returns redis-server arguments for cluster node on port.
+func clusterArgs(port string) []string {
+	return []string{
+		"--cluster-enabled", "yes",
+		"--cluster-config-file", "node-" + port + ".conf",
+		"--cluster-node-timeout", "200",
+		"--cluster-slave-validity-factor", "1000",
+		"--slave-serve-stale-data", "yes",
+		"--cluster-require-full-coverage", "no",
+	}
+}
+
+// Addrs returns addresses of cluster's servers.
+func (cl *Cluster) Addrs() []string {
+	addrs := make([]string, len(cl.Node))
+	for i := range cl.Node {
+		addrs[i] = cl.Node[i].Addr()
+	}
+	return addrs
+}
+
 // Stop stops all cluster's servers
 func (cl *Cluster) Stop() {
 	for i := range cl.Node {
@@ -137,7 +153,7 @@ func (cl *Cluster) ClusterOk() bool {
 				masters++
 			}
 		}
-		if masters != 3+(len(cl.Node)-6) {
+		if masters != len(cl.Node)-cl.Replicas {
 			return false
 		}
 		infos, _ := redisclusterutil.ParseClusterNodes(res)
@@ -153,6 +169,9 @@ func (cl *Cluster) ClusterOk() bool {
 // AttemptFailover tries to issue CLUSTER FAILOVER FORCE to slaves of falled masters.
 // This is work around replication bug present in Redis till 4.0.9 (including)
 func (cl *Cluster) AttemptFailover() {
+	if cl.Replicas == 0 {
+		return
+	}
 	for i := range cl.Node[:6] {
 		if !cl.Node[i].RunningNow() {
 			slave := (i + 3) % 6
@@ -205,14 +224,7 @@ func (cl *Cluster) MoveSlot(slot, from, to int) {
 func (cl *Cluster) StartSeventhNode() {
 	cl.Node = append(cl.Node, Node{})
 	cl.Node[6].Port = cl.Node[0].Port + 6
-	cl.Node[6].Args = []string{
-		"--cluster-enabled", "yes",
-		"--cluster-config-file", "node-" + cl.Node[6].PortStr() + ".conf",
-		"--cluster-node-timeout", "200",
-		"--cluster-slave-validity-factor", "1000",
-		"--slave-serve-stale-data", "yes",
-		"--cluster-require-full-coverage", "no",
-	}
+	cl.Node[6].Args = clusterArgs(cl.Node[6].PortStr())
 	cl.Node[6].Start()
 	cl.Node[6].SetupNodeId()
 	cl.Node[6].DoSure("CLUSTER SET-CONFIG-EPOCH", 0)
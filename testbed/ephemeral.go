@@ -0,0 +1,95 @@
+package testbed
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"strconv"
+
+	"github.com/joomcode/redispipe/rediscluster/redisclusterutil"
+)
+
+// CLI is a path to redis-cli. It is used by StartCluster to create cluster.
+var CLI = func() string { p, _ := exec.LookPath("redis-cli"); return p }()
+
+// portAttempts is how many times server is started on other port if chosen port were taken.
+const portAttempts = 10
+
+// FreePort returns port which is free at the moment.
+// Other process could take it before server binds it, so StartStandalone and StartCluster
+// retry on other port if server failed to start.
+func FreePort() uint16 {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		panic(err)
+	}
+	defer l.Close()
+	return uint16(l.Addr().(*net.TCPAddr).Port)
+}
+
+// StartStandalone starts redis-server with additional args on random free port.
+// If Dir is not initialized, it is initialized in os.TempDir().
+// Server should be stopped with Shutdown (and RmDir should be called when all servers are stopped).
+func StartStandalone(args ...string) *Server {
+	s := &Server{}
+	s.startOnFreePort(func(string) []string { return args })
+	return s
+}
+
+// StartCluster starts cluster of n masters without replicas on random free ports.
+// Slots are distributed evenly with `redis-cli --cluster create` if CLI is found (and n >= 3,
+// as redis-cli requires), otherwise with CLUSTER ADDSLOTS.
+// Cluster should be stopped with Shutdown.
+func StartCluster(n int) *Cluster {
+	cl := &Cluster{Node: make([]Node, n)}
+	for i := range cl.Node {
+		cl.Node[i].startOnFreePort(clusterArgs)
+		cl.Node[i].SetupNodeId()
+	}
+	if CLI != "" && n >= 3 {
+		args := append([]string{"--cluster", "create"}, cl.Addrs()...)
+		args = append(args, "--cluster-replicas", "0", "--cluster-yes")
+		if out, err := exec.Command(CLI, args...).CombinedOutput(); err != nil {
+			cl.Shutdown()
+			panic(fmt.Errorf("redis-cli --cluster create failed: %v\n%s", err, out))
+		}
+	} else {
+		for i := range cl.Node {
+			cl.Node[i].DoSure("CLUSTER SET-CONFIG-EPOCH", i+1)
+			for j := i + 1; j < n; j++ {
+				cl.Node[i].DoSure("CLUSTER MEET", "127.0.0.1", cl.Node[j].Port)
+			}
+			cl.Node[i].AddSlots(i*redisclusterutil.NumSlots/n, (i+1)*redisclusterutil.NumSlots/n-1)
+		}
+	}
+	cl.WaitClusterOk()
+	return cl
+}
+
+// Shutdown stops all cluster's servers with Server.Shutdown.
+func (cl *Cluster) Shutdown() {
+	for i := range cl.Node {
+		func() {
+			defer recover()
+			cl.Node[i].Shutdown()
+		}()
+	}
+}
+
+// startOnFreePort starts server on random free port, and retries on other port if server failed
+// to start (port could be taken by other process between FreePort and server start).
+func (s *Server) startOnFreePort(args func(port string) []string) {
+	if Dir == "" {
+		InitDir(os.TempDir())
+	}
+	var err error
+	for i := 0; i < portAttempts; i++ {
+		s.Port = FreePort()
+		s.Args = args(strconv.Itoa(int(s.Port)))
+		if err = s.start(); err == nil {
+			return
+		}
+	}
+	panic(err)
+}
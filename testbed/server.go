@@ -3,6 +3,7 @@ package testbed
 import (
 	"bufio"
 	"bytes"
+	"fmt"
 	"io"
 	"os"
 	"os/exec"
@@ -34,8 +35,16 @@ func (s *Server) Addr() string {
 
 // Start starts redis and waits for its initialization.
 func (s *Server) Start() {
+	if err := s.start(); err != nil {
+		panic(err)
+	}
+}
+
+// start starts redis and waits for its initialization.
+// If server exits before it is ready (e.g. port is taken), error is returned.
+func (s *Server) start() error {
 	if s.Cmd != nil {
-		return
+		return nil
 	}
 	s.Paused = false
 	port := s.PortStr()
@@ -63,7 +72,10 @@ func (s *Server) Start() {
 	for {
 		l, isPrefix, err := stdout.ReadLine()
 		if err != nil {
-			panic(err)
+			logfile.Close()
+			s.Cmd.Wait()
+			s.Cmd = nil
+			return fmt.Errorf("redis-server on port %s exited before it is ready: %v", port, err)
 		}
 		if isPrefix {
 			panic("logline too long")
@@ -82,6 +94,7 @@ func (s *Server) Start() {
 		}
 	}()
 	s.Conn.Addr = s.Addr()
+	return nil
 }
 
 // Running returns true if server should be running at the moment.
@@ -132,6 +145,16 @@ func (s *Server) Stop() {
 	p.Wait()
 }
 
+// Shutdown disconnects clients with CLIENT KILL, so they see closed connection instead of waiting
+// for timeout, and then stops server.
+func (s *Server) Shutdown() {
+	if s.RunningNow() {
+		s.Do("CLIENT KILL", "TYPE", "normal")
+		s.Do("CLIENT KILL", "TYPE", "pubsub")
+	}
+	s.Stop()
+}
+
 // Do executes command on server.
 func (s *Server) Do(cmd string, args ...interface{}) interface{} {
 	return s.Conn.Do(cmd, args...)